@@ -9,6 +9,7 @@ import (
 	garagestorages3 "ai-notetaking-be/pkg/garage-storage-s3"
 	"context"
 	"log"
+	"net/http"
 	"os"
 
 	"github.com/ThreeDotsLabs/watermill"
@@ -24,7 +25,10 @@ func main() {
 		BodyLimit: 10 * 1024 * 1024,
 	})
 
-	app.Use(cors.New())
+	app.Use(cors.New(cors.Config{
+		ExposeHeaders: fiber.HeaderETag,
+	}))
+	app.Use(serverutils.RequestIdMiddleware())
 	app.Use(serverutils.ErrorHandlerMiddleware())
 
 	s3Config := garagestorages3.Config{
@@ -49,6 +53,31 @@ func main() {
 	chatSessionRepository := repository.NewChatSessionRepository(db)
 	chatMessageRepository := repository.NewChatMessageRepository(db)
 	chatMessageRawRepository := repository.NewChatMessageRawRepository(db)
+	chatToolCallRepository := repository.NewChatToolCallRepository(db)
+	chatRetrievalPlanRepository := repository.NewChatRetrievalPlanRepository(db)
+	promptTemplateRepository := repository.NewPromptTemplateRepository(db)
+	promptTemplateVersionRepository := repository.NewPromptTemplateVersionRepository(db)
+	llmUsageRepository := repository.NewLlmUsageRepository(db)
+	userRepository := repository.NewUserRepository(db)
+	refreshTokenRepository := repository.NewRefreshTokenRepository(db)
+	notebookMemberRepository := repository.NewNotebookMemberRepository(db)
+	notebookInvitationRepository := repository.NewNotebookInvitationRepository(db)
+	apiTokenRepository := repository.NewApiTokenRepository(db)
+	auditEventRepository := repository.NewAuditEventRepository(db)
+	noteRevisionRepository := repository.NewNoteRevisionRepository(db)
+	tagRepository := repository.NewTagRepository(db)
+	noteTagRepository := repository.NewNoteTagRepository(db)
+	chatSessionTagRepository := repository.NewChatSessionTagRepository(db)
+	noteSuggestionRepository := repository.NewNoteSuggestionRepository(db)
+	uploadIntentRepository := repository.NewUploadIntentRepository(db)
+	uploadSessionRepository := repository.NewUploadSessionRepository(db)
+	fileBlobRepository := repository.NewFileBlobRepository(db)
+
+	modelPricing, err := service.ParseModelPricing(os.Getenv("LLM_MODEL_PRICING"))
+	if err != nil {
+		panic(err)
+	}
+	usageService := service.NewUsageService(llmUsageRepository, modelPricing)
 
 	watermillLogger := watermill.NewStdLogger(false, false)
 	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermillLogger)
@@ -57,6 +86,34 @@ func main() {
 		pubSub,
 	)
 
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		panic("JWT_SECRET is not set")
+	}
+	authService := service.NewAuthService(userRepository, refreshTokenRepository, jwtSecret, db)
+	apiTokenService := service.NewApiTokenService(apiTokenRepository, auditEventRepository, db)
+	notebookAccessService := service.NewNotebookAccessService(notebookMemberRepository)
+	notebookMemberService := service.NewNotebookMemberService(notebookRepository, notebookMemberRepository, notebookInvitationRepository, userRepository, notebookAccessService, auditEventRepository, db)
+	exampleService := service.NewExampleService(exampleRepository, s3Client)
+	notebookService := service.NewNotebookService(notebookRepository, noteRepository, noteEmbeddingRepository, publisherService, fileRepository, s3Client, notebookAccessService, auditEventRepository, noteTagRepository, db)
+	noteService := service.NewNoteService(noteRepository, notebookRepository, fileRepository, s3Client, publisherService, noteEmbeddingRepository, usageService, notebookAccessService, auditEventRepository, noteRevisionRepository, noteTagRepository, db)
+	promptTemplateService := service.NewPromptTemplateService(promptTemplateRepository, promptTemplateVersionRepository, db)
+	chatbotService := service.NewChatbotService(db, chatSessionRepository, chatMessageRepository, chatMessageRawRepository, noteEmbeddingRepository, chatToolCallRepository, chatRetrievalPlanRepository, promptTemplateService, usageService, noteService, notebookRepository, notebookAccessService, auditEventRepository, tagRepository, chatSessionTagRepository)
+	uploadPolicy, err := service.ParseUploadPolicy(os.Getenv("UPLOAD_POLICY"))
+	if err != nil {
+		panic(err)
+	}
+	fileService := service.NewFileService(noteRepository, fileRepository, noteEmbeddingRepository, uploadIntentRepository, uploadSessionRepository, fileBlobRepository, s3Client, notebookAccessService, auditEventRepository, publisherService, uploadPolicy, db)
+	auditService := service.NewAuditService(auditEventRepository, noteRepository, notebookAccessService)
+	tagService := service.NewTagService(tagRepository, noteTagRepository, chatSessionTagRepository, noteSuggestionRepository, noteRepository, notebookAccessService, auditEventRepository, publisherService, db)
+	collabService := service.NewCollabService(noteRepository, noteRevisionRepository, auditEventRepository, notebookAccessService, publisherService, db)
+
+	autoTagging, err := service.ParseAutoTagging(os.Getenv("AUTO_TAGGING_ENABLED"))
+	if err != nil {
+		panic(err)
+	}
+	noteSuggestionService := service.NewNoteSuggestionService(noteSuggestionRepository, noteRepository, notebookRepository, noteEmbeddingRepository, tagRepository, noteTagRepository, notebookAccessService, tagService, noteService, usageService, autoTagging, db)
+
 	consumerService := service.NewConsumerService(
 		pubSub,
 		os.Getenv("EMBED_NOTE_CONTENT_TOPIC_NAME"),
@@ -64,33 +121,73 @@ func main() {
 		noteEmbeddingRepository,
 		notebookRepository,
 		fileRepository,
+		noteTagRepository,
+		noteSuggestionService,
 		s3Client,
+		usageService,
 		db,
 	)
 
-	exampleService := service.NewExampleService(exampleRepository, s3Client)
-	notebookService := service.NewNotebookService(notebookRepository, noteRepository, noteEmbeddingRepository, publisherService, fileRepository, s3Client, db)
-	noteService := service.NewNoteService(noteRepository, notebookRepository, fileRepository, s3Client, publisherService, noteEmbeddingRepository, db)
-	chatbotService := service.NewChatbotService(db, chatSessionRepository, chatMessageRepository, chatMessageRawRepository, noteEmbeddingRepository)
-	fileService := service.NewFileService(noteRepository, fileRepository, s3Client)
+	trashRetentionDays, err := service.ParseTrashRetention(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil {
+		panic(err)
+	}
+	trashService := service.NewTrashService(notebookRepository, noteRepository, noteEmbeddingRepository, fileRepository, notebookMemberRepository, notebookInvitationRepository, chatSessionRepository, chatMessageRepository, chatMessageRawRepository, chatToolCallRepository, chatRetrievalPlanRepository, noteRevisionRepository, noteTagRepository, chatSessionTagRepository, noteSuggestionRepository, uploadIntentRepository, uploadSessionRepository, fileBlobRepository, auditEventRepository, notebookAccessService, publisherService, s3Client, trashRetentionDays, db)
 
+	authController := controller.NewAuthController(authService)
 	exampleController := controller.NewExampleController(exampleService)
 	notebookController := controller.NewNotebookController(notebookService)
+	notebookMemberController := controller.NewNotebookMemberController(notebookMemberService)
 	noteController := controller.NewNoteController(noteService)
 	chatbotController := controller.NewChatController(chatbotService)
 	fileController := controller.NewFileController(fileService)
+	promptTemplateController := controller.NewPromptTemplateController(promptTemplateService)
+	usageController := controller.NewUsageController(usageService)
+	apiTokenController := controller.NewApiTokenController(apiTokenService)
+	auditController := controller.NewAuditController(auditService)
+	trashController := controller.NewTrashController(trashService)
+	tagController := controller.NewTagController(tagService)
+	noteSuggestionController := controller.NewNoteSuggestionController(noteSuggestionService)
+	collabController := controller.NewCollabController(collabService, authService.VerifyAccessToken, apiTokenService.VerifyApiToken)
 
 	api := app.Group("/api")
+	authController.RegisterRoutes(api)
+
+	// Fiber runs middlewares in registration order, every route registered
+	// below requires an access token.
+	api.Use(serverutils.AuthMiddleware(authService.VerifyAccessToken, apiTokenService.VerifyApiToken))
+	authController.RegisterProtectedRoutes(api)
 	exampleController.RegisterRoutes(api)
 	notebookController.RegisterRoutes(api)
+	notebookMemberController.RegisterRoutes(api)
 	noteController.RegisterRoutes(api)
 	chatbotController.RegisterRoutes(api)
 	fileController.RegisterRoutes(api)
+	promptTemplateController.RegisterRoutes(api)
+	usageController.RegisterRoutes(api)
+	apiTokenController.RegisterRoutes(api)
+	auditController.RegisterRoutes(api)
+	trashController.RegisterRoutes(api)
+	tagController.RegisterRoutes(api)
+	noteSuggestionController.RegisterRoutes(api)
 
 	err = consumerService.Consume(context.Background())
 	if err != nil {
 		panic(err)
 	}
 
+	trashService.StartPurgeJob(context.Background())
+	fileService.StartUploadCleanupJob(context.Background())
+
+	collabAddr := os.Getenv("COLLAB_ADDR")
+	if collabAddr == "" {
+		collabAddr = ":3001"
+	}
+	collabMux := http.NewServeMux()
+	collabController.RegisterRoutes(collabMux)
+	go func() {
+		log.Fatal(http.ListenAndServe(collabAddr, collabMux))
+	}()
+
 	log.Fatal(app.Listen(":3000"))
 }
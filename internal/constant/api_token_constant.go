@@ -0,0 +1,7 @@
+package constant
+
+const (
+	ApiTokenScopeNotesRead  = "notes:read"
+	ApiTokenScopeNotesWrite = "notes:write"
+	ApiTokenScopeChat       = "chat"
+)
@@ -0,0 +1,26 @@
+package constant
+
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionMove    = "move"
+	AuditActionDelete  = "delete"
+	AuditActionRevoke  = "revoke"
+	AuditActionAccept  = "accept"
+	AuditActionDecline = "decline"
+	AuditActionRestore = "restore"
+	AuditActionPurge   = "purge"
+	AuditActionMerge   = "merge"
+	AuditActionReorder = "reorder"
+)
+
+const (
+	AuditEntityNotebook           = "notebook"
+	AuditEntityNote               = "note"
+	AuditEntityFile               = "file"
+	AuditEntityNotebookMember     = "notebook_member"
+	AuditEntityNotebookInvitation = "notebook_invitation"
+	AuditEntityChatSession        = "chat_session"
+	AuditEntityApiToken           = "api_token"
+	AuditEntityTag                = "tag"
+)
@@ -8,8 +8,19 @@ const (
 
 	ChatMessageRawInititalModelPromptV1 = `Understood. I will answer your questions based solely on the provided references, and I will indicate if I do not have enough information to answer. I will also adapt my responses to the language you use in your subsequent turns. I will not refer to the references by their numbers.\n`
 
-	DecideUseRAGMessageRawInitialUserPromptV1 = `You are a chatbot assistant that will answer your user question based on references provided. In this session, you will provide true or false data. True if you can answer directly without other information, false otherwise.`
+	RetrievalPlannerPromptV1 = `You plan the note retrieval step of a chatbot that answers questions from the user's personal notes. Read the conversation and the user's latest message, then decide:
+- should_retrieve: false only when the latest message can be answered without the user's notes, for example greetings, thanks, or questions about the previous answer itself. Otherwise true.
+- standalone_query: the latest message rewritten as a self-contained search query. Resolve pronouns and references such as "the second one" or "that topic" using the conversation, and keep the user's language.
+- sub_queries: when the message asks about several distinct things, one focused search query per thing. Leave it empty otherwise.
+- reasoning: one short sentence explaining the decision.`
+)
 
-	DecideUseRAGMessageRawInitialModelPromptV1 = `Okay, I understand. I will answer \"True\" if I can
-	definitively answer the user's question based solely on my existing knowledge, and \"False\" if I cannot. I will not attempt to make educated guesses or provide potentially inaccurate information. I will wait for your question.\n`
-)
\ No newline at end of file
+const (
+	ChatToolCallStatusPendingConfirmation = "pending_confirmation"
+	ChatToolCallStatusExecuting           = "executing"
+	ChatToolCallStatusExecuted            = "executed"
+	ChatToolCallStatusRejected            = "rejected"
+	ChatToolCallStatusFailed              = "failed"
+
+	AgentChatInstructionV1 = `You can use the provided tools to search, open and list the user's notes and notebooks, and to create or append to notes. Use the tools whenever the answer depends on the user's notes instead of guessing. Writing tools only run after the user confirms them, so tell the user what you are about to write.`
+)
@@ -0,0 +1,11 @@
+package constant
+
+// Message types of the collaborative editing socket.
+const (
+	CollabMessageSync     = "sync"
+	CollabMessageOps      = "ops"
+	CollabMessageCursor   = "cursor"
+	CollabMessagePresence = "presence"
+	CollabMessageSaved    = "saved"
+	CollabMessageError    = "error"
+)
@@ -0,0 +1,17 @@
+package constant
+
+const FileContentTypePdf = "application/pdf"
+
+const (
+	UploadIntentStatusPending   = "pending"
+	UploadIntentStatusCompleted = "completed"
+)
+
+const (
+	UploadSessionStatusPending = "pending"
+	// The multipart upload is being turned into the object, see
+	// CompleteUploadSession.
+	UploadSessionStatusCompleting = "completing"
+	UploadSessionStatusCompleted  = "completed"
+	UploadSessionStatusAborted    = "aborted"
+)
@@ -0,0 +1,20 @@
+package constant
+
+// Where the content of a note revision came from.
+const (
+	NoteRevisionSourceManual       = "manual"
+	NoteRevisionSourceAiExtraction = "ai_extraction"
+	NoteRevisionSourceImport       = "import"
+	NoteRevisionSourceCollab       = "collab"
+)
+
+const (
+	NoteSuggestionKindTag      = "tag"
+	NoteSuggestionKindNotebook = "notebook"
+
+	NoteSuggestionStatusPending  = "pending"
+	NoteSuggestionStatusAccepted = "accepted"
+	NoteSuggestionStatusRejected = "rejected"
+
+	TagSuggestionPromptV1 = `You tag the notes of a personal note-taking app. Suggest up to 5 short tags that describe the topics of the note below, in the language of the note. Prefer the user's existing tags, spelled exactly as listed, and only invent a new tag when none of them fits. Give each tag a confidence between 0 and 1 of how well it fits the note.`
+)
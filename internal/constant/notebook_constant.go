@@ -0,0 +1,34 @@
+package constant
+
+// Notebook roles, from the most to the least privileged. Commenters can read
+// like viewers, the distinction is kept for the comment features.
+const (
+	NotebookRoleOwner     = "owner"
+	NotebookRoleEditor    = "editor"
+	NotebookRoleCommenter = "commenter"
+	NotebookRoleViewer    = "viewer"
+)
+
+const (
+	NotebookInvitationStatusPending  = "pending"
+	NotebookInvitationStatusAccepted = "accepted"
+	NotebookInvitationStatusDeclined = "declined"
+	NotebookInvitationStatusRevoked  = "revoked"
+)
+
+// How deleting a notebook treats its child notebooks: cascade moves the whole
+// subtree to the trash, reparent hands the children to the parent of the
+// deleted notebook.
+const (
+	NotebookDeleteModeCascade  = "cascade"
+	NotebookDeleteModeReparent = "reparent"
+)
+
+// Orders of notebook and note listings. Manual follows the positions users
+// set by drag and drop, updated and created put the newest first.
+const (
+	SortManual  = "manual"
+	SortName    = "name"
+	SortUpdated = "updated"
+	SortCreated = "created"
+)
@@ -0,0 +1,12 @@
+package constant
+
+const (
+	UsageFeatureChat           = "chat"
+	UsageFeatureAgentChat      = "agent_chat"
+	UsageFeatureRetrievalPlan  = "retrieval_plan"
+	UsageFeatureChatRetrieval  = "chat_retrieval"
+	UsageFeatureSemanticSearch = "semantic_search"
+	UsageFeatureIndexing       = "indexing"
+	UsageFeatureExtractPreview = "extract_preview"
+	UsageFeatureAutoTagging    = "auto_tagging"
+)
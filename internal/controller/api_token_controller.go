@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type IApiTokenController interface {
+	RegisterRoutes(r fiber.Router)
+	GetAll(ctx *fiber.Ctx) error
+	Create(ctx *fiber.Ctx) error
+	Revoke(ctx *fiber.Ctx) error
+}
+
+type apiTokenController struct {
+	service service.IApiTokenService
+}
+
+func NewApiTokenController(service service.IApiTokenService) IApiTokenController {
+	return &apiTokenController{service: service}
+}
+
+// RegisterRoutes keeps API tokens away from their own management, a leaked
+// token cannot mint a broader one.
+func (c *apiTokenController) RegisterRoutes(r fiber.Router) {
+	h := r.Group("/v1")
+	h.Get("/api-token", serverutils.RequireUserSession(), c.GetAll)
+	h.Post("/api-token/create", serverutils.RequireUserSession(), c.Create)
+	h.Delete("/api-token/:id", serverutils.RequireUserSession(), c.Revoke)
+}
+
+func (c *apiTokenController) GetAll(ctx *fiber.Ctx) error {
+	res, err := c.service.GetAll(ctx.Context())
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Get List Api Token Success", res))
+}
+
+func (c *apiTokenController) Create(ctx *fiber.Ctx) error {
+	var req dto.CreateApiTokenRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	err := serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.service.Create(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Create Api Token", res))
+}
+
+func (c *apiTokenController) Revoke(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	err := c.service.Revoke(ctx.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse[any]("Success Revoke Api Token", nil))
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"ai-notetaking-be/internal/constant"
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type IAuditController interface {
+	RegisterRoutes(r fiber.Router)
+	GetAll(ctx *fiber.Ctx) error
+	GetNoteActivity(ctx *fiber.Ctx) error
+	GetNotebookActivity(ctx *fiber.Ctx) error
+}
+
+type auditController struct {
+	service service.IAuditService
+}
+
+func NewAuditController(service service.IAuditService) IAuditController {
+	return &auditController{service: service}
+}
+
+func (c *auditController) RegisterRoutes(r fiber.Router) {
+	h := r.Group("/v1")
+	h.Get("/audit", serverutils.RequireUserSession(), c.GetAll)
+	h.Get("/note/:id/activity", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.GetNoteActivity)
+	h.Get("/notebook/:id/activity", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.GetNotebookActivity)
+}
+
+func (c *auditController) GetAll(ctx *fiber.Ctx) error {
+	req := parseAuditEventsRequest(ctx)
+
+	res, err := c.service.GetAll(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Get List Audit Event Success", res))
+}
+
+func (c *auditController) GetNoteActivity(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	req := parseAuditEventsRequest(ctx)
+
+	res, err := c.service.GetNoteActivity(ctx.Context(), id, &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Get Note Activity Success", res))
+}
+
+func (c *auditController) GetNotebookActivity(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	req := parseAuditEventsRequest(ctx)
+
+	res, err := c.service.GetNotebookActivity(ctx.Context(), id, &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Get Notebook Activity Success", res))
+}
+
+func parseAuditEventsRequest(ctx *fiber.Ctx) dto.GetAuditEventsRequest {
+	return dto.GetAuditEventsRequest{
+		ActorId:    ctx.Query("actor_id", ""),
+		Action:     ctx.Query("action", ""),
+		EntityType: ctx.Query("entity_type", ""),
+		EntityId:   ctx.Query("entity_id", ""),
+		NotebookId: ctx.Query("notebook_id", ""),
+		NoteId:     ctx.Query("note_id", ""),
+		From:       ctx.Query("from", ""),
+		To:         ctx.Query("to", ""),
+		Limit:      ctx.QueryInt("limit", 0),
+		Offset:     ctx.QueryInt("offset", 0),
+	}
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type IAuthController interface {
+	RegisterRoutes(r fiber.Router)
+	RegisterProtectedRoutes(r fiber.Router)
+	Register(ctx *fiber.Ctx) error
+	Login(ctx *fiber.Ctx) error
+	Refresh(ctx *fiber.Ctx) error
+	Logout(ctx *fiber.Ctx) error
+	Me(ctx *fiber.Ctx) error
+}
+
+type authController struct {
+	service service.IAuthService
+}
+
+func NewAuthController(service service.IAuthService) IAuthController {
+	return &authController{service: service}
+}
+
+// RegisterRoutes registers the routes that are reachable without a token.
+func (c *authController) RegisterRoutes(r fiber.Router) {
+	h := r.Group("/v1/auth")
+	h.Post("/register", c.Register)
+	h.Post("/login", c.Login)
+	h.Post("/refresh", c.Refresh)
+	h.Post("/logout", c.Logout)
+}
+
+func (c *authController) RegisterProtectedRoutes(r fiber.Router) {
+	h := r.Group("/v1/auth")
+	h.Get("/me", serverutils.RequireUserSession(), c.Me)
+}
+
+func (c *authController) Register(ctx *fiber.Ctx) error {
+	var req dto.RegisterRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	err := serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.service.Register(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Register", res))
+}
+
+func (c *authController) Login(ctx *fiber.Ctx) error {
+	var req dto.LoginRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	err := serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.service.Login(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Login", res))
+}
+
+func (c *authController) Refresh(ctx *fiber.Ctx) error {
+	var req dto.RefreshTokenRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	err := serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.service.Refresh(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Refresh Token", res))
+}
+
+func (c *authController) Logout(ctx *fiber.Ctx) error {
+	var req dto.LogoutRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	err := serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	err = c.service.Logout(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse[any]("Success Logout", nil))
+}
+
+func (c *authController) Me(ctx *fiber.Ctx) error {
+	res, err := c.service.Me(ctx.Context())
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success", res))
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"ai-notetaking-be/internal/constant"
 	"ai-notetaking-be/internal/dto"
 	"ai-notetaking-be/internal/pkg/serverutils"
 	"ai-notetaking-be/internal/service"
@@ -16,6 +17,13 @@ type IChatbotController interface {
 	GetChatHistory(ctx *fiber.Ctx) error
 	SendChat(ctx *fiber.Ctx) error
 	DeleteSession(ctx *fiber.Ctx) error
+	RegenerateChat(ctx *fiber.Ctx) error
+	EditChat(ctx *fiber.Ctx) error
+	GetChatBranches(ctx *fiber.Ctx) error
+	SwitchBranch(ctx *fiber.Ctx) error
+	AgentChat(ctx *fiber.Ctx) error
+	ConfirmToolCall(ctx *fiber.Ctx) error
+	SetSessionTags(ctx *fiber.Ctx) error
 }
 
 type chatbotController struct {
@@ -30,16 +38,33 @@ func NewChatController(chatbotService service.IChatbotService) IChatbotControlle
 
 func (c *chatbotController) RegisterRoutes(r fiber.Router) {
 	h := r.Group("/v1/chatbot")
-	h.Post("/create-session", c.CreateSession)
-	h.Get("/sessions", c.GetAllSession)
-	h.Get("/chat-history", c.GetChatHistory)
-	h.Post("/send-chat", c.SendChat)
-	h.Delete("/delete-session", c.DeleteSession)
+	h.Post("/create-session", serverutils.RequireScope(constant.ApiTokenScopeChat), c.CreateSession)
+	h.Get("/sessions", serverutils.RequireScope(constant.ApiTokenScopeChat), c.GetAllSession)
+	h.Get("/chat-history", serverutils.RequireScope(constant.ApiTokenScopeChat), c.GetChatHistory)
+	h.Post("/send-chat", serverutils.RequireScope(constant.ApiTokenScopeChat), c.SendChat)
+	h.Delete("/delete-session", serverutils.RequireScope(constant.ApiTokenScopeChat), c.DeleteSession)
+	h.Post("/regenerate-chat", serverutils.RequireScope(constant.ApiTokenScopeChat), c.RegenerateChat)
+	h.Post("/edit-chat", serverutils.RequireScope(constant.ApiTokenScopeChat), c.EditChat)
+	h.Get("/chat-branches", serverutils.RequireScope(constant.ApiTokenScopeChat), c.GetChatBranches)
+	h.Put("/switch-branch", serverutils.RequireScope(constant.ApiTokenScopeChat), c.SwitchBranch)
+	h.Post("/agent-chat", serverutils.RequireScope(constant.ApiTokenScopeChat), c.AgentChat)
+	h.Post("/confirm-tool-call", serverutils.RequireScope(constant.ApiTokenScopeChat), c.ConfirmToolCall)
+	h.Put("/session-tags", serverutils.RequireScope(constant.ApiTokenScopeChat), c.SetSessionTags)
 }
 
 func (c *chatbotController) CreateSession(ctx *fiber.Ctx) error {
 
-	res, err := c.chatbotService.CreateSession(ctx.Context())
+	var req dto.CreateSessionRequest
+
+	// The body is optional, an empty request starts a session with the default template.
+	if len(ctx.Body()) > 0 {
+		err := ctx.BodyParser(&req)
+		if err != nil {
+			return err
+		}
+	}
+
+	res, err := c.chatbotService.CreateSession(ctx.Context(), &req)
 	if err != nil {
 		return err
 	}
@@ -116,3 +141,155 @@ func (c *chatbotController) DeleteSession(ctx *fiber.Ctx) error {
 
 	return ctx.JSON(serverutils.SuccessResponse[any]("Success Delete Session Chat", nil))
 }
+
+func (c *chatbotController) RegenerateChat(ctx *fiber.Ctx) error {
+
+	var req dto.RegenerateChatRequest
+
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return err
+	}
+
+	err = serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.chatbotService.RegenerateChat(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success regenerate chat", res))
+}
+
+func (c *chatbotController) EditChat(ctx *fiber.Ctx) error {
+
+	var req dto.EditChatRequest
+
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return err
+	}
+
+	err = serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.chatbotService.EditChat(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success edit chat", res))
+}
+
+func (c *chatbotController) GetChatBranches(ctx *fiber.Ctx) error {
+
+	sessionId, err := uuid.Parse(ctx.Query("chat_session_id", ""))
+	if err != nil {
+		return err
+	}
+
+	messageId, err := uuid.Parse(ctx.Query("chat_message_id", ""))
+	if err != nil {
+		return err
+	}
+
+	res, err := c.chatbotService.GetChatBranches(ctx.Context(), sessionId, messageId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success get chat branches", res))
+}
+
+func (c *chatbotController) SwitchBranch(ctx *fiber.Ctx) error {
+
+	var req dto.SwitchBranchRequest
+
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return err
+	}
+
+	err = serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.chatbotService.SwitchBranch(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success switch branch", res))
+}
+
+func (c *chatbotController) AgentChat(ctx *fiber.Ctx) error {
+
+	var req dto.SendChatRequest
+
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return err
+	}
+
+	err = serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.chatbotService.AgentChat(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success create agent chat", res))
+}
+
+func (c *chatbotController) ConfirmToolCall(ctx *fiber.Ctx) error {
+
+	var req dto.ConfirmToolCallRequest
+
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return err
+	}
+
+	err = serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.chatbotService.ConfirmToolCall(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success confirm tool call", res))
+}
+
+func (c *chatbotController) SetSessionTags(ctx *fiber.Ctx) error {
+
+	var req dto.SetSessionTagsRequest
+
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return err
+	}
+
+	err = serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.chatbotService.SetSessionTags(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success set session tags", res))
+}
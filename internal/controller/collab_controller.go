@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"ai-notetaking-be/internal/constant"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"nhooyr.io/websocket"
+)
+
+const collabReadLimit = 1 << 20
+
+// ICollabController serves the collaborative editing socket. Fiber runs on
+// fasthttp, which cannot hand a connection over to the WebSocket library, so
+// it is registered on a net/http mux instead of the Fiber app.
+type ICollabController interface {
+	RegisterRoutes(mux *http.ServeMux)
+	Connect(w http.ResponseWriter, r *http.Request)
+}
+
+type collabController struct {
+	service           service.ICollabService
+	verifyAccessToken func(ctx context.Context, token string) (uuid.UUID, error)
+	verifyApiToken    func(ctx context.Context, token string) (uuid.UUID, []string, error)
+}
+
+func NewCollabController(
+	service service.ICollabService,
+	verifyAccessToken func(ctx context.Context, token string) (uuid.UUID, error),
+	verifyApiToken func(ctx context.Context, token string) (uuid.UUID, []string, error),
+) ICollabController {
+	return &collabController{
+		service:           service,
+		verifyAccessToken: verifyAccessToken,
+		verifyApiToken:    verifyApiToken,
+	}
+}
+
+func (c *collabController) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/note/{id}/collab", c.Connect)
+}
+
+// Connect upgrades to a WebSocket on the room of the note. Browsers cannot
+// set headers on a WebSocket, so the token may also come as the access_token
+// query parameter. Tokens without notes:write join read-only.
+func (c *collabController) Connect(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeCollabError(w, serverutils.ErrNotFound)
+		return
+	}
+
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		token = r.URL.Query().Get("access_token")
+	}
+
+	ctx, err := serverutils.Authenticate(r.Context(), strings.TrimSpace(token), c.verifyAccessToken, c.verifyApiToken)
+	if err != nil {
+		writeCollabError(w, err)
+		return
+	}
+
+	if !serverutils.HasScope(ctx, constant.ApiTokenScopeNotesRead) {
+		writeCollabError(w, serverutils.ErrForbidden)
+		return
+	}
+
+	client, err := c.service.Join(ctx, id, !serverutils.HasScope(ctx, constant.ApiTokenScopeNotesWrite))
+	if err != nil {
+		writeCollabError(w, err)
+		return
+	}
+
+	defer client.Leave()
+
+	// The token authenticates the socket, not cookies, so any origin may
+	// connect.
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
+	if err != nil {
+		return
+	}
+
+	defer conn.Close(websocket.StatusInternalError, "")
+
+	conn.SetReadLimit(collabReadLimit)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	go func() {
+		defer cancel()
+
+		for payload := range client.Outbox() {
+			err := conn.Write(ctx, websocket.MessageText, payload)
+			if err != nil {
+				return
+			}
+		}
+
+		conn.Close(websocket.StatusPolicyViolation, "disconnected by the server")
+	}()
+
+	for {
+		_, payload, err := conn.Read(ctx)
+		if err != nil {
+			if websocket.CloseStatus(err) == websocket.StatusNormalClosure {
+				conn.Close(websocket.StatusNormalClosure, "")
+			}
+			return
+		}
+
+		client.Handle(payload)
+	}
+}
+
+func writeCollabError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	message := "Internal Server Error"
+	switch {
+	case errors.Is(err, serverutils.ErrUnauthorized):
+		status = http.StatusUnauthorized
+		message = err.Error()
+	case errors.Is(err, serverutils.ErrForbidden):
+		status = http.StatusForbidden
+		message = err.Error()
+	case errors.Is(err, serverutils.ErrNotFound):
+		status = http.StatusNotFound
+		message = err.Error()
+	default:
+		log.Printf("[Collab] %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(serverutils.ErrorResponse(status, message))
+}
@@ -23,7 +23,7 @@ func NewExampleController(service service.IExampleService) IExampleController {
 
 func (c *exampleController) RegisterRoutes(r fiber.Router) {
 	h := r.Group("/v1")
-	h.Post("/hello-world", c.HelloWorld)
+	h.Post("/hello-world", serverutils.RequireUserSession(), c.HelloWorld)
 }
 
 func (c *exampleController) HelloWorld(ctx *fiber.Ctx) error {
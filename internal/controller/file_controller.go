@@ -1,8 +1,11 @@
 package controller
 
 import (
+	"ai-notetaking-be/internal/constant"
+	"ai-notetaking-be/internal/dto"
 	"ai-notetaking-be/internal/pkg/serverutils"
 	"ai-notetaking-be/internal/service"
+	"bytes"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -12,6 +15,17 @@ type IFileController interface {
 	RegisterRoutes(r fiber.Router)
 	UploadToGarage(ctx *fiber.Ctx) error
 	GetFileURL(ctx *fiber.Ctx) error
+	GetByNoteId(ctx *fiber.Ctx) error
+	Delete(ctx *fiber.Ctx) error
+	Rename(ctx *fiber.Ctx) error
+	Reorder(ctx *fiber.Ctx) error
+	CreateUploadIntent(ctx *fiber.Ctx) error
+	CompleteUpload(ctx *fiber.Ctx) error
+	CreateUploadSession(ctx *fiber.Ctx) error
+	GetUploadSession(ctx *fiber.Ctx) error
+	UploadPart(ctx *fiber.Ctx) error
+	CompleteUploadSession(ctx *fiber.Ctx) error
+	AbortUploadSession(ctx *fiber.Ctx) error
 }
 
 type fileController struct {
@@ -24,8 +38,19 @@ func NewFileController(service service.IFileService) IFileController {
 
 func (c *fileController) RegisterRoutes(r fiber.Router) {
 	h := r.Group("/v1")
-	h.Post("/upload", c.UploadToGarage)
-	h.Get("/get-file", c.GetFileURL)
+	h.Post("/upload", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.UploadToGarage)
+	h.Post("/upload/intent", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.CreateUploadIntent)
+	h.Post("/upload/multipart", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.CreateUploadSession)
+	h.Get("/upload/multipart/:id", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.GetUploadSession)
+	h.Put("/upload/multipart/:id/parts/:partNumber", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.UploadPart)
+	h.Post("/upload/multipart/:id/complete", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.CompleteUploadSession)
+	h.Delete("/upload/multipart/:id", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.AbortUploadSession)
+	h.Post("/upload/:id/complete", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.CompleteUpload)
+	h.Get("/get-file", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.GetFileURL)
+	h.Get("/note/:id/files", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.GetByNoteId)
+	h.Delete("/note/:id/files/:fileId", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Delete)
+	h.Put("/note/:id/files/:fileId", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Rename)
+	h.Put("/note/:id/files/:fileId/reorder", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Reorder)
 }
 
 func (c *fileController) UploadToGarage(ctx *fiber.Ctx) error {
@@ -65,3 +90,169 @@ func (c *fileController) GetFileURL(ctx *fiber.Ctx) error {
 
 	return ctx.JSON(serverutils.SuccessResponse("Berhasil mendapatkan URL file", url))
 }
+
+func (c *fileController) GetByNoteId(ctx *fiber.Ctx) error {
+	noteId, _ := uuid.Parse(ctx.Params("id"))
+
+	res, err := c.service.GetByNoteId(ctx.Context(), noteId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success", res))
+}
+
+func (c *fileController) Delete(ctx *fiber.Ctx) error {
+	noteId, _ := uuid.Parse(ctx.Params("id"))
+	id, _ := uuid.Parse(ctx.Params("fileId"))
+
+	err := c.service.Delete(ctx.Context(), noteId, id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse[any]("Success Delete File", nil))
+}
+
+func (c *fileController) Rename(ctx *fiber.Ctx) error {
+	noteId, _ := uuid.Parse(ctx.Params("id"))
+	id, _ := uuid.Parse(ctx.Params("fileId"))
+
+	var req dto.RenameFileRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	err := serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	req.NoteId = noteId
+	req.Id = id
+	res, err := c.service.Rename(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Rename File", res))
+}
+
+func (c *fileController) Reorder(ctx *fiber.Ctx) error {
+	noteId, _ := uuid.Parse(ctx.Params("id"))
+	id, _ := uuid.Parse(ctx.Params("fileId"))
+
+	var req dto.ReorderFileRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	req.NoteId = noteId
+	req.Id = id
+	res, err := c.service.Reorder(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Reorder File", res))
+}
+
+func (c *fileController) CreateUploadIntent(ctx *fiber.Ctx) error {
+	var req dto.CreateUploadIntentRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	err := serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.service.CreateUploadIntent(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Create Upload Intent", res))
+}
+
+func (c *fileController) CompleteUpload(ctx *fiber.Ctx) error {
+	id, _ := uuid.Parse(ctx.Params("id"))
+
+	res, err := c.service.CompleteUpload(ctx.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("File berhasil diunggah dan disimpan", res))
+}
+
+func (c *fileController) CreateUploadSession(ctx *fiber.Ctx) error {
+	var req dto.CreateUploadSessionRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	err := serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.service.CreateUploadSession(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Create Upload Session", res))
+}
+
+func (c *fileController) GetUploadSession(ctx *fiber.Ctx) error {
+	id, _ := uuid.Parse(ctx.Params("id"))
+
+	res, err := c.service.GetUploadSession(ctx.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success", res))
+}
+
+// UploadPart takes the raw bytes of the part as the request body.
+func (c *fileController) UploadPart(ctx *fiber.Ctx) error {
+	id, _ := uuid.Parse(ctx.Params("id"))
+
+	partNumber, err := ctx.ParamsInt("partNumber")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Part number tidak valid")
+	}
+
+	body := ctx.Body()
+	res, err := c.service.UploadPart(ctx.Context(), id, int32(partNumber), bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Upload Part", res))
+}
+
+func (c *fileController) CompleteUploadSession(ctx *fiber.Ctx) error {
+	id, _ := uuid.Parse(ctx.Params("id"))
+
+	res, err := c.service.CompleteUploadSession(ctx.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("File berhasil diunggah dan disimpan", res))
+}
+
+func (c *fileController) AbortUploadSession(ctx *fiber.Ctx) error {
+	id, _ := uuid.Parse(ctx.Params("id"))
+
+	err := c.service.AbortUploadSession(ctx.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse[any]("Success Abort Upload Session", nil))
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"ai-notetaking-be/internal/constant"
 	"ai-notetaking-be/internal/dto"
 	"ai-notetaking-be/internal/pkg/serverutils"
 	"ai-notetaking-be/internal/service"
@@ -12,12 +13,18 @@ import (
 type INoteController interface {
 	RegisterRoutes(r fiber.Router)
 	SemanticSearch(ctx *fiber.Ctx) error
+	List(ctx *fiber.Ctx) error
 	Create(ctx *fiber.Ctx) error
 	Show(ctx *fiber.Ctx) error
 	Update(ctx *fiber.Ctx) error
 	Delete(ctx *fiber.Ctx) error
 	GetExtractPreview(ctx *fiber.Ctx) error
 	ConfirmExtraction(ctx *fiber.Ctx) error
+	GetRevisions(ctx *fiber.Ctx) error
+	ShowRevision(ctx *fiber.Ctx) error
+	DiffRevisions(ctx *fiber.Ctx) error
+	RestoreRevision(ctx *fiber.Ctx) error
+	Reorder(ctx *fiber.Ctx) error
 }
 
 type noteController struct {
@@ -30,15 +37,21 @@ func NewNoteController(service service.INoteService) INoteController {
 
 func (c *noteController) RegisterRoutes(r fiber.Router) {
 	h := r.Group("/v1")
-	h.Post("/note/create", c.Create)
-	h.Get("/semantic-search", c.SemanticSearch)
-	h.Get("/note/:id", c.Show)
-	h.Put("/note/:id", c.Update)
-	h.Delete("/note/:id", c.Delete)
-	h.Put("/note/:id/move", c.Move)
-	h.Get("/note/:id/extract-preview", c.GetExtractPreview)
-	h.Get("/note/:id/extract-preview-ai", c.GetExtractPreviewAi)
-	h.Put("/note/:id/confirm-extraction", c.ConfirmExtraction)
+	h.Post("/note/create", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Create)
+	h.Get("/notes", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.List)
+	h.Get("/semantic-search", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.SemanticSearch)
+	h.Get("/note/:id", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.Show)
+	h.Put("/note/:id", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Update)
+	h.Delete("/note/:id", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Delete)
+	h.Put("/note/:id/move", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Move)
+	h.Put("/note/:id/reorder", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Reorder)
+	h.Get("/note/:id/extract-preview", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.GetExtractPreview)
+	h.Get("/note/:id/extract-preview-ai", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.GetExtractPreviewAi)
+	h.Put("/note/:id/confirm-extraction", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.ConfirmExtraction)
+	h.Get("/note/:id/revisions", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.GetRevisions)
+	h.Get("/note/:id/revisions/diff", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.DiffRevisions)
+	h.Get("/note/:id/revisions/:revisionId", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.ShowRevision)
+	h.Post("/note/:id/revisions/:revisionId/restore", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.RestoreRevision)
 
 }
 
@@ -70,13 +83,39 @@ func (c *noteController) Show(ctx *fiber.Ctx) error {
 		return err
 	}
 
+	ctx.Set(fiber.HeaderETag, serverutils.FormatETag(res.Version))
+
 	return ctx.JSON(serverutils.SuccessResponse("Success", res))
 }
 
 func (c *noteController) SemanticSearch(ctx *fiber.Ctx) error {
-	q := ctx.Query("q", "")
+	req := dto.SemanticSearchRequest{
+		Query:  ctx.Query("q", ""),
+		TagIds: ctx.Query("tag_ids"),
+	}
+
+	res, err := c.service.SemanticSearch(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
 
-	res, err := c.service.SemanticSearch(ctx.Context(), q)
+	return ctx.JSON(serverutils.SuccessResponse("Success", res))
+}
+
+func (c *noteController) List(ctx *fiber.Ctx) error {
+	req := dto.ListNotesRequest{
+		NotebookId: ctx.Query("notebook_id", ""),
+		Subtree:    ctx.Query("subtree", ""),
+		TagIds:     ctx.Query("tag_ids", ""),
+		From:       ctx.Query("from", ""),
+		To:         ctx.Query("to", ""),
+		HasFile:    ctx.Query("has_file", ""),
+		Sort:       ctx.Query("sort", ""),
+		Cursor:     ctx.Query("cursor", ""),
+		Limit:      ctx.QueryInt("limit", 0),
+	}
+
+	res, err := c.service.List(ctx.Context(), &req)
 	if err != nil {
 		return err
 	}
@@ -95,7 +134,13 @@ func (c *noteController) Update(ctx *fiber.Ctx) error {
 
 	req.Id = id
 
-	err := serverutils.ValidateRequest(req)
+	version, err := serverutils.ParseIfMatch(ctx.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		return err
+	}
+	req.Version = version
+
+	err = serverutils.ValidateRequest(req)
 	if err != nil {
 		return err
 	}
@@ -105,6 +150,8 @@ func (c *noteController) Update(ctx *fiber.Ctx) error {
 		return err
 	}
 
+	ctx.Set(fiber.HeaderETag, serverutils.FormatETag(res.Version))
+
 	return ctx.JSON(serverutils.SuccessResponse("Success Updated Note", res))
 }
 
@@ -112,7 +159,12 @@ func (c *noteController) Delete(ctx *fiber.Ctx) error {
 	idParam := ctx.Params("id")
 	id, _ := uuid.Parse(idParam)
 
-	err := c.service.Delete(ctx.Context(), id)
+	version, err := serverutils.ParseIfMatch(ctx.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		return err
+	}
+
+	err = c.service.Delete(ctx.Context(), id, version)
 	if err != nil {
 		return err
 	}
@@ -130,7 +182,13 @@ func (c *noteController) Move(ctx *fiber.Ctx) error {
 	}
 	req.Id = id
 
-	err := serverutils.ValidateRequest(req)
+	version, err := serverutils.ParseIfMatch(ctx.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		return err
+	}
+	req.Version = version
+
+	err = serverutils.ValidateRequest(req)
 	if err != nil {
 		return err
 	}
@@ -140,9 +198,29 @@ func (c *noteController) Move(ctx *fiber.Ctx) error {
 		return err
 	}
 
+	ctx.Set(fiber.HeaderETag, serverutils.FormatETag(res.Version))
+
 	return ctx.JSON(serverutils.SuccessResponse("Success Move Notebook", res))
 }
 
+func (c *noteController) Reorder(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	var req dto.ReorderNoteRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	req.Id = id
+	res, err := c.service.Reorder(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Reorder Note", res))
+}
+
 func (c *noteController) GetExtractPreview(ctx *fiber.Ctx) error {
 	idParam := ctx.Params("id")
 	id, err := uuid.Parse(idParam)
@@ -193,6 +271,11 @@ func (c *noteController) ConfirmExtraction(ctx *fiber.Ctx) error {
 	}
 	req.Id = id
 
+	req.Version, err = serverutils.ParseIfMatch(ctx.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		return err
+	}
+
 	// Validasi konten teks hasil edit user
 	err = serverutils.ValidateRequest(req)
 	if err != nil {
@@ -205,5 +288,67 @@ func (c *noteController) ConfirmExtraction(ctx *fiber.Ctx) error {
 		return err
 	}
 
+	ctx.Set(fiber.HeaderETag, serverutils.FormatETag(res.Version))
+
 	return ctx.JSON(serverutils.SuccessResponse("Success confirm and update extraction", res))
 }
+
+func (c *noteController) GetRevisions(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	res, err := c.service.GetRevisions(ctx.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Get List Note Revision Success", res))
+}
+
+func (c *noteController) ShowRevision(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	revisionIdParam := ctx.Params("revisionId")
+	revisionId, _ := uuid.Parse(revisionIdParam)
+
+	res, err := c.service.ShowRevision(ctx.Context(), id, revisionId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success", res))
+}
+
+func (c *noteController) DiffRevisions(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	req := dto.GetNoteRevisionDiffRequest{
+		NoteId: id,
+		From:   ctx.Query("from"),
+		To:     ctx.Query("to"),
+	}
+
+	res, err := c.service.DiffRevisions(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Diff Note Revision", res))
+}
+
+func (c *noteController) RestoreRevision(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	revisionIdParam := ctx.Params("revisionId")
+	revisionId, _ := uuid.Parse(revisionIdParam)
+
+	res, err := c.service.RestoreRevision(ctx.Context(), id, revisionId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Restore Note Revision", res))
+}
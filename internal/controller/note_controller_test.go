@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/service"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// fakeVersionedService saves a note or notebook at currentVersion, like the
+// services it answers a conflict for any other version.
+type fakeVersionedService struct {
+	currentVersion int
+	calls          int
+}
+
+func (f *fakeVersionedService) save(version *int) (int, error) {
+	f.calls++
+	if version != nil && *version != f.currentVersion {
+		return 0, serverutils.NewConflictError(f.currentVersion, nil)
+	}
+
+	f.currentVersion++
+	return f.currentVersion, nil
+}
+
+type fakeNoteService struct {
+	service.INoteService
+	fakeVersionedService
+}
+
+func (f *fakeNoteService) Update(ctx context.Context, req *dto.UpdateNoteRequest) (*dto.UpdateNoteResponse, error) {
+	version, err := f.save(req.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.UpdateNoteResponse{Id: req.Id, Version: version}, nil
+}
+
+func (f *fakeNoteService) Delete(ctx context.Context, id uuid.UUID, version *int) error {
+	_, err := f.save(version)
+	return err
+}
+
+type fakeNotebookService struct {
+	service.INotebookService
+	fakeVersionedService
+}
+
+func (f *fakeNotebookService) Update(ctx context.Context, req *dto.UpdateNotebookRequest) (*dto.UpdateNotebookResponse, error) {
+	version, err := f.save(req.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.UpdateNotebookResponse{Id: req.Id, Version: version}, nil
+}
+
+func (f *fakeNotebookService) Delete(ctx context.Context, req *dto.DeleteNotebookRequest) error {
+	_, err := f.save(req.Version)
+	return err
+}
+
+func TestIfMatchIsRequired(t *testing.T) {
+	id := uuid.NewString()
+
+	routes := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{name: "update note", method: http.MethodPut, path: "/api/v1/note/" + id, body: `{"title": "Title", "content": "Content"}`},
+		{name: "delete note", method: http.MethodDelete, path: "/api/v1/note/" + id},
+		{name: "update notebook", method: http.MethodPut, path: "/api/v1/notebook/" + id, body: `{"name": "Name"}`},
+		{name: "delete notebook", method: http.MethodDelete, path: "/api/v1/notebook/" + id + "?mode=cascade"},
+	}
+
+	tests := []struct {
+		name       string
+		ifMatch    string
+		wantStatus int
+		wantCalls  int
+		// wantETag is the ETag header of the response, empty for none.
+		wantETag string
+	}{
+		{name: "missing", wantStatus: fiber.StatusPreconditionRequired},
+		{name: "malformed", ifMatch: "3", wantStatus: fiber.StatusBadRequest},
+		{name: "stale", ifMatch: `"2"`, wantStatus: fiber.StatusConflict, wantCalls: 1, wantETag: `"3"`},
+		{name: "matching", ifMatch: `"3"`, wantStatus: fiber.StatusOK, wantCalls: 1},
+		{name: "weak matching", ifMatch: `W/"3"`, wantStatus: fiber.StatusOK, wantCalls: 1},
+		{name: "any version", ifMatch: "*", wantStatus: fiber.StatusOK, wantCalls: 1},
+	}
+
+	for _, route := range routes {
+		for _, tt := range tests {
+			t.Run(route.name+"/"+tt.name, func(t *testing.T) {
+				noteService := &fakeNoteService{fakeVersionedService: fakeVersionedService{currentVersion: 3}}
+				notebookService := &fakeNotebookService{fakeVersionedService: fakeVersionedService{currentVersion: 3}}
+
+				app := fiber.New()
+				app.Use(serverutils.ErrorHandlerMiddleware())
+				api := app.Group("/api")
+				NewNoteController(noteService).RegisterRoutes(api)
+				NewNotebookController(notebookService).RegisterRoutes(api)
+
+				req := httptest.NewRequest(route.method, route.path, strings.NewReader(route.body))
+				req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+				if tt.ifMatch != "" {
+					req.Header.Set(fiber.HeaderIfMatch, tt.ifMatch)
+				}
+
+				res, err := app.Test(req)
+				if err != nil {
+					t.Fatalf("request failed: %v", err)
+				}
+
+				if res.StatusCode != tt.wantStatus {
+					t.Errorf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+				}
+				if calls := noteService.calls + notebookService.calls; calls != tt.wantCalls {
+					t.Errorf("the service was called %d times, want %d", calls, tt.wantCalls)
+				}
+				if tt.wantETag != "" && res.Header.Get(fiber.HeaderETag) != tt.wantETag {
+					t.Errorf("ETag = %q, want %q", res.Header.Get(fiber.HeaderETag), tt.wantETag)
+				}
+			})
+		}
+	}
+}
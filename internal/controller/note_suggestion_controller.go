@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"ai-notetaking-be/internal/constant"
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type INoteSuggestionController interface {
+	RegisterRoutes(r fiber.Router)
+	GetAll(ctx *fiber.Ctx) error
+	Resolve(ctx *fiber.Ctx) error
+}
+
+type noteSuggestionController struct {
+	service service.INoteSuggestionService
+}
+
+func NewNoteSuggestionController(service service.INoteSuggestionService) INoteSuggestionController {
+	return &noteSuggestionController{service: service}
+}
+
+func (c *noteSuggestionController) RegisterRoutes(r fiber.Router) {
+	h := r.Group("/v1")
+	h.Get("/note/:id/suggestions", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.GetAll)
+	h.Put("/note/:id/suggestions/:suggestionId", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Resolve)
+}
+
+func (c *noteSuggestionController) GetAll(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	res, err := c.service.GetAll(ctx.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Get List Note Suggestion Success", res))
+}
+
+func (c *noteSuggestionController) Resolve(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+	suggestionId, _ := uuid.Parse(ctx.Params("suggestionId"))
+
+	var req dto.ResolveNoteSuggestionRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	err := serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	req.NoteId = id
+	req.Id = suggestionId
+	res, err := c.service.Resolve(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Resolve Note Suggestion", res))
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"ai-notetaking-be/internal/constant"
 	"ai-notetaking-be/internal/dto"
 	"ai-notetaking-be/internal/pkg/serverutils"
 	"ai-notetaking-be/internal/service"
@@ -12,6 +13,10 @@ import (
 type INotebookController interface {
 	RegisterRoutes(r fiber.Router)
 	GetAll(ctx *fiber.Ctx) error
+	GetTree(ctx *fiber.Ctx) error
+	GetPath(ctx *fiber.Ctx) error
+	GetNotes(ctx *fiber.Ctx) error
+	Reorder(ctx *fiber.Ctx) error
 	Create(ctx *fiber.Ctx) error
 	Show(ctx *fiber.Ctx) error
 	Update(ctx *fiber.Ctx) error
@@ -29,17 +34,26 @@ func NewNotebookController(service service.INotebookService) INotebookController
 
 func (c *notebookeController) RegisterRoutes(r fiber.Router) {
 	h := r.Group("/v1")
-	h.Get("/notebook", c.GetAll)
-	h.Post("/notebook/create", c.Create)
-	h.Get("/notebook/:id", c.Show)
-	h.Put("/notebook/:id", c.Update)
-	h.Delete("/notebook/:id", c.Delete)
-	h.Put("/notebook/:id/move", c.Move)
+	h.Get("/notebook", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.GetAll)
+	h.Get("/notebook/tree", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.GetTree)
+	h.Post("/notebook/create", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Create)
+	h.Get("/notebook/:id", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.Show)
+	h.Put("/notebook/:id", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Update)
+	h.Delete("/notebook/:id", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Delete)
+	h.Put("/notebook/:id/move", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Move)
+	h.Get("/notebook/:id/path", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.GetPath)
+	h.Get("/notebook/:id/notes", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.GetNotes)
+	h.Put("/notebook/:id/reorder", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Reorder)
 }
 
 func (c *notebookeController) GetAll(ctx *fiber.Ctx) error {
 
-	res, err := c.service.GetAll(ctx.Context())
+	req := dto.GetAllNotebookRequest{
+		TagIds: ctx.Query("tag_ids"),
+		Sort:   ctx.Query("sort"),
+	}
+
+	res, err := c.service.GetAll(ctx.Context(), &req)
 	if err != nil {
 		return err
 	}
@@ -47,6 +61,48 @@ func (c *notebookeController) GetAll(ctx *fiber.Ctx) error {
 	return ctx.JSON(serverutils.SuccessResponse("Get List Notebook Success", res))
 }
 
+func (c *notebookeController) GetTree(ctx *fiber.Ctx) error {
+	req := dto.GetNotebookTreeRequest{
+		Sort: ctx.Query("sort"),
+	}
+
+	res, err := c.service.GetTree(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Get Notebook Tree Success", res))
+}
+
+func (c *notebookeController) GetPath(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	res, err := c.service.GetPath(ctx.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Get Notebook Path Success", res))
+}
+
+func (c *notebookeController) GetNotes(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	req := dto.GetNotebookNotesRequest{
+		Id:   id,
+		Sort: ctx.Query("sort"),
+	}
+
+	res, err := c.service.GetNotes(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Get List Notebook Note Success", res))
+}
+
 func (c *notebookeController) Create(ctx *fiber.Ctx) error {
 	var req dto.CreateNotebookRequest
 	if err := ctx.BodyParser(&req); err != nil {
@@ -75,6 +131,8 @@ func (c *notebookeController) Show(ctx *fiber.Ctx) error {
 		return err
 	}
 
+	ctx.Set(fiber.HeaderETag, serverutils.FormatETag(res.Version))
+
 	return ctx.JSON(serverutils.SuccessResponse("Success", res))
 }
 
@@ -89,7 +147,13 @@ func (c *notebookeController) Update(ctx *fiber.Ctx) error {
 
 	req.Id = id
 
-	err := serverutils.ValidateRequest(req)
+	version, err := serverutils.ParseIfMatch(ctx.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		return err
+	}
+	req.Version = version
+
+	err = serverutils.ValidateRequest(req)
 	if err != nil {
 		return err
 	}
@@ -99,6 +163,8 @@ func (c *notebookeController) Update(ctx *fiber.Ctx) error {
 		return err
 	}
 
+	ctx.Set(fiber.HeaderETag, serverutils.FormatETag(res.Version))
+
 	return ctx.JSON(serverutils.SuccessResponse("Success Updated Notebook", res))
 }
 
@@ -112,7 +178,13 @@ func (c *notebookeController) Move(ctx *fiber.Ctx) error {
 	}
 	req.Id = id
 
-	err := serverutils.ValidateRequest(req)
+	version, err := serverutils.ParseIfMatch(ctx.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		return err
+	}
+	req.Version = version
+
+	err = serverutils.ValidateRequest(req)
 	if err != nil {
 		return err
 	}
@@ -122,14 +194,45 @@ func (c *notebookeController) Move(ctx *fiber.Ctx) error {
 		return err
 	}
 
+	ctx.Set(fiber.HeaderETag, serverutils.FormatETag(res.Version))
+
 	return ctx.JSON(serverutils.SuccessResponse("Success Move Notebook", res))
 }
 
+func (c *notebookeController) Reorder(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	var req dto.ReorderNotebookRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	req.Id = id
+	res, err := c.service.Reorder(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Reorder Notebook", res))
+}
+
 func (c *notebookeController) Delete(ctx *fiber.Ctx) error {
 	idParam := ctx.Params("id")
 	id, _ := uuid.Parse(idParam)
 
-	err := c.service.Delete(ctx.Context(), id)
+	version, err := serverutils.ParseIfMatch(ctx.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		return err
+	}
+
+	req := dto.DeleteNotebookRequest{
+		Id:      id,
+		Mode:    ctx.Query("mode"),
+		Version: version,
+	}
+
+	err = c.service.Delete(ctx.Context(), &req)
 	if err != nil {
 		return err
 	}
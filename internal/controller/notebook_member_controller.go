@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"ai-notetaking-be/internal/constant"
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type INotebookMemberController interface {
+	RegisterRoutes(r fiber.Router)
+	GetMembers(ctx *fiber.Ctx) error
+	UpdateMember(ctx *fiber.Ctx) error
+	RemoveMember(ctx *fiber.Ctx) error
+	Invite(ctx *fiber.Ctx) error
+	GetInvitations(ctx *fiber.Ctx) error
+	RevokeInvitation(ctx *fiber.Ctx) error
+	GetMyInvitations(ctx *fiber.Ctx) error
+	AcceptInvitation(ctx *fiber.Ctx) error
+	DeclineInvitation(ctx *fiber.Ctx) error
+}
+
+type notebookMemberController struct {
+	service service.INotebookMemberService
+}
+
+func NewNotebookMemberController(service service.INotebookMemberService) INotebookMemberController {
+	return &notebookMemberController{service: service}
+}
+
+func (c *notebookMemberController) RegisterRoutes(r fiber.Router) {
+	h := r.Group("/v1")
+	h.Get("/notebook/:id/member", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.GetMembers)
+	h.Put("/notebook/:id/member/:userId", serverutils.RequireUserSession(), c.UpdateMember)
+	h.Delete("/notebook/:id/member/:userId", serverutils.RequireUserSession(), c.RemoveMember)
+	h.Get("/notebook/:id/invitation", serverutils.RequireUserSession(), c.GetInvitations)
+	h.Post("/notebook/:id/invitation", serverutils.RequireUserSession(), c.Invite)
+	h.Delete("/notebook/:id/invitation/:invitationId", serverutils.RequireUserSession(), c.RevokeInvitation)
+	h.Get("/invitation", serverutils.RequireUserSession(), c.GetMyInvitations)
+	h.Post("/invitation/:id/accept", serverutils.RequireUserSession(), c.AcceptInvitation)
+	h.Post("/invitation/:id/decline", serverutils.RequireUserSession(), c.DeclineInvitation)
+}
+
+func (c *notebookMemberController) GetMembers(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	res, err := c.service.GetMembers(ctx.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Get List Member Success", res))
+}
+
+func (c *notebookMemberController) UpdateMember(ctx *fiber.Ctx) error {
+	id, _ := uuid.Parse(ctx.Params("id"))
+	userId, _ := uuid.Parse(ctx.Params("userId"))
+
+	var req dto.UpdateNotebookMemberRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+	req.NotebookId = id
+	req.UserId = userId
+
+	err := serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.service.UpdateMember(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Update Member", res))
+}
+
+func (c *notebookMemberController) RemoveMember(ctx *fiber.Ctx) error {
+	id, _ := uuid.Parse(ctx.Params("id"))
+	userId, _ := uuid.Parse(ctx.Params("userId"))
+
+	err := c.service.RemoveMember(ctx.Context(), id, userId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse[any]("Success Remove Member", nil))
+}
+
+func (c *notebookMemberController) Invite(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	var req dto.CreateNotebookInvitationRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+	req.NotebookId = id
+
+	err := serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.service.Invite(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Invite", res))
+}
+
+func (c *notebookMemberController) GetInvitations(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	res, err := c.service.GetInvitations(ctx.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Get List Invitation Success", res))
+}
+
+func (c *notebookMemberController) RevokeInvitation(ctx *fiber.Ctx) error {
+	id, _ := uuid.Parse(ctx.Params("id"))
+	invitationId, _ := uuid.Parse(ctx.Params("invitationId"))
+
+	err := c.service.RevokeInvitation(ctx.Context(), id, invitationId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse[any]("Success Revoke Invitation", nil))
+}
+
+func (c *notebookMemberController) GetMyInvitations(ctx *fiber.Ctx) error {
+	res, err := c.service.GetMyInvitations(ctx.Context())
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Get List Invitation Success", res))
+}
+
+func (c *notebookMemberController) AcceptInvitation(ctx *fiber.Ctx) error {
+	return c.respondInvitation(ctx, true)
+}
+
+func (c *notebookMemberController) DeclineInvitation(ctx *fiber.Ctx) error {
+	return c.respondInvitation(ctx, false)
+}
+
+func (c *notebookMemberController) respondInvitation(ctx *fiber.Ctx, accept bool) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	res, err := c.service.RespondInvitation(ctx.Context(), &dto.RespondNotebookInvitationRequest{
+		Id:     id,
+		Accept: accept,
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Respond Invitation", res))
+}
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type IPromptTemplateController interface {
+	RegisterRoutes(r fiber.Router)
+	GetAll(ctx *fiber.Ctx) error
+	Show(ctx *fiber.Ctx) error
+	Create(ctx *fiber.Ctx) error
+	Update(ctx *fiber.Ctx) error
+	Delete(ctx *fiber.Ctx) error
+	CreateVersion(ctx *fiber.Ctx) error
+	SetDefault(ctx *fiber.Ctx) error
+}
+
+type promptTemplateController struct {
+	service service.IPromptTemplateService
+}
+
+func NewPromptTemplateController(service service.IPromptTemplateService) IPromptTemplateController {
+	return &promptTemplateController{service: service}
+}
+
+func (c *promptTemplateController) RegisterRoutes(r fiber.Router) {
+	h := r.Group("/v1")
+	h.Get("/prompt-template", serverutils.RequireUserSession(), c.GetAll)
+	h.Post("/prompt-template/create", serverutils.RequireUserSession(), c.Create)
+	h.Get("/prompt-template/:id", serverutils.RequireUserSession(), c.Show)
+	h.Put("/prompt-template/:id", serverutils.RequireUserSession(), c.Update)
+	h.Delete("/prompt-template/:id", serverutils.RequireUserSession(), c.Delete)
+	h.Post("/prompt-template/:id/version", serverutils.RequireUserSession(), c.CreateVersion)
+	h.Put("/prompt-template/:id/default", serverutils.RequireUserSession(), c.SetDefault)
+}
+
+func (c *promptTemplateController) GetAll(ctx *fiber.Ctx) error {
+	res, err := c.service.GetAll(ctx.Context())
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success", res))
+}
+
+func (c *promptTemplateController) Show(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	res, err := c.service.Show(ctx.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success", res))
+}
+
+func (c *promptTemplateController) Create(ctx *fiber.Ctx) error {
+	var req dto.CreatePromptTemplateRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	err := serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.service.Create(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Create Prompt Template", res))
+}
+
+func (c *promptTemplateController) Update(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	var req dto.UpdatePromptTemplateRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	req.Id = id
+
+	err := serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.service.Update(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Update Prompt Template", res))
+}
+
+func (c *promptTemplateController) Delete(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	err := c.service.Delete(ctx.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse[any]("Success Delete Prompt Template", nil))
+}
+
+func (c *promptTemplateController) CreateVersion(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	var req dto.CreatePromptTemplateVersionRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	req.Id = id
+
+	err := serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.service.CreateVersion(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Create Prompt Template Version", res))
+}
+
+func (c *promptTemplateController) SetDefault(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	err := c.service.SetDefault(ctx.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse[any]("Success Set Default Prompt Template", nil))
+}
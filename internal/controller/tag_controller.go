@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"ai-notetaking-be/internal/constant"
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type ITagController interface {
+	RegisterRoutes(r fiber.Router)
+	GetAll(ctx *fiber.Ctx) error
+	Create(ctx *fiber.Ctx) error
+	Update(ctx *fiber.Ctx) error
+	Delete(ctx *fiber.Ctx) error
+	Merge(ctx *fiber.Ctx) error
+	SetNoteTags(ctx *fiber.Ctx) error
+}
+
+type tagController struct {
+	service service.ITagService
+}
+
+func NewTagController(service service.ITagService) ITagController {
+	return &tagController{service: service}
+}
+
+func (c *tagController) RegisterRoutes(r fiber.Router) {
+	h := r.Group("/v1")
+	h.Get("/tag", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.GetAll)
+	h.Post("/tag/create", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Create)
+	h.Put("/tag/:id", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Update)
+	h.Delete("/tag/:id", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Delete)
+	h.Post("/tag/:id/merge", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.Merge)
+	h.Put("/note/:id/tags", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.SetNoteTags)
+}
+
+func (c *tagController) GetAll(ctx *fiber.Ctx) error {
+	res, err := c.service.GetAll(ctx.Context())
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Get List Tag Success", res))
+}
+
+func (c *tagController) Create(ctx *fiber.Ctx) error {
+	var req dto.CreateTagRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	err := serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.service.Create(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Create Tag", res))
+}
+
+func (c *tagController) Update(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	var req dto.UpdateTagRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	err := serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	req.Id = id
+	res, err := c.service.Update(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Update Tag", res))
+}
+
+func (c *tagController) Delete(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	err := c.service.Delete(ctx.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse[any]("Success Delete Tag", nil))
+}
+
+func (c *tagController) Merge(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	var req dto.MergeTagRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	err := serverutils.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	req.Id = id
+	res, err := c.service.Merge(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Merge Tag", res))
+}
+
+func (c *tagController) SetNoteTags(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	var req dto.SetNoteTagsRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	req.NoteId = id
+	res, err := c.service.SetNoteTags(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Set Note Tags", res))
+}
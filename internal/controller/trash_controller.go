@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"ai-notetaking-be/internal/constant"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type ITrashController interface {
+	RegisterRoutes(r fiber.Router)
+	GetAll(ctx *fiber.Ctx) error
+	RestoreNote(ctx *fiber.Ctx) error
+	RestoreNotebook(ctx *fiber.Ctx) error
+	RestoreChatSession(ctx *fiber.Ctx) error
+}
+
+type trashController struct {
+	service service.ITrashService
+}
+
+func NewTrashController(service service.ITrashService) ITrashController {
+	return &trashController{service: service}
+}
+
+func (c *trashController) RegisterRoutes(r fiber.Router) {
+	h := r.Group("/v1")
+	h.Get("/trash", serverutils.RequireScope(constant.ApiTokenScopeNotesRead), c.GetAll)
+	h.Post("/trash/note/:id/restore", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.RestoreNote)
+	h.Post("/trash/notebook/:id/restore", serverutils.RequireScope(constant.ApiTokenScopeNotesWrite), c.RestoreNotebook)
+	h.Post("/trash/chat-session/:id/restore", serverutils.RequireScope(constant.ApiTokenScopeChat), c.RestoreChatSession)
+}
+
+func (c *trashController) GetAll(ctx *fiber.Ctx) error {
+	res, err := c.service.GetAll(ctx.Context())
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Get Trash Success", res))
+}
+
+func (c *trashController) RestoreNote(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	res, err := c.service.RestoreNote(ctx.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Restore Note", res))
+}
+
+func (c *trashController) RestoreNotebook(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	res, err := c.service.RestoreNotebook(ctx.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Restore Notebook", res))
+}
+
+func (c *trashController) RestoreChatSession(ctx *fiber.Ctx) error {
+	idParam := ctx.Params("id")
+	id, _ := uuid.Parse(idParam)
+
+	res, err := c.service.RestoreChatSession(ctx.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success Restore Chat Session", res))
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type IUsageController interface {
+	RegisterRoutes(r fiber.Router)
+	GetReport(ctx *fiber.Ctx) error
+}
+
+type usageController struct {
+	service service.IUsageService
+}
+
+func NewUsageController(service service.IUsageService) IUsageController {
+	return &usageController{service: service}
+}
+
+func (c *usageController) RegisterRoutes(r fiber.Router) {
+	h := r.Group("/v1")
+	h.Get("/usage", serverutils.RequireUserSession(), c.GetReport)
+}
+
+func (c *usageController) GetReport(ctx *fiber.Ctx) error {
+	req := dto.GetUsageReportRequest{
+		From:    ctx.Query("from", ""),
+		To:      ctx.Query("to", ""),
+		Feature: ctx.Query("feature", ""),
+		Model:   ctx.Query("model", ""),
+	}
+
+	res, err := c.service.GetReport(ctx.Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(serverutils.SuccessResponse("Success", res))
+}
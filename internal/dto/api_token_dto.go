@@ -0,0 +1,32 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CreateApiTokenRequest struct {
+	Name          string   `json:"name" validate:"required,max=255"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=notes:read notes:write chat"`
+	ExpiresInDays *int     `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+// CreateApiTokenResponse is the only response that contains the token, it
+// cannot be read back later.
+type CreateApiTokenResponse struct {
+	Id        uuid.UUID  `json:"id"`
+	Token     string     `json:"token"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type ApiTokenResponse struct {
+	Id          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	TokenPrefix string     `json:"token_prefix"`
+	Scopes      []string   `json:"scopes"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -0,0 +1,38 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type GetAuditEventsRequest struct {
+	ActorId    string
+	Action     string
+	EntityType string
+	EntityId   string
+	NotebookId string
+	NoteId     string
+	From       string
+	To         string
+	Limit      int
+	Offset     int
+}
+
+type AuditFieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+type AuditEventResponse struct {
+	Id         uuid.UUID                    `json:"id"`
+	ActorId    *uuid.UUID                   `json:"actor_id"`
+	Action     string                       `json:"action"`
+	EntityType string                       `json:"entity_type"`
+	EntityId   uuid.UUID                    `json:"entity_id"`
+	NotebookId *uuid.UUID                   `json:"notebook_id"`
+	NoteId     *uuid.UUID                   `json:"note_id"`
+	Diff       map[string]*AuditFieldChange `json:"diff"`
+	RequestId  string                       `json:"request_id"`
+	CreatedAt  time.Time                    `json:"created_at"`
+}
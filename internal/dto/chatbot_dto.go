@@ -1,27 +1,45 @@
 package dto
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// CreateSessionRequest limits chat retrieval to the notes carrying every tag
+// of TagIds.
+type CreateSessionRequest struct {
+	PromptTemplateId *uuid.UUID        `json:"prompt_template_id"`
+	Persona          string            `json:"persona"`
+	Variables        map[string]string `json:"variables"`
+	TagIds           []uuid.UUID       `json:"tag_ids"`
+}
+
 type CreateSessionResponse struct {
-	Id uuid.UUID `json:"id"`
+	Id                      uuid.UUID  `json:"id"`
+	PromptTemplateVersionId *uuid.UUID `json:"prompt_template_version_id"`
 }
 
 type GetAllSessionResponse struct {
-	Id        uuid.UUID  `json:"id"`
-	Name      string     `json:"name"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt *time.Time `json:"updated_at"`
+	Id                      uuid.UUID   `json:"id"`
+	Name                    string      `json:"name"`
+	PromptTemplateVersionId *uuid.UUID  `json:"prompt_template_version_id"`
+	TagIds                  []uuid.UUID `json:"tag_ids"`
+	CreatedAt               time.Time   `json:"created_at"`
+	UpdatedAt               *time.Time  `json:"updated_at"`
 }
 
 type GetChatHistoryResponse struct {
-	Id        uuid.UUID `json:"id"`
-	Role      string    `json:"role"`
-	Chat      string    `json:"chat"`
-	CreatedAt time.Time `json:"created_at"`
+	Id          uuid.UUID  `json:"id"`
+	ParentId    *uuid.UUID `json:"parent_id"`
+	Role        string     `json:"role"`
+	Chat        string     `json:"chat"`
+	BranchIndex int        `json:"branch_index"`
+	BranchTotal int        `json:"branch_total"`
+	CreatedAt   time.Time  `json:"created_at"`
+
+	ToolCalls []*ChatToolCallResponse `json:"tool_calls,omitempty"`
 }
 
 type SendChatResponseChat struct {
@@ -29,6 +47,8 @@ type SendChatResponseChat struct {
 	Chat      string    `json:"chat"`
 	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
+
+	ToolCalls []*ChatToolCallResponse `json:"tool_calls,omitempty"`
 }
 
 type SendChatRequest struct {
@@ -41,8 +61,70 @@ type SendChatResponse struct {
 	Title         string                `json:"title"`
 	Send          *SendChatResponseChat `json:"send"`
 	Reply         *SendChatResponseChat `json:"reply"`
+
+	RetrievalPlan *RetrievalPlanResponse `json:"retrieval_plan,omitempty"`
+}
+
+type RetrievalPlanResponse struct {
+	ShouldRetrieve  bool     `json:"should_retrieve"`
+	StandaloneQuery string   `json:"standalone_query"`
+	SubQueries      []string `json:"sub_queries"`
+	Reasoning       string   `json:"reasoning"`
 }
 
 type DeleteSessionRequest struct {
 	ChatSessionId uuid.UUID `json:"chat_session_id"`
 }
+
+type SetSessionTagsRequest struct {
+	ChatSessionId uuid.UUID   `json:"chat_session_id" validate:"required"`
+	TagIds        []uuid.UUID `json:"tag_ids"`
+}
+
+type SetSessionTagsResponse struct {
+	ChatSessionId uuid.UUID   `json:"chat_session_id"`
+	TagIds        []uuid.UUID `json:"tag_ids"`
+}
+
+type RegenerateChatRequest struct {
+	ChatSessionId uuid.UUID `json:"chat_session_id" validate:"required"`
+}
+
+type EditChatRequest struct {
+	ChatSessionId uuid.UUID `json:"chat_session_id" validate:"required"`
+	ChatMessageId uuid.UUID `json:"chat_message_id" validate:"required"`
+	Chat          string    `json:"chat" validate:"required"`
+}
+
+type GetChatBranchesResponse struct {
+	Id        uuid.UUID `json:"id"`
+	Role      string    `json:"role"`
+	Chat      string    `json:"chat"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SwitchBranchRequest struct {
+	ChatSessionId uuid.UUID `json:"chat_session_id" validate:"required"`
+	ChatMessageId uuid.UUID `json:"chat_message_id" validate:"required"`
+}
+
+type ChatToolCallResponse struct {
+	Id        uuid.UUID       `json:"id"`
+	ToolName  string          `json:"tool_name"`
+	Arguments json.RawMessage `json:"arguments"`
+	Result    json.RawMessage `json:"result"`
+	Status    string          `json:"status"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type ConfirmToolCallRequest struct {
+	ChatToolCallId uuid.UUID `json:"chat_tool_call_id" validate:"required"`
+	Approve        bool      `json:"approve"`
+}
+
+type ConfirmToolCallResponse struct {
+	ChatSessionId uuid.UUID             `json:"chat_session_id"`
+	ToolCall      *ChatToolCallResponse `json:"tool_call"`
+	Reply         *SendChatResponseChat `json:"reply"`
+}
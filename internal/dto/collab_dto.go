@@ -0,0 +1,71 @@
+package dto
+
+import (
+	"ai-notetaking-be/pkg/crdt"
+
+	"github.com/google/uuid"
+)
+
+// CollabMessage is what clients send: edits as "ops", or their selection as
+// "cursor".
+type CollabMessage struct {
+	Type   string        `json:"type"`
+	Ops    []crdt.Op     `json:"ops,omitempty"`
+	Cursor *CollabCursor `json:"cursor,omitempty"`
+}
+
+// CollabCursor points at the characters the selection follows, nil for the
+// start of the note, so it stays in place while others edit.
+type CollabCursor struct {
+	Anchor *crdt.Id `json:"anchor"`
+	Head   *crdt.Id `json:"head"`
+}
+
+type CollabPeer struct {
+	ClientId string        `json:"client_id"`
+	UserId   uuid.UUID     `json:"user_id"`
+	CanEdit  bool          `json:"can_edit"`
+	Cursor   *CollabCursor `json:"cursor"`
+}
+
+// CollabSyncMessage replaces the document of the client, inserts of the
+// client must use ClientId and clocks above Clock.
+type CollabSyncMessage struct {
+	Type     string         `json:"type"`
+	ClientId string         `json:"client_id"`
+	CanEdit  bool           `json:"can_edit"`
+	Version  int            `json:"version"`
+	Clock    uint64         `json:"clock"`
+	Elements []crdt.Element `json:"elements"`
+	Peers    []*CollabPeer  `json:"peers"`
+}
+
+type CollabOpsMessage struct {
+	Type     string    `json:"type"`
+	ClientId string    `json:"client_id"`
+	Ops      []crdt.Op `json:"ops"`
+}
+
+type CollabCursorMessage struct {
+	Type     string        `json:"type"`
+	ClientId string        `json:"client_id"`
+	UserId   uuid.UUID     `json:"user_id"`
+	Cursor   *CollabCursor `json:"cursor"`
+}
+
+type CollabPresenceMessage struct {
+	Type  string        `json:"type"`
+	Peers []*CollabPeer `json:"peers"`
+}
+
+// CollabSavedMessage tells clients the note was written at Version, for
+// requests that send If-Match.
+type CollabSavedMessage struct {
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+}
+
+type CollabErrorMessage struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
@@ -1,6 +1,10 @@
 package dto
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type UploadFileRequest struct {
 	NoteId uuid.UUID `form:"note_id" validate:"required"`
@@ -10,3 +14,82 @@ type UploadFileResponse struct {
 	FileId   uuid.UUID `json:"file_id"`
 	FileName string    `json:"file_name"`
 }
+
+type FileResponse struct {
+	Id           uuid.UUID  `json:"id"`
+	FileName     string     `json:"file_name"`
+	OriginalName string     `json:"original_name"`
+	ContentType  string     `json:"content_type"`
+	Position     string     `json:"position"`
+	Sha256       *string    `json:"sha256"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    *time.Time `json:"updated_at"`
+}
+
+// RenameFileRequest only changes the name shown to users, the stored object
+// keeps its key.
+type RenameFileRequest struct {
+	NoteId       uuid.UUID
+	Id           uuid.UUID
+	OriginalName string `json:"original_name" validate:"required,max=255"`
+}
+
+type RenameFileResponse struct {
+	Id           uuid.UUID `json:"id"`
+	OriginalName string    `json:"original_name"`
+}
+
+// ReorderFileRequest places the attachment right before BeforeId or right
+// after AfterId, an attachment of the same note. Exactly one is set.
+type ReorderFileRequest struct {
+	NoteId   uuid.UUID
+	Id       uuid.UUID
+	BeforeId *uuid.UUID `json:"before_id"`
+	AfterId  *uuid.UUID `json:"after_id"`
+}
+
+type ReorderFileResponse struct {
+	Id       uuid.UUID `json:"id"`
+	Position string    `json:"position"`
+}
+
+// CreateUploadIntentRequest announces an attachment the client uploads
+// straight to storage. ContentType and Size are checked against the stored
+// object on completion.
+type CreateUploadIntentRequest struct {
+	NoteId      uuid.UUID `json:"note_id" validate:"required"`
+	FileName    string    `json:"file_name" validate:"required,max=255"`
+	ContentType string    `json:"content_type" validate:"required,max=255"`
+	Size        int64     `json:"size" validate:"required,gt=0"`
+}
+
+// CreateUploadIntentResponse tells the client how to upload: a Method request
+// to Url with Headers, before ExpiresAt.
+type CreateUploadIntentResponse struct {
+	UploadId  uuid.UUID         `json:"upload_id"`
+	Url       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// CreateUploadSessionRequest starts a resumable upload of Size bytes.
+type CreateUploadSessionRequest struct {
+	NoteId      uuid.UUID `json:"note_id" validate:"required"`
+	FileName    string    `json:"file_name" validate:"required,max=255"`
+	ContentType string    `json:"content_type" validate:"required,max=255"`
+	Size        int64     `json:"size" validate:"required,gt=0"`
+}
+
+// UploadSessionResponse tells how far a resumable upload got. Parts are
+// numbered from 1 and hold PartSize bytes, the last one the rest. Offset is
+// where the client resumes: the bytes up to the first missing part.
+type UploadSessionResponse struct {
+	UploadId      uuid.UUID `json:"upload_id"`
+	Status        string    `json:"status"`
+	Size          int64     `json:"size"`
+	PartSize      int64     `json:"part_size"`
+	PartCount     int32     `json:"part_count"`
+	UploadedParts []int32   `json:"uploaded_parts"`
+	Offset        int64     `json:"offset"`
+}
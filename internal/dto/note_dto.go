@@ -21,27 +21,91 @@ type ShowNoteResponse struct {
 	Title      string    `json:"title"`
 	Content    string    `json:"content"`
 	NotebookId uuid.UUID `json:"notebook_id"`
+	Version    int       `json:"version"`
 	CreatedAt  time.Time `json:"created_at"`
+
+	Tags []*NoteTagResponse `json:"tags"`
 }
 
+// UpdateNoteRequest takes Version from If-Match, nil when it is * and the
+// note is saved over any version.
 type UpdateNoteRequest struct {
-	Id        uuid.UUID
-	Title     string `json:"title" validate:"required"`
-	Content   string `json:"content"`
-	UpdatedAt time.Time
+	Id      uuid.UUID
+	Title   string `json:"title" validate:"required"`
+	Content string `json:"content"`
+	Version *int
 }
 
 type UpdateNoteResponse struct {
-	Id uuid.UUID
+	Id      uuid.UUID
+	Version int
 }
 
 type MoveNoteRequest struct {
 	Id         uuid.UUID
 	NotebookId *uuid.UUID `json:"notebook_id"`
+	Version    *int
 }
 
 type MoveNoteResponse struct {
-	Id uuid.UUID
+	Id      uuid.UUID
+	Version int
+}
+
+// ReorderNoteRequest places the note right before BeforeId or right after
+// AfterId, a note of the same notebook. Exactly one is set.
+type ReorderNoteRequest struct {
+	Id       uuid.UUID
+	BeforeId *uuid.UUID `json:"before_id"`
+	AfterId  *uuid.UUID `json:"after_id"`
+}
+
+type ReorderNoteResponse struct {
+	Id       uuid.UUID `json:"id"`
+	Position string    `json:"position"`
+}
+
+// ListNotesRequest filters the notes the user can read. Every field comes
+// from the query string: NotebookId limits the listing to one notebook, and
+// to its descendants as well when Subtree is "true". TagIds is a comma
+// separated list of tag ids, From and To bound the creation time in RFC 3339
+// and HasFile is "true" or "false". Cursor is the NextCursor of the previous
+// page.
+type ListNotesRequest struct {
+	NotebookId string
+	Subtree    string
+	TagIds     string
+	From       string
+	To         string
+	HasFile    string
+	Sort       string
+	Cursor     string
+	Limit      int
+}
+
+type ListNotesResponse struct {
+	Notes      []*NoteSummaryResponse `json:"notes"`
+	NextCursor *string                `json:"next_cursor"`
+}
+
+type NoteSummaryResponse struct {
+	Id         uuid.UUID          `json:"id"`
+	Title      string             `json:"title"`
+	Excerpt    string             `json:"excerpt"`
+	NotebookId uuid.UUID          `json:"notebook_id"`
+	Position   string             `json:"position"`
+	Version    int                `json:"version"`
+	HasFile    bool               `json:"has_file"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  *time.Time         `json:"updated_at"`
+	Tags       []*NoteTagResponse `json:"tags"`
+}
+
+// SemanticSearchRequest only searches notes carrying every tag of TagIds, a
+// comma separated list of tag ids.
+type SemanticSearchRequest struct {
+	Query  string
+	TagIds string
 }
 
 type SemanticSearchResponse struct {
@@ -51,9 +115,47 @@ type SemanticSearchResponse struct {
 	NotebookId uuid.UUID  `json:"notebook_id"`
 	CreatedAt  time.Time  `json:"created_at"`
 	UpdateAt   *time.Time `json:"updated_at"`
+
+	Tags []*NoteTagResponse `json:"tags"`
 }
 
 type ExtractPreviewResponse struct {
 	NoteId        uuid.UUID `json:"note_id"`
 	ExtractedText string    `json:"extracted_text"`
 }
+
+type NoteRevisionResponse struct {
+	Id             uuid.UUID  `json:"id"`
+	NoteId         uuid.UUID  `json:"note_id"`
+	Title          string     `json:"title"`
+	Source         string     `json:"source"`
+	AuthorId       *uuid.UUID `json:"author_id"`
+	RestoredFromId *uuid.UUID `json:"restored_from_id"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type ShowNoteRevisionResponse struct {
+	NoteRevisionResponse
+	Content string `json:"content"`
+}
+
+// GetNoteRevisionDiffRequest compares two revisions, an empty To compares
+// against the current note.
+type GetNoteRevisionDiffRequest struct {
+	NoteId uuid.UUID
+	From   string
+	To     string
+}
+
+type NoteRevisionDiffResponse struct {
+	FromId    uuid.UUID  `json:"from_id"`
+	ToId      *uuid.UUID `json:"to_id"`
+	FromTitle string     `json:"from_title"`
+	ToTitle   string     `json:"to_title"`
+	Diff      string     `json:"diff"`
+}
+
+type RestoreNoteRevisionResponse struct {
+	Id         uuid.UUID `json:"id"`
+	RevisionId uuid.UUID `json:"revision_id"`
+}
@@ -0,0 +1,32 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NoteSuggestionResponse struct {
+	Id           uuid.UUID  `json:"id"`
+	Kind         string     `json:"kind"`
+	TagId        *uuid.UUID `json:"tag_id"`
+	TagName      *string    `json:"tag_name"`
+	NotebookId   *uuid.UUID `json:"notebook_id"`
+	NotebookName *string    `json:"notebook_name"`
+	Confidence   float64    `json:"confidence"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// ResolveNoteSuggestionRequest accepts or rejects a suggestion. Accepting a
+// tag attaches it to the note, creating it first when new, accepting a
+// notebook moves the note there.
+type ResolveNoteSuggestionRequest struct {
+	NoteId uuid.UUID
+	Id     uuid.UUID
+	Status string `json:"status" validate:"required,oneof=accepted rejected"`
+}
+
+type ResolveNoteSuggestionResponse struct {
+	Id     uuid.UUID `json:"id"`
+	Status string    `json:"status"`
+}
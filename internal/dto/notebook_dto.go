@@ -15,40 +15,89 @@ type CreateNotebookResponse struct {
 	Id uuid.UUID `json:"id"`
 }
 
+// UpdateNotebookRequest takes Version from If-Match, nil when it is * and the
+// notebook is saved over any version.
 type UpdateNotebookRequest struct {
-	Id   uuid.UUID
-	Name string `json:"name" validate:"required"`
+	Id      uuid.UUID
+	Name    string `json:"name" validate:"required"`
+	Version *int
 }
 
 type UpdateNotebookResponse struct {
-	Id uuid.UUID `json:"id"`
+	Id      uuid.UUID `json:"id"`
+	Version int       `json:"version"`
 }
 
 type MoveNotebookRequest struct {
 	Id       uuid.UUID
 	ParentId *uuid.UUID `json:"Parent_id"`
+	Version  *int
+}
+
+// DeleteNotebookRequest takes Mode from the query string, one of cascade or
+// reparent, and Version from If-Match.
+type DeleteNotebookRequest struct {
+	Id      uuid.UUID
+	Mode    string
+	Version *int
 }
 
 type MoveNotebookResponse struct {
-	Id uuid.UUID `json:"id"`
+	Id      uuid.UUID `json:"id"`
+	Version int       `json:"version"`
 }
 
 type ShowNotebookResponse struct {
 	Id        uuid.UUID  `json:"id"`
 	Name      string     `json:"name"`
 	ParentId  *uuid.UUID `json:"parent_id"`
+	Role      string     `json:"role"`
+	Version   int        `json:"version"`
 	CreatedAt time.Time  `json:"created_at"`
 }
 
 type NoteFileDTO struct {
-	Name string `json:"original_name"`
-	Url  string `json:"url"`
+	Id   uuid.UUID `json:"id"`
+	Name string    `json:"original_name"`
+	Url  string    `json:"url"`
+}
+
+// GetAllNotebookRequest only lists the notes carrying every tag of TagIds, a
+// comma separated list of tag ids. Sort is one of manual, name, updated or
+// created, manual when empty.
+type GetAllNotebookRequest struct {
+	TagIds string
+	Sort   string
+}
+
+type GetNotebookTreeRequest struct {
+	Sort string
+}
+
+type GetNotebookNotesRequest struct {
+	Id   uuid.UUID
+	Sort string
+}
+
+// ReorderNotebookRequest places the notebook right before BeforeId or right
+// after AfterId, a notebook of the same parent. Exactly one is set.
+type ReorderNotebookRequest struct {
+	Id       uuid.UUID
+	BeforeId *uuid.UUID `json:"before_id"`
+	AfterId  *uuid.UUID `json:"after_id"`
+}
+
+type ReorderNotebookResponse struct {
+	Id       uuid.UUID `json:"id"`
+	Position string    `json:"position"`
 }
 
 type ListNotebookResponse struct {
 	Id        uuid.UUID  `json:"id"`
 	Name      string     `json:"name"`
 	ParentId  *uuid.UUID `json:"parent_id"`
+	Role      string     `json:"role"`
+	Position  string     `json:"position"`
 	CreatedAt time.Time  `json:"created_at"`
 	UpdateAt  *time.Time `json:"updated_at"`
 
@@ -60,6 +109,38 @@ type GetAllNotebookResponseNote struct {
 	Title     string        `json:"title"`
 	Content   string        `json:"content"`
 	Files     []NoteFileDTO `json:"files"`
+	Position  string        `json:"position"`
 	CreatedAt time.Time     `json:"created_at"`
 	UpdateAt  *time.Time    `json:"updated_at"`
+
+	Tags []*NoteTagResponse `json:"tags"`
+}
+
+// NotebookTreeResponse is a notebook with its children, without notes.
+// NoteCount only counts the notes directly in the notebook, LastModifiedAt
+// covers the whole subtree.
+type NotebookTreeResponse struct {
+	Id             uuid.UUID               `json:"id"`
+	Name           string                  `json:"name"`
+	ParentId       *uuid.UUID              `json:"parent_id"`
+	Role           string                  `json:"role"`
+	Position       string                  `json:"position"`
+	NoteCount      int                     `json:"note_count"`
+	LastModifiedAt time.Time               `json:"last_modified_at"`
+	Children       []*NotebookTreeResponse `json:"children"`
+}
+
+type NotebookPathResponse struct {
+	Id   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+type NotebookNoteResponse struct {
+	Id        uuid.UUID          `json:"id"`
+	Title     string             `json:"title"`
+	Version   int                `json:"version"`
+	Position  string             `json:"position"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt *time.Time         `json:"updated_at"`
+	Tags      []*NoteTagResponse `json:"tags"`
 }
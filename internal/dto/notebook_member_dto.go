@@ -0,0 +1,57 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NotebookMemberResponse struct {
+	UserId    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	IsCreator bool      `json:"is_creator"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UpdateNotebookMemberRequest struct {
+	NotebookId uuid.UUID
+	UserId     uuid.UUID
+	Role       string `json:"role" validate:"required,oneof=owner editor commenter viewer"`
+}
+
+type UpdateNotebookMemberResponse struct {
+	UserId uuid.UUID `json:"user_id"`
+	Role   string    `json:"role"`
+}
+
+type CreateNotebookInvitationRequest struct {
+	NotebookId uuid.UUID
+	Email      string `json:"email" validate:"required,email"`
+	Role       string `json:"role" validate:"required,oneof=owner editor commenter viewer"`
+}
+
+type CreateNotebookInvitationResponse struct {
+	Id uuid.UUID `json:"id"`
+}
+
+type NotebookInvitationResponse struct {
+	Id           uuid.UUID `json:"id"`
+	NotebookId   uuid.UUID `json:"notebook_id"`
+	NotebookName string    `json:"notebook_name"`
+	Email        string    `json:"email"`
+	Role         string    `json:"role"`
+	Status       string    `json:"status"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type RespondNotebookInvitationRequest struct {
+	Id     uuid.UUID
+	Accept bool
+}
+
+type RespondNotebookInvitationResponse struct {
+	NotebookId uuid.UUID `json:"notebook_id"`
+	Status     string    `json:"status"`
+}
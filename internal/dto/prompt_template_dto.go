@@ -0,0 +1,74 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CreatePromptTemplateRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+	UserPrompt  string `json:"user_prompt" validate:"required"`
+	ModelPrompt string `json:"model_prompt" validate:"required"`
+	IsDefault   bool   `json:"is_default"`
+}
+
+type CreatePromptTemplateResponse struct {
+	Id        uuid.UUID `json:"id"`
+	VersionId uuid.UUID `json:"version_id"`
+	Version   int       `json:"version"`
+}
+
+type UpdatePromptTemplateRequest struct {
+	Id          uuid.UUID
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+}
+
+type UpdatePromptTemplateResponse struct {
+	Id uuid.UUID `json:"id"`
+}
+
+type CreatePromptTemplateVersionRequest struct {
+	Id          uuid.UUID
+	UserPrompt  string `json:"user_prompt" validate:"required"`
+	ModelPrompt string `json:"model_prompt" validate:"required"`
+}
+
+type CreatePromptTemplateVersionResponse struct {
+	Id        uuid.UUID `json:"id"`
+	VersionId uuid.UUID `json:"version_id"`
+	Version   int       `json:"version"`
+}
+
+type ListPromptTemplateResponse struct {
+	Id          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	IsDefault   bool       `json:"is_default"`
+	IsBuiltIn   bool       `json:"is_built_in"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+}
+
+type ShowPromptTemplateResponse struct {
+	Id          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	IsDefault   bool       `json:"is_default"`
+	IsBuiltIn   bool       `json:"is_built_in"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+
+	Versions []*PromptTemplateVersionResponse `json:"versions"`
+}
+
+type PromptTemplateVersionResponse struct {
+	Id          uuid.UUID `json:"id"`
+	Version     int       `json:"version"`
+	UserPrompt  string    `json:"user_prompt"`
+	ModelPrompt string    `json:"model_prompt"`
+	Variables   []string  `json:"variables"`
+	CreatedAt   time.Time `json:"created_at"`
+}
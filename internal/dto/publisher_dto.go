@@ -4,4 +4,5 @@ import "github.com/google/uuid"
 
 type PublishEmbedNoteMessage struct {
 	NotedId uuid.UUID `json:"note_id"`
-}
\ No newline at end of file
+	UserId  uuid.UUID `json:"user_id"`
+}
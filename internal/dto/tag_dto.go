@@ -0,0 +1,60 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TagResponse struct {
+	Id        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	NoteCount int        `json:"note_count"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+type CreateTagRequest struct {
+	Name string `json:"name" validate:"required,max=64"`
+}
+
+type CreateTagResponse struct {
+	Id uuid.UUID `json:"id"`
+}
+
+type UpdateTagRequest struct {
+	Id   uuid.UUID
+	Name string `json:"name" validate:"required,max=64"`
+}
+
+type UpdateTagResponse struct {
+	Id uuid.UUID `json:"id"`
+}
+
+// MergeTagRequest moves every note and chat session of Id to TargetId, then
+// deletes Id.
+type MergeTagRequest struct {
+	Id       uuid.UUID
+	TargetId uuid.UUID `json:"target_id" validate:"required"`
+}
+
+type MergeTagResponse struct {
+	Id uuid.UUID `json:"id"`
+}
+
+type NoteTagResponse struct {
+	Id   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+// SetNoteTagsRequest replaces the tags of the user on the note, tags other
+// users attached are kept.
+type SetNoteTagsRequest struct {
+	NoteId uuid.UUID
+	TagIds []uuid.UUID `json:"tag_ids"`
+}
+
+type SetNoteTagsResponse struct {
+	Id   uuid.UUID          `json:"id"`
+	Tags []*NoteTagResponse `json:"tags"`
+}
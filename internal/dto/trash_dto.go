@@ -0,0 +1,50 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type GetTrashResponse struct {
+	RetentionDays int                         `json:"retention_days"`
+	Notes         []*TrashNoteResponse        `json:"notes"`
+	Notebooks     []*TrashNotebookResponse    `json:"notebooks"`
+	ChatSessions  []*TrashChatSessionResponse `json:"chat_sessions"`
+}
+
+type TrashNoteResponse struct {
+	Id         uuid.UUID `json:"id"`
+	Title      string    `json:"title"`
+	NotebookId uuid.UUID `json:"notebook_id"`
+	DeletedAt  time.Time `json:"deleted_at"`
+	PurgeAt    time.Time `json:"purge_at"`
+}
+
+type TrashNotebookResponse struct {
+	Id        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	ParentId  *uuid.UUID `json:"parent_id"`
+	DeletedAt time.Time  `json:"deleted_at"`
+	PurgeAt   time.Time  `json:"purge_at"`
+}
+
+type TrashChatSessionResponse struct {
+	Id        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+type RestoreNoteResponse struct {
+	Id uuid.UUID `json:"id"`
+}
+
+type RestoreNotebookResponse struct {
+	Id      uuid.UUID   `json:"id"`
+	NoteIds []uuid.UUID `json:"note_ids"`
+}
+
+type RestoreChatSessionResponse struct {
+	Id uuid.UUID `json:"id"`
+}
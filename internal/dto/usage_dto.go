@@ -0,0 +1,39 @@
+package dto
+
+type GetUsageReportRequest struct {
+	From    string
+	To      string
+	Feature string
+	Model   string
+}
+
+type UsageReportRow struct {
+	Day              string  `json:"day"`
+	Feature          string  `json:"feature"`
+	Model            string  `json:"model"`
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	EmbeddingTokens  int     `json:"embedding_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	AvgLatencyMs     float64 `json:"avg_latency_ms"`
+	Cost             float64 `json:"cost"`
+}
+
+type UsageReportTotal struct {
+	Key         string  `json:"key"`
+	Calls       int     `json:"calls"`
+	TotalTokens int     `json:"total_tokens"`
+	Cost        float64 `json:"cost"`
+}
+
+type GetUsageReportResponse struct {
+	From        string              `json:"from"`
+	To          string              `json:"to"`
+	Currency    string              `json:"currency"`
+	TotalTokens int                 `json:"total_tokens"`
+	TotalCost   float64             `json:"total_cost"`
+	Rows        []*UsageReportRow   `json:"rows"`
+	ByFeature   []*UsageReportTotal `json:"by_feature"`
+	ByModel     []*UsageReportTotal `json:"by_model"`
+}
@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditEvent struct {
+	Id         uuid.UUID
+	ActorId    *uuid.UUID
+	Action     string
+	EntityType string
+	EntityId   uuid.UUID
+	NotebookId *uuid.UUID
+	NoteId     *uuid.UUID
+	Diff       map[string]*AuditFieldChange
+	RequestId  string
+	CreatedAt  time.Time
+}
+
+type AuditFieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditEventFilter narrows GetAll, zero values match everything. Events are
+// only returned when VisibleTo is the actor or their notebook is one of
+// VisibleNotebookIds.
+type AuditEventFilter struct {
+	VisibleTo          uuid.UUID
+	VisibleNotebookIds []uuid.UUID
+	ActorId            *uuid.UUID
+	Action             string
+	EntityType         string
+	EntityId           *uuid.UUID
+	NotebookId         *uuid.UUID
+	NoteId             *uuid.UUID
+	From               *time.Time
+	To                 *time.Time
+	Limit              int
+	Offset             int
+}
@@ -11,6 +11,7 @@ type ChatMessage struct {
 	Role          string
 	Chat          string
 	ChatSessionId uuid.UUID
+	ParentId      *uuid.UUID
 	CreatedAt     time.Time
 	UpdatedAt     *time.Time
 	DeletedAt     *time.Time
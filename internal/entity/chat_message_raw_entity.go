@@ -11,6 +11,8 @@ type ChatMessageRaw struct {
 	Role          string
 	Chat          string
 	ChatSessionId uuid.UUID
+	ParentId      *uuid.UUID
+	ChatMessageId *uuid.UUID
 	CreatedAt     time.Time
 	UpdatedAt     *time.Time
 	DeletedAt     *time.Time
@@ -0,0 +1,18 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ChatRetrievalPlan struct {
+	Id              uuid.UUID
+	ChatSessionId   uuid.UUID
+	ChatMessageId   uuid.UUID
+	ShouldRetrieve  bool
+	StandaloneQuery string
+	SubQueries      []string
+	Reasoning       string
+	CreatedAt       time.Time
+}
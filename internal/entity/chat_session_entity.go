@@ -7,10 +7,14 @@ import (
 )
 
 type ChatSession struct {
-	Id        uuid.UUID
-	Title     string
-	CreatedAt time.Time
-	UpdatedAt *time.Time
-	DeletedAt *time.Time
-	IsDeleted bool
+	Id              uuid.UUID
+	Title           string
+	UserId          uuid.UUID
+	ActiveMessageId *uuid.UUID
+
+	PromptTemplateVersionId *uuid.UUID
+	CreatedAt               time.Time
+	UpdatedAt               *time.Time
+	DeletedAt               *time.Time
+	IsDeleted               bool
 }
@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ChatToolCall struct {
+	Id            uuid.UUID
+	ChatSessionId uuid.UUID
+	ChatMessageId uuid.UUID
+	ToolName      string
+	Arguments     json.RawMessage
+	Result        json.RawMessage
+	Status        string
+	CreatedAt     time.Time
+	UpdatedAt     *time.Time
+	DeletedAt     *time.Time
+	IsDeleted     bool
+}
@@ -0,0 +1,18 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileBlob is an object in storage shared by the files of UserId with the
+// same content, RefCount is how many of them there are.
+type FileBlob struct {
+	Bucket    string
+	FileName  string
+	Sha256    string
+	UserId    *uuid.UUID
+	RefCount  int
+	CreatedAt time.Time
+}
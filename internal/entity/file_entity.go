@@ -12,7 +12,10 @@ type File struct {
 	OriginalName string
 	Bucket       string
 	ContentType  string
+	Position     string
+	Sha256       *string
 	NoteId       uuid.UUID
+	UserId       uuid.UUID
 	CreatedAt    time.Time
 	UpdatedAt    *time.Time
 	DeletedAt    *time.Time
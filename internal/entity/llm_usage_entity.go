@@ -0,0 +1,37 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LlmUsage struct {
+	Id               uuid.UUID
+	Feature          string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	EmbeddingTokens  int
+	TotalTokens      int
+	// IsEstimated is set when the API did not report token counts and they
+	// were approximated from the input length.
+	IsEstimated   bool
+	UserId        *uuid.UUID
+	NoteId        *uuid.UUID
+	ChatSessionId *uuid.UUID
+	LatencyMs     int64
+	CreatedAt     time.Time
+}
+
+type LlmUsageSummary struct {
+	Day              time.Time
+	Feature          string
+	Model            string
+	Calls            int
+	PromptTokens     int
+	CompletionTokens int
+	EmbeddingTokens  int
+	TotalTokens      int
+	AvgLatencyMs     float64
+}
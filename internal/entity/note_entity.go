@@ -11,8 +11,47 @@ type Note struct {
 	Title      string
 	Content    string
 	NotebookId uuid.UUID
+	UserId     uuid.UUID
 	CreatedAt  time.Time
 	UpdatedAt  *time.Time
 	DeletedAt  *time.Time
 	IsDeleted  bool
+	Version    int
+	Position   string
+}
+
+// NoteSummary is a note as listed, Excerpt holds the start of its content.
+type NoteSummary struct {
+	Id         uuid.UUID
+	Title      string
+	Excerpt    string
+	NotebookId uuid.UUID
+	Position   string
+	Version    int
+	HasFile    bool
+	CreatedAt  time.Time
+	UpdatedAt  *time.Time
+}
+
+// NoteFilter narrows List to the notes of NotebookIds. Nil and empty values
+// match everything, From and To bound the creation time. After continues a
+// listing past the note it describes, in the order of Sort.
+type NoteFilter struct {
+	NotebookIds []uuid.UUID
+	TagIds      []uuid.UUID
+	From        *time.Time
+	To          *time.Time
+	HasFile     *bool
+	Sort        string
+	After       *NoteCursor
+	Limit       int
+}
+
+// NoteCursor holds the sort values of the last note of a page.
+type NoteCursor struct {
+	Id         uuid.UUID
+	NotebookId uuid.UUID
+	Position   string
+	Title      string
+	Time       time.Time
 }
@@ -0,0 +1,18 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NoteRevision struct {
+	Id             uuid.UUID
+	NoteId         uuid.UUID
+	Title          string
+	Content        string
+	Source         string
+	AuthorId       *uuid.UUID
+	RestoredFromId *uuid.UUID
+	CreatedAt      time.Time
+}
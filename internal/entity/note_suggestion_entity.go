@@ -0,0 +1,23 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NoteSuggestion is a tag or a notebook proposed for a note after indexing.
+// TagId is nil when the suggested tag does not exist yet.
+type NoteSuggestion struct {
+	Id         uuid.UUID
+	NoteId     uuid.UUID
+	UserId     uuid.UUID
+	Kind       string
+	TagId      *uuid.UUID
+	TagName    *string
+	NotebookId *uuid.UUID
+	Confidence float64
+	Status     string
+	CreatedAt  time.Time
+	ResolvedAt *time.Time
+}
@@ -10,8 +10,24 @@ type Notebook struct {
 	Id        uuid.UUID
 	Name      string
 	ParentId  *uuid.UUID
+	UserId    uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt *time.Time
 	DeletedAt *time.Time
 	IsDeleted bool
+	Version   int
+	Position  string
+}
+
+// NotebookTreeNode is a notebook as listed in the tree, without its notes.
+// LastModifiedAt is the latest change to the notebook or one of its notes.
+type NotebookTreeNode struct {
+	Id             uuid.UUID
+	Name           string
+	ParentId       *uuid.UUID
+	Depth          int
+	Position       string
+	NoteCount      int
+	CreatedAt      time.Time
+	LastModifiedAt time.Time
 }
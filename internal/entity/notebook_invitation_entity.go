@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NotebookInvitation struct {
+	Id          uuid.UUID
+	NotebookId  uuid.UUID
+	Email       string
+	UserId      *uuid.UUID
+	Role        string
+	Status      string
+	InvitedBy   uuid.UUID
+	CreatedAt   time.Time
+	RespondedAt *time.Time
+
+	// Filled by reads that join the notebook table.
+	NotebookName string
+}
@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NotebookMember struct {
+	Id         uuid.UUID
+	NotebookId uuid.UUID
+	UserId     uuid.UUID
+	Role       string
+	CreatedBy  *uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  *time.Time
+
+	// Filled by reads that join the user table.
+	Email string
+	Name  string
+}
+
+// NotebookGrant is one role a user holds on a notebook, either directly or
+// inherited from an ancestor.
+type NotebookGrant struct {
+	NotebookId uuid.UUID
+	Role       string
+}
@@ -0,0 +1,19 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PromptTemplate struct {
+	Id          uuid.UUID
+	UserId      *uuid.UUID
+	Name        string
+	Description string
+	IsDefault   bool
+	CreatedAt   time.Time
+	UpdatedAt   *time.Time
+	DeletedAt   *time.Time
+	IsDeleted   bool
+}
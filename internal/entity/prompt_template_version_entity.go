@@ -0,0 +1,17 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PromptTemplateVersion struct {
+	Id               uuid.UUID
+	PromptTemplateId uuid.UUID
+	Version          int
+	UserPrompt       string
+	ModelPrompt      string
+	Variables        []string
+	CreatedAt        time.Time
+}
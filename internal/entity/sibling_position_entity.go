@@ -0,0 +1,9 @@
+package entity
+
+import "github.com/google/uuid"
+
+// SiblingPosition is the manual position of an item among its siblings.
+type SiblingPosition struct {
+	Id       uuid.UUID
+	Position string
+}
@@ -0,0 +1,24 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Tag struct {
+	Id        uuid.UUID
+	UserId    uuid.UUID
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt *time.Time
+}
+
+// NoteTag is a tag attached to a note, with the name and owner of the tag.
+type NoteTag struct {
+	NoteId    uuid.UUID
+	TagId     uuid.UUID
+	UserId    uuid.UUID
+	Name      string
+	CreatedAt time.Time
+}
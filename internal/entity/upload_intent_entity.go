@@ -0,0 +1,24 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UploadIntent is an attachment the client uploads straight to storage
+// through a presigned URL. It becomes a File once completed.
+type UploadIntent struct {
+	Id           uuid.UUID
+	NoteId       uuid.UUID
+	UserId       uuid.UUID
+	Bucket       string
+	FileName     string
+	OriginalName string
+	ContentType  string
+	Size         int64
+	Status       string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+	CompletedAt  *time.Time
+}
@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UploadSession is a resumable upload of an attachment, stored as an S3
+// multipart upload until completed.
+type UploadSession struct {
+	Id                uuid.UUID
+	NoteId            uuid.UUID
+	UserId            uuid.UUID
+	Bucket            string
+	FileName          string
+	OriginalName      string
+	ContentType       string
+	Size              int64
+	PartSize          int64
+	MultipartUploadId string
+	Status            string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	CompletedAt       *time.Time
+}
+
+type UploadSessionPart struct {
+	UploadSessionId uuid.UUID
+	PartNumber      int32
+	ETag            string
+	Size            int64
+	CreatedAt       time.Time
+}
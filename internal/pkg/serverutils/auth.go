@@ -0,0 +1,140 @@
+package serverutils
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ApiTokenPrefix starts every personal API token, it tells them apart from
+// user JWTs in the Authorization header.
+const ApiTokenPrefix = "ntk_"
+
+type contextKey string
+
+const (
+	userIdContextKey         contextKey = "user_id"
+	apiTokenScopesContextKey contextKey = "api_token_scopes"
+)
+
+// AuthMiddleware rejects requests without a valid "Authorization: Bearer"
+// token. Personal API tokens go through verifyApiToken and keep their scopes
+// on the request for RequireScope, any other token is a user JWT checked by
+// verifyAccessToken. Services read the caller back with GetUserId.
+func AuthMiddleware(
+	verifyAccessToken func(ctx context.Context, token string) (uuid.UUID, error),
+	verifyApiToken func(ctx context.Context, token string) (uuid.UUID, []string, error),
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get(fiber.HeaderAuthorization)
+		token, found := strings.CutPrefix(header, "Bearer ")
+		token = strings.TrimSpace(token)
+		if !found || token == "" {
+			return ErrUnauthorized
+		}
+
+		if strings.HasPrefix(token, ApiTokenPrefix) {
+			userId, scopes, err := verifyApiToken(c.Context(), token)
+			if err != nil {
+				return err
+			}
+
+			c.Locals(userIdContextKey, userId)
+			c.Locals(apiTokenScopesContextKey, scopes)
+
+			return c.Next()
+		}
+
+		userId, err := verifyAccessToken(c.Context(), token)
+		if err != nil {
+			return err
+		}
+
+		c.Locals(userIdContextKey, userId)
+
+		return c.Next()
+	}
+}
+
+// RequireScope guards a route that API tokens may call when they were granted
+// scope. User JWTs are not limited by scopes.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scopes, isApiToken := c.Locals(apiTokenScopesContextKey).([]string)
+		if isApiToken && !slices.Contains(scopes, scope) {
+			return ErrForbidden
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireUserSession guards a route that API tokens may never call, such as
+// the management of the tokens themselves.
+func RequireUserSession() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		_, isApiToken := c.Locals(apiTokenScopesContextKey).([]string)
+		if isApiToken {
+			return ErrForbidden
+		}
+
+		return c.Next()
+	}
+}
+
+// Authenticate is AuthMiddleware for handlers outside of Fiber, it returns
+// ctx carrying the user of token and, for API tokens, their scopes.
+func Authenticate(
+	ctx context.Context,
+	token string,
+	verifyAccessToken func(ctx context.Context, token string) (uuid.UUID, error),
+	verifyApiToken func(ctx context.Context, token string) (uuid.UUID, []string, error),
+) (context.Context, error) {
+	if token == "" {
+		return nil, ErrUnauthorized
+	}
+
+	if strings.HasPrefix(token, ApiTokenPrefix) {
+		userId, scopes, err := verifyApiToken(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx = context.WithValue(ctx, apiTokenScopesContextKey, scopes)
+		return WithUserId(ctx, userId), nil
+	}
+
+	userId, err := verifyAccessToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return WithUserId(ctx, userId), nil
+}
+
+// HasScope is RequireScope for a context returned by Authenticate.
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, isApiToken := ctx.Value(apiTokenScopesContextKey).([]string)
+	return !isApiToken || slices.Contains(scopes, scope)
+}
+
+// WithUserId returns ctx acting as userId, for work done on behalf of a user
+// outside of their request.
+func WithUserId(ctx context.Context, userId uuid.UUID) context.Context {
+	return context.WithValue(ctx, userIdContextKey, userId)
+}
+
+// GetUserId returns the authenticated user of the request. Fiber's request
+// context exposes Locals through Value, so services can call it with the
+// context they receive from controllers.
+func GetUserId(ctx context.Context) (uuid.UUID, error) {
+	userId, ok := ctx.Value(userIdContextKey).(uuid.UUID)
+	if !ok {
+		return uuid.Nil, ErrUnauthorized
+	}
+
+	return userId, nil
+}
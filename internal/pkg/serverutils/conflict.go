@@ -0,0 +1,55 @@
+package serverutils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ConflictError is an ErrConflict carrying the current state of the resource,
+// so the client can merge its change without another request.
+type ConflictError struct {
+	Version int
+	Current any
+}
+
+func NewConflictError(version int, current any) *ConflictError {
+	return &ConflictError{Version: version, Current: current}
+}
+
+func (e *ConflictError) Error() string {
+	return ErrConflict.Error()
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+func FormatETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ParseIfMatch reads the version out of the If-Match header of a request
+// changing a versioned resource. The header is required, "*" explicitly
+// accepts any version and gives nil.
+func ParseIfMatch(header string) (*int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return nil, ErrPreconditionRequired
+	}
+	if header == "*" {
+		return nil, nil
+	}
+
+	tag := strings.TrimPrefix(header, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return nil, fmt.Errorf("%w: If-Match must be a single ETag", ErrBadRequest)
+	}
+
+	version, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: If-Match must be a single ETag", ErrBadRequest)
+	}
+
+	return &version, nil
+}
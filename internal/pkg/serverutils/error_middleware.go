@@ -1,10 +1,12 @@
 package serverutils
 
 import (
+	"ai-notetaking-be/pkg/llmclient"
 	"errors"
 	"fmt"
 	"log"
 	"runtime/debug"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -33,9 +35,61 @@ func ErrorHandlerMiddleware() fiber.Handler {
 		if errors.Is(err, ErrInvalidFile) {
 			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(fiber.StatusBadRequest, err.Error()))
 		}
+		if errors.Is(err, ErrBadRequest) {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(fiber.StatusBadRequest, err.Error()))
+		}
 		if errors.Is(err, ErrUnauthorized) {
 			return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(fiber.StatusUnauthorized, err.Error()))
 		}
+		if errors.Is(err, ErrForbidden) {
+			return c.Status(fiber.StatusForbidden).JSON(ErrorResponse(fiber.StatusForbidden, err.Error()))
+		}
+		var conflictErr *ConflictError
+		if errors.As(err, &conflictErr) {
+			c.Set(fiber.HeaderETag, FormatETag(conflictErr.Version))
+			return c.Status(fiber.StatusConflict).JSON(BaseResponse[any]{
+				Success: false,
+				Code:    fiber.StatusConflict,
+				Message: err.Error(),
+				Data:    conflictErr.Current,
+			})
+		}
+		if errors.Is(err, ErrConflict) {
+			return c.Status(fiber.StatusConflict).JSON(ErrorResponse(fiber.StatusConflict, err.Error()))
+		}
+		if errors.Is(err, ErrPreconditionRequired) {
+			return c.Status(fiber.StatusPreconditionRequired).JSON(ErrorResponse(fiber.StatusPreconditionRequired, err.Error()))
+		}
+
+		// 2. Handle AI Provider Errors
+		if errors.Is(err, llmclient.ErrRateLimited) {
+			var apiErr *llmclient.APIError
+			if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(apiErr.RetryAfter.Seconds()+0.5)))
+			}
+			return c.Status(fiber.StatusTooManyRequests).JSON(ErrorResponse(fiber.StatusTooManyRequests, llmclient.ErrRateLimited.Error()))
+		}
+		if errors.Is(err, llmclient.ErrQuotaExhausted) {
+			log.Printf("[LLM ERROR] %v", err)
+			return c.Status(fiber.StatusServiceUnavailable).JSON(ErrorResponse(fiber.StatusServiceUnavailable, llmclient.ErrQuotaExhausted.Error()))
+		}
+		if errors.Is(err, llmclient.ErrCircuitOpen) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(ErrorResponse(fiber.StatusServiceUnavailable, llmclient.ErrCircuitOpen.Error()))
+		}
+		if errors.Is(err, llmclient.ErrSafetyBlocked) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(ErrorResponse(fiber.StatusUnprocessableEntity, llmclient.ErrSafetyBlocked.Error()))
+		}
+		if errors.Is(err, llmclient.ErrTimeout) {
+			log.Printf("[LLM ERROR] %v", err)
+			return c.Status(fiber.StatusGatewayTimeout).JSON(ErrorResponse(fiber.StatusGatewayTimeout, llmclient.ErrTimeout.Error()))
+		}
+		if errors.Is(err, llmclient.ErrEmptyResponse) {
+			return c.Status(fiber.StatusBadGateway).JSON(ErrorResponse(fiber.StatusBadGateway, llmclient.ErrEmptyResponse.Error()))
+		}
+		if errors.Is(err, llmclient.ErrUpstream) {
+			log.Printf("[LLM ERROR] %v", err)
+			return c.Status(fiber.StatusBadGateway).JSON(ErrorResponse(fiber.StatusBadGateway, llmclient.ErrUpstream.Error()))
+		}
 
 		if fiberErr, ok := err.(*fiber.Error); ok {
 			return c.Status(fiberErr.Code).JSON(ErrorResponse(
@@ -5,7 +5,12 @@ import "errors"
 var (
 	ErrNotFound     = errors.New("the requested resource was not found")
 	ErrUnauthorized = errors.New("you are not authorized to access this resource")
+	ErrForbidden    = errors.New("you do not have permission to perform this action")
 	ErrInvalidFile  = errors.New("invalid file type or corrupted content")
 	ErrInternal     = errors.New("something went wrong on our end, please try again later")
 	ErrBadRequest   = errors.New("the request could not be processed due to invalid input")
+	ErrConflict     = errors.New("the resource was changed by someone else, reload it and try again")
+	// ErrPreconditionRequired asks for an If-Match header, without one a
+	// client would silently save over the edits of others.
+	ErrPreconditionRequired = errors.New("send the ETag of the version you edited as If-Match, or * to overwrite any version")
 )
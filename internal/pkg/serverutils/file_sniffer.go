@@ -0,0 +1,73 @@
+package serverutils
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+)
+
+const (
+	// SniffHeadLength is how much of the start of a file SniffContentType
+	// wants, more than the 512 bytes http.DetectContentType looks at.
+	SniffHeadLength = 8 * 1024
+	// SniffTailLength is how much of the end of a file SniffContentType
+	// wants.
+	SniffTailLength = 1024
+)
+
+var executableSignatures = [][]byte{
+	[]byte("MZ"),               // Windows PE
+	[]byte("\x7fELF"),          // Linux ELF
+	[]byte("\xfe\xed\xfa\xce"), // Mach-O 32 bit
+	[]byte("\xfe\xed\xfa\xcf"), // Mach-O 64 bit
+	[]byte("\xce\xfa\xed\xfe"), // Mach-O 32 bit, little endian
+	[]byte("\xcf\xfa\xed\xfe"), // Mach-O 64 bit, little endian
+	[]byte("\xca\xfe\xba\xbe"), // Mach-O universal, Java class
+	[]byte("#!"),               // script
+}
+
+var markupSignatures = [][]byte{
+	[]byte("<!doctype html"),
+	[]byte("<html"),
+	[]byte("<script"),
+	[]byte("<iframe"),
+	[]byte("<svg"),
+}
+
+// SniffContentType returns the media type of a file from its first
+// SniffHeadLength and last SniffTailLength bytes, without parameters. On top
+// of http.DetectContentType it recognizes executables, markup further into
+// the file than 512 bytes, and PDFs whose header is preceded by garbage. A
+// PDF without its end-of-file marker is reported as
+// application/octet-stream, it is truncated or not a PDF.
+func SniffContentType(head []byte, tail []byte) string {
+	for _, signature := range executableSignatures {
+		if bytes.HasPrefix(head, signature) {
+			return "application/x-executable"
+		}
+	}
+
+	// Readers accept the header anywhere in the first 1024 bytes.
+	if bytes.Contains(head[:min(len(head), 1024)], []byte("%PDF-")) {
+		if bytes.Contains(tail, []byte("%%EOF")) {
+			return "application/pdf"
+		}
+		return "application/octet-stream"
+	}
+
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return "application/octet-stream"
+	}
+
+	if mediaType == "text/plain" {
+		lower := bytes.ToLower(head)
+		for _, signature := range markupSignatures {
+			if bytes.Contains(lower, signature) {
+				return "text/html"
+			}
+		}
+	}
+
+	return mediaType
+}
@@ -49,3 +49,18 @@ func ExtractTextPerPage(reader io.Reader) ([]PdfPage, error) {
 
 	return pages, nil
 }
+
+// CountPdfPages returns the number of pages of the PDF read from reader.
+func CountPdfPages(reader io.Reader) (int, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, err
+	}
+
+	content, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return 0, err
+	}
+
+	return content.NumPage(), nil
+}
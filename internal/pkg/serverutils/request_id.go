@@ -0,0 +1,25 @@
+package serverutils
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+const requestIdContextKey contextKey = "request_id"
+
+// RequestIdMiddleware keeps the X-Request-ID of the caller, or generates one,
+// and echoes it on the response so audit events can be traced to a request.
+func RequestIdMiddleware() fiber.Handler {
+	return requestid.New(requestid.Config{
+		ContextKey: requestIdContextKey,
+	})
+}
+
+// GetRequestId returns the ID of the request, or an empty string outside of
+// an HTTP request such as in the consumer.
+func GetRequestId(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdContextKey).(string)
+	return requestId
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/pkg/database"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IApiTokenRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) IApiTokenRepository
+	Create(ctx context.Context, apiToken *entity.ApiToken) error
+	GetByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.ApiToken, error)
+	GetByTokenHash(ctx context.Context, tokenHash string) (*entity.ApiToken, error)
+	Revoke(ctx context.Context, userId uuid.UUID, id uuid.UUID) error
+	Touch(ctx context.Context, id uuid.UUID, usedAt time.Time) error
+}
+
+type apiTokenRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewApiTokenRepository(db *pgxpool.Pool) IApiTokenRepository {
+	return &apiTokenRepository{
+		db: db,
+	}
+}
+
+func (n *apiTokenRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) IApiTokenRepository {
+	return &apiTokenRepository{
+		db: tx,
+	}
+}
+
+func (n *apiTokenRepository) Create(ctx context.Context, apiToken *entity.ApiToken) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO api_token (id, user_id, name, token_hash, token_prefix, scopes, expires_at, last_used_at, revoked_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		apiToken.Id,
+		apiToken.UserId,
+		apiToken.Name,
+		apiToken.TokenHash,
+		apiToken.TokenPrefix,
+		apiToken.Scopes,
+		apiToken.ExpiresAt,
+		apiToken.LastUsedAt,
+		apiToken.RevokedAt,
+		apiToken.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *apiTokenRepository) GetByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.ApiToken, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id, user_id, name, token_hash, token_prefix, scopes, expires_at, last_used_at, revoked_at, created_at FROM api_token WHERE user_id = $1 ORDER BY created_at DESC`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*entity.ApiToken, 0)
+	for rows.Next() {
+		apiToken, err := scanApiToken(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, apiToken)
+	}
+
+	return res, nil
+}
+
+func (n *apiTokenRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*entity.ApiToken, error) {
+	row := n.db.QueryRow(
+		ctx,
+		`SELECT id, user_id, name, token_hash, token_prefix, scopes, expires_at, last_used_at, revoked_at, created_at FROM api_token WHERE token_hash = $1`,
+		tokenHash,
+	)
+
+	return scanApiToken(row)
+}
+
+func (n *apiTokenRepository) Revoke(ctx context.Context, userId uuid.UUID, id uuid.UUID) error {
+	tag, err := n.db.Exec(
+		ctx,
+		`UPDATE api_token SET revoked_at = $1 WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`,
+		time.Now(),
+		id,
+		userId,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return serverutils.ErrNotFound
+	}
+
+	return nil
+}
+
+// Touch records a use of the token at most once a minute, so scripts calling
+// the API in a loop do not turn every request into a write.
+func (n *apiTokenRepository) Touch(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE api_token SET last_used_at = $1 WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < $3)`,
+		usedAt,
+		id,
+		usedAt.Add(-time.Minute),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func scanApiToken(row pgx.Row) (*entity.ApiToken, error) {
+	var apiToken entity.ApiToken
+	err := row.Scan(
+		&apiToken.Id,
+		&apiToken.UserId,
+		&apiToken.Name,
+		&apiToken.TokenHash,
+		&apiToken.TokenPrefix,
+		&apiToken.Scopes,
+		&apiToken.ExpiresAt,
+		&apiToken.LastUsedAt,
+		&apiToken.RevokedAt,
+		&apiToken.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &apiToken, nil
+}
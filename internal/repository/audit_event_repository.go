@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/pkg/database"
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IAuditEventRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) IAuditEventRepository
+	Create(ctx context.Context, auditEvent *entity.AuditEvent) error
+	GetAll(ctx context.Context, filter *entity.AuditEventFilter) ([]*entity.AuditEvent, error)
+}
+
+type auditEventRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewAuditEventRepository(db *pgxpool.Pool) IAuditEventRepository {
+	return &auditEventRepository{
+		db: db,
+	}
+}
+
+func (n *auditEventRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) IAuditEventRepository {
+	return &auditEventRepository{
+		db: tx,
+	}
+}
+
+func (n *auditEventRepository) Create(ctx context.Context, auditEvent *entity.AuditEvent) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO audit_event (id, actor_id, action, entity_type, entity_id, notebook_id, note_id, diff, request_id, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		auditEvent.Id,
+		auditEvent.ActorId,
+		auditEvent.Action,
+		auditEvent.EntityType,
+		auditEvent.EntityId,
+		auditEvent.NotebookId,
+		auditEvent.NoteId,
+		auditEvent.Diff,
+		auditEvent.RequestId,
+		auditEvent.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetAll returns the newest events first.
+func (n *auditEventRepository) GetAll(ctx context.Context, filter *entity.AuditEventFilter) ([]*entity.AuditEvent, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id, actor_id, action, entity_type, entity_id, notebook_id, note_id, diff, request_id, created_at
+		FROM audit_event
+		WHERE (actor_id = $1 OR notebook_id = ANY($2))
+			AND ($3::uuid IS NULL OR actor_id = $3)
+			AND ($4 = '' OR action = $4)
+			AND ($5 = '' OR entity_type = $5)
+			AND ($6::uuid IS NULL OR entity_id = $6)
+			AND ($7::uuid IS NULL OR notebook_id = $7)
+			AND ($8::uuid IS NULL OR note_id = $8)
+			AND ($9::timestamp IS NULL OR created_at >= $9)
+			AND ($10::timestamp IS NULL OR created_at < $10)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $11 OFFSET $12`,
+		filter.VisibleTo,
+		filter.VisibleNotebookIds,
+		filter.ActorId,
+		filter.Action,
+		filter.EntityType,
+		filter.EntityId,
+		filter.NotebookId,
+		filter.NoteId,
+		filter.From,
+		filter.To,
+		filter.Limit,
+		filter.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*entity.AuditEvent, 0)
+	for rows.Next() {
+		var auditEvent entity.AuditEvent
+		err = rows.Scan(
+			&auditEvent.Id,
+			&auditEvent.ActorId,
+			&auditEvent.Action,
+			&auditEvent.EntityType,
+			&auditEvent.EntityId,
+			&auditEvent.NotebookId,
+			&auditEvent.NoteId,
+			&auditEvent.Diff,
+			&auditEvent.RequestId,
+			&auditEvent.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &auditEvent)
+	}
+
+	return res, nil
+}
@@ -15,6 +15,8 @@ type IChatMessageRawRepository interface {
 	Create(ctx context.Context, chatMessageRaw *entity.ChatMessageRaw) error
 	GetChatBySessionId(ctx context.Context, sessionId uuid.UUID) ([]*entity.ChatMessageRaw, error)
 	DeleteBySessionId(ctx context.Context, chatSessionId uuid.UUID) error
+	RestoreBySessionId(ctx context.Context, chatSessionId uuid.UUID) error
+	HardDeleteBySessionIds(ctx context.Context, chatSessionIds []uuid.UUID) error
 }
 
 type chatmessagerawRepository struct {
@@ -30,11 +32,13 @@ func (n *chatmessagerawRepository) UsingTx(ctx context.Context, tx database.Data
 func (n *chatmessagerawRepository) Create(ctx context.Context, chatMessageRaw *entity.ChatMessageRaw) error {
 	_, err := n.db.Exec(
 		ctx,
-		`INSERT INTO chat_message_raw (id, role, chat, chat_session_id, created_at, updated_at, deleted_at, is_deleted) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		`INSERT INTO chat_message_raw (id, role, chat, chat_session_id, parent_id, chat_message_id, created_at, updated_at, deleted_at, is_deleted) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
 		chatMessageRaw.Id,
 		chatMessageRaw.Role,
 		chatMessageRaw.Chat,
 		chatMessageRaw.ChatSessionId,
+		chatMessageRaw.ParentId,
+		chatMessageRaw.ChatMessageId,
 		chatMessageRaw.CreatedAt,
 		chatMessageRaw.UpdatedAt,
 		chatMessageRaw.DeletedAt,
@@ -50,9 +54,13 @@ func (n *chatmessagerawRepository) Create(ctx context.Context, chatMessageRaw *e
 func (n *chatmessagerawRepository) GetChatBySessionId(ctx context.Context, sessionId uuid.UUID) ([]*entity.ChatMessageRaw, error) {
 	rows, err := n.db.Query(
 		ctx,
-		`SELECT id, role, chat, chat_session_id, created_at, updated_at, deleted_at, is_deleted FROM chat_message_raw WHERE chat_session_id = $1 AND is_deleted = false ORDER BY created_at ASC`,
+		`SELECT id, role, chat, chat_session_id, parent_id, chat_message_id, created_at, updated_at, deleted_at, is_deleted FROM chat_message_raw WHERE chat_session_id = $1 AND is_deleted = false ORDER BY created_at ASC`,
 		sessionId,
 	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
 	res := make([]*entity.ChatMessageRaw, 0)
 
@@ -63,6 +71,8 @@ func (n *chatmessagerawRepository) GetChatBySessionId(ctx context.Context, sessi
 			&chatMessage.Role,
 			&chatMessage.Chat,
 			&chatMessage.ChatSessionId,
+			&chatMessage.ParentId,
+			&chatMessage.ChatMessageId,
 			&chatMessage.CreatedAt,
 			&chatMessage.UpdatedAt,
 			&chatMessage.DeletedAt,
@@ -98,3 +108,29 @@ func NewChatMessageRawRepository(db *pgxpool.Pool) IChatMessageRawRepository {
 		db: db,
 	}
 }
+
+func (n *chatmessagerawRepository) RestoreBySessionId(ctx context.Context, chatSessionId uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE chat_message_raw SET deleted_at = null, is_deleted = false WHERE chat_session_id = $1`,
+		chatSessionId,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *chatmessagerawRepository) HardDeleteBySessionIds(ctx context.Context, chatSessionIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM chat_message_raw WHERE chat_session_id = ANY($1)`,
+		chatSessionIds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -14,6 +14,8 @@ type IChatMessageRepository interface {
 	UsingTx(ctx context.Context, tx database.DatabaseQueryer) IChatMessageRepository
 	Create(ctx context.Context, chatMessage *entity.ChatMessage) error
 	DeleteBySessionId(ctx context.Context, chatSessionId uuid.UUID) error
+	RestoreBySessionId(ctx context.Context, chatSessionId uuid.UUID) error
+	HardDeleteBySessionIds(ctx context.Context, chatSessionIds []uuid.UUID) error
 }
 
 type chatmessageRepository struct {
@@ -29,11 +31,12 @@ func (n *chatmessageRepository) UsingTx(ctx context.Context, tx database.Databas
 func (n *chatmessageRepository) Create(ctx context.Context, chatMessage *entity.ChatMessage) error {
 	_, err := n.db.Exec(
 		ctx,
-		`INSERT INTO chat_message (id, role, chat, chat_session_id, created_at, updated_at, deleted_at, is_deleted) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		`INSERT INTO chat_message (id, role, chat, chat_session_id, parent_id, created_at, updated_at, deleted_at, is_deleted) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
 		chatMessage.Id,
 		chatMessage.Role,
 		chatMessage.Chat,
 		chatMessage.ChatSessionId,
+		chatMessage.ParentId,
 		chatMessage.CreatedAt,
 		chatMessage.UpdatedAt,
 		chatMessage.DeletedAt,
@@ -65,3 +68,29 @@ func NewChatMessageRepository(db *pgxpool.Pool) IChatMessageRepository {
 		db: db,
 	}
 }
+
+func (n *chatmessageRepository) RestoreBySessionId(ctx context.Context, chatSessionId uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE chat_message SET deleted_at = null, is_deleted = false WHERE chat_session_id = $1`,
+		chatSessionId,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *chatmessageRepository) HardDeleteBySessionIds(ctx context.Context, chatSessionIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM chat_message WHERE chat_session_id = ANY($1)`,
+		chatSessionIds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/pkg/database"
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IChatRetrievalPlanRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) IChatRetrievalPlanRepository
+	Create(ctx context.Context, chatRetrievalPlan *entity.ChatRetrievalPlan) error
+	HardDeleteBySessionIds(ctx context.Context, chatSessionIds []uuid.UUID) error
+}
+
+type chatRetrievalPlanRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewChatRetrievalPlanRepository(db *pgxpool.Pool) IChatRetrievalPlanRepository {
+	return &chatRetrievalPlanRepository{
+		db: db,
+	}
+}
+
+func (n *chatRetrievalPlanRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) IChatRetrievalPlanRepository {
+	return &chatRetrievalPlanRepository{
+		db: tx,
+	}
+}
+
+func (n *chatRetrievalPlanRepository) Create(ctx context.Context, chatRetrievalPlan *entity.ChatRetrievalPlan) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO chat_retrieval_plan (id, chat_session_id, chat_message_id, should_retrieve, standalone_query, sub_queries, reasoning, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		chatRetrievalPlan.Id,
+		chatRetrievalPlan.ChatSessionId,
+		chatRetrievalPlan.ChatMessageId,
+		chatRetrievalPlan.ShouldRetrieve,
+		chatRetrievalPlan.StandaloneQuery,
+		chatRetrievalPlan.SubQueries,
+		chatRetrievalPlan.Reasoning,
+		chatRetrievalPlan.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *chatRetrievalPlanRepository) HardDeleteBySessionIds(ctx context.Context, chatSessionIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM chat_retrieval_plan WHERE chat_session_id = ANY($1)`,
+		chatSessionIds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -2,11 +2,14 @@ package repository
 
 import (
 	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
 	"ai-notetaking-be/pkg/database"
 	"context"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -14,10 +17,16 @@ type IChatSessionRepository interface {
 	UsingTx(ctx context.Context, tx database.DatabaseQueryer) IChatSessionRepository
 	Create(ctx context.Context, chatSession *entity.ChatSession) error
 	Update(ctx context.Context, chatSession *entity.ChatSession) error
-	Delete(ctx context.Context, sessionId uuid.UUID) error
-	GetAllSession(ctx context.Context) ([]*entity.ChatSession, error)
-	GetSessionById(ctx context.Context, sessionId uuid.UUID) (*entity.ChatSession, error)
+	Delete(ctx context.Context, userId uuid.UUID, sessionId uuid.UUID) error
+	GetAllSession(ctx context.Context, userId uuid.UUID) ([]*entity.ChatSession, error)
+	GetSessionById(ctx context.Context, userId uuid.UUID, sessionId uuid.UUID) (*entity.ChatSession, error)
 	GetChatBySessionId(ctx context.Context, sessionId uuid.UUID) ([]*entity.ChatMessage, error)
+	GetDeletedByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.ChatSession, error)
+	GetDeletedById(ctx context.Context, userId uuid.UUID, sessionId uuid.UUID) (*entity.ChatSession, error)
+	Restore(ctx context.Context, userId uuid.UUID, sessionId uuid.UUID) error
+	GetPurgeableIds(ctx context.Context, deletedBefore time.Time, limit int) ([]uuid.UUID, error)
+	ClearActiveMessageByIds(ctx context.Context, sessionIds []uuid.UUID) error
+	HardDeleteByIds(ctx context.Context, sessionIds []uuid.UUID) error
 }
 
 type chatbotRepository struct {
@@ -33,9 +42,12 @@ func (n *chatbotRepository) UsingTx(ctx context.Context, tx database.DatabaseQue
 func (n *chatbotRepository) Create(ctx context.Context, chatSession *entity.ChatSession) error {
 	_, err := n.db.Exec(
 		ctx,
-		`INSERT INTO chat_session (id, title, created_at, updated_at, deleted_at, is_deleted) VALUES ($1, $2, $3, $4, $5, $6)`,
+		`INSERT INTO chat_session (id, title, user_id, active_message_id, prompt_template_version_id, created_at, updated_at, deleted_at, is_deleted) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
 		chatSession.Id,
 		chatSession.Title,
+		chatSession.UserId,
+		chatSession.ActiveMessageId,
+		chatSession.PromptTemplateVersionId,
 		chatSession.CreatedAt,
 		chatSession.UpdatedAt,
 		chatSession.DeletedAt,
@@ -48,10 +60,11 @@ func (n *chatbotRepository) Create(ctx context.Context, chatSession *entity.Chat
 	return nil
 }
 
-func (n *chatbotRepository) GetAllSession(ctx context.Context) ([]*entity.ChatSession, error) {
+func (n *chatbotRepository) GetAllSession(ctx context.Context, userId uuid.UUID) ([]*entity.ChatSession, error) {
 	rows, err := n.db.Query(
 		ctx,
-		`SELECT id, title, created_at, updated_at, deleted_at, is_deleted FROM chat_session WHERE is_deleted = false ORDER BY created_at DESC`,
+		`SELECT id, title, user_id, active_message_id, prompt_template_version_id, created_at, updated_at, deleted_at, is_deleted FROM chat_session WHERE is_deleted = false AND user_id = $1 ORDER BY created_at DESC`,
+		userId,
 	)
 	if err != nil {
 		return nil, err
@@ -64,6 +77,9 @@ func (n *chatbotRepository) GetAllSession(ctx context.Context) ([]*entity.ChatSe
 		err = rows.Scan(
 			&chatSession.Id,
 			&chatSession.Title,
+			&chatSession.UserId,
+			&chatSession.ActiveMessageId,
+			&chatSession.PromptTemplateVersionId,
 			&chatSession.CreatedAt,
 			&chatSession.UpdatedAt,
 			&chatSession.DeletedAt,
@@ -80,17 +96,21 @@ func (n *chatbotRepository) GetAllSession(ctx context.Context) ([]*entity.ChatSe
 	return res, err
 }
 
-func (n *chatbotRepository) GetSessionById(ctx context.Context, sessionId uuid.UUID) (*entity.ChatSession, error) {
+func (n *chatbotRepository) GetSessionById(ctx context.Context, userId uuid.UUID, sessionId uuid.UUID) (*entity.ChatSession, error) {
 	rows := n.db.QueryRow(
 		ctx,
-		`SELECT id, title, created_at, updated_at, deleted_at, is_deleted FROM chat_session WHERE id = $1 AND is_deleted = false`,
+		`SELECT id, title, user_id, active_message_id, prompt_template_version_id, created_at, updated_at, deleted_at, is_deleted FROM chat_session WHERE id = $1 AND user_id = $2 AND is_deleted = false`,
 		sessionId,
+		userId,
 	)
 
 	var chatSession entity.ChatSession
 	err := rows.Scan(
 		&chatSession.Id,
 		&chatSession.Title,
+		&chatSession.UserId,
+		&chatSession.ActiveMessageId,
+		&chatSession.PromptTemplateVersionId,
 		&chatSession.CreatedAt,
 		&chatSession.UpdatedAt,
 		&chatSession.DeletedAt,
@@ -98,6 +118,9 @@ func (n *chatbotRepository) GetSessionById(ctx context.Context, sessionId uuid.U
 	)
 
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
 		return nil, err
 	}
 
@@ -107,9 +130,13 @@ func (n *chatbotRepository) GetSessionById(ctx context.Context, sessionId uuid.U
 func (n *chatbotRepository) GetChatBySessionId(ctx context.Context, sessionId uuid.UUID) ([]*entity.ChatMessage, error) {
 	rows, err := n.db.Query(
 		ctx,
-		`SELECT id, role, chat, chat_session_id, created_at, updated_at, deleted_at, is_deleted FROM chat_message WHERE chat_session_id = $1 AND is_deleted = false ORDER BY created_at ASC`,
+		`SELECT id, role, chat, chat_session_id, parent_id, created_at, updated_at, deleted_at, is_deleted FROM chat_message WHERE chat_session_id = $1 AND is_deleted = false ORDER BY created_at ASC`,
 		sessionId,
 	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
 	res := make([]*entity.ChatMessage, 0)
 
@@ -120,6 +147,7 @@ func (n *chatbotRepository) GetChatBySessionId(ctx context.Context, sessionId uu
 			&chatMessage.Role,
 			&chatMessage.Chat,
 			&chatMessage.ChatSessionId,
+			&chatMessage.ParentId,
 			&chatMessage.CreatedAt,
 			&chatMessage.UpdatedAt,
 			&chatMessage.DeletedAt,
@@ -139,10 +167,12 @@ func (n *chatbotRepository) GetChatBySessionId(ctx context.Context, sessionId uu
 func (n *chatbotRepository) Update(ctx context.Context, chatSession *entity.ChatSession) error {
 	_, err := n.db.Exec(
 		ctx,
-		`UPDATE chat_session SET title = $1, updated_at = $2 WHERE id = $3`,
+		`UPDATE chat_session SET title = $1, active_message_id = $2, updated_at = $3 WHERE id = $4 AND user_id = $5`,
 		chatSession.Title,
+		chatSession.ActiveMessageId,
 		chatSession.UpdatedAt,
 		chatSession.Id,
+		chatSession.UserId,
 	)
 	if err != nil {
 		return err
@@ -151,12 +181,149 @@ func (n *chatbotRepository) Update(ctx context.Context, chatSession *entity.Chat
 	return nil
 }
 
-func (n *chatbotRepository) Delete(ctx context.Context, sessionId uuid.UUID) error {
+func (n *chatbotRepository) Delete(ctx context.Context, userId uuid.UUID, sessionId uuid.UUID) error {
 	_, err := n.db.Exec(
 		ctx,
-		`UPDATE chat_session SET deleted_at = $1, is_deleted = true WHERE id = $2`,
+		`UPDATE chat_session SET deleted_at = $1, is_deleted = true WHERE id = $2 AND user_id = $3 AND is_deleted = false`,
 		time.Now(),
 		sessionId,
+		userId,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *chatbotRepository) GetDeletedByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.ChatSession, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id, title, user_id, active_message_id, prompt_template_version_id, created_at, updated_at, deleted_at, is_deleted FROM chat_session WHERE is_deleted = true AND user_id = $1 ORDER BY deleted_at DESC`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*entity.ChatSession, 0)
+	for rows.Next() {
+		var chatSession entity.ChatSession
+		err = rows.Scan(
+			&chatSession.Id,
+			&chatSession.Title,
+			&chatSession.UserId,
+			&chatSession.ActiveMessageId,
+			&chatSession.PromptTemplateVersionId,
+			&chatSession.CreatedAt,
+			&chatSession.UpdatedAt,
+			&chatSession.DeletedAt,
+			&chatSession.IsDeleted,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &chatSession)
+	}
+
+	return res, nil
+}
+
+func (n *chatbotRepository) GetDeletedById(ctx context.Context, userId uuid.UUID, sessionId uuid.UUID) (*entity.ChatSession, error) {
+	row := n.db.QueryRow(
+		ctx,
+		`SELECT id, title, user_id, active_message_id, prompt_template_version_id, created_at, updated_at, deleted_at, is_deleted FROM chat_session WHERE id = $1 AND user_id = $2 AND is_deleted = true`,
+		sessionId,
+		userId,
+	)
+
+	var chatSession entity.ChatSession
+	err := row.Scan(
+		&chatSession.Id,
+		&chatSession.Title,
+		&chatSession.UserId,
+		&chatSession.ActiveMessageId,
+		&chatSession.PromptTemplateVersionId,
+		&chatSession.CreatedAt,
+		&chatSession.UpdatedAt,
+		&chatSession.DeletedAt,
+		&chatSession.IsDeleted,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &chatSession, nil
+}
+
+func (n *chatbotRepository) Restore(ctx context.Context, userId uuid.UUID, sessionId uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE chat_session SET deleted_at = null, is_deleted = false WHERE id = $1 AND user_id = $2`,
+		sessionId,
+		userId,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *chatbotRepository) GetPurgeableIds(ctx context.Context, deletedBefore time.Time, limit int) ([]uuid.UUID, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id FROM chat_session WHERE is_deleted = true AND deleted_at < $1 ORDER BY deleted_at ASC LIMIT $2`,
+		deletedBefore,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, id)
+	}
+
+	return res, nil
+}
+
+// ClearActiveMessageByIds drops the reference to the active message, so the
+// messages of the sessions can be removed before the sessions themselves.
+func (n *chatbotRepository) ClearActiveMessageByIds(ctx context.Context, sessionIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE chat_session SET active_message_id = null WHERE id = ANY($1)`,
+		sessionIds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// HardDeleteByIds expects the messages, raw messages, tool calls and
+// retrieval plans of the sessions to be gone already.
+func (n *chatbotRepository) HardDeleteByIds(ctx context.Context, sessionIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM chat_session WHERE id = ANY($1)`,
+		sessionIds,
 	)
 	if err != nil {
 		return err
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"ai-notetaking-be/pkg/database"
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IChatSessionTagRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) IChatSessionTagRepository
+	Set(ctx context.Context, chatSessionId uuid.UUID, tagIds []uuid.UUID) error
+	GetTagIdsBySessionIds(ctx context.Context, chatSessionIds []uuid.UUID) (map[uuid.UUID][]uuid.UUID, error)
+	Merge(ctx context.Context, fromTagId uuid.UUID, toTagId uuid.UUID) error
+	DeleteByTagId(ctx context.Context, tagId uuid.UUID) error
+	HardDeleteBySessionIds(ctx context.Context, chatSessionIds []uuid.UUID) error
+}
+
+type chatSessionTagRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewChatSessionTagRepository(db *pgxpool.Pool) IChatSessionTagRepository {
+	return &chatSessionTagRepository{
+		db: db,
+	}
+}
+
+func (n *chatSessionTagRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) IChatSessionTagRepository {
+	return &chatSessionTagRepository{
+		db: tx,
+	}
+}
+
+// Set replaces the tags of the session.
+func (n *chatSessionTagRepository) Set(ctx context.Context, chatSessionId uuid.UUID, tagIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM chat_session_tag WHERE chat_session_id = $1`,
+		chatSessionId,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.db.Exec(
+		ctx,
+		`INSERT INTO chat_session_tag (chat_session_id, tag_id) SELECT $1, unnest($2::uuid[]) ON CONFLICT DO NOTHING`,
+		chatSessionId,
+		tagIds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *chatSessionTagRepository) GetTagIdsBySessionIds(ctx context.Context, chatSessionIds []uuid.UUID) (map[uuid.UUID][]uuid.UUID, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT chat_session_id, tag_id FROM chat_session_tag WHERE chat_session_id = ANY($1)`,
+		chatSessionIds,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[uuid.UUID][]uuid.UUID)
+	for rows.Next() {
+		var chatSessionId, tagId uuid.UUID
+		err = rows.Scan(&chatSessionId, &tagId)
+		if err != nil {
+			return nil, err
+		}
+
+		res[chatSessionId] = append(res[chatSessionId], tagId)
+	}
+
+	return res, rows.Err()
+}
+
+// Merge moves the sessions of fromTagId to toTagId.
+func (n *chatSessionTagRepository) Merge(ctx context.Context, fromTagId uuid.UUID, toTagId uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO chat_session_tag (chat_session_id, tag_id) SELECT chat_session_id, $2 FROM chat_session_tag WHERE tag_id = $1 ON CONFLICT DO NOTHING`,
+		fromTagId,
+		toTagId,
+	)
+	if err != nil {
+		return err
+	}
+
+	return n.DeleteByTagId(ctx, fromTagId)
+}
+
+func (n *chatSessionTagRepository) DeleteByTagId(ctx context.Context, tagId uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM chat_session_tag WHERE tag_id = $1`,
+		tagId,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *chatSessionTagRepository) HardDeleteBySessionIds(ctx context.Context, chatSessionIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM chat_session_tag WHERE chat_session_id = ANY($1)`,
+		chatSessionIds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
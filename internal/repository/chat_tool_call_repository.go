@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/pkg/database"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IChatToolCallRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) IChatToolCallRepository
+	Create(ctx context.Context, chatToolCall *entity.ChatToolCall) error
+	Update(ctx context.Context, chatToolCall *entity.ChatToolCall) error
+	Claim(ctx context.Context, id uuid.UUID, updatedAt time.Time) error
+	Fail(ctx context.Context, id uuid.UUID, result []byte, updatedAt time.Time) error
+	GetById(ctx context.Context, id uuid.UUID) (*entity.ChatToolCall, error)
+	GetBySessionId(ctx context.Context, chatSessionId uuid.UUID) ([]*entity.ChatToolCall, error)
+	DeleteBySessionId(ctx context.Context, chatSessionId uuid.UUID) error
+	RestoreBySessionId(ctx context.Context, chatSessionId uuid.UUID) error
+	HardDeleteBySessionIds(ctx context.Context, chatSessionIds []uuid.UUID) error
+}
+
+type chatToolCallRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewChatToolCallRepository(db *pgxpool.Pool) IChatToolCallRepository {
+	return &chatToolCallRepository{
+		db: db,
+	}
+}
+
+func (n *chatToolCallRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) IChatToolCallRepository {
+	return &chatToolCallRepository{
+		db: tx,
+	}
+}
+
+func (n *chatToolCallRepository) Create(ctx context.Context, chatToolCall *entity.ChatToolCall) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO chat_tool_call (id, chat_session_id, chat_message_id, tool_name, arguments, result, status, created_at, updated_at, deleted_at, is_deleted) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		chatToolCall.Id,
+		chatToolCall.ChatSessionId,
+		chatToolCall.ChatMessageId,
+		chatToolCall.ToolName,
+		chatToolCall.Arguments,
+		chatToolCall.Result,
+		chatToolCall.Status,
+		chatToolCall.CreatedAt,
+		chatToolCall.UpdatedAt,
+		chatToolCall.DeletedAt,
+		chatToolCall.IsDeleted,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *chatToolCallRepository) Update(ctx context.Context, chatToolCall *entity.ChatToolCall) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE chat_tool_call SET result = $1, status = $2, updated_at = $3 WHERE id = $4`,
+		chatToolCall.Result,
+		chatToolCall.Status,
+		chatToolCall.UpdatedAt,
+		chatToolCall.Id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Claim moves a call waiting for confirmation to executing, it returns
+// ErrConflict when the call was confirmed or rejected already.
+func (n *chatToolCallRepository) Claim(ctx context.Context, id uuid.UUID, updatedAt time.Time) error {
+	tag, err := n.db.Exec(
+		ctx,
+		`UPDATE chat_tool_call SET status = 'executing', updated_at = $2 WHERE id = $1 AND status = 'pending_confirmation' AND is_deleted = false`,
+		id,
+		updatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return serverutils.ErrConflict
+	}
+
+	return nil
+}
+
+// Fail moves a call stuck in executing to failed with result, it returns
+// ErrConflict when the call is not executing.
+func (n *chatToolCallRepository) Fail(ctx context.Context, id uuid.UUID, result []byte, updatedAt time.Time) error {
+	tag, err := n.db.Exec(
+		ctx,
+		`UPDATE chat_tool_call SET status = 'failed', result = $2, updated_at = $3 WHERE id = $1 AND status = 'executing'`,
+		id,
+		result,
+		updatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return serverutils.ErrConflict
+	}
+
+	return nil
+}
+
+func (n *chatToolCallRepository) GetById(ctx context.Context, id uuid.UUID) (*entity.ChatToolCall, error) {
+	row := n.db.QueryRow(
+		ctx,
+		`SELECT id, chat_session_id, chat_message_id, tool_name, arguments, result, status, created_at, updated_at, deleted_at, is_deleted FROM chat_tool_call WHERE id = $1 AND is_deleted = false`,
+		id,
+	)
+
+	var chatToolCall entity.ChatToolCall
+	err := row.Scan(
+		&chatToolCall.Id,
+		&chatToolCall.ChatSessionId,
+		&chatToolCall.ChatMessageId,
+		&chatToolCall.ToolName,
+		&chatToolCall.Arguments,
+		&chatToolCall.Result,
+		&chatToolCall.Status,
+		&chatToolCall.CreatedAt,
+		&chatToolCall.UpdatedAt,
+		&chatToolCall.DeletedAt,
+		&chatToolCall.IsDeleted,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &chatToolCall, nil
+}
+
+func (n *chatToolCallRepository) GetBySessionId(ctx context.Context, chatSessionId uuid.UUID) ([]*entity.ChatToolCall, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id, chat_session_id, chat_message_id, tool_name, arguments, result, status, created_at, updated_at, deleted_at, is_deleted FROM chat_tool_call WHERE chat_session_id = $1 AND is_deleted = false ORDER BY created_at ASC`,
+		chatSessionId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*entity.ChatToolCall, 0)
+	for rows.Next() {
+		var chatToolCall entity.ChatToolCall
+		err := rows.Scan(
+			&chatToolCall.Id,
+			&chatToolCall.ChatSessionId,
+			&chatToolCall.ChatMessageId,
+			&chatToolCall.ToolName,
+			&chatToolCall.Arguments,
+			&chatToolCall.Result,
+			&chatToolCall.Status,
+			&chatToolCall.CreatedAt,
+			&chatToolCall.UpdatedAt,
+			&chatToolCall.DeletedAt,
+			&chatToolCall.IsDeleted,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &chatToolCall)
+	}
+
+	return res, nil
+}
+
+func (n *chatToolCallRepository) DeleteBySessionId(ctx context.Context, chatSessionId uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE chat_tool_call SET deleted_at = $1, is_deleted = true WHERE chat_session_id = $2`,
+		time.Now(),
+		chatSessionId,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *chatToolCallRepository) RestoreBySessionId(ctx context.Context, chatSessionId uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE chat_tool_call SET deleted_at = null, is_deleted = false WHERE chat_session_id = $1`,
+		chatSessionId,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *chatToolCallRepository) HardDeleteBySessionIds(ctx context.Context, chatSessionIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM chat_tool_call WHERE chat_session_id = ANY($1)`,
+		chatSessionIds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
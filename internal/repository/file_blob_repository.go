@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/pkg/database"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IFileBlobRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) IFileBlobRepository
+	GetBySha256(ctx context.Context, userId uuid.UUID, bucket string, sha256 string) (*entity.FileBlob, error)
+	GetRefCount(ctx context.Context, bucket string, fileName string) (int, error)
+	Acquire(ctx context.Context, userId uuid.UUID, bucket string, fileName string, sha256 string) error
+	Release(ctx context.Context, bucket string, fileName string, count int) (int, error)
+}
+
+type fileBlobRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewFileBlobRepository(db *pgxpool.Pool) IFileBlobRepository {
+	return &fileBlobRepository{
+		db: db,
+	}
+}
+
+func (n *fileBlobRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) IFileBlobRepository {
+	return &fileBlobRepository{
+		db: tx,
+	}
+}
+
+// GetBySha256 returns an object the user stored in the bucket with that
+// content, the oldest when concurrent uploads stored it twice. Objects of
+// other users are never returned. The row stays locked until the end of the
+// transaction so the object cannot be released meanwhile.
+func (n *fileBlobRepository) GetBySha256(ctx context.Context, userId uuid.UUID, bucket string, sha256 string) (*entity.FileBlob, error) {
+	var fileBlob entity.FileBlob
+	err := n.db.QueryRow(
+		ctx,
+		`SELECT bucket, file_name, sha256, user_id, ref_count, created_at
+		FROM file_blob
+		WHERE bucket = $1 AND user_id = $2 AND sha256 = $3 AND ref_count > 0
+		ORDER BY created_at ASC
+		LIMIT 1
+		FOR UPDATE`,
+		bucket,
+		userId,
+		sha256,
+	).Scan(
+		&fileBlob.Bucket,
+		&fileBlob.FileName,
+		&fileBlob.Sha256,
+		&fileBlob.UserId,
+		&fileBlob.RefCount,
+		&fileBlob.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &fileBlob, nil
+}
+
+// GetRefCount returns how many files point at the object, 0 for an object
+// without a row.
+func (n *fileBlobRepository) GetRefCount(ctx context.Context, bucket string, fileName string) (int, error) {
+	var refCount int
+	err := n.db.QueryRow(
+		ctx,
+		`SELECT COALESCE(MAX(ref_count), 0) FROM file_blob WHERE bucket = $1 AND file_name = $2`,
+		bucket,
+		fileName,
+	).Scan(&refCount)
+	if err != nil {
+		return 0, err
+	}
+
+	return refCount, nil
+}
+
+// Acquire counts one more file pointing at the object, creating its row for
+// the first one with userId as the owner.
+func (n *fileBlobRepository) Acquire(ctx context.Context, userId uuid.UUID, bucket string, fileName string, sha256 string) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO file_blob (bucket, file_name, sha256, user_id, ref_count, created_at)
+		VALUES ($1, $2, $3, $4, 1, $5)
+		ON CONFLICT (bucket, file_name) DO UPDATE SET ref_count = file_blob.ref_count + 1`,
+		bucket,
+		fileName,
+		sha256,
+		userId,
+		time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Release counts count fewer files pointing at the object and returns how
+// many are left. The row goes once none is, the caller then deletes the
+// object. An object without a row has none left.
+func (n *fileBlobRepository) Release(ctx context.Context, bucket string, fileName string, count int) (int, error) {
+	var refCount int
+	err := n.db.QueryRow(
+		ctx,
+		`UPDATE file_blob SET ref_count = ref_count - $3 WHERE bucket = $1 AND file_name = $2 RETURNING ref_count`,
+		bucket,
+		fileName,
+		count,
+	).Scan(&refCount)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	if refCount > 0 {
+		return refCount, nil
+	}
+
+	_, err = n.db.Exec(
+		ctx,
+		`DELETE FROM file_blob WHERE bucket = $1 AND file_name = $2`,
+		bucket,
+		fileName,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testTx opens a transaction on the migrated database of
+// TEST_DB_CONNECTION_STRING, rolled back when the test ends. The test is
+// skipped without one.
+func testTx(t *testing.T) pgx.Tx {
+	t.Helper()
+
+	connectionString := os.Getenv("TEST_DB_CONNECTION_STRING")
+	if connectionString == "" {
+		t.Skip("TEST_DB_CONNECTION_STRING is not set")
+	}
+
+	ctx := context.Background()
+	db, err := pgxpool.New(ctx, connectionString)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	t.Cleanup(func() {
+		tx.Rollback(ctx)
+	})
+
+	return tx
+}
+
+func createTestUser(t *testing.T, tx pgx.Tx) uuid.UUID {
+	t.Helper()
+
+	user := &entity.User{
+		Id:           uuid.New(),
+		Email:        uuid.NewString() + "@example.com",
+		Name:         "Test",
+		PasswordHash: "x",
+		CreatedAt:    time.Now(),
+	}
+
+	ctx := context.Background()
+	err := (&userRepository{}).UsingTx(ctx, tx).Create(ctx, user)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	return user.Id
+}
+
+func TestFileBlobGetBySha256IsScopedToTheUser(t *testing.T) {
+	tx := testTx(t)
+	ctx := context.Background()
+	repo := (&fileBlobRepository{}).UsingTx(ctx, tx)
+
+	owner := createTestUser(t, tx)
+	other := createTestUser(t, tx)
+	bucket := "test-" + uuid.NewString()
+	sha256 := strings.Repeat("a", 64)
+
+	err := repo.Acquire(ctx, owner, bucket, "owner.pdf", sha256)
+	if err != nil {
+		t.Fatalf("Acquire returned error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		userId   uuid.UUID
+		wantName string
+		wantErr  error
+	}{
+		{name: "owner reuses the object", userId: owner, wantName: "owner.pdf"},
+		{name: "another user does not see it", userId: other, wantErr: serverutils.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileBlob, err := repo.GetBySha256(ctx, tt.userId, bucket, sha256)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetBySha256 error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && fileBlob.FileName != tt.wantName {
+				t.Errorf("GetBySha256 returned %q, want %q", fileBlob.FileName, tt.wantName)
+			}
+		})
+	}
+
+	// The other user's own upload gets its own object, and each only finds
+	// theirs.
+	err = repo.Acquire(ctx, other, bucket, "other.pdf", sha256)
+	if err != nil {
+		t.Fatalf("Acquire returned error: %v", err)
+	}
+
+	for userId, want := range map[uuid.UUID]string{owner: "owner.pdf", other: "other.pdf"} {
+		fileBlob, err := repo.GetBySha256(ctx, userId, bucket, sha256)
+		if err != nil {
+			t.Fatalf("GetBySha256 returned error: %v", err)
+		}
+		if fileBlob.FileName != want {
+			t.Errorf("GetBySha256 for %s returned %q, want %q", userId, fileBlob.FileName, want)
+		}
+	}
+}
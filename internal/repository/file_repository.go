@@ -8,6 +8,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -17,10 +18,20 @@ import (
 type IFileRepository interface {
 	UsingTx(ctx context.Context, tx database.DatabaseQueryer) IFileRepository
 	Create(ctx context.Context, file *entity.File) error
-	GetByNoteId(ctx context.Context, noteId uuid.UUID) (*entity.File, error)
+	GetById(ctx context.Context, id uuid.UUID) (*entity.File, error)
+	GetByNoteId(ctx context.Context, noteId uuid.UUID) ([]*entity.File, error)
+	GetByFileName(ctx context.Context, fileName string) (*entity.File, error)
 	DeleteByNoteId(ctx context.Context, noteId uuid.UUID) error
 	GetByNoteIds(ctx context.Context, noteIds []uuid.UUID) ([]*entity.File, error)
 	DeleteByNotebookId(ctx context.Context, notebookId uuid.UUID) error
+	RestoreByNoteIds(ctx context.Context, noteIds []uuid.UUID) error
+	GetAllByNoteIds(ctx context.Context, noteIds []uuid.UUID) ([]*entity.File, error)
+	HardDeleteByNoteIds(ctx context.Context, noteIds []uuid.UUID) error
+	HardDelete(ctx context.Context, id uuid.UUID) error
+	UpdateOriginalName(ctx context.Context, id uuid.UUID, originalName string) error
+	GetLastPosition(ctx context.Context, noteId uuid.UUID) (string, error)
+	GetSiblingPositions(ctx context.Context, noteId uuid.UUID) ([]*entity.SiblingPosition, error)
+	UpdatePosition(ctx context.Context, id uuid.UUID, position string) error
 }
 
 type fileRepository struct {
@@ -38,37 +49,114 @@ func (r *fileRepository) UsingTx(ctx context.Context, tx database.DatabaseQuerye
 func (r *fileRepository) Create(ctx context.Context, file *entity.File) error {
 	_, err := r.db.Exec(
 		ctx,
-		`INSERT INTO file (id, file_name, original_name, bucket, content_type, note_id, created_at) 
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		`INSERT INTO file (id, file_name, original_name, bucket, content_type, note_id, user_id, created_at, position, sha256) 
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
 		file.Id,
 		file.FileName,
 		file.OriginalName,
 		file.Bucket,
 		file.ContentType,
 		file.NoteId,
+		file.UserId,
 		file.CreatedAt,
+		file.Position,
+		file.Sha256,
 	)
 	return err
 }
 
-func (r *fileRepository) GetByNoteId(ctx context.Context, noteId uuid.UUID) (*entity.File, error) {
+func (r *fileRepository) GetById(ctx context.Context, id uuid.UUID) (*entity.File, error) {
 	row := r.db.QueryRow(
 		ctx,
-		`SELECT id, file_name, original_name, bucket, content_type, note_id, created_at 
-         FROM file 
-         WHERE note_id = $1`,
+		`SELECT id, file_name, original_name, bucket, content_type, note_id, user_id, created_at, updated_at, position, sha256
+         FROM file
+         WHERE id = $1 AND is_deleted = false`,
+		id,
+	)
+
+	var f entity.File
+	err := row.Scan(
+		&f.Id,
+		&f.FileName,
+		&f.OriginalName,
+		&f.Bucket,
+		&f.ContentType,
+		&f.NoteId,
+		&f.UserId,
+		&f.CreatedAt,
+		&f.UpdatedAt,
+		&f.Position,
+		&f.Sha256,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+	return &f, nil
+}
+
+// GetByNoteId returns the live attachments of the note in their order.
+func (r *fileRepository) GetByNoteId(ctx context.Context, noteId uuid.UUID) ([]*entity.File, error) {
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT id, file_name, original_name, bucket, content_type, note_id, user_id, created_at, updated_at, position, sha256
+         FROM file
+         WHERE note_id = $1 AND is_deleted = false
+         ORDER BY position ASC, id ASC`,
 		noteId,
 	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := make([]*entity.File, 0)
+	for rows.Next() {
+		var f entity.File
+		err := rows.Scan(
+			&f.Id,
+			&f.FileName,
+			&f.OriginalName,
+			&f.Bucket,
+			&f.ContentType,
+			&f.NoteId,
+			&f.UserId,
+			&f.CreatedAt,
+			&f.UpdatedAt,
+			&f.Position,
+			&f.Sha256,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, &f)
+	}
+
+	return files, rows.Err()
+}
+
+func (r *fileRepository) GetByFileName(ctx context.Context, fileName string) (*entity.File, error) {
+	row := r.db.QueryRow(
+		ctx,
+		`SELECT id, file_name, original_name, bucket, content_type, note_id, user_id, created_at 
+         FROM file 
+         WHERE file_name = $1 AND is_deleted = false`,
+		fileName,
+	)
 
 	var f entity.File
-	// PERBAIKAN: Masukkan f.OriginalName sesuai urutan SELECT (ke-3)
 	err := row.Scan(
 		&f.Id,
 		&f.FileName,
-		&f.OriginalName, // Kolom ke-3
+		&f.OriginalName,
 		&f.Bucket,
 		&f.ContentType,
 		&f.NoteId,
+		&f.UserId,
 		&f.CreatedAt,
 	)
 
@@ -81,10 +169,13 @@ func (r *fileRepository) GetByNoteId(ctx context.Context, noteId uuid.UUID) (*en
 	return &f, nil
 }
 
+// DeleteByNoteId moves the files of the note to the trash, the objects stay
+// in S3 until the purge job removes them.
 func (r *fileRepository) DeleteByNoteId(ctx context.Context, noteId uuid.UUID) error {
 	_, err := r.db.Exec(
 		ctx,
-		`DELETE FROM file WHERE note_id = $1`,
+		`UPDATE file SET is_deleted = true, deleted_at = $1 WHERE note_id = $2 AND is_deleted = false`,
+		time.Now(),
 		noteId,
 	)
 	return err
@@ -93,9 +184,10 @@ func (r *fileRepository) DeleteByNoteId(ctx context.Context, noteId uuid.UUID) e
 func (r *fileRepository) GetByNoteIds(ctx context.Context, noteIds []uuid.UUID) ([]*entity.File, error) {
 	// 1. Pastikan semua kolom yang dibutuhkan di-SELECT
 	query := `
-        SELECT id, file_name, original_name, bucket, note_id 
+        SELECT id, file_name, original_name, bucket, note_id, position
         FROM file 
-        WHERE note_id = ANY($1)
+        WHERE note_id = ANY($1) AND is_deleted = false
+        ORDER BY note_id, position ASC, id ASC
     `
 
 	rows, err := r.db.Query(ctx, query, noteIds)
@@ -114,6 +206,7 @@ func (r *fileRepository) GetByNoteIds(ctx context.Context, noteIds []uuid.UUID)
 			&f.OriginalName, // original_name
 			&f.Bucket,       // bucket
 			&f.NoteId,       // note_id
+			&f.Position,     // position
 		)
 
 		if err != nil {
@@ -129,11 +222,157 @@ func (r *fileRepository) GetByNoteIds(ctx context.Context, noteIds []uuid.UUID)
 
 func (r *fileRepository) DeleteByNotebookId(ctx context.Context, notebookId uuid.UUID) error {
 	query := `
-        DELETE FROM file 
-        WHERE note_id IN (
-            SELECT id FROM note WHERE notebook_id = $1
+        UPDATE file SET is_deleted = true, deleted_at = $1
+        WHERE is_deleted = false AND note_id IN (
+            SELECT id FROM note WHERE notebook_id = $2
         )
     `
-	_, err := r.db.Exec(ctx, query, notebookId)
+	_, err := r.db.Exec(ctx, query, time.Now(), notebookId)
+	return err
+}
+
+func (r *fileRepository) RestoreByNoteIds(ctx context.Context, noteIds []uuid.UUID) error {
+	_, err := r.db.Exec(
+		ctx,
+		`UPDATE file SET is_deleted = false, deleted_at = null WHERE note_id = ANY($1) AND is_deleted = true`,
+		noteIds,
+	)
+	return err
+}
+
+// GetAllByNoteIds also returns the files in the trash.
+func (r *fileRepository) GetAllByNoteIds(ctx context.Context, noteIds []uuid.UUID) ([]*entity.File, error) {
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT id, file_name, original_name, bucket, content_type, note_id, user_id, created_at, deleted_at, is_deleted, sha256
+         FROM file
+         WHERE note_id = ANY($1)`,
+		noteIds,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := make([]*entity.File, 0)
+	for rows.Next() {
+		var f entity.File
+		err := rows.Scan(
+			&f.Id,
+			&f.FileName,
+			&f.OriginalName,
+			&f.Bucket,
+			&f.ContentType,
+			&f.NoteId,
+			&f.UserId,
+			&f.CreatedAt,
+			&f.DeletedAt,
+			&f.IsDeleted,
+			&f.Sha256,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, &f)
+	}
+
+	return files, nil
+}
+
+func (r *fileRepository) HardDeleteByNoteIds(ctx context.Context, noteIds []uuid.UUID) error {
+	_, err := r.db.Exec(
+		ctx,
+		`DELETE FROM file WHERE note_id = ANY($1)`,
+		noteIds,
+	)
+	return err
+}
+
+// HardDelete removes a single attachment, the caller deletes its object in
+// S3 and its embeddings.
+// HardDelete returns ErrNotFound when the row is gone already, a concurrent
+// delete of the same file only succeeds once.
+func (r *fileRepository) HardDelete(ctx context.Context, id uuid.UUID) error {
+	tag, err := r.db.Exec(
+		ctx,
+		`DELETE FROM file WHERE id = $1`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return serverutils.ErrNotFound
+	}
+
+	return nil
+}
+
+// UpdateOriginalName renames the attachment as shown to users, the object
+// key in S3 stays the same.
+func (r *fileRepository) UpdateOriginalName(ctx context.Context, id uuid.UUID, originalName string) error {
+	_, err := r.db.Exec(
+		ctx,
+		`UPDATE file SET original_name = $1, updated_at = $2 WHERE id = $3 AND is_deleted = false`,
+		originalName,
+		time.Now(),
+		id,
+	)
+	return err
+}
+
+// GetLastPosition returns the position of the last live attachment of the
+// note, empty when there is none.
+func (r *fileRepository) GetLastPosition(ctx context.Context, noteId uuid.UUID) (string, error) {
+	var position string
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT COALESCE(MAX(position), '') FROM file WHERE is_deleted = false AND note_id = $1`,
+		noteId,
+	).Scan(&position)
+	if err != nil {
+		return "", err
+	}
+
+	return position, nil
+}
+
+// GetSiblingPositions returns the live attachments of the note in manual
+// order, ties broken by id. The rows stay locked until the end of the
+// transaction, concurrent reorders in the note wait for each other.
+func (r *fileRepository) GetSiblingPositions(ctx context.Context, noteId uuid.UUID) ([]*entity.SiblingPosition, error) {
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT id, position FROM file WHERE is_deleted = false AND note_id = $1 ORDER BY position ASC, id ASC FOR UPDATE`,
+		noteId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*entity.SiblingPosition, 0)
+	for rows.Next() {
+		var sibling entity.SiblingPosition
+		err = rows.Scan(&sibling.Id, &sibling.Position)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &sibling)
+	}
+
+	return result, rows.Err()
+}
+
+func (r *fileRepository) UpdatePosition(ctx context.Context, id uuid.UUID, position string) error {
+	_, err := r.db.Exec(
+		ctx,
+		`UPDATE file SET position = $1 WHERE id = $2 AND is_deleted = false`,
+		position,
+		id,
+	)
 	return err
 }
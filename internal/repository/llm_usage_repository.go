@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/pkg/database"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ILlmUsageRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) ILlmUsageRepository
+	Create(ctx context.Context, llmUsage *entity.LlmUsage) error
+	GetSummary(ctx context.Context, userId uuid.UUID, from time.Time, to time.Time, feature string, model string) ([]*entity.LlmUsageSummary, error)
+}
+
+type llmUsageRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewLlmUsageRepository(db *pgxpool.Pool) ILlmUsageRepository {
+	return &llmUsageRepository{
+		db: db,
+	}
+}
+
+func (n *llmUsageRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) ILlmUsageRepository {
+	return &llmUsageRepository{
+		db: tx,
+	}
+}
+
+func (n *llmUsageRepository) Create(ctx context.Context, llmUsage *entity.LlmUsage) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO llm_usage (id, feature, model, prompt_tokens, completion_tokens, embedding_tokens, total_tokens, is_estimated, note_id, chat_session_id, user_id, latency_ms, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		llmUsage.Id,
+		llmUsage.Feature,
+		llmUsage.Model,
+		llmUsage.PromptTokens,
+		llmUsage.CompletionTokens,
+		llmUsage.EmbeddingTokens,
+		llmUsage.TotalTokens,
+		llmUsage.IsEstimated,
+		llmUsage.NoteId,
+		llmUsage.ChatSessionId,
+		llmUsage.UserId,
+		llmUsage.LatencyMs,
+		llmUsage.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetSummary aggregates usage per day, feature and model within [from, to).
+// An empty feature or model matches every value.
+func (n *llmUsageRepository) GetSummary(ctx context.Context, userId uuid.UUID, from time.Time, to time.Time, feature string, model string) ([]*entity.LlmUsageSummary, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT date_trunc('day', created_at) AS day, feature, model, COUNT(*), SUM(prompt_tokens), SUM(completion_tokens), SUM(embedding_tokens), SUM(total_tokens), AVG(latency_ms)::float8
+		FROM llm_usage
+		WHERE created_at >= $1 AND created_at < $2 AND ($3 = '' OR feature = $3) AND ($4 = '' OR model = $4) AND user_id = $5
+		GROUP BY day, feature, model
+		ORDER BY day ASC, feature ASC, model ASC`,
+		from,
+		to,
+		feature,
+		model,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*entity.LlmUsageSummary, 0)
+	for rows.Next() {
+		var summary entity.LlmUsageSummary
+		err = rows.Scan(
+			&summary.Day,
+			&summary.Feature,
+			&summary.Model,
+			&summary.Calls,
+			&summary.PromptTokens,
+			&summary.CompletionTokens,
+			&summary.EmbeddingTokens,
+			&summary.TotalTokens,
+			&summary.AvgLatencyMs,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &summary)
+	}
+
+	return res, nil
+}
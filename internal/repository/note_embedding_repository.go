@@ -2,11 +2,14 @@ package repository
 
 import (
 	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
 	"ai-notetaking-be/pkg/database"
 	"context"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pgvector/pgvector-go"
 )
@@ -15,11 +18,20 @@ type INoteEmbeddingRepository interface {
 	UsingTx(ctx context.Context, tx database.DatabaseQueryer) INoteEmbeddingRepository
 	Create(ctx context.Context, noteEmbedding *entity.NoteEmbedding) error
 	DeleteByID(ctx context.Context, noteId uuid.UUID) error
-	SemanticSearch(ctx context.Context, embeddingValues []float32) ([]*entity.NoteEmbedding, error)
+	SemanticSearch(ctx context.Context, notebookIds []uuid.UUID, tagIds []uuid.UUID, embeddingValues []float32) ([]*entity.NoteEmbedding, error)
 	DeleteByNotebookId(ctx context.Context, notebookId uuid.UUID) error
-	SearchSimilarity(ctx context.Context, embeddingValues []float32) ([]*entity.NoteEmbedding, error)
+	SearchSimilarity(ctx context.Context, notebookIds []uuid.UUID, tagIds []uuid.UUID, embeddingValues []float32) ([]*entity.NoteEmbedding, error)
+	NearestNotebook(ctx context.Context, noteId uuid.UUID, notebookIds []uuid.UUID) (uuid.UUID, float64, error)
+	HardDeleteByNoteIds(ctx context.Context, noteIds []uuid.UUID) error
+	HardDeleteByFileId(ctx context.Context, fileId uuid.UUID) error
+	GetByFileSha256(ctx context.Context, userId uuid.UUID, sha256 string) ([]*entity.NoteEmbedding, error)
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time) error
 }
 
+// noteTagFilter matches the notes n carrying every tag of $3, all notes when
+// $3 is empty.
+const noteTagFilter = `(COALESCE(cardinality($3::uuid[]), 0) = 0 OR n.id IN (SELECT note_id FROM note_tag WHERE tag_id = ANY($3) GROUP BY note_id HAVING COUNT(*) = cardinality($3::uuid[])))`
+
 type noteEmbeddingRepository struct {
 	db database.DatabaseQueryer
 }
@@ -56,11 +68,16 @@ func (n *noteEmbeddingRepository) Create(ctx context.Context, noteEmbedding *ent
 	return nil
 }
 
-func (n *noteEmbeddingRepository) SemanticSearch(ctx context.Context, embeddingValues []float32) ([]*entity.NoteEmbedding, error) {
+// SemanticSearch only considers notes of notebookIds, callers pass the
+// notebooks the user is allowed to read. With tagIds, only notes carrying all
+// of them are considered.
+func (n *noteEmbeddingRepository) SemanticSearch(ctx context.Context, notebookIds []uuid.UUID, tagIds []uuid.UUID, embeddingValues []float32) ([]*entity.NoteEmbedding, error) {
 	rows, err := n.db.Query(
 		ctx,
-		`SELECT id, note_id from note_embedding WHERE is_deleted = false ORDER BY 1 - (embedding_value <-> $1) DESC LIMIT 5`,
+		`SELECT ne.id, ne.note_id from note_embedding ne JOIN note n ON n.id = ne.note_id WHERE ne.is_deleted = false AND n.is_deleted = false AND n.notebook_id = ANY($2) AND `+noteTagFilter+` ORDER BY 1 - (ne.embedding_value <-> $1) DESC LIMIT 5`,
 		pgvector.NewVector(embeddingValues),
+		notebookIds,
+		tagIds,
 	)
 	if err != nil {
 		return nil, err
@@ -85,7 +102,7 @@ func (n *noteEmbeddingRepository) SemanticSearch(ctx context.Context, embeddingV
 func (n *noteEmbeddingRepository) DeleteByID(ctx context.Context, noteId uuid.UUID) error {
 	_, err := n.db.Exec(
 		ctx,
-		`UPDATE note_embedding SET deleted_at = $1, is_deleted = true WHERE note_id = $2`,
+		`UPDATE note_embedding SET deleted_at = $1, is_deleted = true WHERE note_id = $2 AND is_deleted = false`,
 		time.Now(),
 		noteId,
 	)
@@ -112,22 +129,24 @@ func (n *noteEmbeddingRepository) DeleteByNotebookId(ctx context.Context, notebo
 	return nil
 }
 
-func (n *noteEmbeddingRepository) SearchSimilarity(ctx context.Context, embeddingValues []float32) ([]*entity.NoteEmbedding, error) {
+// SearchSimilarity only considers notes of notebookIds, see SemanticSearch.
+func (n *noteEmbeddingRepository) SearchSimilarity(ctx context.Context, notebookIds []uuid.UUID, tagIds []uuid.UUID, embeddingValues []float32) ([]*entity.NoteEmbedding, error) {
 	query := `
         SELECT DISTINCT ON (note_id) 
             id, note_id, chunk_content, similarity
         FROM (
-            SELECT id, note_id, chunk_content, 1 - (embedding_value <=> $1) AS similarity
-            FROM note_embedding
-            WHERE is_deleted = false
-            ORDER BY embedding_value <=> $1
+            SELECT ne.id, ne.note_id, ne.chunk_content, 1 - (ne.embedding_value <=> $1) AS similarity
+            FROM note_embedding ne
+            JOIN note n ON n.id = ne.note_id
+            WHERE ne.is_deleted = false AND n.is_deleted = false AND n.notebook_id = ANY($2) AND ` + noteTagFilter + `
+            ORDER BY ne.embedding_value <=> $1
             LIMIT 50
         ) AS sub
         WHERE similarity > 0.6
         ORDER BY note_id, similarity DESC
         LIMIT 5`
 
-	rows, err := n.db.Query(ctx, query, pgvector.NewVector(embeddingValues))
+	rows, err := n.db.Query(ctx, query, pgvector.NewVector(embeddingValues), notebookIds, tagIds)
 	if err != nil {
 		return nil, err
 	}
@@ -153,6 +172,129 @@ func (n *noteEmbeddingRepository) SearchSimilarity(ctx context.Context, embeddin
 	return res, nil
 }
 
+// NearestNotebook compares the centroid of the chunks of the note with the
+// centroid of the chunks of each of notebookIds, the note itself left out, and
+// returns the most similar notebook with its cosine similarity.
+func (n *noteEmbeddingRepository) NearestNotebook(ctx context.Context, noteId uuid.UUID, notebookIds []uuid.UUID) (uuid.UUID, float64, error) {
+	query := `
+        WITH target AS (
+            SELECT AVG(embedding_value) AS centroid
+            FROM note_embedding
+            WHERE note_id = $1 AND is_deleted = false
+        )
+        SELECT n.notebook_id, 1 - (AVG(ne.embedding_value) <=> (SELECT centroid FROM target)) AS similarity
+        FROM note_embedding ne
+        JOIN note n ON n.id = ne.note_id
+        WHERE ne.is_deleted = false AND n.is_deleted = false AND n.id <> $1 AND n.notebook_id = ANY($2)
+            AND (SELECT centroid FROM target) IS NOT NULL
+        GROUP BY n.notebook_id
+        ORDER BY similarity DESC
+        LIMIT 1`
+
+	var notebookId uuid.UUID
+	var similarity float64
+	err := n.db.QueryRow(ctx, query, noteId, notebookIds).Scan(&notebookId, &similarity)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, 0, serverutils.ErrNotFound
+		}
+		return uuid.Nil, 0, err
+	}
+
+	return notebookId, similarity, nil
+}
+
+func (n *noteEmbeddingRepository) HardDeleteByNoteIds(ctx context.Context, noteIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM note_embedding WHERE note_id = ANY($1)`,
+		noteIds,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// HardDeleteByFileId removes the chunks of an attachment, including those
+// already replaced by a newer indexing.
+func (n *noteEmbeddingRepository) HardDeleteByFileId(ctx context.Context, fileId uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM note_embedding WHERE file_id = $1`,
+		fileId,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetByFileSha256 returns the live chunks of the most recently indexed file
+// of the user with that content, ordered by chunk index. Empty when no such
+// file was indexed.
+func (n *noteEmbeddingRepository) GetByFileSha256(ctx context.Context, userId uuid.UUID, sha256 string) ([]*entity.NoteEmbedding, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT ne.chunk_content, ne.embedding_value, ne.page_number, ne.chunk_index, ne.overlap_range
+		FROM note_embedding ne
+		WHERE ne.is_deleted = false AND ne.file_id = (
+			SELECT source.file_id FROM note_embedding source JOIN file f ON f.id = source.file_id
+			WHERE f.sha256 = $1 AND f.user_id = $2 AND source.is_deleted = false
+			ORDER BY source.created_at DESC
+			LIMIT 1
+		)
+		ORDER BY ne.chunk_index ASC`,
+		sha256,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*entity.NoteEmbedding, 0)
+	for rows.Next() {
+		var noteEmbedding entity.NoteEmbedding
+		var embeddingValue pgvector.Vector
+		err := rows.Scan(
+			&noteEmbedding.ChunkContent,
+			&embeddingValue,
+			&noteEmbedding.PageNumber,
+			&noteEmbedding.ChunkIndex,
+			&noteEmbedding.OverlapRange,
+		)
+		if err != nil {
+			return nil, err
+		}
+		noteEmbedding.EmbeddingValue = embeddingValue.Slice()
+
+		res = append(res, &noteEmbedding)
+	}
+
+	return res, rows.Err()
+}
+
+// PurgeDeleted removes embeddings replaced by a re-embedding or deleted with
+// their note before deletedBefore.
+func (n *noteEmbeddingRepository) PurgeDeleted(ctx context.Context, deletedBefore time.Time) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM note_embedding WHERE is_deleted = true AND deleted_at < $1`,
+		deletedBefore,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func NewNoteEmbeddingRepository(db *pgxpool.Pool) INoteEmbeddingRepository {
 	return &noteEmbeddingRepository{
 		db: db,
@@ -1,13 +1,13 @@
 package repository
 
 import (
+	"ai-notetaking-be/internal/constant"
 	"ai-notetaking-be/internal/entity"
 	"ai-notetaking-be/internal/pkg/serverutils"
 	"ai-notetaking-be/pkg/database"
 	"context"
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,10 +20,21 @@ type INoteRepository interface {
 	Create(ctx context.Context, note *entity.Note) error
 	GetById(ctx context.Context, id uuid.UUID) (*entity.Note, error)
 	GetByNotesIds(ctx context.Context, ids []uuid.UUID) ([]*entity.Note, error)
+	GetSummariesByNotebookId(ctx context.Context, notebookId uuid.UUID) ([]*entity.Note, error)
+	List(ctx context.Context, filter *entity.NoteFilter, excerptLength int) ([]*entity.NoteSummary, error)
 	Update(ctx context.Context, note *entity.Note) error
 	DeleteById(ctx context.Context, id uuid.UUID) error
 	DeleteByNotebookId(ctx context.Context, notebookId uuid.UUID) error
 	GetByIds(ctx context.Context, ids []uuid.UUID) ([]*entity.Note, error)
+	GetDeletedById(ctx context.Context, id uuid.UUID) (*entity.Note, error)
+	GetDeletedByNotebookIds(ctx context.Context, notebookIds []uuid.UUID) ([]*entity.Note, error)
+	Restore(ctx context.Context, id uuid.UUID) error
+	RestoreByNotebookIds(ctx context.Context, notebookIds []uuid.UUID, deletedSince time.Time) ([]uuid.UUID, error)
+	GetPurgeableIds(ctx context.Context, deletedBefore time.Time, notebookIds []uuid.UUID, limit int) ([]uuid.UUID, error)
+	HardDeleteByIds(ctx context.Context, ids []uuid.UUID) error
+	GetLastPosition(ctx context.Context, notebookId uuid.UUID) (string, error)
+	GetSiblingPositions(ctx context.Context, notebookId uuid.UUID) ([]*entity.SiblingPosition, error)
+	UpdatePosition(ctx context.Context, id uuid.UUID, position string) error
 }
 
 type noteRepository struct {
@@ -45,15 +56,17 @@ func (n *noteRepository) UsingTx(ctx context.Context, tx database.DatabaseQuerye
 func (n *noteRepository) Create(ctx context.Context, note *entity.Note) error {
 	_, err := n.db.Exec(
 		ctx,
-		`INSERT INTO note (id, title, content, notebook_id, created_at, updated_at, deleted_at, is_deleted) VALUES ($1, $2, $3, $4, $5, $6 , $7 , $8)`,
+		`INSERT INTO note (id, title, content, notebook_id, user_id, created_at, updated_at, deleted_at, is_deleted, position) VALUES ($1, $2, $3, $4, $5, $6 , $7 , $8, $9, $10)`,
 		note.Id,
 		note.Title,
 		note.Content,
 		note.NotebookId,
+		note.UserId,
 		note.CreatedAt,
 		note.UpdatedAt,
 		note.DeletedAt,
 		note.IsDeleted,
+		note.Position,
 	)
 
 	if err != nil {
@@ -66,7 +79,7 @@ func (n *noteRepository) Create(ctx context.Context, note *entity.Note) error {
 func (n *noteRepository) GetById(ctx context.Context, id uuid.UUID) (*entity.Note, error) {
 	row := n.db.QueryRow(
 		ctx,
-		`SELECT id, title, content, notebook_id, created_at, updated_at, deleted_at, is_deleted FROM note as n WHERE n.is_deleted = false AND n.id = $1`,
+		`SELECT id, title, content, notebook_id, user_id, created_at, updated_at, deleted_at, is_deleted, version, position FROM note as n WHERE n.is_deleted = false AND n.id = $1`,
 		id,
 	)
 
@@ -77,10 +90,13 @@ func (n *noteRepository) GetById(ctx context.Context, id uuid.UUID) (*entity.Not
 		&note.Title,
 		&note.Content,
 		&note.NotebookId,
+		&note.UserId,
 		&note.CreatedAt,
 		&note.UpdatedAt,
 		&note.DeletedAt,
 		&note.IsDeleted,
+		&note.Version,
+		&note.Position,
 	)
 
 	if err != nil {
@@ -93,34 +109,44 @@ func (n *noteRepository) GetById(ctx context.Context, id uuid.UUID) (*entity.Not
 	return &note, nil
 }
 
+// Update saves the note when it is still at note.Version, and moves it to
+// the next version. A note changed in the meantime gives ErrConflict.
 func (n *noteRepository) Update(ctx context.Context, note *entity.Note) error {
-	_, err := n.db.Exec(
+	tag, err := n.db.Exec(
 		ctx,
 		`UPDATE note SET 
 		title = $1,
 		content = $2,
 		notebook_id = $3,
-		updated_at = $4
-		WHERE id = $5`,
+		updated_at = $4,
+		version = version + 1
+		WHERE id = $5 AND version = $6 AND is_deleted = false`,
 
 		note.Title,
 		note.Content,
 		note.NotebookId,
 		note.UpdatedAt,
 		note.Id,
+		note.Version,
 	)
 
 	if err != nil {
 		return err
 	}
 
+	if tag.RowsAffected() == 0 {
+		return serverutils.ErrConflict
+	}
+
+	note.Version++
+
 	return nil
 }
 
 func (n *noteRepository) DeleteById(ctx context.Context, id uuid.UUID) error {
 	_, err := n.db.Exec(
 		ctx,
-		`UPDATE note set is_deleted = true, deleted_at = $1 WHERE id = $2`,
+		`UPDATE note set is_deleted = true, deleted_at = $1 WHERE id = $2 AND is_deleted = false`,
 		time.Now(),
 		id,
 	)
@@ -135,7 +161,7 @@ func (n *noteRepository) DeleteById(ctx context.Context, id uuid.UUID) error {
 func (n *noteRepository) DeleteByNotebookId(ctx context.Context, notebookId uuid.UUID) error {
 	_, err := n.db.Exec(
 		ctx,
-		`UPDATE note set deleted_at = $1, is_deleted = true WHERE notebook_id = $2`,
+		`UPDATE note set deleted_at = $1, is_deleted = true WHERE notebook_id = $2 AND is_deleted = false`,
 		time.Now(),
 		notebookId,
 	)
@@ -148,25 +174,178 @@ func (n *noteRepository) DeleteByNotebookId(ctx context.Context, notebookId uuid
 }
 
 func (n *noteRepository) GetByNotesIds(ctx context.Context, ids []uuid.UUID) ([]*entity.Note, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id, title, content, notebook_id, user_id, created_at, updated_at, version, position FROM note WHERE notebook_id = ANY($1) AND is_deleted = false`,
+		ids,
+	)
 
-	if len(ids) == 0 {
-		return make([]*entity.Note, 0), nil
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
+
+	result := make([]*entity.Note, 0)
+	for rows.Next() {
+		var note entity.Note
+
+		err = rows.Scan(
+			&note.Id,
+			&note.Title,
+			&note.Content,
+			&note.NotebookId,
+			&note.UserId,
+			&note.CreatedAt,
+			&note.UpdatedAt,
+			&note.Version,
+			&note.Position,
+		)
+
+		if err != nil {
+			return nil, err
+		}
 
-	idStr := make([]string, 0)
-	for _, id := range ids {
-		idStr = append(idStr, fmt.Sprintf("'%s'", id.String()))
+		result = append(result, &note)
 	}
-	idSqlFormat := strings.Join(idStr, ", ")
 
+	return result, nil
+}
+
+// GetSummariesByNotebookId lists the notes of the notebook without their
+// content, in their manual order.
+func (n *noteRepository) GetSummariesByNotebookId(ctx context.Context, notebookId uuid.UUID) ([]*entity.Note, error) {
 	rows, err := n.db.Query(
 		ctx,
-		fmt.Sprintf(`SELECT id, title, content, notebook_id, created_at, updated_at FROM note WHERE notebook_id IN (%s) AND is_deleted = false`, idSqlFormat),
+		`SELECT id, title, notebook_id, user_id, created_at, updated_at, version, position FROM note WHERE notebook_id = $1 AND is_deleted = false ORDER BY position ASC, id ASC`,
+		notebookId,
 	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*entity.Note, 0)
+	for rows.Next() {
+		var note entity.Note
+		err = rows.Scan(
+			&note.Id,
+			&note.Title,
+			&note.NotebookId,
+			&note.UserId,
+			&note.CreatedAt,
+			&note.UpdatedAt,
+			&note.Version,
+			&note.Position,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &note)
+	}
 
+	return result, rows.Err()
+}
+
+// noteListOrders maps each sort to its ORDER BY and to the condition keeping
+// the notes after the cursor, whose values start at $8. Manual order is only
+// meaningful within a notebook, so notes are grouped by notebook first.
+var noteListOrders = map[string]struct {
+	orderBy string
+	after   string
+}{
+	constant.SortManual: {
+		orderBy: `n.notebook_id ASC, n.position ASC, n.id ASC`,
+		after:   `(n.notebook_id, n.position, n.id) > ($8, $9, $10)`,
+	},
+	constant.SortName: {
+		orderBy: `lower(n.title) ASC, n.id ASC`,
+		after:   `(lower(n.title), n.id) > (lower($8), $9)`,
+	},
+	constant.SortUpdated: {
+		orderBy: `COALESCE(n.updated_at, n.created_at) DESC, n.id DESC`,
+		after:   `(COALESCE(n.updated_at, n.created_at), n.id) < ($8, $9)`,
+	},
+	constant.SortCreated: {
+		orderBy: `n.created_at DESC, n.id DESC`,
+		after:   `(n.created_at, n.id) < ($8, $9)`,
+	},
+}
+
+// List returns up to filter.Limit notes with the first excerptLength
+// characters of their content.
+func (n *noteRepository) List(ctx context.Context, filter *entity.NoteFilter, excerptLength int) ([]*entity.NoteSummary, error) {
+	order, ok := noteListOrders[filter.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown note sort %q", filter.Sort)
+	}
+
+	// $3 is the tag filter, see noteTagFilter.
+	query := `SELECT n.id, n.title, left(n.content, $2), n.notebook_id, n.position, n.version, n.created_at, n.updated_at,
+			EXISTS (SELECT 1 FROM file f WHERE f.note_id = n.id AND f.is_deleted = false) AS has_file
+		FROM note n
+		WHERE n.is_deleted = false AND n.notebook_id = ANY($1) AND ` + noteTagFilter + `
+			AND ($4::timestamp IS NULL OR n.created_at >= $4)
+			AND ($5::timestamp IS NULL OR n.created_at < $5)
+			AND ($6::boolean IS NULL OR EXISTS (SELECT 1 FROM file f WHERE f.note_id = n.id AND f.is_deleted = false) = $6)`
+
+	args := []any{filter.NotebookIds, excerptLength, filter.TagIds, filter.From, filter.To, filter.HasFile, filter.Limit}
+
+	if filter.After != nil {
+		query += ` AND ` + order.after
+		switch filter.Sort {
+		case constant.SortManual:
+			args = append(args, filter.After.NotebookId, filter.After.Position, filter.After.Id)
+		case constant.SortName:
+			args = append(args, filter.After.Title, filter.After.Id)
+		default:
+			args = append(args, filter.After.Time, filter.After.Id)
+		}
+	}
+
+	query += ` ORDER BY ` + order.orderBy + ` LIMIT $7`
+
+	rows, err := n.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	result := make([]*entity.NoteSummary, 0)
+	for rows.Next() {
+		var note entity.NoteSummary
+		err = rows.Scan(
+			&note.Id,
+			&note.Title,
+			&note.Excerpt,
+			&note.NotebookId,
+			&note.Position,
+			&note.Version,
+			&note.CreatedAt,
+			&note.UpdatedAt,
+			&note.HasFile,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &note)
+	}
+
+	return result, rows.Err()
+}
+
+func (n *noteRepository) GetByIds(ctx context.Context, ids []uuid.UUID) ([]*entity.Note, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id, title, content, notebook_id, user_id, created_at, updated_at, version, position FROM note WHERE id = ANY($1) AND is_deleted = false`,
+		ids,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
 	result := make([]*entity.Note, 0)
 	for rows.Next() {
@@ -177,8 +356,11 @@ func (n *noteRepository) GetByNotesIds(ctx context.Context, ids []uuid.UUID) ([]
 			&note.Title,
 			&note.Content,
 			&note.NotebookId,
+			&note.UserId,
 			&note.CreatedAt,
 			&note.UpdatedAt,
+			&note.Version,
+			&note.Position,
 		)
 
 		if err != nil {
@@ -191,26 +373,48 @@ func (n *noteRepository) GetByNotesIds(ctx context.Context, ids []uuid.UUID) ([]
 	return result, nil
 }
 
-func (n *noteRepository) GetByIds(ctx context.Context, ids []uuid.UUID) ([]*entity.Note, error) {
+func (n *noteRepository) GetDeletedById(ctx context.Context, id uuid.UUID) (*entity.Note, error) {
+	row := n.db.QueryRow(
+		ctx,
+		`SELECT id, title, content, notebook_id, user_id, created_at, updated_at, deleted_at, is_deleted, version FROM note WHERE is_deleted = true AND id = $1`,
+		id,
+	)
 
-	if len(ids) == 0 {
-		return make([]*entity.Note, 0), nil
-	}
+	var note entity.Note
+
+	err := row.Scan(
+		&note.Id,
+		&note.Title,
+		&note.Content,
+		&note.NotebookId,
+		&note.UserId,
+		&note.CreatedAt,
+		&note.UpdatedAt,
+		&note.DeletedAt,
+		&note.IsDeleted,
+		&note.Version,
+	)
 
-	idStr := make([]string, 0)
-	for _, id := range ids {
-		idStr = append(idStr, fmt.Sprintf("'%s'", id.String()))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
 	}
-	idSqlFormat := strings.Join(idStr, ", ")
 
+	return &note, nil
+}
+
+func (n *noteRepository) GetDeletedByNotebookIds(ctx context.Context, notebookIds []uuid.UUID) ([]*entity.Note, error) {
 	rows, err := n.db.Query(
 		ctx,
-		fmt.Sprintf(`SELECT id, title, content, notebook_id, created_at, updated_at FROM note WHERE id IN (%s) AND is_deleted = false`, idSqlFormat),
+		`SELECT id, title, content, notebook_id, user_id, created_at, updated_at, deleted_at, is_deleted, version FROM note WHERE is_deleted = true AND notebook_id = ANY($1) ORDER BY deleted_at DESC`,
+		notebookIds,
 	)
-
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	result := make([]*entity.Note, 0)
 	for rows.Next() {
@@ -221,10 +425,13 @@ func (n *noteRepository) GetByIds(ctx context.Context, ids []uuid.UUID) ([]*enti
 			&note.Title,
 			&note.Content,
 			&note.NotebookId,
+			&note.UserId,
 			&note.CreatedAt,
 			&note.UpdatedAt,
+			&note.DeletedAt,
+			&note.IsDeleted,
+			&note.Version,
 		)
-
 		if err != nil {
 			return nil, err
 		}
@@ -234,3 +441,148 @@ func (n *noteRepository) GetByIds(ctx context.Context, ids []uuid.UUID) ([]*enti
 
 	return result, nil
 }
+
+func (n *noteRepository) Restore(ctx context.Context, id uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE note set is_deleted = false, deleted_at = null WHERE id = $1`,
+		id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RestoreByNotebookIds restores the notes deleted together with the
+// notebooks, notes deleted on their own before that stay in the trash.
+func (n *noteRepository) RestoreByNotebookIds(ctx context.Context, notebookIds []uuid.UUID, deletedSince time.Time) ([]uuid.UUID, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`UPDATE note set is_deleted = false, deleted_at = null WHERE notebook_id = ANY($1) AND is_deleted = true AND deleted_at >= $2 RETURNING id`,
+		notebookIds,
+		deletedSince,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, id)
+	}
+
+	return result, rows.Err()
+}
+
+// GetPurgeableIds returns notes deleted before deletedBefore and every note
+// of the notebooks about to be purged.
+func (n *noteRepository) GetPurgeableIds(ctx context.Context, deletedBefore time.Time, notebookIds []uuid.UUID, limit int) ([]uuid.UUID, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id FROM note WHERE (is_deleted = true AND deleted_at < $1) OR notebook_id = ANY($2) LIMIT $3`,
+		deletedBefore,
+		notebookIds,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, id)
+	}
+
+	return result, nil
+}
+
+func (n *noteRepository) HardDeleteByIds(ctx context.Context, ids []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM note WHERE id = ANY($1)`,
+		ids,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetLastPosition returns the position of the last live note of the
+// notebook, empty when there is none.
+func (n *noteRepository) GetLastPosition(ctx context.Context, notebookId uuid.UUID) (string, error) {
+	var position string
+	err := n.db.QueryRow(
+		ctx,
+		`SELECT COALESCE(MAX(position), '') FROM note WHERE is_deleted = false AND notebook_id = $1`,
+		notebookId,
+	).Scan(&position)
+	if err != nil {
+		return "", err
+	}
+
+	return position, nil
+}
+
+// GetSiblingPositions returns the live notes of the notebook in manual
+// order, ties broken by id. The rows stay locked until the end of the
+// transaction, concurrent reorders in the notebook wait for each other.
+func (n *noteRepository) GetSiblingPositions(ctx context.Context, notebookId uuid.UUID) ([]*entity.SiblingPosition, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id, position FROM note WHERE is_deleted = false AND notebook_id = $1 ORDER BY position ASC, id ASC FOR UPDATE`,
+		notebookId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*entity.SiblingPosition, 0)
+	for rows.Next() {
+		var sibling entity.SiblingPosition
+		err = rows.Scan(&sibling.Id, &sibling.Position)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &sibling)
+	}
+
+	return result, rows.Err()
+}
+
+// UpdatePosition leaves the version alone, reordering does not change the
+// note itself.
+func (n *noteRepository) UpdatePosition(ctx context.Context, id uuid.UUID, position string) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE note SET position = $1 WHERE id = $2 AND is_deleted = false`,
+		position,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/pkg/database"
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type INoteRevisionRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) INoteRevisionRepository
+	Create(ctx context.Context, noteRevision *entity.NoteRevision) error
+	GetByNoteId(ctx context.Context, noteId uuid.UUID) ([]*entity.NoteRevision, error)
+	GetById(ctx context.Context, noteId uuid.UUID, id uuid.UUID) (*entity.NoteRevision, error)
+	ExistsByNoteId(ctx context.Context, noteId uuid.UUID) (bool, error)
+	HardDeleteByNoteIds(ctx context.Context, noteIds []uuid.UUID) error
+}
+
+type noteRevisionRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewNoteRevisionRepository(db *pgxpool.Pool) INoteRevisionRepository {
+	return &noteRevisionRepository{
+		db: db,
+	}
+}
+
+func (n *noteRevisionRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) INoteRevisionRepository {
+	return &noteRevisionRepository{
+		db: tx,
+	}
+}
+
+func (n *noteRevisionRepository) Create(ctx context.Context, noteRevision *entity.NoteRevision) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO note_revision (id, note_id, title, content, source, author_id, restored_from_id, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		noteRevision.Id,
+		noteRevision.NoteId,
+		noteRevision.Title,
+		noteRevision.Content,
+		noteRevision.Source,
+		noteRevision.AuthorId,
+		noteRevision.RestoredFromId,
+		noteRevision.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetByNoteId returns the revisions of the note, newest first.
+func (n *noteRevisionRepository) GetByNoteId(ctx context.Context, noteId uuid.UUID) ([]*entity.NoteRevision, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id, note_id, title, content, source, author_id, restored_from_id, created_at FROM note_revision WHERE note_id = $1 ORDER BY created_at DESC`,
+		noteId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*entity.NoteRevision, 0)
+	for rows.Next() {
+		noteRevision, err := scanNoteRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, noteRevision)
+	}
+
+	return res, nil
+}
+
+func (n *noteRevisionRepository) GetById(ctx context.Context, noteId uuid.UUID, id uuid.UUID) (*entity.NoteRevision, error) {
+	row := n.db.QueryRow(
+		ctx,
+		`SELECT id, note_id, title, content, source, author_id, restored_from_id, created_at FROM note_revision WHERE id = $1 AND note_id = $2`,
+		id,
+		noteId,
+	)
+
+	return scanNoteRevision(row)
+}
+
+func (n *noteRevisionRepository) ExistsByNoteId(ctx context.Context, noteId uuid.UUID) (bool, error) {
+	var exists bool
+	err := n.db.QueryRow(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM note_revision WHERE note_id = $1)`,
+		noteId,
+	).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func (n *noteRevisionRepository) HardDeleteByNoteIds(ctx context.Context, noteIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM note_revision WHERE note_id = ANY($1)`,
+		noteIds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func scanNoteRevision(row pgx.Row) (*entity.NoteRevision, error) {
+	var noteRevision entity.NoteRevision
+	err := row.Scan(
+		&noteRevision.Id,
+		&noteRevision.NoteId,
+		&noteRevision.Title,
+		&noteRevision.Content,
+		&noteRevision.Source,
+		&noteRevision.AuthorId,
+		&noteRevision.RestoredFromId,
+		&noteRevision.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &noteRevision, nil
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/pkg/database"
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type INoteSuggestionRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) INoteSuggestionRepository
+	Create(ctx context.Context, noteSuggestion *entity.NoteSuggestion) error
+	Update(ctx context.Context, noteSuggestion *entity.NoteSuggestion) error
+	GetById(ctx context.Context, noteId uuid.UUID, id uuid.UUID) (*entity.NoteSuggestion, error)
+	GetByNoteId(ctx context.Context, noteId uuid.UUID, userId uuid.UUID, status string) ([]*entity.NoteSuggestion, error)
+	DeletePending(ctx context.Context, noteId uuid.UUID, userId uuid.UUID) error
+	DeleteByTagId(ctx context.Context, tagId uuid.UUID) error
+	HardDeleteByNoteIds(ctx context.Context, noteIds []uuid.UUID) error
+	HardDeleteByNotebookIds(ctx context.Context, notebookIds []uuid.UUID) error
+}
+
+type noteSuggestionRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewNoteSuggestionRepository(db *pgxpool.Pool) INoteSuggestionRepository {
+	return &noteSuggestionRepository{
+		db: db,
+	}
+}
+
+func (n *noteSuggestionRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) INoteSuggestionRepository {
+	return &noteSuggestionRepository{
+		db: tx,
+	}
+}
+
+func (n *noteSuggestionRepository) Create(ctx context.Context, noteSuggestion *entity.NoteSuggestion) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO note_suggestion (id, note_id, user_id, kind, tag_id, tag_name, notebook_id, confidence, status, created_at, resolved_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		noteSuggestion.Id,
+		noteSuggestion.NoteId,
+		noteSuggestion.UserId,
+		noteSuggestion.Kind,
+		noteSuggestion.TagId,
+		noteSuggestion.TagName,
+		noteSuggestion.NotebookId,
+		noteSuggestion.Confidence,
+		noteSuggestion.Status,
+		noteSuggestion.CreatedAt,
+		noteSuggestion.ResolvedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *noteSuggestionRepository) Update(ctx context.Context, noteSuggestion *entity.NoteSuggestion) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE note_suggestion SET tag_id = $1, status = $2, resolved_at = $3 WHERE id = $4`,
+		noteSuggestion.TagId,
+		noteSuggestion.Status,
+		noteSuggestion.ResolvedAt,
+		noteSuggestion.Id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *noteSuggestionRepository) GetById(ctx context.Context, noteId uuid.UUID, id uuid.UUID) (*entity.NoteSuggestion, error) {
+	row := n.db.QueryRow(
+		ctx,
+		`SELECT id, note_id, user_id, kind, tag_id, tag_name, notebook_id, confidence, status, created_at, resolved_at FROM note_suggestion WHERE id = $1 AND note_id = $2`,
+		id,
+		noteId,
+	)
+
+	return scanNoteSuggestion(row)
+}
+
+// GetByNoteId returns the suggestions of the note made for the user, the
+// most confident first. An empty status matches every status.
+func (n *noteSuggestionRepository) GetByNoteId(ctx context.Context, noteId uuid.UUID, userId uuid.UUID, status string) ([]*entity.NoteSuggestion, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id, note_id, user_id, kind, tag_id, tag_name, notebook_id, confidence, status, created_at, resolved_at FROM note_suggestion WHERE note_id = $1 AND user_id = $2 AND ($3 = '' OR status = $3) ORDER BY confidence DESC`,
+		noteId,
+		userId,
+		status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*entity.NoteSuggestion, 0)
+	for rows.Next() {
+		noteSuggestion, err := scanNoteSuggestion(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, noteSuggestion)
+	}
+
+	return res, rows.Err()
+}
+
+// DeletePending clears the unresolved suggestions before the note is
+// suggested for again.
+func (n *noteSuggestionRepository) DeletePending(ctx context.Context, noteId uuid.UUID, userId uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM note_suggestion WHERE note_id = $1 AND user_id = $2 AND status = 'pending'`,
+		noteId,
+		userId,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *noteSuggestionRepository) DeleteByTagId(ctx context.Context, tagId uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM note_suggestion WHERE tag_id = $1`,
+		tagId,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *noteSuggestionRepository) HardDeleteByNoteIds(ctx context.Context, noteIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM note_suggestion WHERE note_id = ANY($1)`,
+		noteIds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// HardDeleteByNotebookIds deletes the suggestions to move notes to the
+// notebooks.
+func (n *noteSuggestionRepository) HardDeleteByNotebookIds(ctx context.Context, notebookIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM note_suggestion WHERE notebook_id = ANY($1)`,
+		notebookIds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func scanNoteSuggestion(row pgx.Row) (*entity.NoteSuggestion, error) {
+	var noteSuggestion entity.NoteSuggestion
+	err := row.Scan(
+		&noteSuggestion.Id,
+		&noteSuggestion.NoteId,
+		&noteSuggestion.UserId,
+		&noteSuggestion.Kind,
+		&noteSuggestion.TagId,
+		&noteSuggestion.TagName,
+		&noteSuggestion.NotebookId,
+		&noteSuggestion.Confidence,
+		&noteSuggestion.Status,
+		&noteSuggestion.CreatedAt,
+		&noteSuggestion.ResolvedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &noteSuggestion, nil
+}
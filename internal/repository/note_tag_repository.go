@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/pkg/database"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type INoteTagRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) INoteTagRepository
+	Create(ctx context.Context, noteId uuid.UUID, tagIds []uuid.UUID, createdAt time.Time) error
+	Delete(ctx context.Context, noteId uuid.UUID, tagIds []uuid.UUID) error
+	GetByNoteIds(ctx context.Context, noteIds []uuid.UUID) ([]*entity.NoteTag, error)
+	GetNoteIdsByTagId(ctx context.Context, tagId uuid.UUID) ([]uuid.UUID, error)
+	Merge(ctx context.Context, fromTagId uuid.UUID, toTagId uuid.UUID) error
+	DeleteByTagId(ctx context.Context, tagId uuid.UUID) error
+	HardDeleteByNoteIds(ctx context.Context, noteIds []uuid.UUID) error
+}
+
+type noteTagRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewNoteTagRepository(db *pgxpool.Pool) INoteTagRepository {
+	return &noteTagRepository{
+		db: db,
+	}
+}
+
+func (n *noteTagRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) INoteTagRepository {
+	return &noteTagRepository{
+		db: tx,
+	}
+}
+
+// Create attaches tagIds to the note, tags it already carries are skipped.
+func (n *noteTagRepository) Create(ctx context.Context, noteId uuid.UUID, tagIds []uuid.UUID, createdAt time.Time) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO note_tag (note_id, tag_id, created_at) SELECT $1, unnest($2::uuid[]), $3 ON CONFLICT DO NOTHING`,
+		noteId,
+		tagIds,
+		createdAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *noteTagRepository) Delete(ctx context.Context, noteId uuid.UUID, tagIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM note_tag WHERE note_id = $1 AND tag_id = ANY($2)`,
+		noteId,
+		tagIds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetByNoteIds returns the tags of the notes ordered by name, whoever owns
+// them.
+func (n *noteTagRepository) GetByNoteIds(ctx context.Context, noteIds []uuid.UUID) ([]*entity.NoteTag, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT nt.note_id, nt.tag_id, t.user_id, t.name, nt.created_at FROM note_tag nt JOIN tag t ON t.id = nt.tag_id WHERE nt.note_id = ANY($1) ORDER BY lower(t.name)`,
+		noteIds,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*entity.NoteTag, 0)
+	for rows.Next() {
+		var noteTag entity.NoteTag
+		err = rows.Scan(
+			&noteTag.NoteId,
+			&noteTag.TagId,
+			&noteTag.UserId,
+			&noteTag.Name,
+			&noteTag.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &noteTag)
+	}
+
+	return res, rows.Err()
+}
+
+// GetNoteIdsByTagId leaves out notes in the trash.
+func (n *noteTagRepository) GetNoteIdsByTagId(ctx context.Context, tagId uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT nt.note_id FROM note_tag nt JOIN note n ON n.id = nt.note_id WHERE nt.tag_id = $1 AND n.is_deleted = false`,
+		tagId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var noteId uuid.UUID
+		err = rows.Scan(&noteId)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, noteId)
+	}
+
+	return res, rows.Err()
+}
+
+// Merge moves the notes of fromTagId to toTagId.
+func (n *noteTagRepository) Merge(ctx context.Context, fromTagId uuid.UUID, toTagId uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO note_tag (note_id, tag_id, created_at) SELECT note_id, $2, created_at FROM note_tag WHERE tag_id = $1 ON CONFLICT DO NOTHING`,
+		fromTagId,
+		toTagId,
+	)
+	if err != nil {
+		return err
+	}
+
+	return n.DeleteByTagId(ctx, fromTagId)
+}
+
+func (n *noteTagRepository) DeleteByTagId(ctx context.Context, tagId uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM note_tag WHERE tag_id = $1`,
+		tagId,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *noteTagRepository) HardDeleteByNoteIds(ctx context.Context, noteIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM note_tag WHERE note_id = ANY($1)`,
+		noteIds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
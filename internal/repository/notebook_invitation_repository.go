@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/pkg/database"
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type INotebookInvitationRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) INotebookInvitationRepository
+	Create(ctx context.Context, invitation *entity.NotebookInvitation) error
+	Update(ctx context.Context, invitation *entity.NotebookInvitation) error
+	GetById(ctx context.Context, id uuid.UUID) (*entity.NotebookInvitation, error)
+	GetPendingByNotebookIdAndEmail(ctx context.Context, notebookId uuid.UUID, email string) (*entity.NotebookInvitation, error)
+	GetPendingByNotebookId(ctx context.Context, notebookId uuid.UUID) ([]*entity.NotebookInvitation, error)
+	GetPendingByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.NotebookInvitation, error)
+	DeleteByNotebookIds(ctx context.Context, notebookIds []uuid.UUID) error
+}
+
+type notebookInvitationRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewNotebookInvitationRepository(db *pgxpool.Pool) INotebookInvitationRepository {
+	return &notebookInvitationRepository{
+		db: db,
+	}
+}
+
+func (n *notebookInvitationRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) INotebookInvitationRepository {
+	return &notebookInvitationRepository{
+		db: tx,
+	}
+}
+
+func (n *notebookInvitationRepository) Create(ctx context.Context, invitation *entity.NotebookInvitation) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO notebook_invitation (id, notebook_id, email, user_id, role, status, invited_by, created_at, responded_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		invitation.Id,
+		invitation.NotebookId,
+		invitation.Email,
+		invitation.UserId,
+		invitation.Role,
+		invitation.Status,
+		invitation.InvitedBy,
+		invitation.CreatedAt,
+		invitation.RespondedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Update only changes a pending invitation, it returns ErrConflict when the
+// invitation was answered or revoked meanwhile.
+func (n *notebookInvitationRepository) Update(ctx context.Context, invitation *entity.NotebookInvitation) error {
+	tag, err := n.db.Exec(
+		ctx,
+		`UPDATE notebook_invitation SET role = $1, status = $2, responded_at = $3 WHERE id = $4 AND status = 'pending'`,
+		invitation.Role,
+		invitation.Status,
+		invitation.RespondedAt,
+		invitation.Id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return serverutils.ErrConflict
+	}
+
+	return nil
+}
+
+func (n *notebookInvitationRepository) GetById(ctx context.Context, id uuid.UUID) (*entity.NotebookInvitation, error) {
+	row := n.db.QueryRow(
+		ctx,
+		`SELECT i.id, i.notebook_id, i.email, i.user_id, i.role, i.status, i.invited_by, i.created_at, i.responded_at, nb.name
+		FROM notebook_invitation i
+		JOIN notebook nb ON nb.id = i.notebook_id
+		WHERE i.id = $1 AND nb.is_deleted = false`,
+		id,
+	)
+
+	return scanNotebookInvitation(row)
+}
+
+func (n *notebookInvitationRepository) GetPendingByNotebookIdAndEmail(ctx context.Context, notebookId uuid.UUID, email string) (*entity.NotebookInvitation, error) {
+	row := n.db.QueryRow(
+		ctx,
+		`SELECT i.id, i.notebook_id, i.email, i.user_id, i.role, i.status, i.invited_by, i.created_at, i.responded_at, nb.name
+		FROM notebook_invitation i
+		JOIN notebook nb ON nb.id = i.notebook_id
+		WHERE i.notebook_id = $1 AND lower(i.email) = lower($2) AND i.status = 'pending'`,
+		notebookId,
+		email,
+	)
+
+	return scanNotebookInvitation(row)
+}
+
+func (n *notebookInvitationRepository) GetPendingByNotebookId(ctx context.Context, notebookId uuid.UUID) ([]*entity.NotebookInvitation, error) {
+	return n.getMany(
+		ctx,
+		`SELECT i.id, i.notebook_id, i.email, i.user_id, i.role, i.status, i.invited_by, i.created_at, i.responded_at, nb.name
+		FROM notebook_invitation i
+		JOIN notebook nb ON nb.id = i.notebook_id
+		WHERE i.notebook_id = $1 AND i.status = 'pending'
+		ORDER BY i.created_at DESC`,
+		notebookId,
+	)
+}
+
+func (n *notebookInvitationRepository) GetPendingByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.NotebookInvitation, error) {
+	return n.getMany(
+		ctx,
+		`SELECT i.id, i.notebook_id, i.email, i.user_id, i.role, i.status, i.invited_by, i.created_at, i.responded_at, nb.name
+		FROM notebook_invitation i
+		JOIN notebook nb ON nb.id = i.notebook_id
+		WHERE i.user_id = $1 AND i.status = 'pending' AND nb.is_deleted = false
+		ORDER BY i.created_at DESC`,
+		userId,
+	)
+}
+
+func (n *notebookInvitationRepository) DeleteByNotebookIds(ctx context.Context, notebookIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM notebook_invitation WHERE notebook_id = ANY($1)`,
+		notebookIds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *notebookInvitationRepository) getMany(ctx context.Context, query string, args ...any) ([]*entity.NotebookInvitation, error) {
+	rows, err := n.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*entity.NotebookInvitation, 0)
+	for rows.Next() {
+		invitation, err := scanNotebookInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, invitation)
+	}
+
+	return res, nil
+}
+
+func scanNotebookInvitation(row pgx.Row) (*entity.NotebookInvitation, error) {
+	var invitation entity.NotebookInvitation
+	err := row.Scan(
+		&invitation.Id,
+		&invitation.NotebookId,
+		&invitation.Email,
+		&invitation.UserId,
+		&invitation.Role,
+		&invitation.Status,
+		&invitation.InvitedBy,
+		&invitation.CreatedAt,
+		&invitation.RespondedAt,
+		&invitation.NotebookName,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &invitation, nil
+}
@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/pkg/database"
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type INotebookMemberRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) INotebookMemberRepository
+	Create(ctx context.Context, member *entity.NotebookMember) error
+	Update(ctx context.Context, member *entity.NotebookMember) error
+	Delete(ctx context.Context, notebookId uuid.UUID, userId uuid.UUID) error
+	GetByNotebookIdAndUserId(ctx context.Context, notebookId uuid.UUID, userId uuid.UUID) (*entity.NotebookMember, error)
+	GetByNotebookId(ctx context.Context, notebookId uuid.UUID) ([]*entity.NotebookMember, error)
+	GetRolesOnNotebook(ctx context.Context, userId uuid.UUID, notebookId uuid.UUID, includeDeleted bool) ([]string, error)
+	GetGrants(ctx context.Context, userId uuid.UUID, includeDeleted bool) ([]*entity.NotebookGrant, error)
+	DeleteByNotebookIds(ctx context.Context, notebookIds []uuid.UUID) error
+}
+
+type notebookMemberRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewNotebookMemberRepository(db *pgxpool.Pool) INotebookMemberRepository {
+	return &notebookMemberRepository{
+		db: db,
+	}
+}
+
+func (n *notebookMemberRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) INotebookMemberRepository {
+	return &notebookMemberRepository{
+		db: tx,
+	}
+}
+
+func (n *notebookMemberRepository) Create(ctx context.Context, member *entity.NotebookMember) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO notebook_member (id, notebook_id, user_id, role, created_by, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		member.Id,
+		member.NotebookId,
+		member.UserId,
+		member.Role,
+		member.CreatedBy,
+		member.CreatedAt,
+		member.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *notebookMemberRepository) Update(ctx context.Context, member *entity.NotebookMember) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE notebook_member SET role = $1, updated_at = $2 WHERE id = $3`,
+		member.Role,
+		member.UpdatedAt,
+		member.Id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *notebookMemberRepository) Delete(ctx context.Context, notebookId uuid.UUID, userId uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM notebook_member WHERE notebook_id = $1 AND user_id = $2`,
+		notebookId,
+		userId,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *notebookMemberRepository) GetByNotebookIdAndUserId(ctx context.Context, notebookId uuid.UUID, userId uuid.UUID) (*entity.NotebookMember, error) {
+	row := n.db.QueryRow(
+		ctx,
+		`SELECT m.id, m.notebook_id, m.user_id, m.role, m.created_by, m.created_at, m.updated_at, u.email, u.name
+		FROM notebook_member m
+		JOIN "user" u ON u.id = m.user_id
+		WHERE m.notebook_id = $1 AND m.user_id = $2`,
+		notebookId,
+		userId,
+	)
+
+	var member entity.NotebookMember
+	err := row.Scan(
+		&member.Id,
+		&member.NotebookId,
+		&member.UserId,
+		&member.Role,
+		&member.CreatedBy,
+		&member.CreatedAt,
+		&member.UpdatedAt,
+		&member.Email,
+		&member.Name,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+func (n *notebookMemberRepository) GetByNotebookId(ctx context.Context, notebookId uuid.UUID) ([]*entity.NotebookMember, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT m.id, m.notebook_id, m.user_id, m.role, m.created_by, m.created_at, m.updated_at, u.email, u.name
+		FROM notebook_member m
+		JOIN "user" u ON u.id = m.user_id
+		WHERE m.notebook_id = $1
+		ORDER BY m.created_at ASC`,
+		notebookId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*entity.NotebookMember, 0)
+	for rows.Next() {
+		var member entity.NotebookMember
+		err = rows.Scan(
+			&member.Id,
+			&member.NotebookId,
+			&member.UserId,
+			&member.Role,
+			&member.CreatedBy,
+			&member.CreatedAt,
+			&member.UpdatedAt,
+			&member.Email,
+			&member.Name,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &member)
+	}
+
+	return res, nil
+}
+
+// GetRolesOnNotebook walks from the notebook up to its root and returns every
+// role the user holds on the way. Creating a notebook makes its creator an
+// owner without a member row. includeDeleted also walks through notebooks in
+// the trash, to authorize restoring them.
+func (n *notebookMemberRepository) GetRolesOnNotebook(ctx context.Context, userId uuid.UUID, notebookId uuid.UUID, includeDeleted bool) ([]string, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`WITH RECURSIVE ancestor AS (
+			SELECT id, parent_id, user_id, ARRAY[id] AS path FROM notebook WHERE id = $1 AND ($3 OR is_deleted = false)
+			UNION ALL
+			SELECT nb.id, nb.parent_id, nb.user_id, a.path || nb.id
+			FROM notebook nb
+			JOIN ancestor a ON nb.id = a.parent_id
+			WHERE ($3 OR nb.is_deleted = false) AND NOT nb.id = ANY(a.path)
+		)
+		SELECT 'owner'::text FROM ancestor WHERE user_id = $2
+		UNION ALL
+		SELECT m.role::text FROM notebook_member m JOIN ancestor a ON a.id = m.notebook_id WHERE m.user_id = $2`,
+		notebookId,
+		userId,
+		includeDeleted,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]string, 0)
+	for rows.Next() {
+		var role string
+		err = rows.Scan(&role)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, role)
+	}
+
+	return res, nil
+}
+
+// GetGrants returns one row per role the user holds on every readable
+// notebook, a notebook appears several times when it is reachable from
+// several grants. includeDeleted also returns notebooks in the trash.
+func (n *notebookMemberRepository) GetGrants(ctx context.Context, userId uuid.UUID, includeDeleted bool) ([]*entity.NotebookGrant, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`WITH RECURSIVE root AS (
+			SELECT id AS notebook_id, 'owner'::text AS role FROM notebook WHERE user_id = $1 AND ($2 OR is_deleted = false)
+			UNION ALL
+			SELECT m.notebook_id, m.role::text FROM notebook_member m JOIN notebook nb ON nb.id = m.notebook_id WHERE m.user_id = $1 AND ($2 OR nb.is_deleted = false)
+		), granted AS (
+			SELECT notebook_id, role, ARRAY[notebook_id] AS path FROM root
+			UNION ALL
+			SELECT nb.id, g.role, g.path || nb.id
+			FROM notebook nb
+			JOIN granted g ON nb.parent_id = g.notebook_id
+			WHERE ($2 OR nb.is_deleted = false) AND NOT nb.id = ANY(g.path)
+		)
+		SELECT DISTINCT notebook_id, role FROM granted`,
+		userId,
+		includeDeleted,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*entity.NotebookGrant, 0)
+	for rows.Next() {
+		var grant entity.NotebookGrant
+		err = rows.Scan(
+			&grant.NotebookId,
+			&grant.Role,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &grant)
+	}
+
+	return res, nil
+}
+
+func (n *notebookMemberRepository) DeleteByNotebookIds(ctx context.Context, notebookIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM notebook_member WHERE notebook_id = ANY($1)`,
+		notebookIds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
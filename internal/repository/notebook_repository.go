@@ -6,6 +6,8 @@ import (
 	"ai-notetaking-be/pkg/database"
 	"context"
 	"errors"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,13 +17,28 @@ import (
 
 type INotebookRepository interface {
 	UsingTx(ctx context.Context, tx database.DatabaseQueryer) INotebookRepository
-	GetAll(ctx context.Context) ([]*entity.Notebook, error)
+	GetByIds(ctx context.Context, ids []uuid.UUID) ([]*entity.Notebook, error)
 	Create(ctx context.Context, notebook *entity.Notebook) error
 	GetById(ctx context.Context, id uuid.UUID) (*entity.Notebook, error)
 	Update(ctx context.Context, notebook *entity.Notebook) error
 	DeleteById(ctx context.Context, id uuid.UUID) error
+	DeleteByIdAndVersion(ctx context.Context, id uuid.UUID, version int) error
 	NullifyParentById(ctx context.Context, id uuid.UUID) error
 	Move(ctx context.Context, id uuid.UUID, parent_id *uuid.UUID) error
+	GetSubtreeIds(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error)
+	GetTree(ctx context.Context, ids []uuid.UUID) ([]*entity.NotebookTreeNode, error)
+	GetAncestors(ctx context.Context, id uuid.UUID) ([]*entity.Notebook, error)
+	GetChildren(ctx context.Context, id uuid.UUID) ([]*entity.Notebook, error)
+	LockHierarchy(ctx context.Context, ownerIds ...uuid.UUID) error
+	GetLastPosition(ctx context.Context, userId uuid.UUID, parentId *uuid.UUID) (string, error)
+	GetSiblingPositions(ctx context.Context, userId uuid.UUID, parentId *uuid.UUID) ([]*entity.SiblingPosition, error)
+	UpdatePosition(ctx context.Context, id uuid.UUID, position string) error
+	GetDeletedById(ctx context.Context, id uuid.UUID) (*entity.Notebook, error)
+	GetDeletedRootsByIds(ctx context.Context, ids []uuid.UUID) ([]*entity.Notebook, error)
+	GetDeletedSubtreeIds(ctx context.Context, id uuid.UUID, deletedSince time.Time) ([]uuid.UUID, error)
+	RestoreByIds(ctx context.Context, ids []uuid.UUID) error
+	GetPurgeableIds(ctx context.Context, deletedBefore time.Time, limit int) ([]uuid.UUID, error)
+	HardDeleteByIds(ctx context.Context, ids []uuid.UUID) error
 }
 
 type notebookRepository struct {
@@ -40,10 +57,11 @@ func (n *notebookRepository) UsingTx(ctx context.Context, tx database.DatabaseQu
 	}
 }
 
-func (n *notebookRepository) GetAll(ctx context.Context) ([]*entity.Notebook, error) {
+func (n *notebookRepository) GetByIds(ctx context.Context, ids []uuid.UUID) ([]*entity.Notebook, error) {
 	rows, err := n.db.Query(
 		ctx,
-		`SELECT id, name, parent_id, created_at, updated_at FROM notebook WHERE is_deleted = false ORDER BY name ASC`,
+		`SELECT id, name, parent_id, user_id, created_at, updated_at, version, position FROM notebook WHERE is_deleted = false AND id = ANY($1) ORDER BY name ASC`,
+		ids,
 	)
 
 	if err != nil {
@@ -57,8 +75,11 @@ func (n *notebookRepository) GetAll(ctx context.Context) ([]*entity.Notebook, er
 			&notebook.Id,
 			&notebook.Name,
 			&notebook.ParentId,
+			&notebook.UserId,
 			&notebook.CreatedAt,
 			&notebook.UpdatedAt,
+			&notebook.Version,
+			&notebook.Position,
 		)
 
 		if err != nil {
@@ -74,14 +95,16 @@ func (n *notebookRepository) GetAll(ctx context.Context) ([]*entity.Notebook, er
 func (n *notebookRepository) Create(ctx context.Context, notebook *entity.Notebook) error {
 	_, err := n.db.Exec(
 		ctx,
-		`INSERT INTO notebook (id, name, parent_id, created_at, updated_at, deleted_at, is_deleted) VALUES ($1, $2, $3, $4, $5, $6 , $7)`,
+		`INSERT INTO notebook (id, name, parent_id, user_id, created_at, updated_at, deleted_at, is_deleted, position) VALUES ($1, $2, $3, $4, $5, $6 , $7, $8, $9)`,
 		notebook.Id,
 		notebook.Name,
 		notebook.ParentId,
+		notebook.UserId,
 		notebook.CreatedAt,
 		notebook.UpdatedAt,
 		notebook.DeletedAt,
 		notebook.IsDeleted,
+		notebook.Position,
 	)
 
 	if err != nil {
@@ -91,32 +114,43 @@ func (n *notebookRepository) Create(ctx context.Context, notebook *entity.Notebo
 	return nil
 }
 
+// Update saves the notebook when it is still at notebook.Version, and moves
+// it to the next version. A notebook changed in the meantime gives
+// ErrConflict.
 func (n *notebookRepository) Update(ctx context.Context, notebook *entity.Notebook) error {
-	_, err := n.db.Exec(
+	tag, err := n.db.Exec(
 		ctx,
 		`UPDATE notebook SET 
 		name = $1, 
 		parent_id = $2,
-		updated_at = $3
-		WHERE id = $4`,
+		updated_at = $3,
+		version = version + 1
+		WHERE id = $4 AND version = $5 AND is_deleted = false`,
 
 		notebook.Name,
 		notebook.ParentId,
 		notebook.UpdatedAt,
 		notebook.Id,
+		notebook.Version,
 	)
 
 	if err != nil {
 		return err
 	}
 
+	if tag.RowsAffected() == 0 {
+		return serverutils.ErrConflict
+	}
+
+	notebook.Version++
+
 	return nil
 }
 
 func (n *notebookRepository) GetById(ctx context.Context, id uuid.UUID) (*entity.Notebook, error) {
 	row := n.db.QueryRow(
 		ctx,
-		`SELECT id, name, parent_id, created_at, updated_at, deleted_at, is_deleted FROM notebook as n WHERE n.is_deleted = false AND n.id = $1`,
+		`SELECT id, name, parent_id, user_id, created_at, updated_at, deleted_at, is_deleted, version, position FROM notebook as n WHERE n.is_deleted = false AND n.id = $1`,
 		id,
 	)
 
@@ -126,10 +160,13 @@ func (n *notebookRepository) GetById(ctx context.Context, id uuid.UUID) (*entity
 		&notebook.Id,
 		&notebook.Name,
 		&notebook.ParentId,
+		&notebook.UserId,
 		&notebook.CreatedAt,
 		&notebook.UpdatedAt,
 		&notebook.DeletedAt,
 		&notebook.IsDeleted,
+		&notebook.Version,
+		&notebook.Position,
 	)
 
 	if err != nil {
@@ -145,7 +182,7 @@ func (n *notebookRepository) GetById(ctx context.Context, id uuid.UUID) (*entity
 func (n *notebookRepository) DeleteById(ctx context.Context, id uuid.UUID) error {
 	_, err := n.db.Exec(
 		ctx,
-		`UPDATE notebook set is_deleted = true, deleted_at = $1 WHERE id = $2`,
+		`UPDATE notebook set is_deleted = true, deleted_at = $1 WHERE id = $2 AND is_deleted = false`,
 		time.Now(),
 		id,
 	)
@@ -157,6 +194,27 @@ func (n *notebookRepository) DeleteById(ctx context.Context, id uuid.UUID) error
 	return nil
 }
 
+// DeleteByIdAndVersion moves the notebook to the trash only at version,
+// ErrConflict when it changed meanwhile.
+func (n *notebookRepository) DeleteByIdAndVersion(ctx context.Context, id uuid.UUID, version int) error {
+	tag, err := n.db.Exec(
+		ctx,
+		`UPDATE notebook set is_deleted = true, deleted_at = $1 WHERE id = $2 AND version = $3 AND is_deleted = false`,
+		time.Now(),
+		id,
+		version,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return serverutils.ErrConflict
+	}
+
+	return nil
+}
+
 func (n *notebookRepository) NullifyParentById(ctx context.Context, parent_id uuid.UUID) error {
 	_, err := n.db.Exec(
 		ctx,
@@ -175,7 +233,7 @@ func (n *notebookRepository) NullifyParentById(ctx context.Context, parent_id uu
 func (n *notebookRepository) Move(ctx context.Context, id uuid.UUID, parent_id *uuid.UUID) error {
 	_, err := n.db.Exec(
 		ctx,
-		`UPDATE notebook set parent_id = $1, updated_at = $2 WHERE id = $3`,
+		`UPDATE notebook set parent_id = $1, updated_at = $2, version = version + 1 WHERE id = $3`,
 		parent_id,
 		time.Now(),
 		id,
@@ -187,3 +245,411 @@ func (n *notebookRepository) Move(ctx context.Context, id uuid.UUID, parent_id *
 
 	return nil
 }
+
+// GetSubtreeIds returns the notebook followed by its live descendants.
+func (n *notebookRepository) GetSubtreeIds(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	return n.getIds(
+		ctx,
+		`WITH RECURSIVE subtree AS (
+			SELECT id, ARRAY[id] AS path FROM notebook WHERE id = $1 AND is_deleted = false
+			UNION ALL
+			SELECT nb.id, s.path || nb.id
+			FROM notebook nb
+			JOIN subtree s ON nb.parent_id = s.id
+			WHERE nb.is_deleted = false AND NOT nb.id = ANY(s.path)
+		)
+		SELECT id FROM subtree ORDER BY array_length(path, 1) ASC`,
+		id,
+	)
+}
+
+// GetTree walks down from the notebooks of ids whose parent is not among ids,
+// only through notebooks of ids. Parents come before their children, siblings
+// are sorted by name.
+func (n *notebookRepository) GetTree(ctx context.Context, ids []uuid.UUID) ([]*entity.NotebookTreeNode, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`WITH RECURSIVE tree AS (
+			SELECT nb.id, nb.name, nb.parent_id, 0 AS depth, ARRAY[nb.id] AS path, ARRAY[lower(nb.name)] AS sort_path
+			FROM notebook nb
+			WHERE nb.is_deleted = false AND nb.id = ANY($1) AND (nb.parent_id IS NULL OR NOT nb.parent_id = ANY($1))
+			UNION ALL
+			SELECT nb.id, nb.name, nb.parent_id, t.depth + 1, t.path || nb.id, t.sort_path || lower(nb.name)
+			FROM notebook nb
+			JOIN tree t ON nb.parent_id = t.id
+			WHERE nb.is_deleted = false AND nb.id = ANY($1) AND NOT nb.id = ANY(t.path)
+		)
+		SELECT t.id, t.name, t.parent_id, t.depth, nb.position, COUNT(n.id), nb.created_at,
+			GREATEST(MAX(COALESCE(nb.updated_at, nb.created_at)), MAX(COALESCE(n.updated_at, n.created_at)))
+		FROM tree t
+		JOIN notebook nb ON nb.id = t.id
+		LEFT JOIN note n ON n.notebook_id = t.id AND n.is_deleted = false
+		GROUP BY t.id, t.name, t.parent_id, t.depth, t.sort_path, nb.position, nb.created_at
+		ORDER BY t.sort_path ASC`,
+		ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*entity.NotebookTreeNode, 0)
+	for rows.Next() {
+		var node entity.NotebookTreeNode
+		err = rows.Scan(
+			&node.Id,
+			&node.Name,
+			&node.ParentId,
+			&node.Depth,
+			&node.Position,
+			&node.NoteCount,
+			&node.CreatedAt,
+			&node.LastModifiedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &node)
+	}
+
+	return result, rows.Err()
+}
+
+// GetAncestors returns the live notebooks from the root down to the notebook
+// itself.
+func (n *notebookRepository) GetAncestors(ctx context.Context, id uuid.UUID) ([]*entity.Notebook, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, 0 AS depth, ARRAY[id] AS path FROM notebook WHERE id = $1 AND is_deleted = false
+			UNION ALL
+			SELECT nb.id, nb.parent_id, a.depth + 1, a.path || nb.id
+			FROM notebook nb
+			JOIN ancestors a ON nb.id = a.parent_id
+			WHERE nb.is_deleted = false AND NOT nb.id = ANY(a.path)
+		)
+		SELECT nb.id, nb.name, nb.parent_id, nb.user_id, nb.created_at, nb.updated_at, nb.version, nb.position
+		FROM ancestors a
+		JOIN notebook nb ON nb.id = a.id
+		ORDER BY a.depth DESC`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*entity.Notebook, 0)
+	for rows.Next() {
+		var notebook entity.Notebook
+		err = rows.Scan(
+			&notebook.Id,
+			&notebook.Name,
+			&notebook.ParentId,
+			&notebook.UserId,
+			&notebook.CreatedAt,
+			&notebook.UpdatedAt,
+			&notebook.Version,
+			&notebook.Position,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &notebook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 {
+		return nil, serverutils.ErrNotFound
+	}
+
+	return result, nil
+}
+
+func (n *notebookRepository) GetChildren(ctx context.Context, id uuid.UUID) ([]*entity.Notebook, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id, name, parent_id, user_id, created_at, updated_at, version, position FROM notebook WHERE is_deleted = false AND parent_id = $1 ORDER BY position ASC, id ASC`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*entity.Notebook, 0)
+	for rows.Next() {
+		var notebook entity.Notebook
+		err = rows.Scan(
+			&notebook.Id,
+			&notebook.Name,
+			&notebook.ParentId,
+			&notebook.UserId,
+			&notebook.CreatedAt,
+			&notebook.UpdatedAt,
+			&notebook.Version,
+			&notebook.Position,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &notebook)
+	}
+
+	return result, rows.Err()
+}
+
+// LockHierarchy locks the trees whose roots the owners hold until the
+// transaction ends, so changes to the same tree wait for each other while
+// other trees go on. The locks are taken in order, two transactions cannot
+// wait on each other.
+func (n *notebookRepository) LockHierarchy(ctx context.Context, ownerIds ...uuid.UUID) error {
+	ownerIds = slices.Clone(ownerIds)
+	slices.SortFunc(ownerIds, func(a, b uuid.UUID) int {
+		return strings.Compare(a.String(), b.String())
+	})
+
+	for _, ownerId := range slices.Compact(ownerIds) {
+		_, err := n.db.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('notebook_hierarchy'), hashtext($1::text))`, ownerId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// notebookSiblingFilter matches the live notebooks under $1. Root notebooks
+// are ordered per user, at the root it only matches the notebooks of $2.
+const notebookSiblingFilter = `is_deleted = false AND parent_id IS NOT DISTINCT FROM $1 AND ($1::uuid IS NOT NULL OR user_id = $2)`
+
+// GetLastPosition returns the position of the last live notebook under
+// parentId, empty when there is none. At the root only the notebooks of
+// userId count.
+func (n *notebookRepository) GetLastPosition(ctx context.Context, userId uuid.UUID, parentId *uuid.UUID) (string, error) {
+	var position string
+	err := n.db.QueryRow(
+		ctx,
+		`SELECT COALESCE(MAX(position), '') FROM notebook WHERE `+notebookSiblingFilter,
+		parentId,
+		userId,
+	).Scan(&position)
+	if err != nil {
+		return "", err
+	}
+
+	return position, nil
+}
+
+// GetSiblingPositions returns the live notebooks under parentId in manual
+// order, ties broken by id. At the root only the notebooks of userId count.
+// The rows stay locked until the end of the transaction, concurrent
+// reorders of the same siblings wait for each other.
+func (n *notebookRepository) GetSiblingPositions(ctx context.Context, userId uuid.UUID, parentId *uuid.UUID) ([]*entity.SiblingPosition, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id, position FROM notebook WHERE `+notebookSiblingFilter+` ORDER BY position ASC, id ASC FOR UPDATE`,
+		parentId,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*entity.SiblingPosition, 0)
+	for rows.Next() {
+		var sibling entity.SiblingPosition
+		err = rows.Scan(&sibling.Id, &sibling.Position)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &sibling)
+	}
+
+	return result, rows.Err()
+}
+
+// UpdatePosition leaves the version alone, reordering does not change the
+// notebook itself.
+func (n *notebookRepository) UpdatePosition(ctx context.Context, id uuid.UUID, position string) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE notebook SET position = $1 WHERE id = $2 AND is_deleted = false`,
+		position,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *notebookRepository) GetDeletedById(ctx context.Context, id uuid.UUID) (*entity.Notebook, error) {
+	row := n.db.QueryRow(
+		ctx,
+		`SELECT id, name, parent_id, user_id, created_at, updated_at, deleted_at, is_deleted, version FROM notebook WHERE is_deleted = true AND id = $1`,
+		id,
+	)
+
+	var notebook entity.Notebook
+
+	err := row.Scan(
+		&notebook.Id,
+		&notebook.Name,
+		&notebook.ParentId,
+		&notebook.UserId,
+		&notebook.CreatedAt,
+		&notebook.UpdatedAt,
+		&notebook.DeletedAt,
+		&notebook.IsDeleted,
+		&notebook.Version,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &notebook, nil
+}
+
+// GetDeletedRootsByIds returns the deleted notebooks among ids that were
+// deleted on their own or as the top of a deleted subtree, their parent is
+// missing or still live.
+func (n *notebookRepository) GetDeletedRootsByIds(ctx context.Context, ids []uuid.UUID) ([]*entity.Notebook, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT nb.id, nb.name, nb.parent_id, nb.user_id, nb.created_at, nb.updated_at, nb.deleted_at, nb.is_deleted, nb.version
+		FROM notebook nb
+		LEFT JOIN notebook parent ON parent.id = nb.parent_id
+		WHERE nb.is_deleted = true AND nb.id = ANY($1) AND (parent.id IS NULL OR parent.is_deleted = false)
+		ORDER BY nb.deleted_at DESC`,
+		ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*entity.Notebook, 0)
+	for rows.Next() {
+		var notebook entity.Notebook
+		err = rows.Scan(
+			&notebook.Id,
+			&notebook.Name,
+			&notebook.ParentId,
+			&notebook.UserId,
+			&notebook.CreatedAt,
+			&notebook.UpdatedAt,
+			&notebook.DeletedAt,
+			&notebook.IsDeleted,
+			&notebook.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &notebook)
+	}
+
+	return result, nil
+}
+
+// GetDeletedSubtreeIds returns the deleted notebook followed by its
+// descendants deleted together with it, at or after deletedSince.
+func (n *notebookRepository) GetDeletedSubtreeIds(ctx context.Context, id uuid.UUID, deletedSince time.Time) ([]uuid.UUID, error) {
+	return n.getIds(
+		ctx,
+		`WITH RECURSIVE subtree AS (
+			SELECT id, ARRAY[id] AS path FROM notebook WHERE id = $1 AND is_deleted = true
+			UNION ALL
+			SELECT nb.id, s.path || nb.id
+			FROM notebook nb
+			JOIN subtree s ON nb.parent_id = s.id
+			WHERE nb.is_deleted = true AND nb.deleted_at >= $2 AND NOT nb.id = ANY(s.path)
+		)
+		SELECT id FROM subtree ORDER BY array_length(path, 1) ASC`,
+		id,
+		deletedSince,
+	)
+}
+
+func (n *notebookRepository) RestoreByIds(ctx context.Context, ids []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE notebook set is_deleted = false, deleted_at = null, updated_at = $1 WHERE id = ANY($2)`,
+		time.Now(),
+		ids,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *notebookRepository) GetPurgeableIds(ctx context.Context, deletedBefore time.Time, limit int) ([]uuid.UUID, error) {
+	return n.getIds(
+		ctx,
+		`SELECT id FROM notebook WHERE is_deleted = true AND deleted_at < $1 ORDER BY deleted_at ASC LIMIT $2`,
+		deletedBefore,
+		limit,
+	)
+}
+
+// HardDeleteByIds removes the rows for good. Remaining children are detached
+// first so the parent_id references hold.
+func (n *notebookRepository) HardDeleteByIds(ctx context.Context, ids []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE notebook set parent_id = null WHERE parent_id = ANY($1) AND NOT id = ANY($1)`,
+		ids,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.db.Exec(
+		ctx,
+		`DELETE FROM notebook WHERE id = ANY($1)`,
+		ids,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *notebookRepository) getIds(ctx context.Context, query string, args ...any) ([]uuid.UUID, error) {
+	rows, err := n.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, id)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/pkg/database"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IPromptTemplateRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) IPromptTemplateRepository
+	Create(ctx context.Context, promptTemplate *entity.PromptTemplate) error
+	Update(ctx context.Context, promptTemplate *entity.PromptTemplate) error
+	GetAll(ctx context.Context, userId uuid.UUID) ([]*entity.PromptTemplate, error)
+	GetById(ctx context.Context, userId uuid.UUID, id uuid.UUID) (*entity.PromptTemplate, error)
+	GetByName(ctx context.Context, userId uuid.UUID, name string) (*entity.PromptTemplate, error)
+	GetDefault(ctx context.Context, userId uuid.UUID) (*entity.PromptTemplate, error)
+	ClearDefault(ctx context.Context, userId uuid.UUID) error
+	DeleteById(ctx context.Context, id uuid.UUID) error
+}
+
+type promptTemplateRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewPromptTemplateRepository(db *pgxpool.Pool) IPromptTemplateRepository {
+	return &promptTemplateRepository{
+		db: db,
+	}
+}
+
+func (n *promptTemplateRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) IPromptTemplateRepository {
+	return &promptTemplateRepository{
+		db: tx,
+	}
+}
+
+func (n *promptTemplateRepository) Create(ctx context.Context, promptTemplate *entity.PromptTemplate) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO prompt_template (id, user_id, name, description, is_default, created_at, updated_at, deleted_at, is_deleted) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		promptTemplate.Id,
+		promptTemplate.UserId,
+		promptTemplate.Name,
+		promptTemplate.Description,
+		promptTemplate.IsDefault,
+		promptTemplate.CreatedAt,
+		promptTemplate.UpdatedAt,
+		promptTemplate.DeletedAt,
+		promptTemplate.IsDeleted,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *promptTemplateRepository) Update(ctx context.Context, promptTemplate *entity.PromptTemplate) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE prompt_template SET 
+		name = $1,
+		description = $2,
+		is_default = $3,
+		updated_at = $4
+		WHERE id = $5`,
+		promptTemplate.Name,
+		promptTemplate.Description,
+		promptTemplate.IsDefault,
+		promptTemplate.UpdatedAt,
+		promptTemplate.Id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetAll lists the templates of userId and the built-ins. Like the other
+// reads it never returns templates of other users.
+func (n *promptTemplateRepository) GetAll(ctx context.Context, userId uuid.UUID) ([]*entity.PromptTemplate, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id, user_id, name, description, is_default, created_at, updated_at, deleted_at, is_deleted FROM prompt_template WHERE is_deleted = false AND (user_id = $1 OR user_id IS NULL) ORDER BY name ASC`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*entity.PromptTemplate, 0)
+	for rows.Next() {
+		var promptTemplate entity.PromptTemplate
+		err = rows.Scan(
+			&promptTemplate.Id,
+			&promptTemplate.UserId,
+			&promptTemplate.Name,
+			&promptTemplate.Description,
+			&promptTemplate.IsDefault,
+			&promptTemplate.CreatedAt,
+			&promptTemplate.UpdatedAt,
+			&promptTemplate.DeletedAt,
+			&promptTemplate.IsDeleted,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &promptTemplate)
+	}
+
+	return result, nil
+}
+
+func (n *promptTemplateRepository) GetById(ctx context.Context, userId uuid.UUID, id uuid.UUID) (*entity.PromptTemplate, error) {
+	return n.getOne(
+		ctx,
+		`SELECT id, user_id, name, description, is_default, created_at, updated_at, deleted_at, is_deleted FROM prompt_template WHERE is_deleted = false AND (user_id = $1 OR user_id IS NULL) AND id = $2`,
+		userId,
+		id,
+	)
+}
+
+// GetByName prefers the template of the user over a built-in of that name.
+func (n *promptTemplateRepository) GetByName(ctx context.Context, userId uuid.UUID, name string) (*entity.PromptTemplate, error) {
+	return n.getOne(
+		ctx,
+		`SELECT id, user_id, name, description, is_default, created_at, updated_at, deleted_at, is_deleted FROM prompt_template WHERE is_deleted = false AND (user_id = $1 OR user_id IS NULL) AND name = $2 ORDER BY user_id NULLS LAST LIMIT 1`,
+		userId,
+		name,
+	)
+}
+
+// GetDefault prefers the default of the user over the built-in default.
+func (n *promptTemplateRepository) GetDefault(ctx context.Context, userId uuid.UUID) (*entity.PromptTemplate, error) {
+	return n.getOne(
+		ctx,
+		`SELECT id, user_id, name, description, is_default, created_at, updated_at, deleted_at, is_deleted FROM prompt_template WHERE is_deleted = false AND (user_id = $1 OR user_id IS NULL) AND is_default = true ORDER BY user_id NULLS LAST LIMIT 1`,
+		userId,
+	)
+}
+
+func (n *promptTemplateRepository) getOne(ctx context.Context, query string, args ...any) (*entity.PromptTemplate, error) {
+	row := n.db.QueryRow(ctx, query, args...)
+
+	var promptTemplate entity.PromptTemplate
+	err := row.Scan(
+		&promptTemplate.Id,
+		&promptTemplate.UserId,
+		&promptTemplate.Name,
+		&promptTemplate.Description,
+		&promptTemplate.IsDefault,
+		&promptTemplate.CreatedAt,
+		&promptTemplate.UpdatedAt,
+		&promptTemplate.DeletedAt,
+		&promptTemplate.IsDeleted,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &promptTemplate, nil
+}
+
+// ClearDefault only touches the templates of userId, the built-in default
+// stays.
+func (n *promptTemplateRepository) ClearDefault(ctx context.Context, userId uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE prompt_template SET is_default = false, updated_at = $1 WHERE user_id = $2 AND is_default = true`,
+		time.Now(),
+		userId,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *promptTemplateRepository) DeleteById(ctx context.Context, id uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE prompt_template SET is_deleted = true, is_default = false, deleted_at = $1 WHERE id = $2`,
+		time.Now(),
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/pkg/database"
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IPromptTemplateVersionRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) IPromptTemplateVersionRepository
+	Create(ctx context.Context, version *entity.PromptTemplateVersion) error
+	GetById(ctx context.Context, id uuid.UUID) (*entity.PromptTemplateVersion, error)
+	GetLatestByTemplateId(ctx context.Context, promptTemplateId uuid.UUID) (*entity.PromptTemplateVersion, error)
+	GetByTemplateId(ctx context.Context, promptTemplateId uuid.UUID) ([]*entity.PromptTemplateVersion, error)
+}
+
+type promptTemplateVersionRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewPromptTemplateVersionRepository(db *pgxpool.Pool) IPromptTemplateVersionRepository {
+	return &promptTemplateVersionRepository{
+		db: db,
+	}
+}
+
+func (n *promptTemplateVersionRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) IPromptTemplateVersionRepository {
+	return &promptTemplateVersionRepository{
+		db: tx,
+	}
+}
+
+// Create stores the next version of a template, the version number is
+// assigned here so concurrent saves of the same template never collide.
+func (n *promptTemplateVersionRepository) Create(ctx context.Context, version *entity.PromptTemplateVersion) error {
+	row := n.db.QueryRow(
+		ctx,
+		`INSERT INTO prompt_template_version (id, prompt_template_id, version, user_prompt, model_prompt, variables, created_at)
+		VALUES ($1, $2, (SELECT COALESCE(MAX(version), 0) + 1 FROM prompt_template_version WHERE prompt_template_id = $2), $3, $4, $5, $6)
+		RETURNING version`,
+		version.Id,
+		version.PromptTemplateId,
+		version.UserPrompt,
+		version.ModelPrompt,
+		version.Variables,
+		version.CreatedAt,
+	)
+
+	return row.Scan(&version.Version)
+}
+
+func (n *promptTemplateVersionRepository) GetById(ctx context.Context, id uuid.UUID) (*entity.PromptTemplateVersion, error) {
+	row := n.db.QueryRow(
+		ctx,
+		`SELECT id, prompt_template_id, version, user_prompt, model_prompt, variables, created_at FROM prompt_template_version WHERE id = $1`,
+		id,
+	)
+
+	return scanPromptTemplateVersion(row)
+}
+
+func (n *promptTemplateVersionRepository) GetLatestByTemplateId(ctx context.Context, promptTemplateId uuid.UUID) (*entity.PromptTemplateVersion, error) {
+	row := n.db.QueryRow(
+		ctx,
+		`SELECT id, prompt_template_id, version, user_prompt, model_prompt, variables, created_at FROM prompt_template_version WHERE prompt_template_id = $1 ORDER BY version DESC LIMIT 1`,
+		promptTemplateId,
+	)
+
+	return scanPromptTemplateVersion(row)
+}
+
+func (n *promptTemplateVersionRepository) GetByTemplateId(ctx context.Context, promptTemplateId uuid.UUID) ([]*entity.PromptTemplateVersion, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id, prompt_template_id, version, user_prompt, model_prompt, variables, created_at FROM prompt_template_version WHERE prompt_template_id = $1 ORDER BY version DESC`,
+		promptTemplateId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*entity.PromptTemplateVersion, 0)
+	for rows.Next() {
+		version, err := scanPromptTemplateVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, version)
+	}
+
+	return result, nil
+}
+
+func scanPromptTemplateVersion(row pgx.Row) (*entity.PromptTemplateVersion, error) {
+	var version entity.PromptTemplateVersion
+	err := row.Scan(
+		&version.Id,
+		&version.PromptTemplateId,
+		&version.Version,
+		&version.UserPrompt,
+		&version.ModelPrompt,
+		&version.Variables,
+		&version.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &version, nil
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/pkg/database"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IRefreshTokenRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) IRefreshTokenRepository
+	Create(ctx context.Context, refreshToken *entity.RefreshToken) error
+	GetById(ctx context.Context, id uuid.UUID) (*entity.RefreshToken, error)
+	Revoke(ctx context.Context, id uuid.UUID) error
+}
+
+type refreshTokenRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewRefreshTokenRepository(db *pgxpool.Pool) IRefreshTokenRepository {
+	return &refreshTokenRepository{
+		db: db,
+	}
+}
+
+func (n *refreshTokenRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) IRefreshTokenRepository {
+	return &refreshTokenRepository{
+		db: tx,
+	}
+}
+
+func (n *refreshTokenRepository) Create(ctx context.Context, refreshToken *entity.RefreshToken) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO refresh_token (id, user_id, expires_at, revoked_at, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		refreshToken.Id,
+		refreshToken.UserId,
+		refreshToken.ExpiresAt,
+		refreshToken.RevokedAt,
+		refreshToken.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *refreshTokenRepository) GetById(ctx context.Context, id uuid.UUID) (*entity.RefreshToken, error) {
+	row := n.db.QueryRow(
+		ctx,
+		`SELECT id, user_id, expires_at, revoked_at, created_at FROM refresh_token WHERE id = $1`,
+		id,
+	)
+
+	var refreshToken entity.RefreshToken
+	err := row.Scan(
+		&refreshToken.Id,
+		&refreshToken.UserId,
+		&refreshToken.ExpiresAt,
+		&refreshToken.RevokedAt,
+		&refreshToken.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &refreshToken, nil
+}
+
+// Revoke only affects a token that is still active, so a refresh token can
+// be rotated at most once even under concurrent requests.
+func (n *refreshTokenRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	tag, err := n.db.Exec(
+		ctx,
+		`UPDATE refresh_token SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`,
+		time.Now(),
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return serverutils.ErrUnauthorized
+	}
+
+	return nil
+}
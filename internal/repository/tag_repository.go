@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/pkg/database"
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ITagRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) ITagRepository
+	Create(ctx context.Context, tag *entity.Tag) error
+	Update(ctx context.Context, tag *entity.Tag) error
+	DeleteById(ctx context.Context, id uuid.UUID) error
+	GetById(ctx context.Context, userId uuid.UUID, id uuid.UUID) (*entity.Tag, error)
+	GetByIds(ctx context.Context, userId uuid.UUID, ids []uuid.UUID) ([]*entity.Tag, error)
+	GetByName(ctx context.Context, userId uuid.UUID, name string) (*entity.Tag, error)
+	GetByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.Tag, error)
+	CountNotes(ctx context.Context, userId uuid.UUID, notebookIds []uuid.UUID) (map[uuid.UUID]int, error)
+}
+
+type tagRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewTagRepository(db *pgxpool.Pool) ITagRepository {
+	return &tagRepository{
+		db: db,
+	}
+}
+
+func (n *tagRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) ITagRepository {
+	return &tagRepository{
+		db: tx,
+	}
+}
+
+func (n *tagRepository) Create(ctx context.Context, tag *entity.Tag) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO tag (id, user_id, name, created_at, updated_at) VALUES ($1, $2, $3, $4, $5)`,
+		tag.Id,
+		tag.UserId,
+		tag.Name,
+		tag.CreatedAt,
+		tag.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *tagRepository) Update(ctx context.Context, tag *entity.Tag) error {
+	_, err := n.db.Exec(
+		ctx,
+		`UPDATE tag SET name = $1, updated_at = $2 WHERE id = $3`,
+		tag.Name,
+		tag.UpdatedAt,
+		tag.Id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteById expects the tag to be detached from notes and chat sessions.
+func (n *tagRepository) DeleteById(ctx context.Context, id uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM tag WHERE id = $1`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *tagRepository) GetById(ctx context.Context, userId uuid.UUID, id uuid.UUID) (*entity.Tag, error) {
+	row := n.db.QueryRow(
+		ctx,
+		`SELECT id, user_id, name, created_at, updated_at FROM tag WHERE id = $1 AND user_id = $2`,
+		id,
+		userId,
+	)
+
+	return scanTag(row)
+}
+
+// GetByIds leaves out the ids that are not tags of the user.
+func (n *tagRepository) GetByIds(ctx context.Context, userId uuid.UUID, ids []uuid.UUID) ([]*entity.Tag, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id, user_id, name, created_at, updated_at FROM tag WHERE id = ANY($1) AND user_id = $2 ORDER BY lower(name)`,
+		ids,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTags(rows)
+}
+
+// GetByName matches name case-insensitively.
+func (n *tagRepository) GetByName(ctx context.Context, userId uuid.UUID, name string) (*entity.Tag, error) {
+	row := n.db.QueryRow(
+		ctx,
+		`SELECT id, user_id, name, created_at, updated_at FROM tag WHERE user_id = $1 AND lower(name) = lower($2)`,
+		userId,
+		name,
+	)
+
+	return scanTag(row)
+}
+
+func (n *tagRepository) GetByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.Tag, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id, user_id, name, created_at, updated_at FROM tag WHERE user_id = $1 ORDER BY lower(name)`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTags(rows)
+}
+
+// CountNotes counts, for every tag of the user, the notes carrying it in
+// notebookIds. Tags without such notes are left out.
+func (n *tagRepository) CountNotes(ctx context.Context, userId uuid.UUID, notebookIds []uuid.UUID) (map[uuid.UUID]int, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT nt.tag_id, COUNT(*) FROM note_tag nt JOIN tag t ON t.id = nt.tag_id JOIN note n ON n.id = nt.note_id WHERE t.user_id = $1 AND n.is_deleted = false AND n.notebook_id = ANY($2) GROUP BY nt.tag_id`,
+		userId,
+		notebookIds,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[uuid.UUID]int)
+	for rows.Next() {
+		var tagId uuid.UUID
+		var count int
+		err = rows.Scan(&tagId, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		res[tagId] = count
+	}
+
+	return res, rows.Err()
+}
+
+func scanTags(rows pgx.Rows) ([]*entity.Tag, error) {
+	res := make([]*entity.Tag, 0)
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, tag)
+	}
+
+	return res, rows.Err()
+}
+
+func scanTag(row pgx.Row) (*entity.Tag, error) {
+	var tag entity.Tag
+	err := row.Scan(
+		&tag.Id,
+		&tag.UserId,
+		&tag.Name,
+		&tag.CreatedAt,
+		&tag.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &tag, nil
+}
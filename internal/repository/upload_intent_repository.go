@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/pkg/database"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IUploadIntentRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) IUploadIntentRepository
+	Create(ctx context.Context, uploadIntent *entity.UploadIntent) error
+	GetById(ctx context.Context, id uuid.UUID) (*entity.UploadIntent, error)
+	Complete(ctx context.Context, id uuid.UUID, completedAt time.Time) error
+	HardDeleteByNoteIds(ctx context.Context, noteIds []uuid.UUID) error
+}
+
+type uploadIntentRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewUploadIntentRepository(db *pgxpool.Pool) IUploadIntentRepository {
+	return &uploadIntentRepository{
+		db: db,
+	}
+}
+
+func (n *uploadIntentRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) IUploadIntentRepository {
+	return &uploadIntentRepository{
+		db: tx,
+	}
+}
+
+func (n *uploadIntentRepository) Create(ctx context.Context, uploadIntent *entity.UploadIntent) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO upload_intent (id, note_id, user_id, bucket, file_name, original_name, content_type, size, status, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		uploadIntent.Id,
+		uploadIntent.NoteId,
+		uploadIntent.UserId,
+		uploadIntent.Bucket,
+		uploadIntent.FileName,
+		uploadIntent.OriginalName,
+		uploadIntent.ContentType,
+		uploadIntent.Size,
+		uploadIntent.Status,
+		uploadIntent.ExpiresAt,
+		uploadIntent.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *uploadIntentRepository) GetById(ctx context.Context, id uuid.UUID) (*entity.UploadIntent, error) {
+	var uploadIntent entity.UploadIntent
+	err := n.db.QueryRow(
+		ctx,
+		`SELECT id, note_id, user_id, bucket, file_name, original_name, content_type, size, status, expires_at, created_at, completed_at
+		FROM upload_intent WHERE id = $1`,
+		id,
+	).Scan(
+		&uploadIntent.Id,
+		&uploadIntent.NoteId,
+		&uploadIntent.UserId,
+		&uploadIntent.Bucket,
+		&uploadIntent.FileName,
+		&uploadIntent.OriginalName,
+		&uploadIntent.ContentType,
+		&uploadIntent.Size,
+		&uploadIntent.Status,
+		&uploadIntent.ExpiresAt,
+		&uploadIntent.CreatedAt,
+		&uploadIntent.CompletedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &uploadIntent, nil
+}
+
+// Complete marks a pending intent as completed, ErrConflict when it was
+// completed already.
+func (n *uploadIntentRepository) Complete(ctx context.Context, id uuid.UUID, completedAt time.Time) error {
+	tag, err := n.db.Exec(
+		ctx,
+		`UPDATE upload_intent SET status = 'completed', completed_at = $1 WHERE id = $2 AND status = 'pending'`,
+		completedAt,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return serverutils.ErrConflict
+	}
+
+	return nil
+}
+
+func (n *uploadIntentRepository) HardDeleteByNoteIds(ctx context.Context, noteIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM upload_intent WHERE note_id = ANY($1)`,
+		noteIds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
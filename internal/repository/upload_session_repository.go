@@ -0,0 +1,289 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/pkg/database"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IUploadSessionRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) IUploadSessionRepository
+	Create(ctx context.Context, uploadSession *entity.UploadSession) error
+	GetById(ctx context.Context, id uuid.UUID) (*entity.UploadSession, error)
+	GetStale(ctx context.Context, updatedBefore time.Time, limit int) ([]*entity.UploadSession, error)
+	StartCompleting(ctx context.Context, id uuid.UUID, updatedAt time.Time) error
+	Complete(ctx context.Context, id uuid.UUID, completedAt time.Time) error
+	Abort(ctx context.Context, id uuid.UUID, status string, abortedAt time.Time) error
+	SavePart(ctx context.Context, part *entity.UploadSessionPart) error
+	GetParts(ctx context.Context, uploadSessionId uuid.UUID) ([]*entity.UploadSessionPart, error)
+	HardDeleteByNoteIds(ctx context.Context, noteIds []uuid.UUID) error
+}
+
+type uploadSessionRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewUploadSessionRepository(db *pgxpool.Pool) IUploadSessionRepository {
+	return &uploadSessionRepository{
+		db: db,
+	}
+}
+
+func (n *uploadSessionRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) IUploadSessionRepository {
+	return &uploadSessionRepository{
+		db: tx,
+	}
+}
+
+func (n *uploadSessionRepository) Create(ctx context.Context, uploadSession *entity.UploadSession) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO upload_session (id, note_id, user_id, bucket, file_name, original_name, content_type, size, part_size, multipart_upload_id, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		uploadSession.Id,
+		uploadSession.NoteId,
+		uploadSession.UserId,
+		uploadSession.Bucket,
+		uploadSession.FileName,
+		uploadSession.OriginalName,
+		uploadSession.ContentType,
+		uploadSession.Size,
+		uploadSession.PartSize,
+		uploadSession.MultipartUploadId,
+		uploadSession.Status,
+		uploadSession.CreatedAt,
+		uploadSession.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *uploadSessionRepository) GetById(ctx context.Context, id uuid.UUID) (*entity.UploadSession, error) {
+	var uploadSession entity.UploadSession
+	err := n.db.QueryRow(
+		ctx,
+		`SELECT id, note_id, user_id, bucket, file_name, original_name, content_type, size, part_size, multipart_upload_id, status, created_at, updated_at, completed_at
+		FROM upload_session WHERE id = $1`,
+		id,
+	).Scan(
+		&uploadSession.Id,
+		&uploadSession.NoteId,
+		&uploadSession.UserId,
+		&uploadSession.Bucket,
+		&uploadSession.FileName,
+		&uploadSession.OriginalName,
+		&uploadSession.ContentType,
+		&uploadSession.Size,
+		&uploadSession.PartSize,
+		&uploadSession.MultipartUploadId,
+		&uploadSession.Status,
+		&uploadSession.CreatedAt,
+		&uploadSession.UpdatedAt,
+		&uploadSession.CompletedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &uploadSession, nil
+}
+
+// GetStale returns pending or completing sessions without activity since
+// updatedBefore, oldest first.
+func (n *uploadSessionRepository) GetStale(ctx context.Context, updatedBefore time.Time, limit int) ([]*entity.UploadSession, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT id, bucket, file_name, multipart_upload_id
+		FROM upload_session
+		WHERE status IN ('pending', 'completing') AND updated_at < $1
+		ORDER BY updated_at ASC
+		LIMIT $2`,
+		updatedBefore,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*entity.UploadSession, 0)
+	for rows.Next() {
+		var uploadSession entity.UploadSession
+		err = rows.Scan(
+			&uploadSession.Id,
+			&uploadSession.Bucket,
+			&uploadSession.FileName,
+			&uploadSession.MultipartUploadId,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &uploadSession)
+	}
+
+	return result, rows.Err()
+}
+
+// StartCompleting marks a pending session as completing, ErrConflict when it
+// is not pending anymore.
+func (n *uploadSessionRepository) StartCompleting(ctx context.Context, id uuid.UUID, updatedAt time.Time) error {
+	tag, err := n.db.Exec(
+		ctx,
+		`UPDATE upload_session SET status = 'completing', updated_at = $1 WHERE id = $2 AND status = 'pending'`,
+		updatedAt,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return serverutils.ErrConflict
+	}
+
+	return nil
+}
+
+// Complete marks a completing session as completed, ErrConflict when it is
+// not completing anymore.
+func (n *uploadSessionRepository) Complete(ctx context.Context, id uuid.UUID, completedAt time.Time) error {
+	tag, err := n.db.Exec(
+		ctx,
+		`UPDATE upload_session SET status = 'completed', updated_at = $1, completed_at = $1 WHERE id = $2 AND status = 'completing'`,
+		completedAt,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return serverutils.ErrConflict
+	}
+
+	return nil
+}
+
+// Abort moves a session that is still in status to aborted, ErrConflict when
+// it moved on meanwhile and its objects are not the caller's to discard.
+func (n *uploadSessionRepository) Abort(ctx context.Context, id uuid.UUID, status string, abortedAt time.Time) error {
+	var abortedId uuid.UUID
+	err := n.db.QueryRow(
+		ctx,
+		`UPDATE upload_session SET status = 'aborted', updated_at = $1 WHERE id = $2 AND status = $3 RETURNING id`,
+		abortedAt,
+		id,
+		status,
+	).Scan(&abortedId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return serverutils.ErrConflict
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// SavePart records an uploaded part, replacing an earlier upload of the same
+// part number, and counts as activity on the session.
+func (n *uploadSessionRepository) SavePart(ctx context.Context, part *entity.UploadSessionPart) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO upload_session_part (upload_session_id, part_number, etag, size, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (upload_session_id, part_number) DO UPDATE SET etag = EXCLUDED.etag, size = EXCLUDED.size, created_at = EXCLUDED.created_at`,
+		part.UploadSessionId,
+		part.PartNumber,
+		part.ETag,
+		part.Size,
+		part.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.db.Exec(
+		ctx,
+		`UPDATE upload_session SET updated_at = $1 WHERE id = $2`,
+		part.CreatedAt,
+		part.UploadSessionId,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetParts returns the uploaded parts ordered by part number.
+func (n *uploadSessionRepository) GetParts(ctx context.Context, uploadSessionId uuid.UUID) ([]*entity.UploadSessionPart, error) {
+	rows, err := n.db.Query(
+		ctx,
+		`SELECT upload_session_id, part_number, etag, size, created_at
+		FROM upload_session_part
+		WHERE upload_session_id = $1
+		ORDER BY part_number ASC`,
+		uploadSessionId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*entity.UploadSessionPart, 0)
+	for rows.Next() {
+		var part entity.UploadSessionPart
+		err = rows.Scan(
+			&part.UploadSessionId,
+			&part.PartNumber,
+			&part.ETag,
+			&part.Size,
+			&part.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &part)
+	}
+
+	return result, rows.Err()
+}
+
+func (n *uploadSessionRepository) HardDeleteByNoteIds(ctx context.Context, noteIds []uuid.UUID) error {
+	_, err := n.db.Exec(
+		ctx,
+		`DELETE FROM upload_session_part WHERE upload_session_id IN (SELECT id FROM upload_session WHERE note_id = ANY($1))`,
+		noteIds,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.db.Exec(
+		ctx,
+		`DELETE FROM upload_session WHERE note_id = ANY($1)`,
+		noteIds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/pkg/database"
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IUserRepository interface {
+	UsingTx(ctx context.Context, tx database.DatabaseQueryer) IUserRepository
+	Create(ctx context.Context, user *entity.User) error
+	GetById(ctx context.Context, id uuid.UUID) (*entity.User, error)
+	GetByEmail(ctx context.Context, email string) (*entity.User, error)
+}
+
+type userRepository struct {
+	db database.DatabaseQueryer
+}
+
+func NewUserRepository(db *pgxpool.Pool) IUserRepository {
+	return &userRepository{
+		db: db,
+	}
+}
+
+func (n *userRepository) UsingTx(ctx context.Context, tx database.DatabaseQueryer) IUserRepository {
+	return &userRepository{
+		db: tx,
+	}
+}
+
+func (n *userRepository) Create(ctx context.Context, user *entity.User) error {
+	_, err := n.db.Exec(
+		ctx,
+		`INSERT INTO "user" (id, email, name, password_hash, created_at, updated_at, deleted_at, is_deleted) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		user.Id,
+		user.Email,
+		user.Name,
+		user.PasswordHash,
+		user.CreatedAt,
+		user.UpdatedAt,
+		user.DeletedAt,
+		user.IsDeleted,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *userRepository) GetById(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	return n.getOne(
+		ctx,
+		`SELECT id, email, name, password_hash, created_at, updated_at, deleted_at, is_deleted FROM "user" WHERE id = $1 AND is_deleted = false`,
+		id,
+	)
+}
+
+func (n *userRepository) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
+	return n.getOne(
+		ctx,
+		`SELECT id, email, name, password_hash, created_at, updated_at, deleted_at, is_deleted FROM "user" WHERE lower(email) = lower($1) AND is_deleted = false`,
+		email,
+	)
+}
+
+func (n *userRepository) getOne(ctx context.Context, query string, args ...any) (*entity.User, error) {
+	row := n.db.QueryRow(ctx, query, args...)
+
+	var user entity.User
+	err := row.Scan(
+		&user.Id,
+		&user.Email,
+		&user.Name,
+		&user.PasswordHash,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.DeletedAt,
+		&user.IsDeleted,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"ai-notetaking-be/internal/constant"
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/repository"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	auditDefaultLimit = 50
+	auditMaxLimit     = 200
+)
+
+type IAuditService interface {
+	GetAll(ctx context.Context, req *dto.GetAuditEventsRequest) ([]*dto.AuditEventResponse, error)
+	GetNoteActivity(ctx context.Context, noteId uuid.UUID, req *dto.GetAuditEventsRequest) ([]*dto.AuditEventResponse, error)
+	GetNotebookActivity(ctx context.Context, notebookId uuid.UUID, req *dto.GetAuditEventsRequest) ([]*dto.AuditEventResponse, error)
+}
+
+type auditService struct {
+	auditEventRepository  repository.IAuditEventRepository
+	noteRepository        repository.INoteRepository
+	notebookAccessService INotebookAccessService
+}
+
+func NewAuditService(
+	auditEventRepository repository.IAuditEventRepository,
+	noteRepository repository.INoteRepository,
+	notebookAccessService INotebookAccessService,
+) IAuditService {
+	return &auditService{
+		auditEventRepository:  auditEventRepository,
+		noteRepository:        noteRepository,
+		notebookAccessService: notebookAccessService,
+	}
+}
+
+// GetAll lists the events the user made plus every event of the notebooks
+// they can currently read.
+func (c *auditService) GetAll(ctx context.Context, req *dto.GetAuditEventsRequest) ([]*dto.AuditEventResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := c.parseFilter(ctx, userId, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.getAll(ctx, filter)
+}
+
+func (c *auditService) GetNoteActivity(ctx context.Context, noteId uuid.UUID, req *dto.GetAuditEventsRequest) ([]*dto.AuditEventResponse, error) {
+	note, err := c.noteRepository.GetById(ctx, noteId)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := c.notebookAccessService.Authorize(ctx, note.NotebookId, constant.NotebookRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := c.parseFilter(ctx, userId, req)
+	if err != nil {
+		return nil, err
+	}
+	filter.NoteId = &noteId
+
+	return c.getAll(ctx, filter)
+}
+
+func (c *auditService) GetNotebookActivity(ctx context.Context, notebookId uuid.UUID, req *dto.GetAuditEventsRequest) ([]*dto.AuditEventResponse, error) {
+	userId, err := c.notebookAccessService.Authorize(ctx, notebookId, constant.NotebookRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := c.parseFilter(ctx, userId, req)
+	if err != nil {
+		return nil, err
+	}
+	filter.NotebookId = &notebookId
+
+	return c.getAll(ctx, filter)
+}
+
+func (c *auditService) getAll(ctx context.Context, filter *entity.AuditEventFilter) ([]*dto.AuditEventResponse, error) {
+	auditEvents, err := c.auditEventRepository.GetAll(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*dto.AuditEventResponse, 0)
+	for _, auditEvent := range auditEvents {
+		diff := make(map[string]*dto.AuditFieldChange)
+		for field, change := range auditEvent.Diff {
+			diff[field] = &dto.AuditFieldChange{
+				Before: change.Before,
+				After:  change.After,
+			}
+		}
+
+		response = append(response, &dto.AuditEventResponse{
+			Id:         auditEvent.Id,
+			ActorId:    auditEvent.ActorId,
+			Action:     auditEvent.Action,
+			EntityType: auditEvent.EntityType,
+			EntityId:   auditEvent.EntityId,
+			NotebookId: auditEvent.NotebookId,
+			NoteId:     auditEvent.NoteId,
+			Diff:       diff,
+			RequestId:  auditEvent.RequestId,
+			CreatedAt:  auditEvent.CreatedAt,
+		})
+	}
+
+	return response, nil
+}
+
+func (c *auditService) parseFilter(ctx context.Context, userId uuid.UUID, req *dto.GetAuditEventsRequest) (*entity.AuditEventFilter, error) {
+	notebookIds, err := c.notebookAccessService.ReadableNotebookIds(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := entity.AuditEventFilter{
+		VisibleTo:          userId,
+		VisibleNotebookIds: notebookIds,
+		Action:             req.Action,
+		EntityType:         req.EntityType,
+		Limit:              req.Limit,
+		Offset:             req.Offset,
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = auditDefaultLimit
+	}
+	if filter.Limit > auditMaxLimit {
+		filter.Limit = auditMaxLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	ids := []struct {
+		name  string
+		value string
+		dest  **uuid.UUID
+	}{
+		{"actor_id", req.ActorId, &filter.ActorId},
+		{"entity_id", req.EntityId, &filter.EntityId},
+		{"notebook_id", req.NotebookId, &filter.NotebookId},
+		{"note_id", req.NoteId, &filter.NoteId},
+	}
+	for _, id := range ids {
+		if id.value == "" {
+			continue
+		}
+
+		parsed, err := uuid.Parse(id.value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be a UUID", serverutils.ErrBadRequest, id.name)
+		}
+		*id.dest = &parsed
+	}
+
+	times := []struct {
+		name  string
+		value string
+		dest  **time.Time
+	}{
+		{"from", req.From, &filter.From},
+		{"to", req.To, &filter.To},
+	}
+	for _, t := range times {
+		if t.value == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, t.value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be formatted as RFC 3339", serverutils.ErrBadRequest, t.name)
+		}
+		*t.dest = &parsed
+	}
+
+	return &filter, nil
+}
+
+// newAuditEvent describes a mutation by the user of the request. before and
+// after are snapshots of the entity, nil on creation and deletion
+// respectively, and only their differing fields are kept.
+func newAuditEvent(ctx context.Context, action string, entityType string, entityId uuid.UUID, before any, after any) (*entity.AuditEvent, error) {
+	diff, err := auditDiff(before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	auditEvent := entity.AuditEvent{
+		Id:         uuid.New(),
+		Action:     action,
+		EntityType: entityType,
+		EntityId:   entityId,
+		Diff:       diff,
+		RequestId:  serverutils.GetRequestId(ctx),
+		CreatedAt:  time.Now(),
+	}
+
+	userId, err := serverutils.GetUserId(ctx)
+	if err == nil {
+		auditEvent.ActorId = &userId
+	}
+
+	return &auditEvent, nil
+}
+
+func auditDiff(before any, after any) (map[string]*entity.AuditFieldChange, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := make(map[string]*entity.AuditFieldChange)
+	for field, value := range beforeFields {
+		if !reflect.DeepEqual(value, afterFields[field]) {
+			diff[field] = &entity.AuditFieldChange{Before: value, After: afterFields[field]}
+		}
+	}
+	for field, value := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			diff[field] = &entity.AuditFieldChange{After: value}
+		}
+	}
+
+	return diff, nil
+}
+
+// auditFields flattens a snapshot through its JSON form, so the diff holds
+// the values the way they are stored.
+func auditFields(snapshot any) (map[string]any, error) {
+	fields := make(map[string]any)
+	value := reflect.ValueOf(snapshot)
+	if snapshot == nil || (value.Kind() == reflect.Pointer && value.IsNil()) {
+		return fields, nil
+	}
+
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(raw, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+)
+
+type IAuthService interface {
+	Register(ctx context.Context, req *dto.RegisterRequest) (*dto.AuthResponse, error)
+	Login(ctx context.Context, req *dto.LoginRequest) (*dto.AuthResponse, error)
+	Refresh(ctx context.Context, req *dto.RefreshTokenRequest) (*dto.AuthResponse, error)
+	Logout(ctx context.Context, req *dto.LogoutRequest) error
+	Me(ctx context.Context) (*dto.UserResponse, error)
+	VerifyAccessToken(ctx context.Context, token string) (uuid.UUID, error)
+}
+
+type authService struct {
+	userRepository         repository.IUserRepository
+	refreshTokenRepository repository.IRefreshTokenRepository
+	jwtSecret              []byte
+	db                     *pgxpool.Pool
+}
+
+func NewAuthService(
+	userRepository repository.IUserRepository,
+	refreshTokenRepository repository.IRefreshTokenRepository,
+	jwtSecret string,
+	db *pgxpool.Pool,
+) IAuthService {
+	return &authService{
+		userRepository:         userRepository,
+		refreshTokenRepository: refreshTokenRepository,
+		jwtSecret:              []byte(jwtSecret),
+		db:                     db,
+	}
+}
+
+type authClaims struct {
+	TokenType string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+func (c *authService) Register(ctx context.Context, req *dto.RegisterRequest) (*dto.AuthResponse, error) {
+	_, err := c.userRepository.GetByEmail(ctx, req.Email)
+	if err == nil {
+		return nil, fmt.Errorf("%w: email %s is already registered", serverutils.ErrBadRequest, req.Email)
+	}
+	if !errors.Is(err, serverutils.ErrNotFound) {
+		return nil, err
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := entity.User{
+		Id:           uuid.New(),
+		Email:        strings.TrimSpace(req.Email),
+		Name:         req.Name,
+		PasswordHash: string(passwordHash),
+		CreatedAt:    time.Now(),
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = c.userRepository.UsingTx(ctx, tx).Create(ctx, &user)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.issueTokens(ctx, c.refreshTokenRepository.UsingTx(ctx, tx), &user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (c *authService) Login(ctx context.Context, req *dto.LoginRequest) (*dto.AuthResponse, error) {
+	user, err := c.userRepository.GetByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, serverutils.ErrNotFound) {
+			return nil, serverutils.ErrUnauthorized
+		}
+		return nil, err
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
+	if err != nil {
+		return nil, serverutils.ErrUnauthorized
+	}
+
+	return c.issueTokens(ctx, c.refreshTokenRepository, user)
+}
+
+// Refresh rotates the refresh token: the presented one is revoked and a new
+// pair is issued, so a stolen refresh token only works once.
+func (c *authService) Refresh(ctx context.Context, req *dto.RefreshTokenRequest) (*dto.AuthResponse, error) {
+	claims, err := c.parseToken(req.RefreshToken, tokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenId, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, serverutils.ErrUnauthorized
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	refreshTokenRepository := c.refreshTokenRepository.UsingTx(ctx, tx)
+
+	refreshToken, err := refreshTokenRepository.GetById(ctx, tokenId)
+	if err != nil {
+		if errors.Is(err, serverutils.ErrNotFound) {
+			return nil, serverutils.ErrUnauthorized
+		}
+		return nil, err
+	}
+
+	if refreshToken.RevokedAt != nil || time.Now().After(refreshToken.ExpiresAt) {
+		return nil, serverutils.ErrUnauthorized
+	}
+
+	err = refreshTokenRepository.Revoke(ctx, refreshToken.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := c.userRepository.UsingTx(ctx, tx).GetById(ctx, refreshToken.UserId)
+	if err != nil {
+		if errors.Is(err, serverutils.ErrNotFound) {
+			return nil, serverutils.ErrUnauthorized
+		}
+		return nil, err
+	}
+
+	res, err := c.issueTokens(ctx, refreshTokenRepository, user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (c *authService) Logout(ctx context.Context, req *dto.LogoutRequest) error {
+	claims, err := c.parseToken(req.RefreshToken, tokenTypeRefresh)
+	if err != nil {
+		return err
+	}
+
+	tokenId, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return serverutils.ErrUnauthorized
+	}
+
+	return c.refreshTokenRepository.Revoke(ctx, tokenId)
+}
+
+func (c *authService) Me(ctx context.Context) (*dto.UserResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := c.userRepository.GetById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.UserResponse{
+		Id:        user.Id,
+		Email:     user.Email,
+		Name:      user.Name,
+		CreatedAt: user.CreatedAt,
+	}, nil
+}
+
+// VerifyAccessToken is the token check of the auth middleware.
+func (c *authService) VerifyAccessToken(ctx context.Context, token string) (uuid.UUID, error) {
+	claims, err := c.parseToken(token, tokenTypeAccess)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	userId, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, serverutils.ErrUnauthorized
+	}
+
+	return userId, nil
+}
+
+func (c *authService) issueTokens(ctx context.Context, refreshTokenRepository repository.IRefreshTokenRepository, user *entity.User) (*dto.AuthResponse, error) {
+	now := time.Now()
+
+	accessToken, err := c.signToken(user.Id, uuid.New(), tokenTypeAccess, now, now.Add(accessTokenTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken := entity.RefreshToken{
+		Id:        uuid.New(),
+		UserId:    user.Id,
+		ExpiresAt: now.Add(refreshTokenTTL),
+		CreatedAt: now,
+	}
+
+	err = refreshTokenRepository.Create(ctx, &refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	signedRefreshToken, err := c.signToken(user.Id, refreshToken.Id, tokenTypeRefresh, now, refreshToken.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: signedRefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+		User: &dto.UserResponse{
+			Id:        user.Id,
+			Email:     user.Email,
+			Name:      user.Name,
+			CreatedAt: user.CreatedAt,
+		},
+	}, nil
+}
+
+func (c *authService) signToken(userId uuid.UUID, tokenId uuid.UUID, tokenType string, issuedAt time.Time, expiresAt time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, authClaims{
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenId.String(),
+			Subject:   userId.String(),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+
+	return token.SignedString(c.jwtSecret)
+}
+
+// parseToken validates the signature, expiry and type of a token. An access
+// token is never accepted where a refresh token is expected and vice versa.
+func (c *authService) parseToken(token string, tokenType string) (*authClaims, error) {
+	var claims authClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		return c.jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, serverutils.ErrUnauthorized
+	}
+
+	if claims.TokenType != tokenType {
+		return nil, serverutils.ErrUnauthorized
+	}
+
+	return &claims, nil
+}
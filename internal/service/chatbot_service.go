@@ -4,33 +4,62 @@ import (
 	"ai-notetaking-be/internal/constant"
 	"ai-notetaking-be/internal/dto"
 	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
 	"ai-notetaking-be/internal/repository"
 	"ai-notetaking-be/pkg/chatbot"
 	"ai-notetaking-be/pkg/embedding"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type IChatbotService interface {
-	CreateSession(ctx context.Context) (*dto.CreateSessionResponse, error)
+	CreateSession(ctx context.Context, request *dto.CreateSessionRequest) (*dto.CreateSessionResponse, error)
 	GetAllSession(ctx context.Context) ([]*dto.GetAllSessionResponse, error)
 	GetChatHistory(ctx context.Context, sessionId uuid.UUID) ([]*dto.GetChatHistoryResponse, error)
 	SendChat(ctx context.Context, request *dto.SendChatRequest) (*dto.SendChatResponse, error)
 	DeleteSession(ctx context.Context, sessionId *dto.DeleteSessionRequest) error
+	RegenerateChat(ctx context.Context, request *dto.RegenerateChatRequest) (*dto.SendChatResponse, error)
+	EditChat(ctx context.Context, request *dto.EditChatRequest) (*dto.SendChatResponse, error)
+	GetChatBranches(ctx context.Context, sessionId uuid.UUID, messageId uuid.UUID) ([]*dto.GetChatBranchesResponse, error)
+	SwitchBranch(ctx context.Context, request *dto.SwitchBranchRequest) ([]*dto.GetChatHistoryResponse, error)
+	AgentChat(ctx context.Context, request *dto.SendChatRequest) (*dto.SendChatResponse, error)
+	ConfirmToolCall(ctx context.Context, request *dto.ConfirmToolCallRequest) (*dto.ConfirmToolCallResponse, error)
+	SetSessionTags(ctx context.Context, request *dto.SetSessionTagsRequest) (*dto.SetSessionTagsResponse, error)
 }
 
+// agentMaxToolRounds caps how many times the agent may call tools before it
+// has to answer, so a confused model cannot loop forever.
+const agentMaxToolRounds = 5
+
+// maxRetrievalReferences caps the chunks sent to the model when the planner
+// splits a question into several sub-queries.
+const maxRetrievalReferences = 8
+
 type chatbotService struct {
-	db                       *pgxpool.Pool
-	chatSessionRepository    repository.IChatSessionRepository
-	chatMessageRepository    repository.IChatMessageRepository
-	chatMessageRawRepository repository.IChatMessageRawRepository
-	notEmbeddingRepository   repository.INoteEmbeddingRepository
+	db                          *pgxpool.Pool
+	chatSessionRepository       repository.IChatSessionRepository
+	chatMessageRepository       repository.IChatMessageRepository
+	chatMessageRawRepository    repository.IChatMessageRawRepository
+	notEmbeddingRepository      repository.INoteEmbeddingRepository
+	chatToolCallRepository      repository.IChatToolCallRepository
+	chatRetrievalPlanRepository repository.IChatRetrievalPlanRepository
+	promptTemplateService       IPromptTemplateService
+	usageService                IUsageService
+	notebookAccessService       INotebookAccessService
+	auditEventRepository        repository.IAuditEventRepository
+	tagRepository               repository.ITagRepository
+	chatSessionTagRepository    repository.IChatSessionTagRepository
+	tools                       chatbotToolRegistry
 }
 
 func NewChatbotService(
@@ -39,23 +68,76 @@ func NewChatbotService(
 	chatMessageRepository repository.IChatMessageRepository,
 	chatMessageRawRepository repository.IChatMessageRawRepository,
 	notEmbeddingRepository repository.INoteEmbeddingRepository,
+	chatToolCallRepository repository.IChatToolCallRepository,
+	chatRetrievalPlanRepository repository.IChatRetrievalPlanRepository,
+	promptTemplateService IPromptTemplateService,
+	usageService IUsageService,
+	noteService INoteService,
+	notebookRepository repository.INotebookRepository,
+	notebookAccessService INotebookAccessService,
+	auditEventRepository repository.IAuditEventRepository,
+	tagRepository repository.ITagRepository,
+	chatSessionTagRepository repository.IChatSessionTagRepository,
 ) IChatbotService {
 	return &chatbotService{
-		db:                       db,
-		chatSessionRepository:    chatSessionRepository,
-		chatMessageRepository:    chatMessageRepository,
-		chatMessageRawRepository: chatMessageRawRepository,
-		notEmbeddingRepository:   notEmbeddingRepository,
+		db:                          db,
+		chatSessionRepository:       chatSessionRepository,
+		chatMessageRepository:       chatMessageRepository,
+		chatMessageRawRepository:    chatMessageRawRepository,
+		notEmbeddingRepository:      notEmbeddingRepository,
+		chatToolCallRepository:      chatToolCallRepository,
+		chatRetrievalPlanRepository: chatRetrievalPlanRepository,
+		promptTemplateService:       promptTemplateService,
+		usageService:                usageService,
+		notebookAccessService:       notebookAccessService,
+		auditEventRepository:        auditEventRepository,
+		tagRepository:               tagRepository,
+		chatSessionTagRepository:    chatSessionTagRepository,
+		tools:                       newChatbotToolRegistry(noteService, notebookRepository, notebookAccessService),
 	}
 }
 
-func (c *chatbotService) CreateSession(ctx context.Context) (*dto.CreateSessionResponse, error) {
+func (c *chatbotService) CreateSession(ctx context.Context, request *dto.CreateSessionRequest) (*dto.CreateSessionResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	initialUserPrompt := constant.ChatMessageRawInititalUserPromptV1
+	initialModelPrompt := constant.ChatMessageRawInititalModelPromptV1
+
+	// Without any default template the built-in prompts are used, so a fresh
+	// database still gets working sessions.
+	var promptTemplateVersionId *uuid.UUID
+	promptTemplateVersion, err := c.promptTemplateService.ResolveVersion(ctx, request.PromptTemplateId, request.Persona)
+	if err == nil {
+		initialUserPrompt, err = renderPromptTemplate(promptTemplateVersion.UserPrompt, request.Variables)
+		if err != nil {
+			return nil, err
+		}
+
+		initialModelPrompt, err = renderPromptTemplate(promptTemplateVersion.ModelPrompt, request.Variables)
+		if err != nil {
+			return nil, err
+		}
+
+		promptTemplateVersionId = &promptTemplateVersion.Id
+	} else if !errors.Is(err, serverutils.ErrNotFound) || request.PromptTemplateId != nil || request.Persona != "" {
+		return nil, err
+	}
+
+	tagIds, err := c.ownTagIds(ctx, userId, request.TagIds)
+	if err != nil {
+		return nil, err
+	}
 
 	now := time.Now()
 	chatSession := &entity.ChatSession{
-		Id:        uuid.New(),
-		Title:     "Unamed session",
-		CreatedAt: now,
+		Id:                      uuid.New(),
+		Title:                   "Unamed session",
+		UserId:                  userId,
+		PromptTemplateVersionId: promptTemplateVersionId,
+		CreatedAt:               now,
 	}
 
 	chatMessage := &entity.ChatMessage{
@@ -65,10 +147,11 @@ func (c *chatbotService) CreateSession(ctx context.Context) (*dto.CreateSessionR
 		ChatSessionId: chatSession.Id,
 		CreatedAt:     now,
 	}
+	chatSession.ActiveMessageId = &chatMessage.Id
 
 	chatMessageRawUser := &entity.ChatMessageRaw{
 		Id:            uuid.New(),
-		Chat:          constant.ChatMessageRawInititalUserPromptV1,
+		Chat:          initialUserPrompt,
 		Role:          constant.ChatMessageRoleUser,
 		ChatSessionId: chatSession.Id,
 		CreatedAt:     now,
@@ -76,9 +159,11 @@ func (c *chatbotService) CreateSession(ctx context.Context) (*dto.CreateSessionR
 
 	chatMessageRawModel := &entity.ChatMessageRaw{
 		Id:            uuid.New(),
-		Chat:          constant.ChatMessageRawInititalModelPromptV1,
+		Chat:          initialModelPrompt,
 		Role:          constant.ChatMessageRoleModel,
 		ChatSessionId: chatSession.Id,
+		ParentId:      &chatMessageRawUser.Id,
+		ChatMessageId: &chatMessage.Id,
 		CreatedAt:     now,
 	}
 
@@ -113,32 +198,58 @@ func (c *chatbotService) CreateSession(ctx context.Context) (*dto.CreateSessionR
 		return nil, err
 	}
 
+	err = c.chatSessionTagRepository.UsingTx(ctx, tx).Set(ctx, chatSession.Id, tagIds)
+	if err != nil {
+		return nil, err
+	}
+
 	err = tx.Commit(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	return &dto.CreateSessionResponse{
-		Id: chatSession.Id,
+		Id:                      chatSession.Id,
+		PromptTemplateVersionId: chatSession.PromptTemplateVersionId,
 	}, nil
 
 }
 
 func (c *chatbotService) GetAllSession(ctx context.Context) ([]*dto.GetAllSessionResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := c.chatSessionRepository.GetAllSession(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
 
-	sessions, err := c.chatSessionRepository.GetAllSession(ctx)
+	sessionIds := make([]uuid.UUID, 0, len(sessions))
+	for _, session := range sessions {
+		sessionIds = append(sessionIds, session.Id)
+	}
+
+	sessionTagIds, err := c.chatSessionTagRepository.GetTagIdsBySessionIds(ctx, sessionIds)
 	if err != nil {
 		return nil, err
 	}
 
 	response := make([]*dto.GetAllSessionResponse, 0)
 	for _, sessions := range sessions {
+		tagIds := sessionTagIds[sessions.Id]
+		if tagIds == nil {
+			tagIds = make([]uuid.UUID, 0)
+		}
 
 		response = append(response, &dto.GetAllSessionResponse{
-			Id:        sessions.Id,
-			Name:      sessions.Title,
-			CreatedAt: sessions.CreatedAt,
-			UpdatedAt: sessions.UpdatedAt,
+			Id:                      sessions.Id,
+			Name:                    sessions.Title,
+			PromptTemplateVersionId: sessions.PromptTemplateVersionId,
+			TagIds:                  tagIds,
+			CreatedAt:               sessions.CreatedAt,
+			UpdatedAt:               sessions.UpdatedAt,
 		})
 
 	}
@@ -147,8 +258,12 @@ func (c *chatbotService) GetAllSession(ctx context.Context) ([]*dto.GetAllSessio
 }
 
 func (c *chatbotService) GetChatHistory(ctx context.Context, sessionId uuid.UUID) ([]*dto.GetChatHistoryResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	_, err := c.chatSessionRepository.GetSessionById(ctx, sessionId)
+	session, err := c.chatSessionRepository.GetSessionById(ctx, userId, sessionId)
 	if err != nil {
 		return nil, err
 	}
@@ -158,22 +273,28 @@ func (c *chatbotService) GetChatHistory(ctx context.Context, sessionId uuid.UUID
 		return nil, err
 	}
 
-	response := make([]*dto.GetChatHistoryResponse, 0)
-	for _, sessions := range messages {
-
-		response = append(response, &dto.GetChatHistoryResponse{
-			Id:        sessions.Id,
-			Role:      sessions.Role,
-			Chat:      sessions.Chat,
-			CreatedAt: sessions.CreatedAt,
-		})
+	toolCalls, err := c.chatToolCallRepository.GetBySessionId(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
 
+	response := buildChatHistoryResponse(messages, activeLeafId(session, messages))
+	for _, message := range response {
+		for _, toolCall := range toolCalls {
+			if toolCall.ChatMessageId == message.Id {
+				message.ToolCalls = append(message.ToolCalls, toChatToolCallResponse(toolCall))
+			}
+		}
 	}
 
 	return response, nil
 }
 
 func (c *chatbotService) SendChat(ctx context.Context, request *dto.SendChatRequest) (*dto.SendChatResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	tx, err := c.db.Begin(ctx)
 	if err != nil {
@@ -183,130 +304,110 @@ func (c *chatbotService) SendChat(ctx context.Context, request *dto.SendChatRequ
 	defer tx.Rollback(ctx)
 
 	chatSessionRepository := c.chatSessionRepository.UsingTx(ctx, tx)
-	chatMessageRepository := c.chatMessageRepository.UsingTx(ctx, tx)
 	chatMessageRawRepository := c.chatMessageRawRepository.UsingTx(ctx, tx)
-	noteEmbeddingRepository := c.notEmbeddingRepository.UsingTx(ctx, tx)
 
-	SessionChat, err := chatSessionRepository.GetSessionById(ctx, request.ChatSessionId)
+	SessionChat, err := chatSessionRepository.GetSessionById(ctx, userId, request.ChatSessionId)
 	if err != nil {
 		return nil, err
 	}
 
-	ExistingChatRaw, err := chatMessageRawRepository.GetChatBySessionId(ctx, request.ChatSessionId)
+	messages, err := chatSessionRepository.GetChatBySessionId(ctx, request.ChatSessionId)
 	if err != nil {
 		return nil, err
 	}
 
-	updateSessionTitle := len(ExistingChatRaw) == 2
-	now := time.Now()
+	ExistingChatRaw, err := chatMessageRawRepository.GetChatBySessionId(ctx, request.ChatSessionId)
+	if err != nil {
+		return nil, err
+	}
 
-	chatMessageUser := entity.ChatMessage{
-		Id:            uuid.New(),
-		Chat:          request.Chat,
-		Role:          constant.ChatMessageRoleUser,
-		ChatSessionId: request.ChatSessionId,
-		CreatedAt:     now,
+	leafId := activeLeafId(SessionChat, messages)
+	if leafId == nil {
+		return nil, serverutils.ErrNotFound
 	}
 
-	embeddingRes, err := embedding.GetGeminiEmbedding(
-		os.Getenv("GOOGLE_GEMINI_API_KEY"),
-		"models/gemini-embedding-exp-03-07",
-		request.Chat,
-		"RETRIEVAL_QUERY",
-	)
+	res, err := c.replyTo(ctx, tx, SessionChat, messages, ExistingChatRaw, findChatMessage(messages, *leafId), request.Chat)
+	if err != nil {
+		return nil, err
+	}
 
+	err = tx.Commit(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	decideUseRAGChatHistories := make([]*chatbot.ChatHistory, 0)
-	for i, rawChat := range ExistingChatRaw {
-		if i == 0 {
-			decideUseRAGChatHistories = append(decideUseRAGChatHistories, &chatbot.ChatHistory{
-				Chat: constant.DecideUseRAGMessageRawInitialUserPromptV1,
-				Role: constant.ChatMessageRoleUser,
-			})
-		} else if i == 1 {
-			decideUseRAGChatHistories = append(decideUseRAGChatHistories, &chatbot.ChatHistory{
-				Chat: constant.ChatMessageRawInititalModelPromptV1,
-				Role: constant.ChatMessageRoleModel,
-			})
-		}
+	return res, nil
+}
 
-		decideUseRAGChatHistories = append(decideUseRAGChatHistories, &chatbot.ChatHistory{
-			Chat: rawChat.Chat,
-			Role: rawChat.Role,
-		})
+func (c *chatbotService) RegenerateChat(ctx context.Context, request *dto.RegenerateChatRequest) (*dto.SendChatResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	useRAG, err := chatbot.DecideToUseRAG(
-		ctx,
-		os.Getenv("GOOGLE_GEMINI_API_KEY"),
-		decideUseRAGChatHistories,
-	)
+	tx, err := c.db.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	strBuilder := strings.Builder{}
-
-	if useRAG {
+	defer tx.Rollback(ctx)
 
-		noteEmbeddings, err := noteEmbeddingRepository.SearchSimilarity(ctx, embeddingRes.Embedding.Values)
-		if err != nil {
-			return nil, err
-		}
+	chatSessionRepository := c.chatSessionRepository.UsingTx(ctx, tx)
+	chatMessageRepository := c.chatMessageRepository.UsingTx(ctx, tx)
+	chatMessageRawRepository := c.chatMessageRawRepository.UsingTx(ctx, tx)
 
-		for i, noteEmbeding := range noteEmbeddings {
-			strBuilder.WriteString(fmt.Sprintf("Reference %d\n", i+1))
-			strBuilder.WriteString(noteEmbeding.ChunkContent)
-			strBuilder.WriteString("\n\n")
-		}
+	SessionChat, err := chatSessionRepository.GetSessionById(ctx, userId, request.ChatSessionId)
+	if err != nil {
+		return nil, err
+	}
 
+	messages, err := chatSessionRepository.GetChatBySessionId(ctx, request.ChatSessionId)
+	if err != nil {
+		return nil, err
 	}
 
-	strBuilder.WriteString("User Next Question: ")
-	strBuilder.WriteString(request.Chat)
-	strBuilder.WriteString("\n\n")
-	strBuilder.WriteString("Your Answer")
+	ExistingChatRaw, err := chatMessageRawRepository.GetChatBySessionId(ctx, request.ChatSessionId)
+	if err != nil {
+		return nil, err
+	}
 
-	chatMessageRawUser := entity.ChatMessageRaw{
-		Id:            uuid.New(),
-		Chat:          strBuilder.String(),
-		Role:          constant.ChatMessageRoleUser,
-		ChatSessionId: request.ChatSessionId,
-		CreatedAt:     now,
+	// Only a model reply that answers a user question can be regenerated,
+	// the greeting at the root of the session has no question to answer.
+	leafId := activeLeafId(SessionChat, messages)
+	if leafId == nil {
+		return nil, serverutils.ErrNotFound
 	}
 
-	ExistingChatRaw = append(
-		ExistingChatRaw,
-		&chatMessageRawUser,
-	)
+	lastReply := findChatMessage(messages, *leafId)
+	if lastReply == nil || lastReply.Role != constant.ChatMessageRoleModel || lastReply.ParentId == nil {
+		return nil, serverutils.ErrBadRequest
+	}
 
-	geminiReq := make([]*chatbot.ChatHistory, 0)
-	for _, ExistingChat := range ExistingChatRaw {
+	question := findChatMessage(messages, *lastReply.ParentId)
+	if question == nil || question.Role != constant.ChatMessageRoleUser {
+		return nil, serverutils.ErrBadRequest
+	}
 
-		geminiReq = append(geminiReq, &chatbot.ChatHistory{
-			Chat: ExistingChat.Chat,
-			Role: ExistingChat.Role,
-		})
+	rawQuestion := findChatMessageRaw(ExistingChatRaw, question.Id)
+	if rawQuestion == nil {
+		return nil, serverutils.ErrNotFound
 	}
 
-	reply, err := chatbot.GetGeminiResponse(
-		ctx,
-		os.Getenv("GOOGLE_GEMINI_API_KEY"),
-		geminiReq,
-	)
+	rawPath := chatMessageRawPath(ExistingChatRaw, rawQuestion.Id)
+
+	reply, err := c.getReply(ctx, rawPath)
 	if err != nil {
 		return nil, err
 	}
 
+	now := time.Now()
 	chatMessageModel := entity.ChatMessage{
 		Id:            uuid.New(),
 		Chat:          reply,
 		Role:          constant.ChatMessageRoleModel,
 		ChatSessionId: request.ChatSessionId,
-		CreatedAt:     now.Add(1 * time.Millisecond),
+		ParentId:      &question.Id,
+		CreatedAt:     now,
 	}
 
 	chatMessageRawModel := entity.ChatMessageRaw{
@@ -314,19 +415,24 @@ func (c *chatbotService) SendChat(ctx context.Context, request *dto.SendChatRequ
 		Chat:          reply,
 		Role:          constant.ChatMessageRoleModel,
 		ChatSessionId: request.ChatSessionId,
-		CreatedAt:     now.Add(1 * time.Millisecond),
+		ParentId:      &rawQuestion.Id,
+		ChatMessageId: &chatMessageModel.Id,
+		CreatedAt:     now,
 	}
 
-	chatMessageRepository.Create(ctx, &chatMessageUser)
-	chatMessageRepository.Create(ctx, &chatMessageModel)
-	chatMessageRawRepository.Create(ctx, &chatMessageRawUser)
-	chatMessageRawRepository.Create(ctx, &chatMessageRawModel)
+	err = chatMessageRepository.Create(ctx, &chatMessageModel)
+	if err != nil {
+		return nil, err
+	}
 
-	if updateSessionTitle {
-		SessionChat.Title = request.Chat
-		SessionChat.UpdatedAt = &now
+	err = chatMessageRawRepository.Create(ctx, &chatMessageRawModel)
+	if err != nil {
+		return nil, err
 	}
 
+	SessionChat.ActiveMessageId = &chatMessageModel.Id
+	SessionChat.UpdatedAt = &now
+
 	err = chatSessionRepository.Update(ctx, SessionChat)
 	if err != nil {
 		return nil, err
@@ -341,10 +447,10 @@ func (c *chatbotService) SendChat(ctx context.Context, request *dto.SendChatRequ
 		ChatSessionId: SessionChat.Id,
 		Title:         SessionChat.Title,
 		Send: &dto.SendChatResponseChat{
-			Id:        chatMessageUser.Id,
-			Chat:      chatMessageUser.Chat,
-			Role:      chatMessageUser.Role,
-			CreatedAt: chatMessageUser.CreatedAt,
+			Id:        question.Id,
+			Chat:      question.Chat,
+			Role:      question.Role,
+			CreatedAt: question.CreatedAt,
 		},
 		Reply: &dto.SendChatResponseChat{
 			Id:        chatMessageModel.Id,
@@ -355,43 +461,1154 @@ func (c *chatbotService) SendChat(ctx context.Context, request *dto.SendChatRequ
 	}, nil
 }
 
-func (c *chatbotService) DeleteSession(ctx context.Context, session *dto.DeleteSessionRequest) error {
+func (c *chatbotService) EditChat(ctx context.Context, request *dto.EditChatRequest) (*dto.SendChatResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	tx, err := c.db.Begin(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	defer tx.Rollback(ctx)
 
 	chatSessionRepository := c.chatSessionRepository.UsingTx(ctx, tx)
-	chatMessageRepository := c.chatMessageRepository.UsingTx(ctx, tx)
 	chatMessageRawRepository := c.chatMessageRawRepository.UsingTx(ctx, tx)
 
-	_, err = chatSessionRepository.GetSessionById(ctx, session.ChatSessionId)
+	SessionChat, err := chatSessionRepository.GetSessionById(ctx, userId, request.ChatSessionId)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = chatSessionRepository.Delete(ctx, session.ChatSessionId)
+	messages, err := chatSessionRepository.GetChatBySessionId(ctx, request.ChatSessionId)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = chatMessageRawRepository.DeleteBySessionId(ctx, session.ChatSessionId)
+	ExistingChatRaw, err := chatMessageRawRepository.GetChatBySessionId(ctx, request.ChatSessionId)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = chatMessageRepository.DeleteBySessionId(ctx, session.ChatSessionId)
+	edited := findChatMessage(messages, request.ChatMessageId)
+	if edited == nil {
+		return nil, serverutils.ErrNotFound
+	}
+
+	if edited.Role != constant.ChatMessageRoleUser || edited.ParentId == nil {
+		return nil, serverutils.ErrBadRequest
+	}
+
+	// The edited question becomes a sibling of the original one, so the old
+	// branch stays reachable through SwitchBranch.
+	res, err := c.replyTo(ctx, tx, SessionChat, messages, ExistingChatRaw, findChatMessage(messages, *edited.ParentId), request.Chat)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	err = tx.Commit(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return res, nil
+}
+
+func (c *chatbotService) GetChatBranches(ctx context.Context, sessionId uuid.UUID, messageId uuid.UUID) ([]*dto.GetChatBranchesResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := c.chatSessionRepository.GetSessionById(ctx, userId, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := c.chatSessionRepository.GetChatBySessionId(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	message := findChatMessage(messages, messageId)
+	if message == nil {
+		return nil, serverutils.ErrNotFound
+	}
+
+	activePath := make(map[uuid.UUID]bool)
+	if leafId := activeLeafId(session, messages); leafId != nil {
+		for _, m := range chatMessagePath(messages, *leafId) {
+			activePath[m.Id] = true
+		}
+	}
+
+	response := make([]*dto.GetChatBranchesResponse, 0)
+	for _, sibling := range chatMessageSiblings(messages, message) {
+		response = append(response, &dto.GetChatBranchesResponse{
+			Id:        sibling.Id,
+			Role:      sibling.Role,
+			Chat:      sibling.Chat,
+			IsActive:  activePath[sibling.Id],
+			CreatedAt: sibling.CreatedAt,
+		})
+	}
+
+	return response, nil
+}
+
+func (c *chatbotService) SwitchBranch(ctx context.Context, request *dto.SwitchBranchRequest) ([]*dto.GetChatHistoryResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := c.chatSessionRepository.GetSessionById(ctx, userId, request.ChatSessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := c.chatSessionRepository.GetChatBySessionId(ctx, request.ChatSessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	message := findChatMessage(messages, request.ChatMessageId)
+	if message == nil {
+		return nil, serverutils.ErrNotFound
+	}
+
+	leafId := latestLeafId(messages, message.Id)
+	now := time.Now()
+	session.ActiveMessageId = &leafId
+	session.UpdatedAt = &now
+
+	err = c.chatSessionRepository.Update(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildChatHistoryResponse(messages, &leafId), nil
+}
+
+// replyTo appends a user question under parent, answers it and moves the
+// session's active branch to the answer.
+func (c *chatbotService) replyTo(
+	ctx context.Context,
+	tx pgx.Tx,
+	SessionChat *entity.ChatSession,
+	messages []*entity.ChatMessage,
+	ExistingChatRaw []*entity.ChatMessageRaw,
+	parent *entity.ChatMessage,
+	chat string,
+) (*dto.SendChatResponse, error) {
+
+	if parent == nil {
+		return nil, serverutils.ErrNotFound
+	}
+
+	chatSessionRepository := c.chatSessionRepository.UsingTx(ctx, tx)
+	chatMessageRepository := c.chatMessageRepository.UsingTx(ctx, tx)
+	chatMessageRawRepository := c.chatMessageRawRepository.UsingTx(ctx, tx)
+	noteEmbeddingRepository := c.notEmbeddingRepository.UsingTx(ctx, tx)
+	chatRetrievalPlanRepository := c.chatRetrievalPlanRepository.UsingTx(ctx, tx)
+
+	rawParent := findChatMessageRaw(ExistingChatRaw, parent.Id)
+	if rawParent == nil {
+		return nil, serverutils.ErrNotFound
+	}
+
+	rawPath := chatMessageRawPath(ExistingChatRaw, rawParent.Id)
+
+	updateSessionTitle := len(rawPath) == 2
+	now := time.Now()
+
+	chatMessageUser := entity.ChatMessage{
+		Id:            uuid.New(),
+		Chat:          chat,
+		Role:          constant.ChatMessageRoleUser,
+		ChatSessionId: SessionChat.Id,
+		ParentId:      &parent.Id,
+		CreatedAt:     now,
+	}
+
+	plan := c.planRetrieval(ctx, SessionChat.Id, chatMessagePath(messages, parent.Id), chat)
+
+	strBuilder := strings.Builder{}
+
+	if plan.ShouldRetrieve {
+
+		noteEmbeddings, err := c.retrieveReferences(ctx, SessionChat.Id, SessionChat.UserId, noteEmbeddingRepository, plan)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, noteEmbeding := range noteEmbeddings {
+			strBuilder.WriteString(fmt.Sprintf("Reference %d\n", i+1))
+			strBuilder.WriteString(noteEmbeding.ChunkContent)
+			strBuilder.WriteString("\n\n")
+		}
+
+	}
+
+	strBuilder.WriteString("User Next Question: ")
+	strBuilder.WriteString(chat)
+	strBuilder.WriteString("\n\n")
+	strBuilder.WriteString("Your Answer")
+
+	chatMessageRawUser := entity.ChatMessageRaw{
+		Id:            uuid.New(),
+		Chat:          strBuilder.String(),
+		Role:          constant.ChatMessageRoleUser,
+		ChatSessionId: SessionChat.Id,
+		ParentId:      &rawParent.Id,
+		ChatMessageId: &chatMessageUser.Id,
+		CreatedAt:     now,
+	}
+
+	reply, err := c.getReply(ctx, append(rawPath, &chatMessageRawUser))
+	if err != nil {
+		return nil, err
+	}
+
+	chatMessageModel := entity.ChatMessage{
+		Id:            uuid.New(),
+		Chat:          reply,
+		Role:          constant.ChatMessageRoleModel,
+		ChatSessionId: SessionChat.Id,
+		ParentId:      &chatMessageUser.Id,
+		CreatedAt:     now.Add(1 * time.Millisecond),
+	}
+
+	chatMessageRawModel := entity.ChatMessageRaw{
+		Id:            uuid.New(),
+		Chat:          reply,
+		Role:          constant.ChatMessageRoleModel,
+		ChatSessionId: SessionChat.Id,
+		ParentId:      &chatMessageRawUser.Id,
+		ChatMessageId: &chatMessageModel.Id,
+		CreatedAt:     now.Add(1 * time.Millisecond),
+	}
+
+	for _, chatMessage := range []*entity.ChatMessage{&chatMessageUser, &chatMessageModel} {
+		err = chatMessageRepository.Create(ctx, chatMessage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, chatMessageRaw := range []*entity.ChatMessageRaw{&chatMessageRawUser, &chatMessageRawModel} {
+		err = chatMessageRawRepository.Create(ctx, chatMessageRaw)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = chatRetrievalPlanRepository.Create(ctx, &entity.ChatRetrievalPlan{
+		Id:              uuid.New(),
+		ChatSessionId:   SessionChat.Id,
+		ChatMessageId:   chatMessageUser.Id,
+		ShouldRetrieve:  plan.ShouldRetrieve,
+		StandaloneQuery: plan.StandaloneQuery,
+		SubQueries:      plan.SubQueries,
+		Reasoning:       plan.Reasoning,
+		CreatedAt:       now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if updateSessionTitle {
+		SessionChat.Title = chat
+	}
+	SessionChat.ActiveMessageId = &chatMessageModel.Id
+	SessionChat.UpdatedAt = &now
+
+	err = chatSessionRepository.Update(ctx, SessionChat)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.SendChatResponse{
+		ChatSessionId: SessionChat.Id,
+		Title:         SessionChat.Title,
+		Send: &dto.SendChatResponseChat{
+			Id:        chatMessageUser.Id,
+			Chat:      chatMessageUser.Chat,
+			Role:      chatMessageUser.Role,
+			CreatedAt: chatMessageUser.CreatedAt,
+		},
+		Reply: &dto.SendChatResponseChat{
+			Id:        chatMessageModel.Id,
+			Chat:      chatMessageModel.Chat,
+			Role:      chatMessageModel.Role,
+			CreatedAt: chatMessageModel.CreatedAt,
+		},
+		RetrievalPlan: &dto.RetrievalPlanResponse{
+			ShouldRetrieve:  plan.ShouldRetrieve,
+			StandaloneQuery: plan.StandaloneQuery,
+			SubQueries:      plan.SubQueries,
+			Reasoning:       plan.Reasoning,
+		},
+	}, nil
+}
+
+// planRetrieval asks the planner whether the notes should be searched and
+// rewrites the question into standalone queries using the conversation so far.
+// When the planner fails the raw question is searched, like before it existed.
+func (c *chatbotService) planRetrieval(ctx context.Context, sessionId uuid.UUID, history []*entity.ChatMessage, question string) *chatbot.RetrievalPlan {
+	transcript := strings.Builder{}
+	for _, message := range history {
+		transcript.WriteString(fmt.Sprintf("%s: %s\n", message.Role, message.Chat))
+	}
+
+	start := time.Now()
+	plan, usage, err := chatbot.PlanRetrieval(
+		ctx,
+		os.Getenv("GOOGLE_GEMINI_API_KEY"),
+		[]*chatbot.ChatHistory{
+			{
+				Chat: fmt.Sprintf("%s\n\nConversation:\n%s\nLatest message: %s", constant.RetrievalPlannerPromptV1, transcript.String(), question),
+				Role: constant.ChatMessageRoleUser,
+			},
+		},
+	)
+	if err == nil {
+		llmUsage := chatUsage(constant.UsageFeatureRetrievalPlan, usage, time.Since(start))
+		llmUsage.ChatSessionId = &sessionId
+		c.usageService.Record(ctx, llmUsage)
+	} else {
+		log.Printf("[RetrievalPlan] Planner failed, searching the raw question: %v", err)
+		plan = &chatbot.RetrievalPlan{
+			ShouldRetrieve: true,
+			Reasoning:      "planner unavailable",
+		}
+	}
+
+	if strings.TrimSpace(plan.StandaloneQuery) == "" {
+		plan.StandaloneQuery = question
+	}
+	if plan.SubQueries == nil {
+		plan.SubQueries = make([]string, 0)
+	}
+
+	log.Printf(
+		"[RetrievalPlan] retrieve=%v query=%q sub_queries=%q reasoning=%q",
+		plan.ShouldRetrieve,
+		plan.StandaloneQuery,
+		plan.SubQueries,
+		plan.Reasoning,
+	)
+
+	return plan
+}
+
+// retrieveReferences embeds the standalone query and every sub-query and
+// merges their matches, keeping the first occurrence of each chunk.
+func (c *chatbotService) retrieveReferences(
+	ctx context.Context,
+	sessionId uuid.UUID,
+	userId uuid.UUID,
+	noteEmbeddingRepository repository.INoteEmbeddingRepository,
+	plan *chatbot.RetrievalPlan,
+) ([]*entity.NoteEmbedding, error) {
+
+	// Sharing can change between two messages, so the readable notebooks are
+	// resolved on every retrieval.
+	notebookIds, err := c.notebookAccessService.ReadableNotebookIds(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionTagIds, err := c.chatSessionTagRepository.GetTagIdsBySessionIds(ctx, []uuid.UUID{sessionId})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	references := make([]*entity.NoteEmbedding, 0)
+
+	for _, query := range append([]string{plan.StandaloneQuery}, plan.SubQueries...) {
+		if strings.TrimSpace(query) == "" {
+			continue
+		}
+
+		start := time.Now()
+		embeddingRes, err := embedding.GetGeminiEmbedding(
+			ctx,
+			os.Getenv("GOOGLE_GEMINI_API_KEY"),
+			"models/gemini-embedding-exp-03-07",
+			query,
+			"RETRIEVAL_QUERY",
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		llmUsage := embeddingUsage(constant.UsageFeatureChatRetrieval, query, embeddingRes.UsageMetadata, time.Since(start))
+		llmUsage.ChatSessionId = &sessionId
+		c.usageService.Record(ctx, llmUsage)
+
+		noteEmbeddings, err := noteEmbeddingRepository.SearchSimilarity(ctx, notebookIds, sessionTagIds[sessionId], embeddingRes.Embedding.Values)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, noteEmbedding := range noteEmbeddings {
+			if seen[noteEmbedding.Id] {
+				continue
+			}
+			seen[noteEmbedding.Id] = true
+			references = append(references, noteEmbedding)
+		}
+	}
+
+	if len(references) > maxRetrievalReferences {
+		references = references[:maxRetrievalReferences]
+	}
+
+	return references, nil
+}
+
+func (c *chatbotService) getReply(ctx context.Context, rawPath []*entity.ChatMessageRaw) (string, error) {
+	geminiReq := make([]*chatbot.ChatHistory, 0)
+	for _, ExistingChat := range rawPath {
+
+		geminiReq = append(geminiReq, &chatbot.ChatHistory{
+			Chat: ExistingChat.Chat,
+			Role: ExistingChat.Role,
+		})
+	}
+
+	start := time.Now()
+	reply, usage, err := chatbot.GetGeminiResponse(
+		ctx,
+		os.Getenv("GOOGLE_GEMINI_API_KEY"),
+		geminiReq,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	llmUsage := chatUsage(constant.UsageFeatureChat, usage, time.Since(start))
+	llmUsage.ChatSessionId = &rawPath[len(rawPath)-1].ChatSessionId
+	c.usageService.Record(ctx, llmUsage)
+
+	return reply, nil
+}
+
+func (c *chatbotService) DeleteSession(ctx context.Context, session *dto.DeleteSessionRequest) error {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	chatSessionRepository := c.chatSessionRepository.UsingTx(ctx, tx)
+	chatMessageRepository := c.chatMessageRepository.UsingTx(ctx, tx)
+	chatMessageRawRepository := c.chatMessageRawRepository.UsingTx(ctx, tx)
+	chatToolCallRepository := c.chatToolCallRepository.UsingTx(ctx, tx)
+
+	chatSession, err := chatSessionRepository.GetSessionById(ctx, userId, session.ChatSessionId)
+	if err != nil {
+		return err
+	}
+
+	err = chatSessionRepository.Delete(ctx, userId, session.ChatSessionId)
+	if err != nil {
+		return err
+	}
+
+	err = chatMessageRawRepository.DeleteBySessionId(ctx, session.ChatSessionId)
+	if err != nil {
+		return err
+	}
+
+	err = chatMessageRepository.DeleteBySessionId(ctx, session.ChatSessionId)
+	if err != nil {
+		return err
+	}
+
+	err = chatToolCallRepository.DeleteBySessionId(ctx, session.ChatSessionId)
+	if err != nil {
+		return err
+	}
+
+	auditEvent, err := newAuditEvent(ctx, constant.AuditActionDelete, constant.AuditEntityChatSession, chatSession.Id, chatSession, nil)
+	if err != nil {
+		return err
+	}
+
+	err = c.auditEventRepository.UsingTx(ctx, tx).Create(ctx, auditEvent)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *chatbotService) AgentChat(ctx context.Context, request *dto.SendChatRequest) (*dto.SendChatResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	SessionChat, err := c.chatSessionRepository.GetSessionById(ctx, userId, request.ChatSessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := c.chatSessionRepository.GetChatBySessionId(ctx, request.ChatSessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	ExistingChatRaw, err := c.chatMessageRawRepository.GetChatBySessionId(ctx, request.ChatSessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	leafId := activeLeafId(SessionChat, messages)
+	if leafId == nil {
+		return nil, serverutils.ErrNotFound
+	}
+
+	rawParent := findChatMessageRaw(ExistingChatRaw, *leafId)
+	if rawParent == nil {
+		return nil, serverutils.ErrNotFound
+	}
+
+	rawPath := chatMessageRawPath(ExistingChatRaw, rawParent.Id)
+
+	updateSessionTitle := len(rawPath) == 2
+	now := time.Now()
+
+	chatMessageUser := entity.ChatMessage{
+		Id:            uuid.New(),
+		Chat:          request.Chat,
+		Role:          constant.ChatMessageRoleUser,
+		ChatSessionId: SessionChat.Id,
+		ParentId:      leafId,
+		CreatedAt:     now,
+	}
+
+	chatMessageModel := entity.ChatMessage{
+		Id:            uuid.New(),
+		Role:          constant.ChatMessageRoleModel,
+		ChatSessionId: SessionChat.Id,
+		ParentId:      &chatMessageUser.Id,
+		CreatedAt:     now.Add(1 * time.Millisecond),
+	}
+
+	chatMessageRawUser := entity.ChatMessageRaw{
+		Id:            uuid.New(),
+		Chat:          fmt.Sprintf("%s\n\nUser Next Question: %s\n\nYour Answer", constant.AgentChatInstructionV1, request.Chat),
+		Role:          constant.ChatMessageRoleUser,
+		ChatSessionId: SessionChat.Id,
+		ParentId:      &rawParent.Id,
+		ChatMessageId: &chatMessageUser.Id,
+		CreatedAt:     now,
+	}
+
+	contents := make([]*chatbot.GeminiChatContent, 0)
+	for _, raw := range append(rawPath, &chatMessageRawUser) {
+		contents = append(contents, &chatbot.GeminiChatContent{
+			Parts: []*chatbot.GeminiChatParts{{Text: raw.Chat}},
+			Role:  raw.Role,
+		})
+	}
+
+	toolCalls := make([]*entity.ChatToolCall, 0)
+	for round := 0; round < agentMaxToolRounds && chatMessageModel.Chat == ""; round++ {
+		start := time.Now()
+		turn, usage, err := chatbot.GetGeminiToolResponse(
+			ctx,
+			os.Getenv("GOOGLE_GEMINI_API_KEY"),
+			contents,
+			c.tools.declarations(ctx),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		llmUsage := chatUsage(constant.UsageFeatureAgentChat, usage, time.Since(start))
+		llmUsage.ChatSessionId = &SessionChat.Id
+		c.usageService.Record(ctx, llmUsage)
+
+		calls := turn.FunctionCalls()
+		if len(calls) == 0 {
+			chatMessageModel.Chat = turn.Text()
+			break
+		}
+
+		responseParts := make([]*chatbot.GeminiChatParts, 0)
+		waitingConfirmation := false
+		for _, call := range calls {
+			toolCall, response := c.runToolCall(ctx, SessionChat.Id, chatMessageModel.Id, call)
+			toolCalls = append(toolCalls, toolCall)
+			waitingConfirmation = waitingConfirmation || toolCall.Status == constant.ChatToolCallStatusPendingConfirmation
+
+			responseParts = append(responseParts, &chatbot.GeminiChatParts{
+				FunctionResponse: &chatbot.GeminiFunctionResponse{
+					Name:     call.Name,
+					Response: response,
+				},
+			})
+		}
+
+		// A write tool needs the user's approval, so the agent stops here and
+		// the pending calls are resumed through ConfirmToolCall.
+		if waitingConfirmation {
+			chatMessageModel.Chat = turn.Text()
+			if chatMessageModel.Chat == "" {
+				chatMessageModel.Chat = "I need your confirmation before I make changes to your notes."
+			}
+			break
+		}
+
+		contents = append(contents, turn, &chatbot.GeminiChatContent{
+			Parts: responseParts,
+			Role:  constant.ChatMessageRoleUser,
+		})
+	}
+
+	if chatMessageModel.Chat == "" {
+		chatMessageModel.Chat = "Sorry, I could not finish this request. Please try a more specific question."
+	}
+
+	chatMessageRawModel := entity.ChatMessageRaw{
+		Id:            uuid.New(),
+		Chat:          chatMessageModel.Chat,
+		Role:          constant.ChatMessageRoleModel,
+		ChatSessionId: SessionChat.Id,
+		ParentId:      &chatMessageRawUser.Id,
+		ChatMessageId: &chatMessageModel.Id,
+		CreatedAt:     chatMessageModel.CreatedAt,
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	chatSessionRepository := c.chatSessionRepository.UsingTx(ctx, tx)
+	chatMessageRepository := c.chatMessageRepository.UsingTx(ctx, tx)
+	chatMessageRawRepository := c.chatMessageRawRepository.UsingTx(ctx, tx)
+	chatToolCallRepository := c.chatToolCallRepository.UsingTx(ctx, tx)
+
+	for _, chatMessage := range []*entity.ChatMessage{&chatMessageUser, &chatMessageModel} {
+		err = chatMessageRepository.Create(ctx, chatMessage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, chatMessageRaw := range []*entity.ChatMessageRaw{&chatMessageRawUser, &chatMessageRawModel} {
+		err = chatMessageRawRepository.Create(ctx, chatMessageRaw)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	toolCallResponses := make([]*dto.ChatToolCallResponse, 0)
+	for _, toolCall := range toolCalls {
+		err = chatToolCallRepository.Create(ctx, toolCall)
+		if err != nil {
+			return nil, err
+		}
+		toolCallResponses = append(toolCallResponses, toChatToolCallResponse(toolCall))
+	}
+
+	if updateSessionTitle {
+		SessionChat.Title = request.Chat
+	}
+	SessionChat.ActiveMessageId = &chatMessageModel.Id
+	SessionChat.UpdatedAt = &now
+
+	err = chatSessionRepository.Update(ctx, SessionChat)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.SendChatResponse{
+		ChatSessionId: SessionChat.Id,
+		Title:         SessionChat.Title,
+		Send: &dto.SendChatResponseChat{
+			Id:        chatMessageUser.Id,
+			Chat:      chatMessageUser.Chat,
+			Role:      chatMessageUser.Role,
+			CreatedAt: chatMessageUser.CreatedAt,
+		},
+		Reply: &dto.SendChatResponseChat{
+			Id:        chatMessageModel.Id,
+			Chat:      chatMessageModel.Chat,
+			Role:      chatMessageModel.Role,
+			CreatedAt: chatMessageModel.CreatedAt,
+			ToolCalls: toolCallResponses,
+		},
+	}, nil
+}
+
+func (c *chatbotService) ConfirmToolCall(ctx context.Context, request *dto.ConfirmToolCallRequest) (*dto.ConfirmToolCallResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	toolCall, err := c.chatToolCallRepository.GetById(ctx, request.ChatToolCallId)
+	if err != nil {
+		return nil, err
+	}
+
+	if toolCall.Status != constant.ChatToolCallStatusPendingConfirmation {
+		return nil, serverutils.ErrBadRequest
+	}
+
+	tool, ok := c.tools[toolCall.ToolName]
+	if !ok {
+		return nil, serverutils.ErrBadRequest
+	}
+
+	SessionChat, err := c.chatSessionRepository.GetSessionById(ctx, userId, toolCall.ChatSessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	// The token approving the call needs the scope of the tool, not only the
+	// chat scope of the agent.
+	if request.Approve && !serverutils.HasScope(ctx, tool.scope) {
+		return nil, serverutils.ErrForbidden
+	}
+
+	messages, err := c.chatSessionRepository.GetChatBySessionId(ctx, toolCall.ChatSessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	ExistingChatRaw, err := c.chatMessageRawRepository.GetChatBySessionId(ctx, toolCall.ChatSessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	leafId := activeLeafId(SessionChat, messages)
+	if leafId == nil {
+		return nil, serverutils.ErrNotFound
+	}
+
+	// A call left behind on another branch is not resumed, its outcome would
+	// be attached to a conversation that never asked for it.
+	if findChatMessage(chatMessagePath(messages, *leafId), toolCall.ChatMessageId) == nil {
+		return nil, fmt.Errorf("%w: the tool call is not on the active branch", serverutils.ErrBadRequest)
+	}
+
+	rawParent := findChatMessageRaw(ExistingChatRaw, *leafId)
+	if rawParent == nil {
+		return nil, serverutils.ErrNotFound
+	}
+
+	var args map[string]any
+	err = json.Unmarshal(toolCall.Arguments, &args)
+	if err != nil {
+		return nil, err
+	}
+
+	// The call is claimed before the tool runs, of two concurrent
+	// confirmations only one executes it.
+	err = c.chatToolCallRepository.Claim(ctx, toolCall.Id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing else moves a claimed call on, if its outcome cannot be saved it
+	// is marked failed rather than left executing.
+	saved := false
+	defer func() {
+		if !saved {
+			c.failToolCall(ctx, toolCall.Id)
+		}
+	}()
+
+	var (
+		result map[string]any
+		reply  string
+	)
+	if request.Approve {
+		result, err = tool.execute(ctx, args)
+		if err != nil {
+			toolCall.Status = constant.ChatToolCallStatusFailed
+			result = map[string]any{"error": err.Error()}
+			reply = fmt.Sprintf("I could not run %s: %s", toolCall.ToolName, err.Error())
+		} else {
+			toolCall.Status = constant.ChatToolCallStatusExecuted
+			reply = fmt.Sprintf("Done, %s finished successfully.", toolCall.ToolName)
+		}
+	} else {
+		toolCall.Status = constant.ChatToolCallStatusRejected
+		result = map[string]any{"error": "the user rejected this call"}
+		reply = fmt.Sprintf("Okay, I did not run %s.", toolCall.ToolName)
+	}
+
+	resultJson, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	toolCall.Result = resultJson
+	toolCall.UpdatedAt = &now
+
+	chatMessageModel := entity.ChatMessage{
+		Id:            uuid.New(),
+		Chat:          reply,
+		Role:          constant.ChatMessageRoleModel,
+		ChatSessionId: SessionChat.Id,
+		ParentId:      leafId,
+		CreatedAt:     now,
+	}
+
+	// The raw history has to alternate between user and model, so the outcome
+	// is reported to the model as a user turn that has no visible message.
+	chatMessageRawUser := entity.ChatMessageRaw{
+		Id:            uuid.New(),
+		Chat:          fmt.Sprintf("Tool %s was %s with arguments %s. Result: %s", toolCall.ToolName, toolCall.Status, string(toolCall.Arguments), string(resultJson)),
+		Role:          constant.ChatMessageRoleUser,
+		ChatSessionId: SessionChat.Id,
+		ParentId:      &rawParent.Id,
+		CreatedAt:     now,
+	}
+
+	chatMessageRawModel := entity.ChatMessageRaw{
+		Id:            uuid.New(),
+		Chat:          reply,
+		Role:          constant.ChatMessageRoleModel,
+		ChatSessionId: SessionChat.Id,
+		ParentId:      &chatMessageRawUser.Id,
+		ChatMessageId: &chatMessageModel.Id,
+		CreatedAt:     now.Add(1 * time.Millisecond),
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	chatSessionRepository := c.chatSessionRepository.UsingTx(ctx, tx)
+	chatMessageRepository := c.chatMessageRepository.UsingTx(ctx, tx)
+	chatMessageRawRepository := c.chatMessageRawRepository.UsingTx(ctx, tx)
+	chatToolCallRepository := c.chatToolCallRepository.UsingTx(ctx, tx)
+
+	err = chatToolCallRepository.Update(ctx, toolCall)
+	if err != nil {
+		return nil, err
+	}
+
+	err = chatMessageRepository.Create(ctx, &chatMessageModel)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, chatMessageRaw := range []*entity.ChatMessageRaw{&chatMessageRawUser, &chatMessageRawModel} {
+		err = chatMessageRawRepository.Create(ctx, chatMessageRaw)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	SessionChat.ActiveMessageId = &chatMessageModel.Id
+	SessionChat.UpdatedAt = &now
+
+	err = chatSessionRepository.Update(ctx, SessionChat)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	saved = true
+
+	return &dto.ConfirmToolCallResponse{
+		ChatSessionId: SessionChat.Id,
+		ToolCall:      toChatToolCallResponse(toolCall),
+		Reply: &dto.SendChatResponseChat{
+			Id:        chatMessageModel.Id,
+			Chat:      chatMessageModel.Chat,
+			Role:      chatMessageModel.Role,
+			CreatedAt: chatMessageModel.CreatedAt,
+		},
+	}, nil
+}
+
+// runToolCall executes a single function call requested by the model and
+// returns the record to store plus the response to feed back to the model.
+func (c *chatbotService) runToolCall(
+	ctx context.Context,
+	sessionId uuid.UUID,
+	messageId uuid.UUID,
+	call *chatbot.GeminiFunctionCall,
+) (*entity.ChatToolCall, map[string]any) {
+
+	arguments, err := json.Marshal(call.Args)
+	if err != nil {
+		arguments = []byte("{}")
+	}
+
+	toolCall := &entity.ChatToolCall{
+		Id:            uuid.New(),
+		ChatSessionId: sessionId,
+		ChatMessageId: messageId,
+		ToolName:      call.Name,
+		Arguments:     arguments,
+		CreatedAt:     time.Now(),
+	}
+
+	var response map[string]any
+	tool, ok := c.tools[call.Name]
+	if !ok || !serverutils.HasScope(ctx, tool.scope) {
+		toolCall.Status = constant.ChatToolCallStatusFailed
+		response = map[string]any{"error": fmt.Sprintf("unknown tool %s", call.Name)}
+	} else if tool.requiresConfirmation {
+		toolCall.Status = constant.ChatToolCallStatusPendingConfirmation
+		return toolCall, map[string]any{"status": "waiting for the user to confirm"}
+	} else {
+		response, err = tool.execute(ctx, call.Args)
+		if err != nil {
+			log.Printf("[AgentChat] Tool %s failed: %v", call.Name, err)
+			toolCall.Status = constant.ChatToolCallStatusFailed
+			response = map[string]any{"error": err.Error()}
+		} else {
+			toolCall.Status = constant.ChatToolCallStatusExecuted
+		}
+	}
+
+	result, err := json.Marshal(response)
+	if err == nil {
+		toolCall.Result = result
+	}
+
+	return toolCall, response
+}
+
+// failToolCall marks a claimed call failed outside of the transaction of
+// the request, which did not commit. It still runs when the request was
+// cancelled.
+func (c *chatbotService) failToolCall(ctx context.Context, id uuid.UUID) {
+	ctx = context.WithoutCancel(ctx)
+
+	result, err := json.Marshal(map[string]any{"error": "the outcome of the call could not be saved"})
+	if err == nil {
+		err = c.chatToolCallRepository.Fail(ctx, id, result, time.Now())
+	}
+	if err != nil {
+		log.Printf("[AgentChat] Failed to mark tool call %s failed: %v", id, err)
+	}
+}
+
+func toChatToolCallResponse(toolCall *entity.ChatToolCall) *dto.ChatToolCallResponse {
+	return &dto.ChatToolCallResponse{
+		Id:        toolCall.Id,
+		ToolName:  toolCall.ToolName,
+		Arguments: toolCall.Arguments,
+		Result:    toolCall.Result,
+		Status:    toolCall.Status,
+		CreatedAt: toolCall.CreatedAt,
+	}
+}
+
+// activeLeafId returns the last message of the session's active branch. Sessions
+// created before branching existed fall back to their most recent message.
+func activeLeafId(session *entity.ChatSession, messages []*entity.ChatMessage) *uuid.UUID {
+	if session.ActiveMessageId != nil {
+		return session.ActiveMessageId
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return &messages[len(messages)-1].Id
+}
+
+// latestLeafId follows the most recent child from messageId down to a leaf.
+func latestLeafId(messages []*entity.ChatMessage, messageId uuid.UUID) uuid.UUID {
+	for {
+		var latest *entity.ChatMessage
+		for _, m := range messages {
+			if m.ParentId != nil && *m.ParentId == messageId {
+				if latest == nil || m.CreatedAt.After(latest.CreatedAt) {
+					latest = m
+				}
+			}
+		}
+
+		if latest == nil {
+			return messageId
+		}
+		messageId = latest.Id
+	}
+}
+
+func findChatMessage(messages []*entity.ChatMessage, id uuid.UUID) *entity.ChatMessage {
+	for _, m := range messages {
+		if m.Id == id {
+			return m
+		}
+	}
+
+	return nil
+}
+
+func findChatMessageRaw(raws []*entity.ChatMessageRaw, chatMessageId uuid.UUID) *entity.ChatMessageRaw {
+	for _, r := range raws {
+		if r.ChatMessageId != nil && *r.ChatMessageId == chatMessageId {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// chatMessagePath returns the branch ending at leafId, oldest message first.
+func chatMessagePath(messages []*entity.ChatMessage, leafId uuid.UUID) []*entity.ChatMessage {
+	path := make([]*entity.ChatMessage, 0)
+	for current := findChatMessage(messages, leafId); current != nil; {
+		path = append([]*entity.ChatMessage{current}, path...)
+		if current.ParentId == nil {
+			break
+		}
+		current = findChatMessage(messages, *current.ParentId)
+	}
+
+	return path
+}
+
+// chatMessageRawPath returns the raw branch ending at leafId, oldest message first.
+func chatMessageRawPath(raws []*entity.ChatMessageRaw, leafId uuid.UUID) []*entity.ChatMessageRaw {
+	byId := make(map[uuid.UUID]*entity.ChatMessageRaw, len(raws))
+	for _, r := range raws {
+		byId[r.Id] = r
+	}
+
+	path := make([]*entity.ChatMessageRaw, 0)
+	for current := byId[leafId]; current != nil; {
+		path = append([]*entity.ChatMessageRaw{current}, path...)
+		if current.ParentId == nil {
+			break
+		}
+		current = byId[*current.ParentId]
+	}
+
+	return path
+}
+
+// chatMessageSiblings returns every message sharing message's parent, including itself.
+func chatMessageSiblings(messages []*entity.ChatMessage, message *entity.ChatMessage) []*entity.ChatMessage {
+	siblings := make([]*entity.ChatMessage, 0)
+	for _, m := range messages {
+		if m.ParentId == nil && message.ParentId == nil {
+			siblings = append(siblings, m)
+		} else if m.ParentId != nil && message.ParentId != nil && *m.ParentId == *message.ParentId {
+			siblings = append(siblings, m)
+		}
+	}
+
+	return siblings
+}
+
+func buildChatHistoryResponse(messages []*entity.ChatMessage, leafId *uuid.UUID) []*dto.GetChatHistoryResponse {
+	response := make([]*dto.GetChatHistoryResponse, 0)
+	if leafId == nil {
+		return response
+	}
+
+	for _, message := range chatMessagePath(messages, *leafId) {
+		siblings := chatMessageSiblings(messages, message)
+		branchIndex := 0
+		for i, sibling := range siblings {
+			if sibling.Id == message.Id {
+				branchIndex = i
+			}
+		}
+
+		response = append(response, &dto.GetChatHistoryResponse{
+			Id:          message.Id,
+			ParentId:    message.ParentId,
+			Role:        message.Role,
+			Chat:        message.Chat,
+			BranchIndex: branchIndex,
+			BranchTotal: len(siblings),
+			CreatedAt:   message.CreatedAt,
+		})
+	}
+
+	return response
+}
+
+// SetSessionTags replaces the tags the retrieval of the session is limited
+// to, an empty list searches every note again.
+func (c *chatbotService) SetSessionTags(ctx context.Context, request *dto.SetSessionTagsRequest) (*dto.SetSessionTagsResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.chatSessionRepository.GetSessionById(ctx, userId, request.ChatSessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	tagIds, err := c.ownTagIds(ctx, userId, request.TagIds)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.chatSessionTagRepository.Set(ctx, request.ChatSessionId, tagIds)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.SetSessionTagsResponse{
+		ChatSessionId: request.ChatSessionId,
+		TagIds:        tagIds,
+	}, nil
+}
+
+// ownTagIds deduplicates tagIds and checks they are all tags of the user.
+func (c *chatbotService) ownTagIds(ctx context.Context, userId uuid.UUID, tagIds []uuid.UUID) ([]uuid.UUID, error) {
+	tagIds = uniqueIds(tagIds)
+	if len(tagIds) == 0 {
+		return tagIds, nil
+	}
+
+	tags, err := c.tagRepository.GetByIds(ctx, userId, tagIds)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) != len(tagIds) {
+		return nil, fmt.Errorf("%w: every tag must be one of your tags", serverutils.ErrBadRequest)
+	}
+
+	return tagIds, nil
 }
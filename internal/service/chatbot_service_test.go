@@ -0,0 +1,161 @@
+package service
+
+import (
+	"ai-notetaking-be/internal/constant"
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/repository"
+	"ai-notetaking-be/pkg/chatbot"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type fakeChatToolCallRepository struct {
+	repository.IChatToolCallRepository
+	toolCall *entity.ChatToolCall
+}
+
+func (f *fakeChatToolCallRepository) GetById(ctx context.Context, id uuid.UUID) (*entity.ChatToolCall, error) {
+	if f.toolCall.Id != id {
+		return nil, serverutils.ErrNotFound
+	}
+
+	toolCall := *f.toolCall
+	return &toolCall, nil
+}
+
+func (f *fakeChatToolCallRepository) Claim(ctx context.Context, id uuid.UUID, updatedAt time.Time) error {
+	if f.toolCall.Status != constant.ChatToolCallStatusPendingConfirmation {
+		return serverutils.ErrConflict
+	}
+
+	f.toolCall.Status = constant.ChatToolCallStatusExecuting
+	return nil
+}
+
+func (f *fakeChatToolCallRepository) Fail(ctx context.Context, id uuid.UUID, result []byte, updatedAt time.Time) error {
+	if f.toolCall.Status != constant.ChatToolCallStatusExecuting {
+		return serverutils.ErrConflict
+	}
+
+	f.toolCall.Status = constant.ChatToolCallStatusFailed
+	f.toolCall.Result = result
+	return nil
+}
+
+type fakeChatSessionRepository struct {
+	repository.IChatSessionRepository
+	session  *entity.ChatSession
+	messages []*entity.ChatMessage
+}
+
+func (f *fakeChatSessionRepository) GetSessionById(ctx context.Context, userId uuid.UUID, sessionId uuid.UUID) (*entity.ChatSession, error) {
+	if f.session.UserId != userId || f.session.Id != sessionId {
+		return nil, serverutils.ErrNotFound
+	}
+
+	return f.session, nil
+}
+
+func (f *fakeChatSessionRepository) GetChatBySessionId(ctx context.Context, sessionId uuid.UUID) ([]*entity.ChatMessage, error) {
+	return f.messages, nil
+}
+
+type fakeChatMessageRawRepository struct {
+	repository.IChatMessageRawRepository
+	raws []*entity.ChatMessageRaw
+}
+
+func (f *fakeChatMessageRawRepository) GetChatBySessionId(ctx context.Context, sessionId uuid.UUID) ([]*entity.ChatMessageRaw, error) {
+	return f.raws, nil
+}
+
+// When the outcome of a confirmed call cannot be saved, the call is marked
+// failed instead of staying executing forever.
+func TestConfirmToolCallFailsWhenTheOutcomeCannotBeSaved(t *testing.T) {
+	tests := []struct {
+		name     string
+		approve  bool
+		wantRuns int
+	}{
+		{name: "approved", approve: true, wantRuns: 1},
+		{name: "rejected", approve: false, wantRuns: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userId := uuid.New()
+			session := &entity.ChatSession{Id: uuid.New(), UserId: userId}
+			message := &entity.ChatMessage{Id: uuid.New(), ChatSessionId: session.Id, Role: constant.ChatMessageRoleModel}
+			session.ActiveMessageId = &message.Id
+
+			toolCalls := &fakeChatToolCallRepository{
+				toolCall: &entity.ChatToolCall{
+					Id:            uuid.New(),
+					ChatSessionId: session.Id,
+					ChatMessageId: message.Id,
+					ToolName:      "test_tool",
+					Arguments:     json.RawMessage(`{}`),
+					Status:        constant.ChatToolCallStatusPendingConfirmation,
+				},
+			}
+
+			runs := 0
+			tools := make(chatbotToolRegistry)
+			tools.register(&chatbotTool{
+				declaration:          &chatbot.GeminiFunctionDeclaration{Name: "test_tool"},
+				requiresConfirmation: true,
+				execute: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+					runs++
+					return map[string]any{}, nil
+				},
+			})
+
+			// Nothing listens on the port, beginning the transaction fails.
+			db, err := pgxpool.New(context.Background(), "postgres://test@127.0.0.1:1/test?connect_timeout=1")
+			if err != nil {
+				t.Fatalf("failed to configure the pool: %v", err)
+			}
+			defer db.Close()
+
+			service := &chatbotService{
+				db:                     db,
+				chatSessionRepository:  &fakeChatSessionRepository{session: session, messages: []*entity.ChatMessage{message}},
+				chatToolCallRepository: toolCalls,
+				chatMessageRawRepository: &fakeChatMessageRawRepository{raws: []*entity.ChatMessageRaw{
+					{Id: uuid.New(), ChatSessionId: session.Id, ChatMessageId: &message.Id, Role: constant.ChatMessageRoleModel},
+				}},
+				tools: tools,
+			}
+
+			ctx := serverutils.WithUserId(context.Background(), userId)
+			_, err = service.ConfirmToolCall(ctx, &dto.ConfirmToolCallRequest{
+				ChatToolCallId: toolCalls.toolCall.Id,
+				Approve:        tt.approve,
+			})
+			if err == nil {
+				t.Fatal("ConfirmToolCall succeeded without a database")
+			}
+
+			if runs != tt.wantRuns {
+				t.Errorf("the tool ran %d times, want %d", runs, tt.wantRuns)
+			}
+			if toolCalls.toolCall.Status != constant.ChatToolCallStatusFailed {
+				t.Errorf("status = %q, want %q", toolCalls.toolCall.Status, constant.ChatToolCallStatusFailed)
+			}
+
+			// The failed call cannot be confirmed again.
+			_, err = service.ConfirmToolCall(ctx, &dto.ConfirmToolCallRequest{ChatToolCallId: toolCalls.toolCall.Id, Approve: true})
+			if !errors.Is(err, serverutils.ErrBadRequest) {
+				t.Errorf("confirming again returned %v, want %v", err, serverutils.ErrBadRequest)
+			}
+		})
+	}
+}
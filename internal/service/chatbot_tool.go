@@ -0,0 +1,312 @@
+package service
+
+import (
+	"ai-notetaking-be/internal/constant"
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/repository"
+	"ai-notetaking-be/pkg/chatbot"
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ChatToolSearchNotes      = "search_notes"
+	ChatToolOpenNote         = "open_note"
+	ChatToolListNotebookTree = "list_notebook_tree"
+	ChatToolCreateNote       = "create_note"
+	ChatToolAppendToNote     = "append_to_note"
+)
+
+type chatbotTool struct {
+	declaration *chatbot.GeminiFunctionDeclaration
+	// scope is what an API token needs to use the tool, the chat scope alone
+	// does not give access to the notes.
+	scope string
+	// requiresConfirmation marks tools that write user data, they are only
+	// recorded by the agent and run once the user confirms the call.
+	requiresConfirmation bool
+	execute              func(ctx context.Context, args map[string]any) (map[string]any, error)
+}
+
+type chatbotToolRegistry map[string]*chatbotTool
+
+func (r chatbotToolRegistry) register(tool *chatbotTool) {
+	r[tool.declaration.Name] = tool
+}
+
+// declarations lists the tools ctx may use, the model is not told about the
+// others.
+func (r chatbotToolRegistry) declarations(ctx context.Context) []*chatbot.GeminiFunctionDeclaration {
+	declarations := make([]*chatbot.GeminiFunctionDeclaration, 0, len(r))
+	for _, tool := range r {
+		if serverutils.HasScope(ctx, tool.scope) {
+			declarations = append(declarations, tool.declaration)
+		}
+	}
+
+	return declarations
+}
+
+func newChatbotToolRegistry(
+	noteService INoteService,
+	notebookRepository repository.INotebookRepository,
+	notebookAccessService INotebookAccessService,
+) chatbotToolRegistry {
+	registry := make(chatbotToolRegistry)
+
+	registry.register(&chatbotTool{
+		declaration: &chatbot.GeminiFunctionDeclaration{
+			Name:        ChatToolSearchNotes,
+			Description: "Semantic search over the user's notes. Returns the best matching notes with a short excerpt.",
+			Parameters: &chatbot.GeminiSchema{
+				Type: "OBJECT",
+				Properties: map[string]*chatbot.GeminiSchema{
+					"query": {Type: "STRING", Description: "What to search for"},
+				},
+				Required: []string{"query"},
+			},
+		},
+		scope: constant.ApiTokenScopeNotesRead,
+		execute: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+			query, err := toolStringArg(args, "query")
+			if err != nil {
+				return nil, err
+			}
+
+			notes, err := noteService.SemanticSearch(ctx, &dto.SemanticSearchRequest{Query: query})
+			if err != nil {
+				return nil, err
+			}
+
+			results := make([]map[string]any, 0)
+			for _, note := range notes {
+				results = append(results, map[string]any{
+					"id":          note.Id,
+					"title":       note.Title,
+					"notebook_id": note.NotebookId,
+					"excerpt":     toolExcerpt(note.Content, 500),
+				})
+			}
+
+			return map[string]any{"notes": results}, nil
+		},
+	})
+
+	registry.register(&chatbotTool{
+		declaration: &chatbot.GeminiFunctionDeclaration{
+			Name:        ChatToolOpenNote,
+			Description: "Open a note by its ID and return its full content.",
+			Parameters: &chatbot.GeminiSchema{
+				Type: "OBJECT",
+				Properties: map[string]*chatbot.GeminiSchema{
+					"note_id": {Type: "STRING", Description: "The note ID"},
+				},
+				Required: []string{"note_id"},
+			},
+		},
+		scope: constant.ApiTokenScopeNotesRead,
+		execute: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+			noteId, err := toolUUIDArg(args, "note_id")
+			if err != nil {
+				return nil, err
+			}
+
+			note, err := noteService.Show(ctx, noteId)
+			if err != nil {
+				return nil, err
+			}
+
+			return map[string]any{
+				"id":          note.Id,
+				"title":       note.Title,
+				"content":     note.Content,
+				"notebook_id": note.NotebookId,
+			}, nil
+		},
+	})
+
+	registry.register(&chatbotTool{
+		declaration: &chatbot.GeminiFunctionDeclaration{
+			Name:        ChatToolListNotebookTree,
+			Description: "List every notebook of the user as a tree, with notebook IDs and names.",
+		},
+		scope: constant.ApiTokenScopeNotesRead,
+		execute: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+			userId, err := serverutils.GetUserId(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			notebookIds, err := notebookAccessService.ReadableNotebookIds(ctx, userId)
+			if err != nil {
+				return nil, err
+			}
+
+			notebooks, err := notebookRepository.GetByIds(ctx, notebookIds)
+			if err != nil {
+				return nil, err
+			}
+
+			// A shared subtree is listed as a root when its parent is not shared.
+			readable := make(map[uuid.UUID]bool)
+			for _, notebook := range notebooks {
+				readable[notebook.Id] = true
+			}
+			for _, notebook := range notebooks {
+				if notebook.ParentId != nil && !readable[*notebook.ParentId] {
+					notebook.ParentId = nil
+				}
+			}
+
+			return map[string]any{"notebooks": toolNotebookTree(notebooks, nil, make(map[uuid.UUID]bool))}, nil
+		},
+	})
+
+	registry.register(&chatbotTool{
+		declaration: &chatbot.GeminiFunctionDeclaration{
+			Name:        ChatToolCreateNote,
+			Description: "Create a new note inside a notebook, for example to save an answer.",
+			Parameters: &chatbot.GeminiSchema{
+				Type: "OBJECT",
+				Properties: map[string]*chatbot.GeminiSchema{
+					"notebook_id": {Type: "STRING", Description: "The notebook ID to create the note in"},
+					"title":       {Type: "STRING", Description: "The note title"},
+					"content":     {Type: "STRING", Description: "The note content in markdown"},
+				},
+				Required: []string{"notebook_id", "title", "content"},
+			},
+		},
+		scope:                constant.ApiTokenScopeNotesWrite,
+		requiresConfirmation: true,
+		execute: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+			notebookId, err := toolUUIDArg(args, "notebook_id")
+			if err != nil {
+				return nil, err
+			}
+
+			title, err := toolStringArg(args, "title")
+			if err != nil {
+				return nil, err
+			}
+
+			content, err := toolStringArg(args, "content")
+			if err != nil {
+				return nil, err
+			}
+
+			res, err := noteService.Create(ctx, &dto.CreateNoteRequest{
+				Title:      title,
+				Content:    content,
+				NotebookId: notebookId,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return map[string]any{"id": res.Id, "title": title}, nil
+		},
+	})
+
+	registry.register(&chatbotTool{
+		declaration: &chatbot.GeminiFunctionDeclaration{
+			Name:        ChatToolAppendToNote,
+			Description: "Append text to the end of an existing note.",
+			Parameters: &chatbot.GeminiSchema{
+				Type: "OBJECT",
+				Properties: map[string]*chatbot.GeminiSchema{
+					"note_id": {Type: "STRING", Description: "The note ID"},
+					"content": {Type: "STRING", Description: "The text to append in markdown"},
+				},
+				Required: []string{"note_id", "content"},
+			},
+		},
+		scope:                constant.ApiTokenScopeNotesWrite,
+		requiresConfirmation: true,
+		execute: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+			noteId, err := toolUUIDArg(args, "note_id")
+			if err != nil {
+				return nil, err
+			}
+
+			content, err := toolStringArg(args, "content")
+			if err != nil {
+				return nil, err
+			}
+
+			note, err := noteService.Show(ctx, noteId)
+			if err != nil {
+				return nil, err
+			}
+
+			res, err := noteService.Update(ctx, &dto.UpdateNoteRequest{
+				Id:      note.Id,
+				Title:   note.Title,
+				Content: note.Content + "\n\n" + content,
+				Version: &note.Version,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return map[string]any{"id": res.Id, "title": note.Title}, nil
+		},
+	})
+
+	return registry
+}
+
+func toolStringArg(args map[string]any, key string) (string, error) {
+	value, ok := args[key].(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("%w: missing argument %s", serverutils.ErrBadRequest, key)
+	}
+
+	return value, nil
+}
+
+func toolUUIDArg(args map[string]any, key string) (uuid.UUID, error) {
+	value, err := toolStringArg(args, key)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: argument %s is not a valid id", serverutils.ErrBadRequest, key)
+	}
+
+	return id, nil
+}
+
+func toolExcerpt(content string, maxLength int) string {
+	runes := []rune(content)
+	if len(runes) <= maxLength {
+		return content
+	}
+
+	return string(runes[:maxLength]) + "..."
+}
+
+func toolNotebookTree(notebooks []*entity.Notebook, parentId *uuid.UUID, visited map[uuid.UUID]bool) []map[string]any {
+	tree := make([]map[string]any, 0)
+	for _, notebook := range notebooks {
+		isChild := (parentId == nil && notebook.ParentId == nil) ||
+			(parentId != nil && notebook.ParentId != nil && *parentId == *notebook.ParentId)
+		if !isChild || visited[notebook.Id] {
+			continue
+		}
+		visited[notebook.Id] = true
+
+		tree = append(tree, map[string]any{
+			"id":       notebook.Id,
+			"name":     notebook.Name,
+			"children": toolNotebookTree(notebooks, &notebook.Id, visited),
+		})
+	}
+
+	return tree
+}
@@ -0,0 +1,670 @@
+package service
+
+import (
+	"ai-notetaking-be/internal/constant"
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/repository"
+	"ai-notetaking-be/pkg/crdt"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const (
+	collabSnapshotInterval = 10 * time.Second
+	// Re-embedding is far more expensive than a snapshot, it waits for the
+	// editing to settle.
+	collabEmbedDebounce = time.Minute
+	collabOutboxSize    = 256
+)
+
+type ICollabService interface {
+	Join(ctx context.Context, noteId uuid.UUID, readOnly bool) (*CollabClient, error)
+}
+
+type collabService struct {
+	noteRepository         repository.INoteRepository
+	noteRevisionRepository repository.INoteRevisionRepository
+	auditEventRepository   repository.IAuditEventRepository
+	notebookAccessService  INotebookAccessService
+	publisherService       IPublisherService
+	db                     *pgxpool.Pool
+
+	mu    sync.Mutex
+	rooms map[uuid.UUID]*collabRoom
+}
+
+func NewCollabService(
+	noteRepository repository.INoteRepository,
+	noteRevisionRepository repository.INoteRevisionRepository,
+	auditEventRepository repository.IAuditEventRepository,
+	notebookAccessService INotebookAccessService,
+	publisherService IPublisherService,
+	db *pgxpool.Pool,
+) ICollabService {
+	return &collabService{
+		noteRepository:         noteRepository,
+		noteRevisionRepository: noteRevisionRepository,
+		auditEventRepository:   auditEventRepository,
+		notebookAccessService:  notebookAccessService,
+		publisherService:       publisherService,
+		db:                     db,
+		rooms:                  make(map[uuid.UUID]*collabRoom),
+	}
+}
+
+// Join connects the user of ctx to the room of the note, starting the room
+// when nobody is editing it yet. Viewers and readOnly connections receive
+// the edits of others but cannot send their own.
+func (c *collabService) Join(ctx context.Context, noteId uuid.UUID, readOnly bool) (*CollabClient, error) {
+	note, err := c.noteRepository.GetById(ctx, noteId)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := c.notebookAccessService.Authorize(ctx, note.NotebookId, constant.NotebookRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := c.notebookAccessService.Role(ctx, userId, note.NotebookId)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &CollabClient{
+		Id:      uuid.NewString(),
+		UserId:  userId,
+		CanEdit: !readOnly && notebookRoleRank[role] >= notebookRoleRank[constant.NotebookRoleEditor],
+		outbox:  make(chan []byte, collabOutboxSize),
+	}
+
+	for {
+		room, err := c.room(ctx, noteId)
+		if err != nil {
+			return nil, err
+		}
+
+		if room.add(client) {
+			return client, nil
+		}
+
+		// The last client just left and the room is saving, the next room
+		// starts from what it saved.
+		<-room.done
+	}
+}
+
+func (c *collabService) room(ctx context.Context, noteId uuid.UUID) (*collabRoom, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	room, ok := c.rooms[noteId]
+	if ok {
+		return room, nil
+	}
+
+	note, err := c.noteRepository.GetById(ctx, noteId)
+	if err != nil {
+		return nil, err
+	}
+
+	seedClient := "seed:" + uuid.NewString()
+	doc := crdt.NewDocument(seedClient, note.Content)
+	room = &collabRoom{
+		service:     c,
+		noteId:      noteId,
+		doc:         doc,
+		seedClient:  seedClient,
+		baseContent: note.Content,
+		baseIds:     doc.VisibleIds(),
+		version:     note.Version,
+		clients:     make(map[string]*CollabClient),
+		editors:     make(map[uuid.UUID]bool),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	c.rooms[noteId] = room
+
+	go room.run()
+
+	return room, nil
+}
+
+func (c *collabService) publishEmbed(noteId uuid.UUID, userId uuid.UUID) {
+	payload, err := json.Marshal(dto.PublishEmbedNoteMessage{
+		NotedId: noteId,
+		UserId:  userId,
+	})
+	if err == nil {
+		err = c.publisherService.Publish(context.Background(), payload)
+	}
+	if err != nil {
+		log.Printf("[Collab] Failed to publish embedding of note %s: %v", noteId, err)
+	}
+}
+
+// CollabClient is one connection to the room of a note. The socket handler
+// writes what comes out of Outbox and passes what it reads to Handle, until
+// either side closes and it calls Leave.
+type CollabClient struct {
+	Id      string
+	UserId  uuid.UUID
+	CanEdit bool
+
+	// Guarded by the lock of room.
+	room   *collabRoom
+	cursor *dto.CollabCursor
+	outbox chan []byte
+	closed bool
+}
+
+// Outbox is closed when the room drops the client, because it fell too far
+// behind or the note was deleted.
+func (c *CollabClient) Outbox() <-chan []byte {
+	return c.outbox
+}
+
+func (c *CollabClient) Handle(payload []byte) {
+	room := c.room
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	var message dto.CollabMessage
+	err := json.Unmarshal(payload, &message)
+	if err != nil {
+		c.send(collabErrorMessage("the message is not valid JSON"))
+		return
+	}
+
+	switch message.Type {
+	case constant.CollabMessageOps:
+		room.applyOps(c, message.Ops)
+	case constant.CollabMessageCursor:
+		c.cursor = message.Cursor
+		room.broadcast(c.Id, &dto.CollabCursorMessage{
+			Type:     constant.CollabMessageCursor,
+			ClientId: c.Id,
+			UserId:   c.UserId,
+			Cursor:   c.cursor,
+		})
+	default:
+		c.send(collabErrorMessage(fmt.Sprintf("unknown message type %q", message.Type)))
+	}
+}
+
+func (c *CollabClient) Leave() {
+	room := c.room
+	room.mu.Lock()
+
+	_, ok := room.clients[c.Id]
+	if !ok {
+		room.mu.Unlock()
+		return
+	}
+
+	delete(room.clients, c.Id)
+	c.close()
+
+	if len(room.clients) > 0 {
+		room.broadcast("", room.presenceMessage())
+		room.mu.Unlock()
+		return
+	}
+
+	room.closing = true
+	room.mu.Unlock()
+
+	room.close()
+}
+
+func (c *CollabClient) send(message any) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("[Collab] Failed to encode message: %v", err)
+		return
+	}
+
+	c.sendPayload(payload)
+}
+
+// sendPayload never blocks the room, a client whose outbox is full is
+// dropped and resyncs when it reconnects.
+func (c *CollabClient) sendPayload(payload []byte) {
+	if c.closed {
+		return
+	}
+
+	select {
+	case c.outbox <- payload:
+	default:
+		c.close()
+	}
+}
+
+func (c *CollabClient) close() {
+	if c.closed {
+		return
+	}
+
+	c.closed = true
+	close(c.outbox)
+}
+
+// collabRoom holds the shared document of a note while anyone edits it. The
+// document is written back to the note every collabSnapshotInterval and when
+// the last client leaves.
+type collabRoom struct {
+	service    *collabService
+	noteId     uuid.UUID
+	seedClient string
+
+	mu      sync.Mutex
+	doc     *crdt.Document
+	clients map[string]*CollabClient
+	closing bool
+
+	// baseContent is the content of the note at version, baseIds the
+	// elements of its characters. Changes made through the REST API are
+	// diffed against them.
+	baseContent string
+	baseIds     []crdt.Id
+	version     int
+
+	// editors are the users whose edits are in the document since the last
+	// save, lastEditor the one who edited last.
+	dirty       bool
+	editors     map[uuid.UUID]bool
+	lastEditor  uuid.UUID
+	embedUserId uuid.UUID
+	embedTimer  *time.Timer
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (r *collabRoom) run() {
+	ticker := time.NewTicker(collabSnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			r.snapshot(context.Background())
+			r.mu.Unlock()
+		}
+	}
+}
+
+func (r *collabRoom) add(client *CollabClient) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closing {
+		return false
+	}
+
+	client.room = r
+	r.clients[client.Id] = client
+
+	client.send(r.syncMessage(client))
+	r.broadcast(client.Id, r.presenceMessage())
+
+	return true
+}
+
+func (r *collabRoom) close() {
+	close(r.stop)
+
+	r.mu.Lock()
+	r.snapshot(context.Background())
+	if r.embedTimer != nil && r.embedTimer.Stop() {
+		r.service.publishEmbed(r.noteId, r.embedUserId)
+	}
+	r.mu.Unlock()
+
+	r.service.mu.Lock()
+	delete(r.service.rooms, r.noteId)
+	r.service.mu.Unlock()
+
+	close(r.done)
+}
+
+func (r *collabRoom) applyOps(client *CollabClient, ops []crdt.Op) {
+	if !client.CanEdit {
+		client.send(collabErrorMessage("you can only view this note"))
+		return
+	}
+
+	var err error
+	applied := make([]crdt.Op, 0, len(ops))
+	for _, op := range ops {
+		if op.Kind == crdt.OpInsert && op.Id.Client != client.Id {
+			err = fmt.Errorf("%w: inserts must use your client id", crdt.ErrInvalidOp)
+			break
+		}
+
+		err = r.doc.Apply(op)
+		if err != nil {
+			break
+		}
+
+		applied = append(applied, op)
+	}
+
+	if len(applied) > 0 {
+		r.dirty = true
+		r.editors[client.UserId] = true
+		r.lastEditor = client.UserId
+		r.broadcast(client.Id, &dto.CollabOpsMessage{
+			Type:     constant.CollabMessageOps,
+			ClientId: client.Id,
+			Ops:      applied,
+		})
+	}
+
+	// The client has diverged, it starts over from the document of the
+	// room.
+	if err != nil {
+		client.send(collabErrorMessage(err.Error()))
+		client.send(r.syncMessage(client))
+	}
+}
+
+// snapshot brings in changes made to the note outside of the room, then
+// writes the document back if it changed. Must be called with r.mu held.
+func (r *collabRoom) snapshot(ctx context.Context) {
+	note, err := r.service.noteRepository.GetById(ctx, r.noteId)
+	if err != nil {
+		if errors.Is(err, serverutils.ErrNotFound) {
+			r.broadcast("", collabErrorMessage("the note was deleted"))
+			for _, client := range r.clients {
+				client.close()
+			}
+			return
+		}
+
+		log.Printf("[Collab] Failed to load note %s: %v", r.noteId, err)
+		return
+	}
+
+	r.checkRoles(ctx, note.NotebookId)
+
+	if note.Version != r.version {
+		r.merge(note.Content)
+		r.baseContent = note.Content
+		r.version = note.Version
+	}
+
+	if !r.dirty {
+		return
+	}
+
+	content := r.doc.Text()
+	if content == note.Content {
+		r.dirty = false
+		return
+	}
+
+	err = r.save(ctx, note, content)
+	if err != nil {
+		// A conflict means the note changed since it was loaded, the next
+		// snapshot merges that change first.
+		if !errors.Is(err, serverutils.ErrConflict) {
+			log.Printf("[Collab] Failed to save note %s: %v", r.noteId, err)
+		}
+		return
+	}
+
+	r.baseContent = content
+	r.baseIds = r.doc.VisibleIds()
+	r.version = note.Version
+	r.dirty = false
+	clear(r.editors)
+
+	r.broadcast("", &dto.CollabSavedMessage{
+		Type:    constant.CollabMessageSaved,
+		Version: note.Version,
+	})
+	r.scheduleEmbed(r.lastEditor)
+}
+
+func (r *collabRoom) save(ctx context.Context, note *entity.Note, content string) error {
+	ctx = serverutils.WithUserId(ctx, r.lastEditor)
+
+	before := *note
+	now := time.Now()
+	note.Content = content
+	note.UpdatedAt = &now
+
+	tx, err := r.service.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = r.service.noteRepository.UsingTx(ctx, tx).Update(ctx, note)
+	if err != nil {
+		return err
+	}
+
+	revision := newNoteRevision(note, &r.lastEditor, constant.NoteRevisionSourceCollab, now)
+	err = createNoteRevision(ctx, r.service.noteRevisionRepository.UsingTx(ctx, tx), &before, revision)
+	if err != nil {
+		return err
+	}
+
+	// The snapshot merges the edits of everyone who edited since the last
+	// one, each of them gets an audit event of the update.
+	editors := make([]uuid.UUID, 0, len(r.editors))
+	for userId := range r.editors {
+		editors = append(editors, userId)
+	}
+	slices.SortFunc(editors, func(a, b uuid.UUID) int {
+		return strings.Compare(a.String(), b.String())
+	})
+
+	auditEventRepo := r.service.auditEventRepository.UsingTx(ctx, tx)
+	for _, userId := range editors {
+		auditEvent, err := newAuditEvent(serverutils.WithUserId(ctx, userId), constant.AuditActionUpdate, constant.AuditEntityNote, note.Id, &before, note)
+		if err != nil {
+			return err
+		}
+		auditEvent.NotebookId = &note.NotebookId
+		auditEvent.NoteId = &note.Id
+
+		err = auditEventRepo.Create(ctx, auditEvent)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// checkRoles takes editing away from clients whose user is no longer an
+// editor of the notebook, and drops those who cannot read it anymore. Must
+// be called with r.mu held.
+func (r *collabRoom) checkRoles(ctx context.Context, notebookId uuid.UUID) {
+	roles := make(map[uuid.UUID]string)
+	changed := false
+	for _, client := range r.clients {
+		if !client.CanEdit || client.closed {
+			continue
+		}
+
+		role, ok := roles[client.UserId]
+		if !ok {
+			var err error
+			role, err = r.service.notebookAccessService.Role(ctx, client.UserId, notebookId)
+			if err != nil {
+				log.Printf("[Collab] Failed to check the role of user %s on note %s: %v", client.UserId, r.noteId, err)
+				continue
+			}
+			roles[client.UserId] = role
+		}
+
+		if notebookRoleRank[role] >= notebookRoleRank[constant.NotebookRoleEditor] {
+			continue
+		}
+
+		// The socket handler leaves the room once the outbox is closed.
+		if notebookRoleRank[role] < notebookRoleRank[constant.NotebookRoleViewer] {
+			client.send(collabErrorMessage("you no longer have access to this note"))
+			client.close()
+			continue
+		}
+
+		changed = true
+		client.CanEdit = false
+		client.send(collabErrorMessage("you can no longer edit this note"))
+		client.send(r.syncMessage(client))
+	}
+
+	if changed {
+		r.broadcast("", r.presenceMessage())
+	}
+}
+
+// merge applies the difference between baseContent and content to the
+// document as edits of the room, keeping what clients changed meanwhile.
+func (r *collabRoom) merge(content string) {
+	base := strings.Split(r.baseContent, "")
+	target := strings.Split(content, "")
+
+	ids := make([]crdt.Id, 0, len(target))
+	ops := make([]crdt.Op, 0)
+	matcher := difflib.NewMatcherWithJunk(base, target, false, nil)
+	for _, code := range matcher.GetOpCodes() {
+		if code.Tag == 'e' {
+			ids = append(ids, r.baseIds[code.I1:code.I2]...)
+			continue
+		}
+
+		if code.Tag == 'd' || code.Tag == 'r' {
+			for _, id := range r.baseIds[code.I1:code.I2] {
+				op, err := r.doc.Delete(id)
+				if err != nil {
+					log.Printf("[Collab] Failed to merge note %s: %v", r.noteId, err)
+					continue
+				}
+				ops = append(ops, op)
+			}
+		}
+
+		if code.Tag == 'i' || code.Tag == 'r' {
+			var after *crdt.Id
+			if code.I2 > 0 {
+				id := r.baseIds[code.I2-1]
+				after = &id
+			}
+
+			op, err := r.doc.Insert(r.seedClient, after, strings.Join(target[code.J1:code.J2], ""))
+			if err != nil {
+				log.Printf("[Collab] Failed to merge note %s: %v", r.noteId, err)
+				continue
+			}
+			ops = append(ops, op)
+
+			for i := range code.J2 - code.J1 {
+				ids = append(ids, crdt.Id{Client: op.Id.Client, Clock: op.Id.Clock + uint64(i)})
+			}
+		}
+	}
+
+	r.baseIds = ids
+
+	if len(ops) > 0 {
+		r.broadcast("", &dto.CollabOpsMessage{
+			Type:     constant.CollabMessageOps,
+			ClientId: r.seedClient,
+			Ops:      ops,
+		})
+	}
+}
+
+func (r *collabRoom) scheduleEmbed(userId uuid.UUID) {
+	r.embedUserId = userId
+	if r.embedTimer != nil {
+		r.embedTimer.Reset(collabEmbedDebounce)
+		return
+	}
+
+	r.embedTimer = time.AfterFunc(collabEmbedDebounce, func() {
+		r.mu.Lock()
+		userId := r.embedUserId
+		r.mu.Unlock()
+
+		r.service.publishEmbed(r.noteId, userId)
+	})
+}
+
+func (r *collabRoom) broadcast(exceptClientId string, message any) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("[Collab] Failed to encode message: %v", err)
+		return
+	}
+
+	for id, client := range r.clients {
+		if id != exceptClientId {
+			client.sendPayload(payload)
+		}
+	}
+}
+
+func (r *collabRoom) syncMessage(client *CollabClient) *dto.CollabSyncMessage {
+	return &dto.CollabSyncMessage{
+		Type:     constant.CollabMessageSync,
+		ClientId: client.Id,
+		CanEdit:  client.CanEdit,
+		Version:  r.version,
+		Clock:    r.doc.Clock(),
+		Elements: r.doc.Elements(),
+		Peers:    r.peers(),
+	}
+}
+
+func (r *collabRoom) presenceMessage() *dto.CollabPresenceMessage {
+	return &dto.CollabPresenceMessage{
+		Type:  constant.CollabMessagePresence,
+		Peers: r.peers(),
+	}
+}
+
+func (r *collabRoom) peers() []*dto.CollabPeer {
+	peers := make([]*dto.CollabPeer, 0, len(r.clients))
+	for _, client := range r.clients {
+		peers = append(peers, &dto.CollabPeer{
+			ClientId: client.Id,
+			UserId:   client.UserId,
+			CanEdit:  client.CanEdit,
+			Cursor:   client.cursor,
+		})
+	}
+
+	return peers
+}
+
+func collabErrorMessage(message string) *dto.CollabErrorMessage {
+	return &dto.CollabErrorMessage{
+		Type:    constant.CollabMessageError,
+		Message: message,
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"ai-notetaking-be/internal/constant"
 	"ai-notetaking-be/internal/dto"
 	"ai-notetaking-be/internal/entity"
 	"ai-notetaking-be/internal/pkg/serverutils"
@@ -33,7 +34,10 @@ type consumerService struct {
 	noteEmbeddingRepository repository.INoteEmbeddingRepository
 	pubSub                  *gochannel.GoChannel
 	fileRepository          repository.IFileRepository
+	noteTagRepository       repository.INoteTagRepository
+	noteSuggestionService   INoteSuggestionService
 	s3Client                *garagestorages3.GarageS3
+	usageService            IUsageService
 	topicName               string
 
 	db *pgxpool.Pool
@@ -89,19 +93,31 @@ func (cs *consumerService) processMessage(ctx context.Context, msg *message.Mess
 	}
 
 	// =========================
-	// File Metadata (Opsional)
+	// File Metadata (Opsional, bisa lebih dari satu)
 	// =========================
-	var (
-		fileIDPtr    *uuid.UUID
-		originalName string
-	)
+	files, err := cs.fileRepository.GetByNoteId(ctx, note.Id)
+	if err != nil {
+		log.Errorf("[Repo] Gagal ambil lampiran note %s: %v", note.Id, err)
+		return err
+	}
+
+	originalNames := make([]string, 0, len(files))
+	for _, file := range files {
+		originalNames = append(originalNames, file.OriginalName)
+	}
 
-	fileMeta, err := cs.fileRepository.GetByNoteId(ctx, note.Id)
+	// =========================
+	// Tags, bagian dari header agar ikut memengaruhi retrieval
+	// =========================
+	noteTags, err := cs.noteTagRepository.GetByNoteIds(ctx, []uuid.UUID{note.Id})
 	if err != nil {
-		log.Infof("[Repo] Note %s tidak memiliki lampiran file (opsional)", note.Id)
-	} else if fileMeta != nil {
-		fileIDPtr = &fileMeta.Id
-		originalName = fileMeta.OriginalName
+		log.Errorf("[Repo] Gagal ambil tag note %s: %v", note.Id, err)
+		return err
+	}
+
+	tagNames := make([]string, 0, len(noteTags))
+	for _, noteTag := range noteTags {
+		tagNames = append(tagNames, noteTag.Name)
 	}
 
 	// =========================
@@ -115,6 +131,7 @@ func (cs *consumerService) processMessage(ctx context.Context, msg *message.Mess
 	noteContent := fmt.Sprintf(`
 Note Title      : %s
 Notebook Title  : %s
+Tags            : %s
 File Referensi  : %s
 
 %s
@@ -124,7 +141,8 @@ Updated At      : %s
 `,
 		note.Title,
 		notebook.Name,
-		originalName,
+		strings.Join(tagNames, ", "),
+		strings.Join(originalNames, ", "),
 		note.Content,
 		note.CreatedAt.Format(time.RFC3339),
 		noteUpdatedAt,
@@ -147,31 +165,56 @@ Updated At      : %s
 		)...,
 	)
 
-	// PDF → Per Page
-	if fileMeta != nil {
-		body, err := cs.s3Client.Download(ctx, fileMeta.Bucket, fileMeta.FileName)
-		if err != nil {
-			log.Errorf("[Storage] Download gagal: %v", err)
-			return err
+	// PDF → Per Page, untuk setiap lampiran PDF
+	// File dengan isi yang sama (sha256) milik user yang sama sudah pernah diproses,
+	// chunk & embedding-nya disalin tanpa ekstrak ulang maupun panggil Gemini
+	var reusedEmbeddings []*entity.NoteEmbedding
+	for _, file := range files {
+		if file.ContentType != constant.FileContentTypePdf {
+			continue
 		}
-		defer body.Close()
 
-		pages, err := serverutils.ExtractTextPerPage(body)
+		if file.Sha256 != nil {
+			embeddings, err := cs.noteEmbeddingRepository.GetByFileSha256(ctx, file.UserId, *file.Sha256)
+			if err != nil {
+				log.Errorf("[Repo] Gagal ambil embedding file identik untuk file %s: %v", file.Id, err)
+				return err
+			}
+
+			if len(embeddings) > 0 {
+				log.Debugf("[Dedup] File %s memakai ulang %d chunk dari file identik", file.Id, len(embeddings))
+
+				for _, chunk := range embeddings {
+					fileId := file.Id
+					chunk.FileId = &fileId
+				}
+
+				reusedEmbeddings = append(reusedEmbeddings, embeddings...)
+				continue
+			}
+		}
+
+		pages, err := cs.extractFilePages(ctx, file)
 		if err != nil {
-			log.Errorf("[PDF] Extract text gagal: %v", err)
+			log.Errorf("[PDF] Extract text gagal untuk file %s: %v", file.Id, err)
 			return err
 		}
 
 		for _, page := range pages {
-			docs = append(docs,
-				chunking.ChunkPdfPage(
-					chunking.PdfPage{
-						PageNumber: page.PageNumber,
-						Content:    page.Content,
-					},
-					maxChunkSize,
-				)...,
+			chunks := chunking.ChunkPdfPage(
+				chunking.PdfPage{
+					PageNumber: page.PageNumber,
+					Content:    page.Content,
+				},
+				maxChunkSize,
 			)
+
+			// Tandai asal file setiap chunk
+			for _, chunk := range chunks {
+				chunk.Metadata["file_id"] = file.Id
+			}
+
+			docs = append(docs, chunks...)
 		}
 	}
 
@@ -201,6 +244,11 @@ Updated At      : %s
 			pageNumber = v
 		}
 
+		var fileId *uuid.UUID
+		if v, ok := doc.Metadata["file_id"].(uuid.UUID); ok {
+			fileId = &v
+		}
+
 		// 1. Ambil sedikit potongan teks untuk inspeksi di log
 		preview := doc.PageContent
 		if len(preview) > 50 {
@@ -226,7 +274,9 @@ Updated At      : %s
 		}
 
 		// 4. Panggil Gemini Embedding
+		start := time.Now()
 		res, err := embedding.GetGeminiEmbedding(
+			ctx,
 			os.Getenv("GOOGLE_GEMINI_API_KEY"),
 			"models/gemini-embedding-exp-03-07",
 			cleanContent, // Gunakan teks yang sudah dibersihkan
@@ -238,11 +288,16 @@ Updated At      : %s
 			return err
 		}
 
+		llmUsage := embeddingUsage(constant.UsageFeatureIndexing, cleanContent, res.UsageMetadata, time.Since(start))
+		llmUsage.UserId = &payload.UserId
+		llmUsage.NoteId = &note.Id
+		cs.usageService.Record(ctx, llmUsage)
+
 		// 5. Simpan ke Repository
 		if err := repo.Create(ctx, &entity.NoteEmbedding{
 			Id:             uuid.New(),
 			NoteId:         note.Id,
-			FileId:         fileIDPtr,
+			FileId:         fileId,
 			ChunkContent:   doc.PageContent,
 			EmbeddingValue: res.Embedding.Values,
 			PageNumber:     pageNumber,
@@ -260,6 +315,26 @@ Updated At      : %s
 		}
 	}
 
+	// =========================
+	// Salin chunk dari file identik
+	// =========================
+	for i, reused := range reusedEmbeddings {
+		if err := repo.Create(ctx, &entity.NoteEmbedding{
+			Id:             uuid.New(),
+			NoteId:         note.Id,
+			FileId:         reused.FileId,
+			ChunkContent:   reused.ChunkContent,
+			EmbeddingValue: reused.EmbeddingValue,
+			PageNumber:     reused.PageNumber,
+			ChunkIndex:     len(docs) + i + 1,
+			OverlapRange:   reused.OverlapRange,
+			CreatedAt:      time.Now(),
+		}); err != nil {
+			log.Errorf("[DB] Gagal salin embedding Note %s | File %s: %v", note.Id, reused.FileId, err)
+			return err
+		}
+	}
+
 	// =========================
 	// Commit
 	// =========================
@@ -269,15 +344,33 @@ Updated At      : %s
 	}
 
 	log.Infof(
-		"[Success] Berhasil memproses %d chunk embedding untuk Note: %s",
-		len(docs),
+		"[Success] Berhasil memproses %d chunk embedding (%d disalin) untuk Note: %s",
+		len(docs)+len(reusedEmbeddings),
+		len(reusedEmbeddings),
 		note.Id,
 	)
 
+	// =========================
+	// Saran Tag & Notebook (tidak menggagalkan indexing)
+	// =========================
+	if err := cs.noteSuggestionService.Suggest(ctx, note.Id, payload.UserId); err != nil {
+		log.Errorf("[Suggestion] Gagal membuat saran untuk note %s: %v", note.Id, err)
+	}
+
 	msg.Ack()
 	return nil
 }
 
+func (cs *consumerService) extractFilePages(ctx context.Context, file *entity.File) ([]serverutils.PdfPage, error) {
+	body, err := cs.s3Client.Download(ctx, file.Bucket, file.FileName)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return serverutils.ExtractTextPerPage(body)
+}
+
 func NewConsumerService(
 	pubSub *gochannel.GoChannel,
 	topicName string,
@@ -285,7 +378,10 @@ func NewConsumerService(
 	noteEmbeddingRepository repository.INoteEmbeddingRepository,
 	notebookRepository repository.INotebookRepository,
 	fileRepository repository.IFileRepository,
+	noteTagRepository repository.INoteTagRepository,
+	noteSuggestionService INoteSuggestionService,
 	s3Client *garagestorages3.GarageS3,
+	usageService IUsageService,
 	db *pgxpool.Pool) IConsumerService {
 	return &consumerService{
 		pubSub:                  pubSub,
@@ -294,7 +390,10 @@ func NewConsumerService(
 		noteEmbeddingRepository: noteEmbeddingRepository,
 		notebookRepository:      notebookRepository,
 		fileRepository:          fileRepository,
+		noteTagRepository:       noteTagRepository,
+		noteSuggestionService:   noteSuggestionService,
 		s3Client:                s3Client,
+		usageService:            usageService,
 		db:                      db,
 	}
 }
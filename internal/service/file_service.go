@@ -1,79 +1,230 @@
 package service
 
 import (
+	"ai-notetaking-be/internal/constant"
 	"ai-notetaking-be/internal/dto"
 	"ai-notetaking-be/internal/entity"
 	"ai-notetaking-be/internal/pkg/serverutils"
 	"ai-notetaking-be/internal/repository"
+	"ai-notetaking-be/pkg/fractional"
 	garagestorages3 "ai-notetaking-be/pkg/garage-storage-s3"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"os"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type IFileService interface {
 	UploadFile(ctx context.Context, noteId uuid.UUID, fileName string, content io.ReadSeeker) (*dto.UploadFileResponse, error)
 	GetFileUrl(ctx context.Context, fileName string) (string, error)
+	GetByNoteId(ctx context.Context, noteId uuid.UUID) ([]*dto.FileResponse, error)
+	Delete(ctx context.Context, noteId uuid.UUID, id uuid.UUID) error
+	Rename(ctx context.Context, req *dto.RenameFileRequest) (*dto.RenameFileResponse, error)
+	Reorder(ctx context.Context, req *dto.ReorderFileRequest) (*dto.ReorderFileResponse, error)
+	CreateUploadIntent(ctx context.Context, req *dto.CreateUploadIntentRequest) (*dto.CreateUploadIntentResponse, error)
+	CompleteUpload(ctx context.Context, id uuid.UUID) (*dto.UploadFileResponse, error)
+	CreateUploadSession(ctx context.Context, req *dto.CreateUploadSessionRequest) (*dto.UploadSessionResponse, error)
+	GetUploadSession(ctx context.Context, id uuid.UUID) (*dto.UploadSessionResponse, error)
+	UploadPart(ctx context.Context, id uuid.UUID, partNumber int32, content io.ReadSeeker, size int64) (*dto.UploadSessionResponse, error)
+	CompleteUploadSession(ctx context.Context, id uuid.UUID) (*dto.UploadFileResponse, error)
+	AbortUploadSession(ctx context.Context, id uuid.UUID) error
+	AbortStaleUploadSessions(ctx context.Context) error
+	StartUploadCleanupJob(ctx context.Context)
 }
 
+// UploadRule limits the attachments of one media type. MaxPages only applies
+// to PDFs, zero means no limit.
+type UploadRule struct {
+	MaxSize  int64 `json:"max_size"`
+	MaxPages int   `json:"max_pages"`
+}
+
+// UploadPolicy maps the media types accepted as attachments to their rules,
+// anything else is refused.
+type UploadPolicy map[string]UploadRule
+
+var defaultUploadPolicy = UploadPolicy{
+	constant.FileContentTypePdf: {MaxSize: 100 * 1024 * 1024, MaxPages: 1000},
+	"image/png":                 {MaxSize: 20 * 1024 * 1024},
+	"image/jpeg":                {MaxSize: 20 * 1024 * 1024},
+	"text/plain":                {MaxSize: 10 * 1024 * 1024},
+}
+
+// ParseUploadPolicy reads the upload policy, a JSON object of media types to
+// rules such as {"application/pdf": {"max_size": 1048576, "max_pages": 50}}.
+// It replaces the default policy as a whole, empty means the default.
+func ParseUploadPolicy(raw string) (UploadPolicy, error) {
+	if raw == "" {
+		return defaultUploadPolicy, nil
+	}
+
+	var policy UploadPolicy
+	err := json.Unmarshal([]byte(raw), &policy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload policy: %w", err)
+	}
+
+	for mediaType, rule := range policy {
+		if rule.MaxSize <= 0 || rule.MaxPages < 0 {
+			return nil, fmt.Errorf("invalid upload policy for %s: max_size must be positive and max_pages not negative", mediaType)
+		}
+	}
+
+	return policy, nil
+}
+
+const (
+	// uploadIntentExpiry is how long a presigned upload URL stays valid.
+	uploadIntentExpiry = 15 * time.Minute
+	// Presigned uploads land under uploadStagingPrefix. The URL stays valid
+	// after completion, so the file gets a copy under a key it cannot write.
+	uploadStagingPrefix = "staging/"
+	// presignedUploadMaxSize bounds uploads that do not go through the
+	// request body limit.
+	presignedUploadMaxSize = 100 * 1024 * 1024
+
+	// uploadPartSize stays under the request body limit, parts go through
+	// the API. S3 wants at least 5 MiB for every part but the last.
+	uploadPartSize         = 8 * 1024 * 1024
+	resumableUploadMaxSize = 5 * 1024 * 1024 * 1024
+	// Pending upload sessions without a part for uploadSessionTtl are
+	// aborted by the cleanup job.
+	uploadSessionTtl       = 24 * time.Hour
+	uploadCleanupInterval  = time.Hour
+	uploadCleanupBatchSize = 100
+)
+
 type fileService struct {
-	noteRepository repository.INoteRepository
-	fileRepository repository.IFileRepository
-	s3Client       *garagestorages3.GarageS3
+	noteRepository          repository.INoteRepository
+	fileRepository          repository.IFileRepository
+	noteEmbeddingRepository repository.INoteEmbeddingRepository
+	uploadIntentRepository  repository.IUploadIntentRepository
+	uploadSessionRepository repository.IUploadSessionRepository
+	fileBlobRepository      repository.IFileBlobRepository
+	s3Client                *garagestorages3.GarageS3
+	notebookAccessService   INotebookAccessService
+	auditEventRepository    repository.IAuditEventRepository
+	publisherService        IPublisherService
+	uploadPolicy            UploadPolicy
+	db                      *pgxpool.Pool
 }
 
 func NewFileService(
 	noteRepository repository.INoteRepository,
 	fileRepository repository.IFileRepository,
+	noteEmbeddingRepository repository.INoteEmbeddingRepository,
+	uploadIntentRepository repository.IUploadIntentRepository,
+	uploadSessionRepository repository.IUploadSessionRepository,
+	fileBlobRepository repository.IFileBlobRepository,
 	s3Client *garagestorages3.GarageS3,
+	notebookAccessService INotebookAccessService,
+	auditEventRepository repository.IAuditEventRepository,
+	publisherService IPublisherService,
+	uploadPolicy UploadPolicy,
+	db *pgxpool.Pool,
 ) IFileService {
 	return &fileService{
-		noteRepository: noteRepository,
-		fileRepository: fileRepository,
-		s3Client:       s3Client,
+		noteRepository:          noteRepository,
+		fileRepository:          fileRepository,
+		noteEmbeddingRepository: noteEmbeddingRepository,
+		uploadIntentRepository:  uploadIntentRepository,
+		uploadSessionRepository: uploadSessionRepository,
+		fileBlobRepository:      fileBlobRepository,
+		s3Client:                s3Client,
+		notebookAccessService:   notebookAccessService,
+		auditEventRepository:    auditEventRepository,
+		publisherService:        publisherService,
+		uploadPolicy:            uploadPolicy,
+		db:                      db,
 	}
 }
 
 func (s *fileService) UploadFile(ctx context.Context, noteId uuid.UUID, fileName string, content io.ReadSeeker) (*dto.UploadFileResponse, error) {
-	_, err := s.noteRepository.GetById(ctx, noteId)
+	note, err := s.noteRepository.GetById(ctx, noteId)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := s.notebookAccessService.Authorize(ctx, note.NotebookId, constant.NotebookRoleEditor)
 	if err != nil {
 		return nil, err
 	}
 
-	safeFileName, err := s.s3Client.Upload(ctx, os.Getenv("BUCKET"), fileName, content)
+	mimeType, err := s.checkContent(content)
 	if err != nil {
-		return nil, fmt.Errorf("gagal upload ke storage: %w", err)
+		return nil, err
 	}
 
-	mimeType, _ := s.s3Client.DetectMimeType(content)
+	contentHash, err := hashContent(content)
+	if err != nil {
+		return nil, err
+	}
 
 	fileEntity := &entity.File{
 		Id:           uuid.New(),
-		FileName:     safeFileName,
 		Bucket:       os.Getenv("BUCKET"),
 		OriginalName: fileName,
 		ContentType:  mimeType,
 		NoteId:       noteId,
+		UserId:       userId,
+		Sha256:       &contentHash,
 		CreatedAt:    time.Now(),
 	}
 
-	err = s.fileRepository.Create(ctx, fileEntity)
+	// The content is streamed to the bucket before the transaction starts,
+	// so a slow upload holds no locks. Content that is stored already is not
+	// uploaded again.
+	var safeFileName string
+	upload := storedObjectGone
+	_, err = s.fileBlobRepository.GetBySha256(ctx, userId, fileEntity.Bucket, contentHash)
+	switch {
+	case err == nil:
+	case errors.Is(err, serverutils.ErrNotFound):
+		safeFileName, err = s.s3Client.Upload(ctx, fileEntity.Bucket, fileName, content)
+		if err != nil {
+			return nil, fmt.Errorf("gagal upload ke storage: %w", err)
+		}
+		upload = uploadedObject(safeFileName)
+	default:
+		return nil, err
+	}
+
+	reused, err := s.createFile(ctx, note, fileEntity, upload)
 	if err != nil {
 		fmt.Printf("[ERROR] Database Create File: %v\n", err)
 
-		deleteErr := s.s3Client.Delete(ctx, os.Getenv("BUCKET"), safeFileName)
-		if deleteErr != nil {
-			fmt.Printf("[CRITICAL] Gagal menghapus file yatim di S3: %v\n", deleteErr)
+		// Nothing was uploaded when the content was already stored.
+		if safeFileName != "" {
+			deleteErr := s.s3Client.Delete(ctx, fileEntity.Bucket, safeFileName)
+			if deleteErr != nil {
+				fmt.Printf("[CRITICAL] Gagal menghapus file yatim di S3: %v\n", deleteErr)
+			}
 		}
 
 		return nil, fmt.Errorf("gagal menyimpan metadata file ke database: %w", err)
 	}
 
+	// An identical upload finished first, the file points at its object.
+	if reused && safeFileName != "" {
+		s.deleteDuplicateObject(ctx, fileEntity.Bucket, safeFileName)
+	}
+
+	err = s.reindex(ctx, noteId, userId)
+	if err != nil {
+		return nil, err
+	}
+
 	return &dto.UploadFileResponse{
 		FileId:   fileEntity.Id,
 		FileName: fileEntity.FileName,
@@ -85,7 +236,22 @@ func (s *fileService) GetFileUrl(ctx context.Context, fileName string) (string,
 		return "", serverutils.ErrBadRequest
 	}
 
-	url, err := s.s3Client.GetPresignedURL(ctx, os.Getenv("BUCKET"), fileName, 15*time.Minute)
+	file, err := s.fileRepository.GetByFileName(ctx, fileName)
+	if err != nil {
+		return "", err
+	}
+
+	note, err := s.noteRepository.GetById(ctx, file.NoteId)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.notebookAccessService.Authorize(ctx, note.NotebookId, constant.NotebookRoleViewer)
+	if err != nil {
+		return "", err
+	}
+
+	url, err := s.s3Client.GetPresignedURL(ctx, file.Bucket, file.FileName, 15*time.Minute)
 
 	if err != nil {
 		log.Printf("[S3 Service] Failed to generate Presigned URL for %s: %v", fileName, err)
@@ -95,3 +261,1087 @@ func (s *fileService) GetFileUrl(ctx context.Context, fileName string) (string,
 
 	return url, nil
 }
+
+func (s *fileService) GetByNoteId(ctx context.Context, noteId uuid.UUID) ([]*dto.FileResponse, error) {
+	note, err := s.noteRepository.GetById(ctx, noteId)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.notebookAccessService.Authorize(ctx, note.NotebookId, constant.NotebookRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := s.fileRepository.GetByNoteId(ctx, note.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*dto.FileResponse, 0, len(files))
+	for _, file := range files {
+		res = append(res, &dto.FileResponse{
+			Id:           file.Id,
+			FileName:     file.FileName,
+			OriginalName: file.OriginalName,
+			ContentType:  file.ContentType,
+			Position:     file.Position,
+			Sha256:       file.Sha256,
+			CreatedAt:    file.CreatedAt,
+			UpdatedAt:    file.UpdatedAt,
+		})
+	}
+
+	return res, nil
+}
+
+// Delete removes the attachment right away, unlike notes it does not go
+// through the trash. Its embeddings go with it and the object is deleted from
+// S3 once the row is gone, unless other files share it.
+func (s *fileService) Delete(ctx context.Context, noteId uuid.UUID, id uuid.UUID) error {
+	note, file, userId, err := s.getAuthorizedFile(ctx, noteId, id, constant.NotebookRoleEditor)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	// The row goes first, a concurrent delete of the same file stops here
+	// instead of releasing the object twice.
+	err = s.fileRepository.UsingTx(ctx, tx).HardDelete(ctx, file.Id)
+	if err != nil {
+		return err
+	}
+
+	err = s.noteEmbeddingRepository.UsingTx(ctx, tx).HardDeleteByFileId(ctx, file.Id)
+	if err != nil {
+		return err
+	}
+
+	remaining, err := s.fileBlobRepository.UsingTx(ctx, tx).Release(ctx, file.Bucket, file.FileName, 1)
+	if err != nil {
+		return err
+	}
+
+	err = s.recordAuditEvent(ctx, s.auditEventRepository.UsingTx(ctx, tx), constant.AuditActionDelete, note, file, nil)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	if remaining == 0 {
+		err = s.s3Client.Delete(ctx, file.Bucket, file.FileName)
+		if err != nil {
+			log.Printf("[S3 Service] Failed to delete object %s of file %s: %v", file.FileName, file.Id, err)
+		}
+	}
+
+	return s.reindex(ctx, note.Id, userId)
+}
+
+func (s *fileService) Rename(ctx context.Context, req *dto.RenameFileRequest) (*dto.RenameFileResponse, error) {
+	note, file, userId, err := s.getAuthorizedFile(ctx, req.NoteId, req.Id, constant.NotebookRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *file
+	file.OriginalName = req.OriginalName
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = s.fileRepository.UsingTx(ctx, tx).UpdateOriginalName(ctx, file.Id, file.OriginalName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.recordAuditEvent(ctx, s.auditEventRepository.UsingTx(ctx, tx), constant.AuditActionUpdate, note, &before, file)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The names of the attachments are part of the indexed note header.
+	err = s.reindex(ctx, note.Id, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.RenameFileResponse{
+		Id:           file.Id,
+		OriginalName: file.OriginalName,
+	}, nil
+}
+
+// Reorder needs the editor role on the notebook of the note.
+func (s *fileService) Reorder(ctx context.Context, req *dto.ReorderFileRequest) (*dto.ReorderFileResponse, error) {
+	anchorId, after, err := parseAnchor(req.Id, req.BeforeId, req.AfterId)
+	if err != nil {
+		return nil, err
+	}
+
+	note, file, _, err := s.getAuthorizedFile(ctx, req.NoteId, req.Id, constant.NotebookRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	fileRepository := s.fileRepository.UsingTx(ctx, tx)
+
+	siblings, err := fileRepository.GetSiblingPositions(ctx, note.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	position, err := placeNextTo(ctx, siblings, anchorId, after, fileRepository.UpdatePosition)
+	if err != nil {
+		if errors.Is(err, serverutils.ErrNotFound) {
+			return nil, fmt.Errorf("%w: the anchor must be an attachment of the same note", serverutils.ErrBadRequest)
+		}
+		return nil, err
+	}
+
+	err = fileRepository.UpdatePosition(ctx, file.Id, position)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *file
+	file.Position = position
+
+	err = s.recordAuditEvent(ctx, s.auditEventRepository.UsingTx(ctx, tx), constant.AuditActionReorder, note, &before, file)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ReorderFileResponse{
+		Id:       file.Id,
+		Position: position,
+	}, nil
+}
+
+// CreateUploadIntent returns a presigned URL the client uploads the
+// attachment to, bypassing the request body limit. The attachment only shows
+// up once CompleteUpload verified the stored object.
+func (s *fileService) CreateUploadIntent(ctx context.Context, req *dto.CreateUploadIntentRequest) (*dto.CreateUploadIntentResponse, error) {
+	if req.Size > presignedUploadMaxSize {
+		return nil, fmt.Errorf("%w: size must be at most %d bytes", serverutils.ErrBadRequest, presignedUploadMaxSize)
+	}
+
+	contentType, err := s.checkAnnounced(req.ContentType, req.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	note, err := s.noteRepository.GetById(ctx, req.NoteId)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := s.notebookAccessService.Authorize(ctx, note.NotebookId, constant.NotebookRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	uploadIntent := entity.UploadIntent{
+		Id:           uuid.New(),
+		NoteId:       note.Id,
+		UserId:       userId,
+		Bucket:       os.Getenv("BUCKET"),
+		FileName:     uploadStagingPrefix + s.s3Client.GenerateSafeFileName(req.FileName),
+		OriginalName: req.FileName,
+		ContentType:  contentType,
+		Size:         req.Size,
+		Status:       constant.UploadIntentStatusPending,
+		ExpiresAt:    now.Add(uploadIntentExpiry),
+		CreatedAt:    now,
+	}
+
+	url, err := s.s3Client.GetPresignedPutURL(ctx, uploadIntent.Bucket, uploadIntent.FileName, uploadIntent.ContentType, uploadIntentExpiry)
+	if err != nil {
+		log.Printf("[S3 Service] Failed to generate presigned upload URL for %s: %v", uploadIntent.FileName, err)
+
+		return nil, serverutils.ErrInternal
+	}
+
+	err = s.uploadIntentRepository.Create(ctx, &uploadIntent)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.CreateUploadIntentResponse{
+		UploadId: uploadIntent.Id,
+		Url:      url,
+		Method:   "PUT",
+		Headers: map[string]string{
+			"Content-Type": uploadIntent.ContentType,
+		},
+		ExpiresAt: uploadIntent.ExpiresAt,
+	}, nil
+}
+
+// CompleteUpload turns the uploaded object into an attachment of the note
+// once it matches the intent and the upload policy, and indexes the note.
+// A rejected object is deleted, the client may upload again until the
+// intent expires.
+func (s *fileService) CompleteUpload(ctx context.Context, id uuid.UUID) (*dto.UploadFileResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	uploadIntent, err := s.uploadIntentRepository.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if uploadIntent.UserId != userId {
+		return nil, serverutils.ErrNotFound
+	}
+
+	if uploadIntent.Status != constant.UploadIntentStatusPending {
+		return nil, fmt.Errorf("%w: the upload was completed already", serverutils.ErrBadRequest)
+	}
+
+	if time.Now().After(uploadIntent.ExpiresAt) {
+		return nil, fmt.Errorf("%w: the upload expired", serverutils.ErrBadRequest)
+	}
+
+	note, err := s.noteRepository.GetById(ctx, uploadIntent.NoteId)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.notebookAccessService.Authorize(ctx, note.NotebookId, constant.NotebookRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	// The presigned URL can still overwrite the staging key, what is checked
+	// and hashed is the copy the file points at.
+	fileName := s.s3Client.GenerateSafeFileName(uploadIntent.OriginalName)
+	err = s.s3Client.Copy(ctx, uploadIntent.Bucket, uploadIntent.FileName, fileName)
+	if err != nil {
+		if errors.Is(err, garagestorages3.ErrObjectNotFound) {
+			return nil, fmt.Errorf("%w: the file was not uploaded", serverutils.ErrBadRequest)
+		}
+		return nil, err
+	}
+
+	// Until the file row is committed the copy is ours to clean up, the
+	// client may fix the upload and complete again.
+	committed := false
+	defer func() {
+		if !committed {
+			s.deleteRejectedObject(ctx, uploadIntent.Bucket, fileName)
+		}
+	}()
+
+	err = s.checkStoredObject(ctx, uploadIntent.Bucket, fileName, uploadIntent.ContentType, uploadIntent.Size)
+	if err != nil {
+		if isRejectedUpload(err) {
+			s.deleteRejectedObject(ctx, uploadIntent.Bucket, uploadIntent.FileName)
+		}
+		return nil, err
+	}
+
+	contentHash, err := s.hashObject(ctx, uploadIntent.Bucket, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	file := &entity.File{
+		Id:           uuid.New(),
+		Bucket:       uploadIntent.Bucket,
+		OriginalName: uploadIntent.OriginalName,
+		ContentType:  uploadIntent.ContentType,
+		NoteId:       note.Id,
+		UserId:       userId,
+		Sha256:       &contentHash,
+		CreatedAt:    now,
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	// Completing twice at once only creates one file.
+	err = s.uploadIntentRepository.UsingTx(ctx, tx).Complete(ctx, uploadIntent.Id, now)
+	if err != nil {
+		return nil, err
+	}
+
+	reused, err := s.insertFile(ctx, tx, note, file, uploadedObject(fileName))
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	committed = true
+	err = s.s3Client.Delete(ctx, uploadIntent.Bucket, uploadIntent.FileName)
+	if err != nil {
+		log.Printf("[S3 Service] Failed to delete staging upload %s: %v", uploadIntent.FileName, err)
+	}
+	if reused {
+		s.deleteDuplicateObject(ctx, uploadIntent.Bucket, fileName)
+	}
+
+	err = s.reindex(ctx, note.Id, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.UploadFileResponse{
+		FileId:   file.Id,
+		FileName: file.FileName,
+	}, nil
+}
+
+// CreateUploadSession starts a resumable upload, see UploadPart.
+func (s *fileService) CreateUploadSession(ctx context.Context, req *dto.CreateUploadSessionRequest) (*dto.UploadSessionResponse, error) {
+	if req.Size > resumableUploadMaxSize {
+		return nil, fmt.Errorf("%w: size must be at most %d bytes", serverutils.ErrBadRequest, int64(resumableUploadMaxSize))
+	}
+
+	contentType, err := s.checkAnnounced(req.ContentType, req.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	note, err := s.noteRepository.GetById(ctx, req.NoteId)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := s.notebookAccessService.Authorize(ctx, note.NotebookId, constant.NotebookRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	uploadSession := entity.UploadSession{
+		Id:           uuid.New(),
+		NoteId:       note.Id,
+		UserId:       userId,
+		Bucket:       os.Getenv("BUCKET"),
+		FileName:     s.s3Client.GenerateSafeFileName(req.FileName),
+		OriginalName: req.FileName,
+		ContentType:  contentType,
+		Size:         req.Size,
+		PartSize:     uploadPartSize,
+		Status:       constant.UploadSessionStatusPending,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	uploadSession.MultipartUploadId, err = s.s3Client.CreateMultipartUpload(ctx, uploadSession.Bucket, uploadSession.FileName, uploadSession.ContentType)
+	if err != nil {
+		log.Printf("[S3 Service] Failed to create multipart upload for %s: %v", uploadSession.FileName, err)
+
+		return nil, serverutils.ErrInternal
+	}
+
+	err = s.uploadSessionRepository.Create(ctx, &uploadSession)
+	if err != nil {
+		abortErr := s.s3Client.AbortMultipartUpload(ctx, uploadSession.Bucket, uploadSession.FileName, uploadSession.MultipartUploadId)
+		if abortErr != nil {
+			log.Printf("[S3 Service] Failed to abort multipart upload %s: %v", uploadSession.MultipartUploadId, abortErr)
+		}
+
+		return nil, err
+	}
+
+	return toUploadSessionResponse(&uploadSession, nil), nil
+}
+
+// GetUploadSession tells the client where to resume.
+func (s *fileService) GetUploadSession(ctx context.Context, id uuid.UUID) (*dto.UploadSessionResponse, error) {
+	uploadSession, err := s.getOwnUploadSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := s.uploadSessionRepository.GetParts(ctx, uploadSession.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return toUploadSessionResponse(uploadSession, parts), nil
+}
+
+// UploadPart stores part partNumber of the upload. Parts may come in any
+// order and a part uploaded again replaces the previous one, so a client
+// retries a failed part as is.
+func (s *fileService) UploadPart(ctx context.Context, id uuid.UUID, partNumber int32, content io.ReadSeeker, size int64) (*dto.UploadSessionResponse, error) {
+	uploadSession, err := s.getOwnUploadSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if uploadSession.Status != constant.UploadSessionStatusPending {
+		return nil, fmt.Errorf("%w: the upload is %s", serverutils.ErrBadRequest, uploadSession.Status)
+	}
+
+	partCount := uploadPartCount(uploadSession)
+	if partNumber < 1 || partNumber > partCount {
+		return nil, fmt.Errorf("%w: part number must be between 1 and %d", serverutils.ErrBadRequest, partCount)
+	}
+
+	expectedSize := uploadSession.PartSize
+	if partNumber == partCount {
+		expectedSize = uploadSession.Size - int64(partCount-1)*uploadSession.PartSize
+	}
+	if size != expectedSize {
+		return nil, fmt.Errorf("%w: part %d must have %d bytes, got %d", serverutils.ErrBadRequest, partNumber, expectedSize, size)
+	}
+
+	etag, err := s.s3Client.UploadPart(ctx, uploadSession.Bucket, uploadSession.FileName, uploadSession.MultipartUploadId, partNumber, content, size)
+	if err != nil {
+		log.Printf("[S3 Service] Failed to upload part %d of %s: %v", partNumber, uploadSession.Id, err)
+
+		return nil, serverutils.ErrInternal
+	}
+
+	err = s.uploadSessionRepository.SavePart(ctx, &entity.UploadSessionPart{
+		UploadSessionId: uploadSession.Id,
+		PartNumber:      partNumber,
+		ETag:            etag,
+		Size:            size,
+		CreatedAt:       time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := s.uploadSessionRepository.GetParts(ctx, uploadSession.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return toUploadSessionResponse(uploadSession, parts), nil
+}
+
+// CompleteUploadSession assembles the parts once all of them are uploaded,
+// then adds the attachment like CompleteUpload. A rejected object is deleted
+// along with the session. The session is completing from the moment S3 may
+// assemble the parts, a failure after that can be retried.
+func (s *fileService) CompleteUploadSession(ctx context.Context, id uuid.UUID) (*dto.UploadFileResponse, error) {
+	uploadSession, err := s.getOwnUploadSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if uploadSession.Status != constant.UploadSessionStatusPending && uploadSession.Status != constant.UploadSessionStatusCompleting {
+		return nil, fmt.Errorf("%w: the upload is %s", serverutils.ErrBadRequest, uploadSession.Status)
+	}
+
+	note, err := s.noteRepository.GetById(ctx, uploadSession.NoteId)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := s.notebookAccessService.Authorize(ctx, note.NotebookId, constant.NotebookRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := s.uploadSessionRepository.GetParts(ctx, uploadSession.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	partCount := uploadPartCount(uploadSession)
+	if int32(len(parts)) != partCount {
+		return nil, fmt.Errorf("%w: %d of %d parts were uploaded", serverutils.ErrBadRequest, len(parts), partCount)
+	}
+
+	uploadedParts := make([]garagestorages3.UploadedPart, 0, len(parts))
+	for _, part := range parts {
+		uploadedParts = append(uploadedParts, garagestorages3.UploadedPart{
+			PartNumber: part.PartNumber,
+			ETag:       part.ETag,
+		})
+	}
+
+	if uploadSession.Status == constant.UploadSessionStatusPending {
+		err = s.uploadSessionRepository.StartCompleting(ctx, uploadSession.Id, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// An earlier attempt may have assembled the parts already, then the object
+	// is checked as if this call had.
+	err = s.s3Client.CompleteMultipartUpload(ctx, uploadSession.Bucket, uploadSession.FileName, uploadSession.MultipartUploadId, uploadedParts)
+	if err != nil && !errors.Is(err, garagestorages3.ErrUploadNotFound) {
+		log.Printf("[S3 Service] Failed to complete multipart upload %s: %v", uploadSession.Id, err)
+
+		return nil, serverutils.ErrInternal
+	}
+
+	now := time.Now()
+	err = s.checkStoredObject(ctx, uploadSession.Bucket, uploadSession.FileName, uploadSession.ContentType, uploadSession.Size)
+	if err != nil {
+		if !isRejectedUpload(err) {
+			return nil, err
+		}
+
+		s.deleteRejectedObject(ctx, uploadSession.Bucket, uploadSession.FileName)
+
+		abortErr := s.uploadSessionRepository.Abort(ctx, uploadSession.Id, constant.UploadSessionStatusCompleting, now)
+		if abortErr != nil {
+			return nil, abortErr
+		}
+
+		return nil, err
+	}
+
+	contentHash, err := s.hashObject(ctx, uploadSession.Bucket, uploadSession.FileName)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &entity.File{
+		Id:           uuid.New(),
+		Bucket:       uploadSession.Bucket,
+		OriginalName: uploadSession.OriginalName,
+		ContentType:  uploadSession.ContentType,
+		NoteId:       note.Id,
+		UserId:       userId,
+		Sha256:       &contentHash,
+		CreatedAt:    now,
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = s.uploadSessionRepository.UsingTx(ctx, tx).Complete(ctx, uploadSession.Id, now)
+	if err != nil {
+		return nil, err
+	}
+
+	reused, err := s.insertFile(ctx, tx, note, file, uploadedObject(uploadSession.FileName))
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if reused {
+		s.deleteDuplicateObject(ctx, uploadSession.Bucket, uploadSession.FileName)
+	}
+
+	err = s.reindex(ctx, note.Id, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.UploadFileResponse{
+		FileId:   file.Id,
+		FileName: file.FileName,
+	}, nil
+}
+
+// AbortUploadSession discards the uploaded parts.
+func (s *fileService) AbortUploadSession(ctx context.Context, id uuid.UUID) error {
+	uploadSession, err := s.getOwnUploadSession(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if uploadSession.Status != constant.UploadSessionStatusPending {
+		return fmt.Errorf("%w: the upload is %s", serverutils.ErrBadRequest, uploadSession.Status)
+	}
+
+	return s.abortUploadSession(ctx, uploadSession)
+}
+
+func (s *fileService) StartUploadCleanupJob(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(uploadCleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			err := s.AbortStaleUploadSessions(ctx)
+			if err != nil {
+				log.Printf("[Upload] Cleanup failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// AbortStaleUploadSessions aborts pending or completing uploads without
+// activity for uploadSessionTtl, a batch at a time. A session that fails is
+// logged and stays for the next run, the others go on.
+func (s *fileService) AbortStaleUploadSessions(ctx context.Context) error {
+	uploadSessions, err := s.uploadSessionRepository.GetStale(ctx, time.Now().Add(-uploadSessionTtl), uploadCleanupBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, uploadSession := range uploadSessions {
+		err = s.abortUploadSession(ctx, uploadSession)
+		if errors.Is(err, serverutils.ErrConflict) {
+			// Completed or aborted by someone else meanwhile.
+			continue
+		}
+		if err != nil {
+			log.Printf("[Upload] Failed to abort stale upload %s: %v", uploadSession.Id, err)
+		}
+	}
+
+	return nil
+}
+
+// abortUploadSession moves the session to aborted before touching its
+// objects, ErrConflict when it left the status it was read in meanwhile, a
+// completion that got there first keeps its object. A multipart upload that
+// is gone was assembled by an interrupted completion or expired, the object
+// it may have left belongs to no file and is deleted. Objects that fail to go
+// are logged, the session is not retried.
+func (s *fileService) abortUploadSession(ctx context.Context, uploadSession *entity.UploadSession) error {
+	err := s.uploadSessionRepository.Abort(ctx, uploadSession.Id, uploadSession.Status, time.Now())
+	if err != nil {
+		return err
+	}
+
+	err = s.s3Client.AbortMultipartUpload(ctx, uploadSession.Bucket, uploadSession.FileName, uploadSession.MultipartUploadId)
+	if errors.Is(err, garagestorages3.ErrUploadNotFound) {
+		err = s.s3Client.Delete(ctx, uploadSession.Bucket, uploadSession.FileName)
+		if err != nil {
+			log.Printf("[S3 Service] Failed to delete object of aborted upload %s: %v", uploadSession.Id, err)
+		}
+	} else if err != nil {
+		log.Printf("[S3 Service] Failed to abort multipart upload %s: %v", uploadSession.Id, err)
+	}
+
+	return nil
+}
+
+// getOwnUploadSession returns the upload session of the user of the request,
+// not found for anyone else.
+func (s *fileService) getOwnUploadSession(ctx context.Context, id uuid.UUID) (*entity.UploadSession, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	uploadSession, err := s.uploadSessionRepository.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if uploadSession.UserId != userId {
+		return nil, serverutils.ErrNotFound
+	}
+
+	return uploadSession, nil
+}
+
+// checkAnnounced refuses an upload up front from what the client announced
+// and returns the media type of contentType, without parameters. The stored
+// object is checked again on completion.
+func (s *fileService) checkAnnounced(contentType string, size int64) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s is not a media type", serverutils.ErrInvalidFile, contentType)
+	}
+
+	err = s.checkFile(mediaType, size, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return mediaType, nil
+}
+
+// checkContent applies the upload policy to content and returns its sniffed
+// media type. content is rewound.
+func (s *fileService) checkContent(content io.ReadSeeker) (string, error) {
+	size, err := s.s3Client.GetFileSize(content)
+	if err != nil {
+		return "", err
+	}
+
+	head := make([]byte, min(size, serverutils.SniffHeadLength))
+	_, err = io.ReadFull(content, head)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = content.Seek(max(0, size-serverutils.SniffTailLength), io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	tail, err := io.ReadAll(content)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = content.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	mimeType := serverutils.SniffContentType(head, tail)
+	err = s.checkFile(mimeType, size, func() (io.ReadCloser, error) {
+		return io.NopCloser(content), nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	_, err = content.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	return mimeType, nil
+}
+
+// checkStoredObject applies the upload policy to an object uploaded straight
+// to storage. Its size must be the announced one and its sniffed media type
+// the announced contentType.
+func (s *fileService) checkStoredObject(ctx context.Context, bucket string, key string, contentType string, size int64) error {
+	object, err := s.s3Client.HeadObject(ctx, bucket, key)
+	if err != nil {
+		if errors.Is(err, garagestorages3.ErrObjectNotFound) {
+			return fmt.Errorf("%w: the file was not uploaded", serverutils.ErrBadRequest)
+		}
+		return err
+	}
+
+	if object.Size != size {
+		return fmt.Errorf("%w: the uploaded file has %d bytes, %d were announced", serverutils.ErrBadRequest, object.Size, size)
+	}
+
+	head, err := s.s3Client.DownloadRange(ctx, bucket, key, 0, serverutils.SniffHeadLength)
+	if err != nil {
+		return err
+	}
+
+	tailOffset := max(0, size-serverutils.SniffTailLength)
+	tail, err := s.s3Client.DownloadRange(ctx, bucket, key, tailOffset, size-tailOffset)
+	if err != nil {
+		return err
+	}
+
+	mimeType := serverutils.SniffContentType(head, tail)
+	if mimeType != contentType {
+		return fmt.Errorf("%w: the uploaded file is %s, %s was announced", serverutils.ErrInvalidFile, mimeType, contentType)
+	}
+
+	return s.checkFile(mimeType, size, func() (io.ReadCloser, error) {
+		return s.s3Client.Download(ctx, bucket, key)
+	})
+}
+
+// checkFile applies the rule of mimeType. The pages of a PDF are only
+// counted when open is set.
+func (s *fileService) checkFile(mimeType string, size int64, open func() (io.ReadCloser, error)) error {
+	rule, ok := s.uploadPolicy[mimeType]
+	if !ok {
+		return fmt.Errorf("%w: %s files are not allowed", serverutils.ErrInvalidFile, mimeType)
+	}
+
+	if size > rule.MaxSize {
+		return fmt.Errorf("%w: %s files must be at most %d bytes", serverutils.ErrInvalidFile, mimeType, rule.MaxSize)
+	}
+
+	if mimeType != constant.FileContentTypePdf || rule.MaxPages == 0 || open == nil {
+		return nil
+	}
+
+	body, err := open()
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	pages, err := serverutils.CountPdfPages(body)
+	if err != nil {
+		return fmt.Errorf("%w: the PDF could not be read", serverutils.ErrInvalidFile)
+	}
+
+	if pages > rule.MaxPages {
+		return fmt.Errorf("%w: PDF files must have at most %d pages, this one has %d", serverutils.ErrInvalidFile, rule.MaxPages, pages)
+	}
+
+	return nil
+}
+
+func (s *fileService) deleteRejectedObject(ctx context.Context, bucket string, key string) {
+	err := s.s3Client.Delete(ctx, bucket, key)
+	if err != nil {
+		log.Printf("[S3 Service] Failed to delete rejected upload %s: %v", key, err)
+	}
+}
+
+// isRejectedUpload tells whether err refuses the uploaded object itself.
+func isRejectedUpload(err error) bool {
+	return errors.Is(err, serverutils.ErrBadRequest) || errors.Is(err, serverutils.ErrInvalidFile)
+}
+
+// getAuthorizedFile returns the attachment of the note, not found when it
+// belongs to another note.
+func (s *fileService) getAuthorizedFile(ctx context.Context, noteId uuid.UUID, id uuid.UUID, role string) (*entity.Note, *entity.File, uuid.UUID, error) {
+	note, err := s.noteRepository.GetById(ctx, noteId)
+	if err != nil {
+		return nil, nil, uuid.Nil, err
+	}
+
+	userId, err := s.notebookAccessService.Authorize(ctx, note.NotebookId, role)
+	if err != nil {
+		return nil, nil, uuid.Nil, err
+	}
+
+	file, err := s.fileRepository.GetById(ctx, id)
+	if err != nil {
+		return nil, nil, uuid.Nil, err
+	}
+
+	if file.NoteId != note.Id {
+		return nil, nil, uuid.Nil, serverutils.ErrNotFound
+	}
+
+	return note, file, userId, nil
+}
+
+// reindex embeds the note again, with the attachments it has now.
+func (s *fileService) reindex(ctx context.Context, noteId uuid.UUID, userId uuid.UUID) error {
+	payload, err := json.Marshal(dto.PublishEmbedNoteMessage{
+		NotedId: noteId,
+		UserId:  userId,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.publisherService.Publish(ctx, payload)
+}
+
+// createFile saves the file row, see insertFile.
+func (s *fileService) createFile(ctx context.Context, note *entity.Note, file *entity.File, upload func() (string, error)) (bool, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	reused, err := s.insertFile(ctx, tx, note, file, upload)
+	if err != nil {
+		return false, err
+	}
+
+	return reused, tx.Commit(ctx)
+}
+
+// insertFile creates the file row last among the attachments of the note,
+// together with its audit event. The file points at the object of an
+// identical one in the bucket when there is one, otherwise upload stores the
+// content and returns its key. It reports whether an object was reused.
+func (s *fileService) insertFile(ctx context.Context, tx pgx.Tx, note *entity.Note, file *entity.File, upload func() (string, error)) (bool, error) {
+	fileRepository := s.fileRepository.UsingTx(ctx, tx)
+	fileBlobRepository := s.fileBlobRepository.UsingTx(ctx, tx)
+
+	reused := false
+	fileBlob, err := fileBlobRepository.GetBySha256(ctx, file.UserId, file.Bucket, *file.Sha256)
+	switch {
+	case err == nil:
+		file.FileName = fileBlob.FileName
+		reused = true
+	case errors.Is(err, serverutils.ErrNotFound):
+		file.FileName, err = upload()
+		if err != nil {
+			return false, err
+		}
+	default:
+		return false, err
+	}
+
+	last, err := fileRepository.GetLastPosition(ctx, note.Id)
+	if err != nil {
+		return false, err
+	}
+
+	file.Position, err = fractional.KeyBetween(last, "")
+	if err != nil {
+		return false, err
+	}
+
+	err = fileRepository.Create(ctx, file)
+	if err != nil {
+		return false, err
+	}
+
+	err = fileBlobRepository.Acquire(ctx, file.UserId, file.Bucket, file.FileName, *file.Sha256)
+	if err != nil {
+		return false, err
+	}
+
+	err = s.recordAuditEvent(ctx, s.auditEventRepository.UsingTx(ctx, tx), constant.AuditActionCreate, note, nil, file)
+	if err != nil {
+		return false, err
+	}
+
+	return reused, nil
+}
+
+// uploadedObject is the upload of insertFile for content that is in the
+// bucket already.
+func uploadedObject(key string) func() (string, error) {
+	return func() (string, error) {
+		return key, nil
+	}
+}
+
+// storedObjectGone is the upload of insertFile for content that was stored
+// when the upload started, the object was released before the file could
+// point at it.
+func storedObjectGone() (string, error) {
+	return "", fmt.Errorf("%w: the stored content was just deleted, upload the file again", serverutils.ErrConflict)
+}
+
+// deleteDuplicateObject deletes an uploaded object once the file points at
+// an identical one instead.
+func (s *fileService) deleteDuplicateObject(ctx context.Context, bucket string, key string) {
+	err := s.s3Client.Delete(ctx, bucket, key)
+	if err != nil {
+		log.Printf("[S3 Service] Failed to delete duplicate upload %s: %v", key, err)
+	}
+}
+
+// hashContent returns the hex sha256 of the content and rewinds it.
+func hashContent(content io.ReadSeeker) (string, error) {
+	hash := sha256.New()
+	_, err := io.Copy(hash, content)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = content.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashObject returns the hex sha256 of a stored object.
+func (s *fileService) hashObject(ctx context.Context, bucket string, key string) (string, error) {
+	body, err := s.s3Client.Download(ctx, bucket, key)
+	if err != nil {
+		log.Printf("[S3 Service] Failed to download %s: %v", key, err)
+
+		return "", serverutils.ErrInternal
+	}
+	defer body.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, body)
+	if err != nil {
+		log.Printf("[S3 Service] Failed to read %s: %v", key, err)
+
+		return "", serverutils.ErrInternal
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// recordAuditEvent records a change to an attachment of note. before and
+// after follow newAuditEvent.
+func (s *fileService) recordAuditEvent(ctx context.Context, auditEventRepository repository.IAuditEventRepository, action string, note *entity.Note, before *entity.File, after *entity.File) error {
+	file := after
+	if file == nil {
+		file = before
+	}
+
+	auditEvent, err := newAuditEvent(ctx, action, constant.AuditEntityFile, file.Id, before, after)
+	if err != nil {
+		return err
+	}
+	auditEvent.NotebookId = &note.NotebookId
+	auditEvent.NoteId = &note.Id
+
+	return auditEventRepository.Create(ctx, auditEvent)
+}
+
+func uploadPartCount(uploadSession *entity.UploadSession) int32 {
+	return int32((uploadSession.Size + uploadSession.PartSize - 1) / uploadSession.PartSize)
+}
+
+func toUploadSessionResponse(uploadSession *entity.UploadSession, parts []*entity.UploadSessionPart) *dto.UploadSessionResponse {
+	uploadedParts := make([]int32, 0, len(parts))
+	var offset int64
+	for _, part := range parts {
+		uploadedParts = append(uploadedParts, part.PartNumber)
+
+		// Parts are ordered, the offset stops at the first gap.
+		if part.PartNumber == int32(len(uploadedParts)) {
+			offset += part.Size
+		}
+	}
+
+	return &dto.UploadSessionResponse{
+		UploadId:      uploadSession.Id,
+		Status:        uploadSession.Status,
+		Size:          uploadSession.Size,
+		PartSize:      uploadSession.PartSize,
+		PartCount:     uploadPartCount(uploadSession),
+		UploadedParts: uploadedParts,
+		Offset:        offset,
+	}
+}
@@ -8,29 +8,60 @@ import (
 	"ai-notetaking-be/internal/repository"
 	"ai-notetaking-be/pkg/chatbot"
 	"ai-notetaking-be/pkg/embedding"
+	"ai-notetaking-be/pkg/fractional"
 	garagestorages3 "ai-notetaking-be/pkg/garage-storage-s3"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pmezard/go-difflib/difflib"
 )
 
+const (
+	noteListDefaultLimit = 20
+	noteListMaxLimit     = 100
+	// noteExcerptLength is the length of an excerpt in runes. Twice as many
+	// characters are read so collapsing whitespace still leaves enough.
+	noteExcerptLength = 200
+)
+
+// noteListCursor is the opaque cursor of a note listing. It remembers its
+// sort so it cannot be replayed against another order.
+type noteListCursor struct {
+	Sort       string    `json:"s"`
+	Id         uuid.UUID `json:"i"`
+	NotebookId uuid.UUID `json:"n,omitempty"`
+	Position   string    `json:"p,omitempty"`
+	Title      string    `json:"t,omitempty"`
+	Time       time.Time `json:"d"`
+}
+
 type INoteService interface {
 	Create(ctx context.Context, req *dto.CreateNoteRequest) (*dto.CreateNoteResponse, error)
 	Show(ctx context.Context, id uuid.UUID) (*dto.ShowNoteResponse, error)
-	SemanticSearch(ctx context.Context, query string) ([]*dto.SemanticSearchResponse, error)
+	SemanticSearch(ctx context.Context, req *dto.SemanticSearchRequest) ([]*dto.SemanticSearchResponse, error)
+	List(ctx context.Context, req *dto.ListNotesRequest) (*dto.ListNotesResponse, error)
 	Update(ctx context.Context, req *dto.UpdateNoteRequest) (*dto.UpdateNoteResponse, error)
-	Delete(ctx context.Context, idParam uuid.UUID) error
+	Delete(ctx context.Context, idParam uuid.UUID, version *int) error
 	Move(ctx context.Context, req *dto.MoveNoteRequest) (*dto.MoveNoteResponse, error)
+	Reorder(ctx context.Context, req *dto.ReorderNoteRequest) (*dto.ReorderNoteResponse, error)
 	ExtractPreview(ctx context.Context, noteId uuid.UUID) (string, error)
 	ExtractPreviewWithAI(ctx context.Context, noteId uuid.UUID) (string, error)
 	UpdateFromExtraction(ctx context.Context, req *dto.UpdateNoteRequest) (*dto.UpdateNoteResponse, error)
+	GetRevisions(ctx context.Context, noteId uuid.UUID) ([]*dto.NoteRevisionResponse, error)
+	ShowRevision(ctx context.Context, noteId uuid.UUID, revisionId uuid.UUID) (*dto.ShowNoteRevisionResponse, error)
+	DiffRevisions(ctx context.Context, req *dto.GetNoteRevisionDiffRequest) (*dto.NoteRevisionDiffResponse, error)
+	RestoreRevision(ctx context.Context, noteId uuid.UUID, revisionId uuid.UUID) (*dto.RestoreNoteRevisionResponse, error)
 }
 
 type noteService struct {
@@ -40,6 +71,11 @@ type noteService struct {
 	s3Client               *garagestorages3.GarageS3
 	publisherService       IPublisherService
 	notEmbeddingRepository repository.INoteEmbeddingRepository
+	usageService           IUsageService
+	notebookAccessService  INotebookAccessService
+	auditEventRepository   repository.IAuditEventRepository
+	noteRevisionRepository repository.INoteRevisionRepository
+	noteTagRepository      repository.INoteTagRepository
 	db                     *pgxpool.Pool
 }
 
@@ -50,6 +86,11 @@ func NewNoteService(
 	s3Client *garagestorages3.GarageS3,
 	publisherService IPublisherService,
 	notEmbeddingRepository repository.INoteEmbeddingRepository,
+	usageService IUsageService,
+	notebookAccessService INotebookAccessService,
+	auditEventRepository repository.IAuditEventRepository,
+	noteRevisionRepository repository.INoteRevisionRepository,
+	noteTagRepository repository.INoteTagRepository,
 	db *pgxpool.Pool,
 ) INoteService {
 	return &noteService{
@@ -59,27 +100,69 @@ func NewNoteService(
 		s3Client:               s3Client,
 		publisherService:       publisherService,
 		notEmbeddingRepository: notEmbeddingRepository,
+		usageService:           usageService,
+		notebookAccessService:  notebookAccessService,
+		auditEventRepository:   auditEventRepository,
+		noteRevisionRepository: noteRevisionRepository,
+		noteTagRepository:      noteTagRepository,
 		db:                     db,
 	}
 }
 
 func (c *noteService) Create(ctx context.Context, req *dto.CreateNoteRequest) (*dto.CreateNoteResponse, error) {
+	userId, err := c.notebookAccessService.Authorize(ctx, req.NotebookId, constant.NotebookRoleEditor)
+	if err != nil {
+		return nil, err
+	}
 
 	note := entity.Note{
 		Id:         uuid.New(),
 		Title:      req.Title,
 		Content:    req.Content,
 		NotebookId: req.NotebookId,
+		UserId:     userId,
 		CreatedAt:  time.Now(),
+		Version:    1,
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	noteRepo := c.noteRepository.UsingTx(ctx, tx)
+
+	// New notes go last in their notebook.
+	note.Position, err = lastNotePosition(ctx, noteRepo, note.NotebookId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = noteRepo.Create(ctx, &note)
+	if err != nil {
+		return nil, err
 	}
 
-	err := c.noteRepository.Create(ctx, &note)
+	err = c.noteRevisionRepository.UsingTx(ctx, tx).Create(ctx, newNoteRevision(&note, &userId, constant.NoteRevisionSourceManual, note.CreatedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.recordAuditEvent(ctx, c.auditEventRepository.UsingTx(ctx, tx), constant.AuditActionCreate, nil, &note)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	msgPayload := dto.PublishEmbedNoteMessage{
 		NotedId: note.Id,
+		UserId:  userId,
 	}
 
 	msgJson, err := json.Marshal(msgPayload)
@@ -99,29 +182,57 @@ func (c *noteService) Create(ctx context.Context, req *dto.CreateNoteRequest) (*
 
 func (c *noteService) Show(ctx context.Context, idParam uuid.UUID) (*dto.ShowNoteResponse, error) {
 
-	note, err := c.noteRepository.GetById(ctx, idParam)
+	note, _, err := c.getAuthorizedNote(ctx, idParam, constant.NotebookRoleViewer)
+
+	if err != nil {
+		return nil, err
+	}
 
+	noteTags, err := c.noteTagRepository.GetByNoteIds(ctx, []uuid.UUID{note.Id})
 	if err != nil {
 		return nil, err
 	}
 
+	tags := noteTagResponses(noteTags)[note.Id]
+	if tags == nil {
+		tags = make([]*dto.NoteTagResponse, 0)
+	}
+
 	res := dto.ShowNoteResponse{
 		Id:         note.Id,
 		Title:      note.Title,
 		NotebookId: note.NotebookId,
 		Content:    note.Content,
+		Version:    note.Version,
 		CreatedAt:  note.CreatedAt,
+		Tags:       tags,
 	}
 
 	return &res, nil
 }
 
-func (c *noteService) SemanticSearch(ctx context.Context, query string) ([]*dto.SemanticSearchResponse, error) {
+func (c *noteService) SemanticSearch(ctx context.Context, req *dto.SemanticSearchRequest) ([]*dto.SemanticSearchResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
 
+	tagIds, err := parseTagIds(req.TagIds)
+	if err != nil {
+		return nil, err
+	}
+
+	notebookIds, err := c.notebookAccessService.ReadableNotebookIds(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
 	embeddingRes, err := embedding.GetGeminiEmbedding(
+		ctx,
 		os.Getenv("GOOGLE_GEMINI_API_KEY"),
 		"models/gemini-embedding-exp-03-07",
-		query,
+		req.Query,
 		"RETRIEVAL_QUERY",
 	)
 
@@ -129,7 +240,9 @@ func (c *noteService) SemanticSearch(ctx context.Context, query string) ([]*dto.
 		return nil, err
 	}
 
-	noteEmbeddings, err := c.notEmbeddingRepository.SemanticSearch(ctx, embeddingRes.Embedding.Values)
+	c.usageService.Record(ctx, embeddingUsage(constant.UsageFeatureSemanticSearch, req.Query, embeddingRes.UsageMetadata, time.Since(start)))
+
+	noteEmbeddings, err := c.notEmbeddingRepository.SemanticSearch(ctx, notebookIds, tagIds, embeddingRes.Embedding.Values)
 	if err != nil {
 		return nil, err
 	}
@@ -144,10 +257,21 @@ func (c *noteService) SemanticSearch(ctx context.Context, query string) ([]*dto.
 		return nil, err
 	}
 
+	noteTags, err := c.noteTagRepository.GetByNoteIds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	tagMap := noteTagResponses(noteTags)
+
 	response := make([]*dto.SemanticSearchResponse, 0)
 	for _, n := range noteEmbeddings {
 		for _, noteItem := range notes {
 			if n.NoteId == noteItem.Id {
+				tags := tagMap[noteItem.Id]
+				if tags == nil {
+					tags = make([]*dto.NoteTagResponse, 0)
+				}
+
 				response = append(response, &dto.SemanticSearchResponse{
 					Id:         noteItem.Id,
 					Title:      noteItem.Title,
@@ -155,6 +279,7 @@ func (c *noteService) SemanticSearch(ctx context.Context, query string) ([]*dto.
 					NotebookId: noteItem.NotebookId,
 					CreatedAt:  noteItem.CreatedAt,
 					UpdateAt:   noteItem.UpdatedAt,
+					Tags:       tags,
 				})
 			}
 		}
@@ -163,25 +288,99 @@ func (c *noteService) SemanticSearch(ctx context.Context, query string) ([]*dto.
 	return response, nil
 }
 
+func (c *noteService) List(ctx context.Context, req *dto.ListNotesRequest) (*dto.ListNotesResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := c.parseListFilter(ctx, userId, req)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := filter.Limit
+	// One more note than asked tells whether another page follows.
+	filter.Limit++
+
+	notes, err := c.noteRepository.List(ctx, filter, noteExcerptLength*2)
+	if err != nil {
+		return nil, err
+	}
+
+	var nextCursor *string
+	if len(notes) > limit {
+		notes = notes[:limit]
+
+		cursor, err := encodeNoteListCursor(filter.Sort, notes[len(notes)-1])
+		if err != nil {
+			return nil, err
+		}
+		nextCursor = &cursor
+	}
+
+	ids := make([]uuid.UUID, 0, len(notes))
+	for _, note := range notes {
+		ids = append(ids, note.Id)
+	}
+
+	noteTags, err := c.noteTagRepository.GetByNoteIds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	tagMap := noteTagResponses(noteTags)
+
+	res := make([]*dto.NoteSummaryResponse, 0, len(notes))
+	for _, note := range notes {
+		tags := tagMap[note.Id]
+		if tags == nil {
+			tags = make([]*dto.NoteTagResponse, 0)
+		}
+
+		res = append(res, &dto.NoteSummaryResponse{
+			Id:         note.Id,
+			Title:      note.Title,
+			Excerpt:    noteExcerpt(note.Excerpt),
+			NotebookId: note.NotebookId,
+			Position:   note.Position,
+			Version:    note.Version,
+			HasFile:    note.HasFile,
+			CreatedAt:  note.CreatedAt,
+			UpdatedAt:  note.UpdatedAt,
+			Tags:       tags,
+		})
+	}
+
+	return &dto.ListNotesResponse{
+		Notes:      res,
+		NextCursor: nextCursor,
+	}, nil
+}
+
 func (c *noteService) Update(ctx context.Context, req *dto.UpdateNoteRequest) (*dto.UpdateNoteResponse, error) {
 
-	note, err := c.noteRepository.GetById(ctx, req.Id)
+	note, userId, err := c.getAuthorizedNote(ctx, req.Id, constant.NotebookRoleEditor)
 	if err != nil {
 		return nil, err
 	}
 
+	before := *note
 	now := time.Now()
 	note.Title = req.Title
 	note.Content = req.Content
 	note.UpdatedAt = &now
+	if req.Version != nil {
+		note.Version = *req.Version
+	}
 
-	err = c.noteRepository.Update(ctx, note)
+	err = c.updateNote(ctx, constant.AuditActionUpdate, &before, note, newNoteRevision(note, &userId, constant.NoteRevisionSourceManual, now))
 	if err != nil {
 		return nil, err
 	}
 
 	payload := dto.PublishEmbedNoteMessage{
 		NotedId: note.Id,
+		UserId:  userId,
 	}
 
 	payloadJson, err := json.Marshal(payload)
@@ -195,17 +394,22 @@ func (c *noteService) Update(ctx context.Context, req *dto.UpdateNoteRequest) (*
 	}
 
 	return &dto.UpdateNoteResponse{
-		Id: note.Id,
+		Id:      note.Id,
+		Version: note.Version,
 	}, nil
 }
 
-func (c *noteService) Delete(ctx context.Context, idParam uuid.UUID) error {
+func (c *noteService) Delete(ctx context.Context, idParam uuid.UUID, version *int) error {
 
-	_, err := c.noteRepository.GetById(ctx, idParam)
+	note, _, err := c.getAuthorizedNote(ctx, idParam, constant.NotebookRoleEditor)
 	if err != nil {
 		return err
 	}
 
+	if version != nil && *version != note.Version {
+		return c.conflict(ctx, note.Id)
+	}
+
 	tx, err := c.db.Begin(ctx)
 	if err != nil {
 		return err
@@ -232,6 +436,11 @@ func (c *noteService) Delete(ctx context.Context, idParam uuid.UUID) error {
 		return err
 	}
 
+	err = c.recordAuditEvent(ctx, c.auditEventRepository.UsingTx(ctx, tx), constant.AuditActionDelete, note, nil)
+	if err != nil {
+		return err
+	}
+
 	err = tx.Commit(ctx)
 	if err != nil {
 		return err
@@ -242,29 +451,46 @@ func (c *noteService) Delete(ctx context.Context, idParam uuid.UUID) error {
 
 func (c *noteService) Move(ctx context.Context, req *dto.MoveNoteRequest) (*dto.MoveNoteResponse, error) {
 
-	note, err := c.noteRepository.GetById(ctx, req.Id)
+	note, userId, err := c.getAuthorizedNote(ctx, req.Id, constant.NotebookRoleEditor)
 	if err != nil {
 		return nil, err
 	}
 
 	if req.NotebookId != nil {
-		_, err = c.notebookRepository.GetById(ctx, *req.NotebookId)
+		_, err = c.notebookAccessService.Authorize(ctx, *req.NotebookId, constant.NotebookRoleEditor)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	before := *note
 	now := time.Now()
 	note.NotebookId = *req.NotebookId
 	note.UpdatedAt = &now
+	if req.Version != nil {
+		note.Version = *req.Version
+	}
 
-	err = c.noteRepository.Update(ctx, note)
+	err = c.updateNote(ctx, constant.AuditActionMove, &before, note, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	if note.NotebookId != before.NotebookId {
+		position, err := lastNotePosition(ctx, c.noteRepository, note.NotebookId)
+		if err != nil {
+			return nil, err
+		}
+
+		err = c.noteRepository.UpdatePosition(ctx, note.Id, position)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	payload := dto.PublishEmbedNoteMessage{
 		NotedId: note.Id,
+		UserId:  userId,
 	}
 
 	payloadJson, err := json.Marshal(payload)
@@ -278,13 +504,71 @@ func (c *noteService) Move(ctx context.Context, req *dto.MoveNoteRequest) (*dto.
 	}
 
 	return &dto.MoveNoteResponse{
-		Id: req.Id,
+		Id:      req.Id,
+		Version: note.Version,
 	}, nil
 }
 
-func (s *noteService) ExtractPreview(ctx context.Context, noteId uuid.UUID) (string, error) {
+// Reorder needs the editor role on the notebook of the note.
+func (c *noteService) Reorder(ctx context.Context, req *dto.ReorderNoteRequest) (*dto.ReorderNoteResponse, error) {
+	anchorId, after, err := parseAnchor(req.Id, req.BeforeId, req.AfterId)
+	if err != nil {
+		return nil, err
+	}
 
-	note, err := s.noteRepository.GetById(ctx, noteId)
+	note, _, err := c.getAuthorizedNote(ctx, req.Id, constant.NotebookRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	noteRepo := c.noteRepository.UsingTx(ctx, tx)
+
+	siblings, err := noteRepo.GetSiblingPositions(ctx, note.NotebookId)
+	if err != nil {
+		return nil, err
+	}
+
+	position, err := placeNextTo(ctx, siblings, anchorId, after, noteRepo.UpdatePosition)
+	if err != nil {
+		if errors.Is(err, serverutils.ErrNotFound) {
+			return nil, fmt.Errorf("%w: the anchor must be a note of the same notebook", serverutils.ErrBadRequest)
+		}
+		return nil, err
+	}
+
+	err = noteRepo.UpdatePosition(ctx, note.Id, position)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *note
+	note.Position = position
+
+	err = c.recordAuditEvent(ctx, c.auditEventRepository.UsingTx(ctx, tx), constant.AuditActionReorder, &before, note)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ReorderNoteResponse{
+		Id:       note.Id,
+		Position: position,
+	}, nil
+}
+
+func (s *noteService) ExtractPreview(ctx context.Context, noteId uuid.UUID) (string, error) {
+	note, _, err := s.getAuthorizedNote(ctx, noteId, constant.NotebookRoleViewer)
 	if err != nil {
 		return "", err
 	}
@@ -297,70 +581,42 @@ func (s *noteService) ExtractPreview(ctx context.Context, noteId uuid.UUID) (str
 		preview.WriteString("\n\n")
 	}
 
-	fileMeta, err := s.fileRepository.GetByNoteId(ctx, note.Id)
-	if err != nil || fileMeta == nil {
-		// langsung return string mentah
-		return preview.String(), nil
-	}
-
-	body, err := s.s3Client.Download(ctx, fileMeta.Bucket, fileMeta.FileName)
+	files, err := s.fileRepository.GetByNoteId(ctx, note.Id)
 	if err != nil {
 		return "", err
 	}
-	defer body.Close()
 
-	pages, err := serverutils.ExtractTextPerPage(body)
+	// teks semua lampiran, sesuai urutannya
+	err = s.writeFilesText(ctx, &preview, files)
 	if err != nil {
 		return "", err
 	}
 
-	for _, page := range pages {
-		if strings.TrimSpace(page.Content) == "" {
-			continue
-		}
-
-		preview.WriteString(page.Content)
-		preview.WriteString("\n\n")
-	}
-
 	return preview.String(), nil
 }
 
 func (s *noteService) ExtractPreviewWithAI(ctx context.Context, noteId uuid.UUID) (string, error) {
 	// 1. Ambil data note dari database
-	note, err := s.noteRepository.GetById(ctx, noteId)
+	note, _, err := s.getAuthorizedNote(ctx, noteId, constant.NotebookRoleViewer)
 	if err != nil {
 		return "", err
 	}
 
-	// 2. Ambil metadata file yang diasosiasikan dengan note
-	fileMeta, err := s.fileRepository.GetByNoteId(ctx, note.Id)
-	if err != nil || fileMeta == nil {
-		return "", fmt.Errorf("no file associated with this note")
-	}
-
-	// 3. Download file dari S3
-	body, err := s.s3Client.Download(ctx, fileMeta.Bucket, fileMeta.FileName)
+	// 2. Ambil metadata semua file yang diasosiasikan dengan note
+	files, err := s.fileRepository.GetByNoteId(ctx, note.Id)
 	if err != nil {
 		return "", err
 	}
-	defer body.Close()
-
-	// 4. Ekstrak teks per halaman dari PDF
-	pages, err := serverutils.ExtractTextPerPage(body)
-	if err != nil {
-		return "", err
+	if len(files) == 0 {
+		return "", fmt.Errorf("no file associated with this note")
 	}
 
-	// 5. Gabungkan konten dari semua halaman ke dalam strings.Builder
+	// 3-5. Download setiap file dari S3, ekstrak teks per halaman dan
+	// gabungkan ke dalam strings.Builder
 	var rawText strings.Builder
-	for _, page := range pages {
-		if strings.TrimSpace(page.Content) == "" {
-			continue
-		}
-
-		rawText.WriteString(page.Content)
-		rawText.WriteString("\n\n")
+	err = s.writeFilesText(ctx, &rawText, files)
+	if err != nil {
+		return "", err
 	}
 
 	// Validasi jika hasil ekstraksi kosong
@@ -409,7 +665,8 @@ func (s *noteService) ExtractPreviewWithAI(ctx context.Context, noteId uuid.UUID
 	})
 
 	// 8. Panggil API Gemini
-	reply, err := chatbot.GetGeminiResponse(
+	start := time.Now()
+	reply, usage, err := chatbot.GetGeminiResponse(
 		ctx,
 		os.Getenv("GOOGLE_GEMINI_API_KEY"),
 		geminiReq,
@@ -419,23 +676,31 @@ func (s *noteService) ExtractPreviewWithAI(ctx context.Context, noteId uuid.UUID
 		return "", err
 	}
 
+	llmUsage := chatUsage(constant.UsageFeatureExtractPreview, usage, time.Since(start))
+	llmUsage.NoteId = &note.Id
+	s.usageService.Record(ctx, llmUsage)
+
 	// 9. Kembalikan hasil yang sudah dinormalisasi
 	return reply, nil
 }
 
 func (s *noteService) UpdateFromExtraction(ctx context.Context, req *dto.UpdateNoteRequest) (*dto.UpdateNoteResponse, error) {
 	// 1. Ambil data note lama
-	note, err := s.noteRepository.GetById(ctx, req.Id)
+	note, userId, err := s.getAuthorizedNote(ctx, req.Id, constant.NotebookRoleEditor)
 	if err != nil {
 		return nil, err
 	}
 
 	// 2. Update konten dengan teks yang sudah di-approve/edit user
+	before := *note
 	now := time.Now()
 	note.Content = req.Content
 	note.UpdatedAt = &now
+	if req.Version != nil {
+		note.Version = *req.Version
+	}
 
-	err = s.noteRepository.Update(ctx, note)
+	err = s.updateNote(ctx, constant.AuditActionUpdate, &before, note, newNoteRevision(note, &userId, constant.NoteRevisionSourceAiExtraction, now))
 	if err != nil {
 		return nil, err
 	}
@@ -443,9 +708,503 @@ func (s *noteService) UpdateFromExtraction(ctx context.Context, req *dto.UpdateN
 	// 3. Trigger Re-Indexing via Publisher
 	payload := dto.PublishEmbedNoteMessage{
 		NotedId: note.Id,
+		UserId:  userId,
 	}
 	payloadJson, _ := json.Marshal(payload)
 	_ = s.publisherService.Publish(ctx, payloadJson)
 
-	return &dto.UpdateNoteResponse{Id: note.Id}, nil
+	return &dto.UpdateNoteResponse{Id: note.Id, Version: note.Version}, nil
+}
+
+func (s *noteService) GetRevisions(ctx context.Context, noteId uuid.UUID) ([]*dto.NoteRevisionResponse, error) {
+	note, _, err := s.getAuthorizedNote(ctx, noteId, constant.NotebookRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	noteRevisions, err := s.noteRevisionRepository.GetByNoteId(ctx, note.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*dto.NoteRevisionResponse, 0)
+	for _, noteRevision := range noteRevisions {
+		response = append(response, toNoteRevisionResponse(noteRevision))
+	}
+
+	return response, nil
+}
+
+func (s *noteService) ShowRevision(ctx context.Context, noteId uuid.UUID, revisionId uuid.UUID) (*dto.ShowNoteRevisionResponse, error) {
+	note, _, err := s.getAuthorizedNote(ctx, noteId, constant.NotebookRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	noteRevision, err := s.noteRevisionRepository.GetById(ctx, note.Id, revisionId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ShowNoteRevisionResponse{
+		NoteRevisionResponse: *toNoteRevisionResponse(noteRevision),
+		Content:              noteRevision.Content,
+	}, nil
+}
+
+// DiffRevisions renders a unified diff of the content between two revisions,
+// or between a revision and the current note.
+func (s *noteService) DiffRevisions(ctx context.Context, req *dto.GetNoteRevisionDiffRequest) (*dto.NoteRevisionDiffResponse, error) {
+	note, _, err := s.getAuthorizedNote(ctx, req.NoteId, constant.NotebookRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	fromId, err := uuid.Parse(req.From)
+	if err != nil {
+		return nil, fmt.Errorf("%w: from must be a revision id", serverutils.ErrBadRequest)
+	}
+
+	from, err := s.noteRevisionRepository.GetById(ctx, note.Id, fromId)
+	if err != nil {
+		return nil, err
+	}
+
+	response := dto.NoteRevisionDiffResponse{
+		FromId:    from.Id,
+		FromTitle: from.Title,
+		ToTitle:   note.Title,
+	}
+	toContent := note.Content
+	toLabel := "current"
+
+	if req.To != "" {
+		toId, err := uuid.Parse(req.To)
+		if err != nil {
+			return nil, fmt.Errorf("%w: to must be a revision id", serverutils.ErrBadRequest)
+		}
+
+		to, err := s.noteRevisionRepository.GetById(ctx, note.Id, toId)
+		if err != nil {
+			return nil, err
+		}
+
+		response.ToId = &to.Id
+		response.ToTitle = to.Title
+		toContent = to.Content
+		toLabel = to.Id.String()
+	}
+
+	response.Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from.Content),
+		B:        difflib.SplitLines(toContent),
+		FromFile: from.Id.String(),
+		ToFile:   toLabel,
+		Context:  3,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// RestoreRevision saves the title and content of an older revision as a new
+// revision, the history in between is kept.
+func (s *noteService) RestoreRevision(ctx context.Context, noteId uuid.UUID, revisionId uuid.UUID) (*dto.RestoreNoteRevisionResponse, error) {
+	note, userId, err := s.getAuthorizedNote(ctx, noteId, constant.NotebookRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	noteRevision, err := s.noteRevisionRepository.GetById(ctx, note.Id, revisionId)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *note
+	now := time.Now()
+	note.Title = noteRevision.Title
+	note.Content = noteRevision.Content
+	note.UpdatedAt = &now
+
+	revision := newNoteRevision(note, &userId, constant.NoteRevisionSourceManual, now)
+	revision.RestoredFromId = &noteRevision.Id
+
+	err = s.updateNote(ctx, constant.AuditActionRestore, &before, note, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := dto.PublishEmbedNoteMessage{
+		NotedId: note.Id,
+		UserId:  userId,
+	}
+
+	payloadJson, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.publisherService.Publish(ctx, payloadJson)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.RestoreNoteRevisionResponse{
+		Id:         note.Id,
+		RevisionId: revision.Id,
+	}, nil
+}
+
+// getAuthorizedNote loads a note once the user of the request holds at least
+// role on its notebook, and returns that user.
+// writeFilesText appends the text of every page of the PDFs among files, in
+// their order.
+func (s *noteService) writeFilesText(ctx context.Context, text *strings.Builder, files []*entity.File) error {
+	for _, file := range files {
+		if file.ContentType != constant.FileContentTypePdf {
+			continue
+		}
+
+		pages, err := s.extractFilePages(ctx, file)
+		if err != nil {
+			return err
+		}
+
+		for _, page := range pages {
+			if strings.TrimSpace(page.Content) == "" {
+				continue
+			}
+
+			text.WriteString(page.Content)
+			text.WriteString("\n\n")
+		}
+	}
+
+	return nil
+}
+
+func (s *noteService) extractFilePages(ctx context.Context, file *entity.File) ([]serverutils.PdfPage, error) {
+	body, err := s.s3Client.Download(ctx, file.Bucket, file.FileName)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return serverutils.ExtractTextPerPage(body)
+}
+
+func (s *noteService) getAuthorizedNote(ctx context.Context, noteId uuid.UUID, role string) (*entity.Note, uuid.UUID, error) {
+	note, err := s.noteRepository.GetById(ctx, noteId)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	userId, err := s.notebookAccessService.Authorize(ctx, note.NotebookId, role)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	return note, userId, nil
+}
+
+// updateNote saves the note together with its audit event, and revision when
+// the content changed.
+func (s *noteService) updateNote(ctx context.Context, action string, before *entity.Note, note *entity.Note, revision *entity.NoteRevision) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = s.noteRepository.UsingTx(ctx, tx).Update(ctx, note)
+	if err != nil {
+		if errors.Is(err, serverutils.ErrConflict) {
+			return s.conflict(ctx, note.Id)
+		}
+		return err
+	}
+
+	if revision != nil {
+		err = createNoteRevision(ctx, s.noteRevisionRepository.UsingTx(ctx, tx), before, revision)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.recordAuditEvent(ctx, s.auditEventRepository.UsingTx(ctx, tx), action, before, note)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *noteService) recordAuditEvent(ctx context.Context, auditEventRepository repository.IAuditEventRepository, action string, before *entity.Note, after *entity.Note) error {
+	note := after
+	if note == nil {
+		note = before
+	}
+
+	auditEvent, err := newAuditEvent(ctx, action, constant.AuditEntityNote, note.Id, before, after)
+	if err != nil {
+		return err
+	}
+	auditEvent.NotebookId = &note.NotebookId
+	auditEvent.NoteId = &note.Id
+
+	return auditEventRepository.Create(ctx, auditEvent)
+}
+
+// conflict reports that the note moved past the version the client edited,
+// with the note as it is now.
+func (s *noteService) conflict(ctx context.Context, noteId uuid.UUID) error {
+	current, err := s.Show(ctx, noteId)
+	if err != nil {
+		return err
+	}
+
+	return serverutils.NewConflictError(current.Version, current)
+}
+
+func (s *noteService) parseListFilter(ctx context.Context, userId uuid.UUID, req *dto.ListNotesRequest) (*entity.NoteFilter, error) {
+	sort, err := parseSort(req.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	tagIds, err := parseTagIds(req.TagIds)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := entity.NoteFilter{
+		TagIds: tagIds,
+		Sort:   sort,
+		Limit:  req.Limit,
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = noteListDefaultLimit
+	}
+	if filter.Limit > noteListMaxLimit {
+		filter.Limit = noteListMaxLimit
+	}
+
+	readableIds, err := s.notebookAccessService.ReadableNotebookIds(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	subtree, err := parseOptionalBool("subtree", req.Subtree)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case req.NotebookId != "":
+		notebookId, err := uuid.Parse(req.NotebookId)
+		if err != nil {
+			return nil, fmt.Errorf("%w: notebook_id must be a UUID", serverutils.ErrBadRequest)
+		}
+
+		_, err = s.notebookAccessService.Authorize(ctx, notebookId, constant.NotebookRoleViewer)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.NotebookIds = []uuid.UUID{notebookId}
+		if subtree != nil && *subtree {
+			subtreeIds, err := s.notebookRepository.GetSubtreeIds(ctx, notebookId)
+			if err != nil {
+				return nil, err
+			}
+
+			// Only keep the descendants the user can read.
+			filter.NotebookIds = make([]uuid.UUID, 0, len(subtreeIds))
+			for _, id := range subtreeIds {
+				if slices.Contains(readableIds, id) {
+					filter.NotebookIds = append(filter.NotebookIds, id)
+				}
+			}
+		}
+	case subtree != nil:
+		return nil, fmt.Errorf("%w: subtree requires notebook_id", serverutils.ErrBadRequest)
+	default:
+		filter.NotebookIds = readableIds
+	}
+
+	filter.HasFile, err = parseOptionalBool("has_file", req.HasFile)
+	if err != nil {
+		return nil, err
+	}
+
+	times := []struct {
+		name  string
+		value string
+		dest  **time.Time
+	}{
+		{"from", req.From, &filter.From},
+		{"to", req.To, &filter.To},
+	}
+	for _, t := range times {
+		if t.value == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, t.value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be formatted as RFC 3339", serverutils.ErrBadRequest, t.name)
+		}
+		*t.dest = &parsed
+	}
+
+	if req.Cursor != "" {
+		filter.After, err = decodeNoteListCursor(req.Cursor, sort)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &filter, nil
+}
+
+// parseOptionalBool reads a "true" or "false" query parameter, nil when
+// empty.
+func parseOptionalBool(name string, raw string) (*bool, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be true or false", serverutils.ErrBadRequest, name)
+	}
+
+	return &value, nil
+}
+
+func encodeNoteListCursor(sort string, note *entity.NoteSummary) (string, error) {
+	cursor := noteListCursor{
+		Sort: sort,
+		Id:   note.Id,
+	}
+
+	switch sort {
+	case constant.SortManual:
+		cursor.NotebookId = note.NotebookId
+		cursor.Position = note.Position
+	case constant.SortName:
+		cursor.Title = note.Title
+	case constant.SortUpdated:
+		cursor.Time = note.CreatedAt
+		if note.UpdatedAt != nil {
+			cursor.Time = *note.UpdatedAt
+		}
+	case constant.SortCreated:
+		cursor.Time = note.CreatedAt
+	}
+
+	raw, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeNoteListCursor(raw string, sort string) (*entity.NoteCursor, error) {
+	invalid := fmt.Errorf("%w: invalid cursor", serverutils.ErrBadRequest)
+
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, invalid
+	}
+
+	var cursor noteListCursor
+	err = json.Unmarshal(data, &cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	if cursor.Sort != sort {
+		return nil, fmt.Errorf("%w: cursor was issued for another sort", serverutils.ErrBadRequest)
+	}
+
+	return &entity.NoteCursor{
+		Id:         cursor.Id,
+		NotebookId: cursor.NotebookId,
+		Position:   cursor.Position,
+		Title:      cursor.Title,
+		Time:       cursor.Time,
+	}, nil
+}
+
+// noteExcerpt collapses the whitespace of content and cuts it to
+// noteExcerptLength runes.
+func noteExcerpt(content string) string {
+	excerpt := []rune(strings.Join(strings.Fields(content), " "))
+	if len(excerpt) <= noteExcerptLength {
+		return string(excerpt)
+	}
+
+	return strings.TrimSpace(string(excerpt[:noteExcerptLength])) + "…"
+}
+
+func lastNotePosition(ctx context.Context, noteRepo repository.INoteRepository, notebookId uuid.UUID) (string, error) {
+	last, err := noteRepo.GetLastPosition(ctx, notebookId)
+	if err != nil {
+		return "", err
+	}
+
+	return fractional.KeyBetween(last, "")
+}
+
+// createNoteRevision records the revision, with a first one holding the
+// content before the update when the note has none yet.
+func createNoteRevision(ctx context.Context, noteRevisionRepo repository.INoteRevisionRepository, before *entity.Note, revision *entity.NoteRevision) error {
+	// Notes from before revisions existed, who wrote that content is not
+	// known.
+	exists, err := noteRevisionRepo.ExistsByNoteId(ctx, revision.NoteId)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		savedAt := before.CreatedAt
+		if before.UpdatedAt != nil {
+			savedAt = *before.UpdatedAt
+		}
+
+		err = noteRevisionRepo.Create(ctx, newNoteRevision(before, nil, constant.NoteRevisionSourceManual, savedAt))
+		if err != nil {
+			return err
+		}
+	}
+
+	return noteRevisionRepo.Create(ctx, revision)
+}
+
+func newNoteRevision(note *entity.Note, authorId *uuid.UUID, source string, createdAt time.Time) *entity.NoteRevision {
+	return &entity.NoteRevision{
+		Id:        uuid.New(),
+		NoteId:    note.Id,
+		Title:     note.Title,
+		Content:   note.Content,
+		Source:    source,
+		AuthorId:  authorId,
+		CreatedAt: createdAt,
+	}
+}
+
+func toNoteRevisionResponse(noteRevision *entity.NoteRevision) *dto.NoteRevisionResponse {
+	return &dto.NoteRevisionResponse{
+		Id:             noteRevision.Id,
+		NoteId:         noteRevision.NoteId,
+		Title:          noteRevision.Title,
+		Source:         noteRevision.Source,
+		AuthorId:       noteRevision.AuthorId,
+		RestoredFromId: noteRevision.RestoredFromId,
+		CreatedAt:      noteRevision.CreatedAt,
+	}
 }
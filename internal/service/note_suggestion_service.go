@@ -0,0 +1,489 @@
+package service
+
+import (
+	"ai-notetaking-be/internal/constant"
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/repository"
+	"ai-notetaking-be/pkg/chatbot"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// Suggestions below these are not worth showing. Embeddings of related
+	// texts are already fairly similar, so notebooks need a higher bar.
+	tagSuggestionMinConfidence      = 0.5
+	notebookSuggestionMinSimilarity = 0.75
+
+	maxTagSuggestions        = 5
+	tagSuggestionContentSize = 8000
+	tagNameMaxLength         = 64
+)
+
+// ParseAutoTagging reads whether notes are suggested tags and notebooks after
+// indexing, empty means disabled.
+func ParseAutoTagging(raw string) (bool, error) {
+	if raw == "" {
+		return false, nil
+	}
+
+	enabled, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid auto tagging flag: %q", raw)
+	}
+
+	return enabled, nil
+}
+
+type INoteSuggestionService interface {
+	Suggest(ctx context.Context, noteId uuid.UUID, userId uuid.UUID) error
+	GetAll(ctx context.Context, noteId uuid.UUID) ([]*dto.NoteSuggestionResponse, error)
+	Resolve(ctx context.Context, req *dto.ResolveNoteSuggestionRequest) (*dto.ResolveNoteSuggestionResponse, error)
+}
+
+type noteSuggestionService struct {
+	noteSuggestionRepository repository.INoteSuggestionRepository
+	noteRepository           repository.INoteRepository
+	notebookRepository       repository.INotebookRepository
+	noteEmbeddingRepository  repository.INoteEmbeddingRepository
+	tagRepository            repository.ITagRepository
+	noteTagRepository        repository.INoteTagRepository
+	notebookAccessService    INotebookAccessService
+	tagService               ITagService
+	noteService              INoteService
+	usageService             IUsageService
+	enabled                  bool
+	db                       *pgxpool.Pool
+}
+
+func NewNoteSuggestionService(
+	noteSuggestionRepository repository.INoteSuggestionRepository,
+	noteRepository repository.INoteRepository,
+	notebookRepository repository.INotebookRepository,
+	noteEmbeddingRepository repository.INoteEmbeddingRepository,
+	tagRepository repository.ITagRepository,
+	noteTagRepository repository.INoteTagRepository,
+	notebookAccessService INotebookAccessService,
+	tagService ITagService,
+	noteService INoteService,
+	usageService IUsageService,
+	enabled bool,
+	db *pgxpool.Pool,
+) INoteSuggestionService {
+	return &noteSuggestionService{
+		noteSuggestionRepository: noteSuggestionRepository,
+		noteRepository:           noteRepository,
+		notebookRepository:       notebookRepository,
+		noteEmbeddingRepository:  noteEmbeddingRepository,
+		tagRepository:            tagRepository,
+		noteTagRepository:        noteTagRepository,
+		notebookAccessService:    notebookAccessService,
+		tagService:               tagService,
+		noteService:              noteService,
+		usageService:             usageService,
+		enabled:                  enabled,
+		db:                       db,
+	}
+}
+
+// Suggest replaces the pending suggestions of the note for the user who
+// triggered its indexing. Tags come from the model, preferring the existing
+// tags of the user, the notebook is the one whose notes are closest to the
+// note in embedding space. Anything the user already resolved once is not
+// suggested again.
+func (c *noteSuggestionService) Suggest(ctx context.Context, noteId uuid.UUID, userId uuid.UUID) error {
+	if !c.enabled {
+		return nil
+	}
+
+	ctx = serverutils.WithUserId(ctx, userId)
+
+	note, err := c.noteRepository.GetById(ctx, noteId)
+	if err != nil {
+		return err
+	}
+
+	// Only editors can act on suggestions, viewers would never see them.
+	_, err = c.notebookAccessService.Authorize(ctx, note.NotebookId, constant.NotebookRoleEditor)
+	if err != nil {
+		if errors.Is(err, serverutils.ErrForbidden) || errors.Is(err, serverutils.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	resolved, err := c.noteSuggestionRepository.GetByNoteId(ctx, note.Id, userId, "")
+	if err != nil {
+		return err
+	}
+
+	resolvedTags := make(map[string]bool)
+	resolvedNotebooks := make(map[uuid.UUID]bool)
+	for _, suggestion := range resolved {
+		if suggestion.Status == constant.NoteSuggestionStatusPending {
+			continue
+		}
+
+		if suggestion.TagName != nil {
+			resolvedTags[strings.ToLower(*suggestion.TagName)] = true
+		}
+		if suggestion.NotebookId != nil {
+			resolvedNotebooks[*suggestion.NotebookId] = true
+		}
+	}
+
+	suggestions, err := c.suggestTags(ctx, note, userId, resolvedTags)
+	if err != nil {
+		return err
+	}
+
+	notebookSuggestion, err := c.suggestNotebook(ctx, note, userId, resolvedNotebooks)
+	if err != nil {
+		return err
+	}
+	if notebookSuggestion != nil {
+		suggestions = append(suggestions, notebookSuggestion)
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	noteSuggestionRepository := c.noteSuggestionRepository.UsingTx(ctx, tx)
+
+	err = noteSuggestionRepository.DeletePending(ctx, note.Id, userId)
+	if err != nil {
+		return err
+	}
+
+	for _, suggestion := range suggestions {
+		err = noteSuggestionRepository.Create(ctx, suggestion)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (c *noteSuggestionService) suggestTags(ctx context.Context, note *entity.Note, userId uuid.UUID, resolvedTags map[string]bool) ([]*entity.NoteSuggestion, error) {
+	tags, err := c.tagRepository.GetByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	noteTags, err := c.noteTagRepository.GetByNoteIds(ctx, []uuid.UUID{note.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	tagsByName := make(map[string]*entity.Tag)
+	tagNames := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tagsByName[strings.ToLower(tag.Name)] = tag
+		tagNames = append(tagNames, tag.Name)
+	}
+
+	attached := make(map[string]bool)
+	for _, noteTag := range noteTags {
+		attached[strings.ToLower(noteTag.Name)] = true
+	}
+
+	content := []rune(note.Content)
+	if len(content) > tagSuggestionContentSize {
+		content = content[:tagSuggestionContentSize]
+	}
+
+	start := time.Now()
+	res, usage, err := chatbot.SuggestTags(
+		ctx,
+		os.Getenv("GOOGLE_GEMINI_API_KEY"),
+		[]*chatbot.ChatHistory{
+			{
+				Chat: fmt.Sprintf("%s\n\nExisting tags: %s\n\nNote title: %s\n\n%s", constant.TagSuggestionPromptV1, strings.Join(tagNames, ", "), note.Title, string(content)),
+				Role: constant.ChatMessageRoleUser,
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	llmUsage := chatUsage(constant.UsageFeatureAutoTagging, usage, time.Since(start))
+	llmUsage.UserId = &userId
+	llmUsage.NoteId = &note.Id
+	c.usageService.Record(ctx, llmUsage)
+
+	suggestions := make([]*entity.NoteSuggestion, 0)
+	seen := make(map[string]bool)
+	for _, tagSuggestion := range res.Tags {
+		name := []rune(strings.TrimSpace(tagSuggestion.Name))
+		if len(name) > tagNameMaxLength {
+			name = name[:tagNameMaxLength]
+		}
+
+		key := strings.ToLower(string(name))
+		if key == "" || seen[key] || attached[key] || resolvedTags[key] {
+			continue
+		}
+		seen[key] = true
+
+		confidence := min(max(tagSuggestion.Confidence, 0), 1)
+		if confidence < tagSuggestionMinConfidence {
+			continue
+		}
+
+		suggestion := &entity.NoteSuggestion{
+			Id:         uuid.New(),
+			NoteId:     note.Id,
+			UserId:     userId,
+			Kind:       constant.NoteSuggestionKindTag,
+			Confidence: confidence,
+			Status:     constant.NoteSuggestionStatusPending,
+			CreatedAt:  time.Now(),
+		}
+
+		tagName := string(name)
+		if tag, ok := tagsByName[key]; ok {
+			suggestion.TagId = &tag.Id
+			tagName = tag.Name
+		}
+		suggestion.TagName = &tagName
+
+		suggestions = append(suggestions, suggestion)
+		if len(suggestions) == maxTagSuggestions {
+			break
+		}
+	}
+
+	return suggestions, nil
+}
+
+// suggestNotebook compares the note with every notebook the user edits,
+// its current notebook included, and suggests the closest one unless it is
+// where the note already is.
+func (c *noteSuggestionService) suggestNotebook(ctx context.Context, note *entity.Note, userId uuid.UUID, resolvedNotebooks map[uuid.UUID]bool) (*entity.NoteSuggestion, error) {
+	grants, err := c.notebookAccessService.Grants(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	notebookIds := make([]uuid.UUID, 0)
+	for notebookId, role := range grants {
+		if notebookRoleRank[role] >= notebookRoleRank[constant.NotebookRoleEditor] {
+			notebookIds = append(notebookIds, notebookId)
+		}
+	}
+
+	if len(notebookIds) < 2 {
+		return nil, nil
+	}
+
+	notebookId, similarity, err := c.noteEmbeddingRepository.NearestNotebook(ctx, note.Id, notebookIds)
+	if err != nil {
+		if errors.Is(err, serverutils.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if notebookId == note.NotebookId || resolvedNotebooks[notebookId] || similarity < notebookSuggestionMinSimilarity {
+		return nil, nil
+	}
+
+	return &entity.NoteSuggestion{
+		Id:         uuid.New(),
+		NoteId:     note.Id,
+		UserId:     userId,
+		Kind:       constant.NoteSuggestionKindNotebook,
+		NotebookId: &notebookId,
+		Confidence: min(similarity, 1),
+		Status:     constant.NoteSuggestionStatusPending,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// GetAll lists the pending suggestions made for the user on the note.
+func (c *noteSuggestionService) GetAll(ctx context.Context, noteId uuid.UUID) ([]*dto.NoteSuggestionResponse, error) {
+	note, err := c.noteRepository.GetById(ctx, noteId)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := c.notebookAccessService.Authorize(ctx, note.NotebookId, constant.NotebookRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions, err := c.noteSuggestionRepository.GetByNoteId(ctx, note.Id, userId, constant.NoteSuggestionStatusPending)
+	if err != nil {
+		return nil, err
+	}
+
+	notebookIds := make([]uuid.UUID, 0)
+	for _, suggestion := range suggestions {
+		if suggestion.NotebookId != nil {
+			notebookIds = append(notebookIds, *suggestion.NotebookId)
+		}
+	}
+
+	notebookNames := make(map[uuid.UUID]string)
+	if len(notebookIds) > 0 {
+		notebooks, err := c.notebookRepository.GetByIds(ctx, notebookIds)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, notebook := range notebooks {
+			notebookNames[notebook.Id] = notebook.Name
+		}
+	}
+
+	response := make([]*dto.NoteSuggestionResponse, 0)
+	for _, suggestion := range suggestions {
+		res := &dto.NoteSuggestionResponse{
+			Id:         suggestion.Id,
+			Kind:       suggestion.Kind,
+			TagId:      suggestion.TagId,
+			TagName:    suggestion.TagName,
+			NotebookId: suggestion.NotebookId,
+			Confidence: suggestion.Confidence,
+			CreatedAt:  suggestion.CreatedAt,
+		}
+
+		if suggestion.NotebookId != nil {
+			name, ok := notebookNames[*suggestion.NotebookId]
+			if !ok {
+				// The notebook is gone since.
+				continue
+			}
+			res.NotebookName = &name
+		}
+
+		response = append(response, res)
+	}
+
+	return response, nil
+}
+
+// Resolve applies an accepted suggestion through the tag and note services,
+// so it is authorized and audited like the same change made by hand.
+func (c *noteSuggestionService) Resolve(ctx context.Context, req *dto.ResolveNoteSuggestionRequest) (*dto.ResolveNoteSuggestionResponse, error) {
+	note, err := c.noteRepository.GetById(ctx, req.NoteId)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := c.notebookAccessService.Authorize(ctx, note.NotebookId, constant.NotebookRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestion, err := c.noteSuggestionRepository.GetById(ctx, note.Id, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if suggestion.UserId != userId {
+		return nil, serverutils.ErrNotFound
+	}
+
+	if suggestion.Status != constant.NoteSuggestionStatusPending {
+		return nil, fmt.Errorf("%w: the suggestion is already %s", serverutils.ErrBadRequest, suggestion.Status)
+	}
+
+	if req.Status == constant.NoteSuggestionStatusAccepted {
+		switch suggestion.Kind {
+		case constant.NoteSuggestionKindTag:
+			tagId, err := c.acceptTag(ctx, note, userId, suggestion)
+			if err != nil {
+				return nil, err
+			}
+			suggestion.TagId = &tagId
+		case constant.NoteSuggestionKindNotebook:
+			_, err = c.noteService.Move(ctx, &dto.MoveNoteRequest{
+				Id:         note.Id,
+				NotebookId: suggestion.NotebookId,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	now := time.Now()
+	suggestion.Status = req.Status
+	suggestion.ResolvedAt = &now
+
+	err = c.noteSuggestionRepository.Update(ctx, suggestion)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ResolveNoteSuggestionResponse{
+		Id:     suggestion.Id,
+		Status: suggestion.Status,
+	}, nil
+}
+
+// acceptTag attaches the suggested tag next to the tags the user already put
+// on the note, creating the tag when it does not exist yet.
+func (c *noteSuggestionService) acceptTag(ctx context.Context, note *entity.Note, userId uuid.UUID, suggestion *entity.NoteSuggestion) (uuid.UUID, error) {
+	var tagId uuid.UUID
+	if suggestion.TagId != nil {
+		tagId = *suggestion.TagId
+	} else {
+		// It may have been created by hand in the meantime.
+		tag, err := c.tagRepository.GetByName(ctx, userId, *suggestion.TagName)
+		if err != nil && !errors.Is(err, serverutils.ErrNotFound) {
+			return uuid.Nil, err
+		}
+
+		if tag != nil {
+			tagId = tag.Id
+		} else {
+			created, err := c.tagService.Create(ctx, &dto.CreateTagRequest{
+				Name: *suggestion.TagName,
+			})
+			if err != nil {
+				return uuid.Nil, err
+			}
+			tagId = created.Id
+		}
+	}
+
+	noteTags, err := c.noteTagRepository.GetByNoteIds(ctx, []uuid.UUID{note.Id})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	tagIds := []uuid.UUID{tagId}
+	for _, noteTag := range noteTags {
+		if noteTag.UserId == userId {
+			tagIds = append(tagIds, noteTag.TagId)
+		}
+	}
+
+	_, err = c.tagService.SetNoteTags(ctx, &dto.SetNoteTagsRequest{
+		NoteId: note.Id,
+		TagIds: tagIds,
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return tagId, nil
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"ai-notetaking-be/internal/constant"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/repository"
+	"context"
+
+	"github.com/google/uuid"
+)
+
+var notebookRoleRank = map[string]int{
+	constant.NotebookRoleViewer:    1,
+	constant.NotebookRoleCommenter: 2,
+	constant.NotebookRoleEditor:    3,
+	constant.NotebookRoleOwner:     4,
+}
+
+type INotebookAccessService interface {
+	Authorize(ctx context.Context, notebookId uuid.UUID, role string) (uuid.UUID, error)
+	Role(ctx context.Context, userId uuid.UUID, notebookId uuid.UUID) (string, error)
+	Grants(ctx context.Context, userId uuid.UUID) (map[uuid.UUID]string, error)
+	ReadableNotebookIds(ctx context.Context, userId uuid.UUID) ([]uuid.UUID, error)
+	AuthorizeTrashed(ctx context.Context, notebookId uuid.UUID, role string) (uuid.UUID, error)
+	TrashGrants(ctx context.Context, userId uuid.UUID) (map[uuid.UUID]string, error)
+}
+
+type notebookAccessService struct {
+	notebookMemberRepository repository.INotebookMemberRepository
+}
+
+func NewNotebookAccessService(notebookMemberRepository repository.INotebookMemberRepository) INotebookAccessService {
+	return &notebookAccessService{
+		notebookMemberRepository: notebookMemberRepository,
+	}
+}
+
+// Authorize checks that the user of the request holds at least role on the
+// notebook and returns that user. A notebook the user cannot read at all is
+// reported as not found so its existence does not leak.
+func (c *notebookAccessService) Authorize(ctx context.Context, notebookId uuid.UUID, role string) (uuid.UUID, error) {
+	return c.authorize(ctx, notebookId, role, false)
+}
+
+// AuthorizeTrashed is Authorize for a notebook that may be in the trash, the
+// roles are those the user held when it was deleted.
+func (c *notebookAccessService) AuthorizeTrashed(ctx context.Context, notebookId uuid.UUID, role string) (uuid.UUID, error) {
+	return c.authorize(ctx, notebookId, role, true)
+}
+
+func (c *notebookAccessService) authorize(ctx context.Context, notebookId uuid.UUID, role string, includeDeleted bool) (uuid.UUID, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	roles, err := c.notebookMemberRepository.GetRolesOnNotebook(ctx, userId, notebookId, includeDeleted)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	effectiveRole := highestNotebookRole(roles...)
+
+	if effectiveRole == "" {
+		return uuid.Nil, serverutils.ErrNotFound
+	}
+	if notebookRoleRank[effectiveRole] < notebookRoleRank[role] {
+		return uuid.Nil, serverutils.ErrForbidden
+	}
+
+	return userId, nil
+}
+
+// Role returns the highest role the user holds on the notebook or one of its
+// ancestors, or an empty string without any.
+func (c *notebookAccessService) Role(ctx context.Context, userId uuid.UUID, notebookId uuid.UUID) (string, error) {
+	roles, err := c.notebookMemberRepository.GetRolesOnNotebook(ctx, userId, notebookId, false)
+	if err != nil {
+		return "", err
+	}
+
+	return highestNotebookRole(roles...), nil
+}
+
+// Grants maps every notebook the user can read to the user's role on it.
+func (c *notebookAccessService) Grants(ctx context.Context, userId uuid.UUID) (map[uuid.UUID]string, error) {
+	return c.grants(ctx, userId, false)
+}
+
+// TrashGrants is Grants including the notebooks in the trash.
+func (c *notebookAccessService) TrashGrants(ctx context.Context, userId uuid.UUID) (map[uuid.UUID]string, error) {
+	return c.grants(ctx, userId, true)
+}
+
+func (c *notebookAccessService) grants(ctx context.Context, userId uuid.UUID, includeDeleted bool) (map[uuid.UUID]string, error) {
+	grants, err := c.notebookMemberRepository.GetGrants(ctx, userId, includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[uuid.UUID]string)
+	for _, grant := range grants {
+		res[grant.NotebookId] = highestNotebookRole(res[grant.NotebookId], grant.Role)
+	}
+
+	return res, nil
+}
+
+func (c *notebookAccessService) ReadableNotebookIds(ctx context.Context, userId uuid.UUID) ([]uuid.UUID, error) {
+	grants, err := c.Grants(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(grants))
+	for id := range grants {
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func highestNotebookRole(roles ...string) string {
+	highest := ""
+	for _, role := range roles {
+		if notebookRoleRank[role] > notebookRoleRank[highest] {
+			highest = role
+		}
+	}
+
+	return highest
+}
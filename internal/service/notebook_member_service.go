@@ -0,0 +1,457 @@
+package service
+
+import (
+	"ai-notetaking-be/internal/constant"
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type INotebookMemberService interface {
+	GetMembers(ctx context.Context, notebookId uuid.UUID) ([]*dto.NotebookMemberResponse, error)
+	UpdateMember(ctx context.Context, req *dto.UpdateNotebookMemberRequest) (*dto.UpdateNotebookMemberResponse, error)
+	RemoveMember(ctx context.Context, notebookId uuid.UUID, userId uuid.UUID) error
+	Invite(ctx context.Context, req *dto.CreateNotebookInvitationRequest) (*dto.CreateNotebookInvitationResponse, error)
+	GetInvitations(ctx context.Context, notebookId uuid.UUID) ([]*dto.NotebookInvitationResponse, error)
+	RevokeInvitation(ctx context.Context, notebookId uuid.UUID, invitationId uuid.UUID) error
+	GetMyInvitations(ctx context.Context) ([]*dto.NotebookInvitationResponse, error)
+	RespondInvitation(ctx context.Context, req *dto.RespondNotebookInvitationRequest) (*dto.RespondNotebookInvitationResponse, error)
+}
+
+type notebookMemberService struct {
+	notebookRepository           repository.INotebookRepository
+	notebookMemberRepository     repository.INotebookMemberRepository
+	notebookInvitationRepository repository.INotebookInvitationRepository
+	userRepository               repository.IUserRepository
+	notebookAccessService        INotebookAccessService
+	auditEventRepository         repository.IAuditEventRepository
+	db                           *pgxpool.Pool
+}
+
+func NewNotebookMemberService(
+	notebookRepository repository.INotebookRepository,
+	notebookMemberRepository repository.INotebookMemberRepository,
+	notebookInvitationRepository repository.INotebookInvitationRepository,
+	userRepository repository.IUserRepository,
+	notebookAccessService INotebookAccessService,
+	auditEventRepository repository.IAuditEventRepository,
+	db *pgxpool.Pool,
+) INotebookMemberService {
+	return &notebookMemberService{
+		notebookRepository:           notebookRepository,
+		notebookMemberRepository:     notebookMemberRepository,
+		notebookInvitationRepository: notebookInvitationRepository,
+		userRepository:               userRepository,
+		notebookAccessService:        notebookAccessService,
+		auditEventRepository:         auditEventRepository,
+		db:                           db,
+	}
+}
+
+// GetMembers lists the creator and the direct members of the notebook,
+// members inherited from ancestors are listed on the ancestor.
+func (c *notebookMemberService) GetMembers(ctx context.Context, notebookId uuid.UUID) ([]*dto.NotebookMemberResponse, error) {
+	_, err := c.notebookAccessService.Authorize(ctx, notebookId, constant.NotebookRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	notebook, err := c.notebookRepository.GetById(ctx, notebookId)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*dto.NotebookMemberResponse, 0)
+
+	creator, err := c.userRepository.GetById(ctx, notebook.UserId)
+	if err == nil {
+		response = append(response, &dto.NotebookMemberResponse{
+			UserId:    creator.Id,
+			Email:     creator.Email,
+			Name:      creator.Name,
+			Role:      constant.NotebookRoleOwner,
+			IsCreator: true,
+			CreatedAt: notebook.CreatedAt,
+		})
+	} else if !errors.Is(err, serverutils.ErrNotFound) {
+		return nil, err
+	}
+
+	members, err := c.notebookMemberRepository.GetByNotebookId(ctx, notebookId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range members {
+		response = append(response, &dto.NotebookMemberResponse{
+			UserId:    member.UserId,
+			Email:     member.Email,
+			Name:      member.Name,
+			Role:      member.Role,
+			CreatedAt: member.CreatedAt,
+		})
+	}
+
+	return response, nil
+}
+
+func (c *notebookMemberService) UpdateMember(ctx context.Context, req *dto.UpdateNotebookMemberRequest) (*dto.UpdateNotebookMemberResponse, error) {
+	_, err := c.notebookAccessService.Authorize(ctx, req.NotebookId, constant.NotebookRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := c.notebookMemberRepository.GetByNotebookIdAndUserId(ctx, req.NotebookId, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *member
+	now := time.Now()
+	member.Role = req.Role
+	member.UpdatedAt = &now
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = c.notebookMemberRepository.UsingTx(ctx, tx).Update(ctx, member)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.recordAuditEvent(ctx, c.auditEventRepository.UsingTx(ctx, tx), constant.AuditActionUpdate, constant.AuditEntityNotebookMember, member.Id, member.NotebookId, &before, member)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.UpdateNotebookMemberResponse{
+		UserId: member.UserId,
+		Role:   member.Role,
+	}, nil
+}
+
+// RemoveMember lets owners remove anyone and members remove themselves.
+func (c *notebookMemberService) RemoveMember(ctx context.Context, notebookId uuid.UUID, memberUserId uuid.UUID) error {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return err
+	}
+
+	if userId != memberUserId {
+		_, err = c.notebookAccessService.Authorize(ctx, notebookId, constant.NotebookRoleOwner)
+		if err != nil {
+			return err
+		}
+	}
+
+	member, err := c.notebookMemberRepository.GetByNotebookIdAndUserId(ctx, notebookId, memberUserId)
+	if err != nil {
+		return err
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = c.notebookMemberRepository.UsingTx(ctx, tx).Delete(ctx, notebookId, memberUserId)
+	if err != nil {
+		return err
+	}
+
+	err = c.recordAuditEvent(ctx, c.auditEventRepository.UsingTx(ctx, tx), constant.AuditActionDelete, constant.AuditEntityNotebookMember, member.Id, notebookId, member, nil)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Invite creates a pending invitation for the account with the email, inviting
+// the same email again only changes the role of the pending invitation. The
+// account has to exist, only its owner can accept.
+func (c *notebookMemberService) Invite(ctx context.Context, req *dto.CreateNotebookInvitationRequest) (*dto.CreateNotebookInvitationResponse, error) {
+	userId, err := c.notebookAccessService.Authorize(ctx, req.NotebookId, constant.NotebookRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	notebook, err := c.notebookRepository.GetById(ctx, req.NotebookId)
+	if err != nil {
+		return nil, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	invitee, err := c.userRepository.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, serverutils.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s has no account", serverutils.ErrBadRequest, email)
+		}
+		return nil, err
+	}
+
+	if invitee.Id == notebook.UserId {
+		return nil, fmt.Errorf("%w: %s created this notebook", serverutils.ErrBadRequest, email)
+	}
+
+	_, err = c.notebookMemberRepository.GetByNotebookIdAndUserId(ctx, req.NotebookId, invitee.Id)
+	if err == nil {
+		return nil, fmt.Errorf("%w: %s is already a member", serverutils.ErrBadRequest, email)
+	}
+	if !errors.Is(err, serverutils.ErrNotFound) {
+		return nil, err
+	}
+
+	invitation, err := c.notebookInvitationRepository.GetPendingByNotebookIdAndEmail(ctx, req.NotebookId, email)
+	if err == nil {
+		before := *invitation
+		invitation.Role = req.Role
+
+		err = c.saveInvitation(ctx, constant.AuditActionUpdate, &before, invitation)
+		if err != nil {
+			return nil, err
+		}
+
+		return &dto.CreateNotebookInvitationResponse{
+			Id: invitation.Id,
+		}, nil
+	}
+	if !errors.Is(err, serverutils.ErrNotFound) {
+		return nil, err
+	}
+
+	invitation = &entity.NotebookInvitation{
+		Id:         uuid.New(),
+		NotebookId: req.NotebookId,
+		Email:      email,
+		UserId:     &invitee.Id,
+		Role:       req.Role,
+		Status:     constant.NotebookInvitationStatusPending,
+		InvitedBy:  userId,
+		CreatedAt:  time.Now(),
+	}
+
+	err = c.saveInvitation(ctx, constant.AuditActionCreate, nil, invitation)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.CreateNotebookInvitationResponse{
+		Id: invitation.Id,
+	}, nil
+}
+
+func (c *notebookMemberService) GetInvitations(ctx context.Context, notebookId uuid.UUID) ([]*dto.NotebookInvitationResponse, error) {
+	_, err := c.notebookAccessService.Authorize(ctx, notebookId, constant.NotebookRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	invitations, err := c.notebookInvitationRepository.GetPendingByNotebookId(ctx, notebookId)
+	if err != nil {
+		return nil, err
+	}
+
+	return toNotebookInvitationResponses(invitations), nil
+}
+
+func (c *notebookMemberService) RevokeInvitation(ctx context.Context, notebookId uuid.UUID, invitationId uuid.UUID) error {
+	_, err := c.notebookAccessService.Authorize(ctx, notebookId, constant.NotebookRoleOwner)
+	if err != nil {
+		return err
+	}
+
+	invitation, err := c.notebookInvitationRepository.GetById(ctx, invitationId)
+	if err != nil {
+		return err
+	}
+
+	if invitation.NotebookId != notebookId || invitation.Status != constant.NotebookInvitationStatusPending {
+		return serverutils.ErrNotFound
+	}
+
+	before := *invitation
+	now := time.Now()
+	invitation.Status = constant.NotebookInvitationStatusRevoked
+	invitation.RespondedAt = &now
+
+	return c.saveInvitation(ctx, constant.AuditActionRevoke, &before, invitation)
+}
+
+func (c *notebookMemberService) GetMyInvitations(ctx context.Context) ([]*dto.NotebookInvitationResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	invitations, err := c.notebookInvitationRepository.GetPendingByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return toNotebookInvitationResponses(invitations), nil
+}
+
+// RespondInvitation accepts or declines an invitation addressed to the user.
+// Accepting replaces any direct role the user already had.
+func (c *notebookMemberService) RespondInvitation(ctx context.Context, req *dto.RespondNotebookInvitationRequest) (*dto.RespondNotebookInvitationResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation, err := c.notebookInvitationRepository.GetById(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if invitation.UserId == nil || *invitation.UserId != userId || invitation.Status != constant.NotebookInvitationStatusPending {
+		return nil, serverutils.ErrNotFound
+	}
+
+	before := *invitation
+	now := time.Now()
+	invitation.RespondedAt = &now
+	invitation.Status = constant.NotebookInvitationStatusDeclined
+	action := constant.AuditActionDecline
+	if req.Accept {
+		invitation.Status = constant.NotebookInvitationStatusAccepted
+		action = constant.AuditActionAccept
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	notebookInvitationRepository := c.notebookInvitationRepository.UsingTx(ctx, tx)
+	notebookMemberRepository := c.notebookMemberRepository.UsingTx(ctx, tx)
+	auditEventRepository := c.auditEventRepository.UsingTx(ctx, tx)
+
+	err = notebookInvitationRepository.Update(ctx, invitation)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.recordAuditEvent(ctx, auditEventRepository, action, constant.AuditEntityNotebookInvitation, invitation.Id, invitation.NotebookId, &before, invitation)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Accept {
+		member, err := notebookMemberRepository.GetByNotebookIdAndUserId(ctx, invitation.NotebookId, userId)
+		if err == nil {
+			memberBefore := *member
+			member.Role = invitation.Role
+			member.UpdatedAt = &now
+			err = notebookMemberRepository.Update(ctx, member)
+			if err == nil {
+				err = c.recordAuditEvent(ctx, auditEventRepository, constant.AuditActionUpdate, constant.AuditEntityNotebookMember, member.Id, member.NotebookId, &memberBefore, member)
+			}
+		} else if errors.Is(err, serverutils.ErrNotFound) {
+			member = &entity.NotebookMember{
+				Id:         uuid.New(),
+				NotebookId: invitation.NotebookId,
+				UserId:     userId,
+				Role:       invitation.Role,
+				CreatedBy:  &invitation.InvitedBy,
+				CreatedAt:  now,
+			}
+			err = notebookMemberRepository.Create(ctx, member)
+			if err == nil {
+				err = c.recordAuditEvent(ctx, auditEventRepository, constant.AuditActionCreate, constant.AuditEntityNotebookMember, member.Id, member.NotebookId, nil, member)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.RespondNotebookInvitationResponse{
+		NotebookId: invitation.NotebookId,
+		Status:     invitation.Status,
+	}, nil
+}
+
+// saveInvitation creates the invitation when before is nil and updates it
+// otherwise, together with its audit event.
+func (c *notebookMemberService) saveInvitation(ctx context.Context, action string, before *entity.NotebookInvitation, invitation *entity.NotebookInvitation) error {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	notebookInvitationRepository := c.notebookInvitationRepository.UsingTx(ctx, tx)
+	if before == nil {
+		err = notebookInvitationRepository.Create(ctx, invitation)
+	} else {
+		err = notebookInvitationRepository.Update(ctx, invitation)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = c.recordAuditEvent(ctx, c.auditEventRepository.UsingTx(ctx, tx), action, constant.AuditEntityNotebookInvitation, invitation.Id, invitation.NotebookId, before, invitation)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (c *notebookMemberService) recordAuditEvent(ctx context.Context, auditEventRepository repository.IAuditEventRepository, action string, entityType string, entityId uuid.UUID, notebookId uuid.UUID, before any, after any) error {
+	auditEvent, err := newAuditEvent(ctx, action, entityType, entityId, before, after)
+	if err != nil {
+		return err
+	}
+	auditEvent.NotebookId = &notebookId
+
+	return auditEventRepository.Create(ctx, auditEvent)
+}
+
+func toNotebookInvitationResponses(invitations []*entity.NotebookInvitation) []*dto.NotebookInvitationResponse {
+	response := make([]*dto.NotebookInvitationResponse, 0)
+	for _, invitation := range invitations {
+		response = append(response, &dto.NotebookInvitationResponse{
+			Id:           invitation.Id,
+			NotebookId:   invitation.NotebookId,
+			NotebookName: invitation.NotebookName,
+			Email:        invitation.Email,
+			Role:         invitation.Role,
+			Status:       invitation.Status,
+			CreatedAt:    invitation.CreatedAt,
+		})
+	}
+
+	return response
+}
@@ -1,13 +1,20 @@
 package service
 
 import (
+	"ai-notetaking-be/internal/constant"
 	"ai-notetaking-be/internal/dto"
 	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
 	"ai-notetaking-be/internal/repository"
+	"ai-notetaking-be/pkg/fractional"
 	garagestorages3 "ai-notetaking-be/pkg/garage-storage-s3"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,11 +22,15 @@ import (
 )
 
 type INotebookService interface {
-	GetAll(ctx context.Context) ([]*dto.ListNotebookResponse, error)
+	GetAll(ctx context.Context, req *dto.GetAllNotebookRequest) ([]*dto.ListNotebookResponse, error)
+	GetTree(ctx context.Context, req *dto.GetNotebookTreeRequest) ([]*dto.NotebookTreeResponse, error)
+	GetPath(ctx context.Context, id uuid.UUID) ([]*dto.NotebookPathResponse, error)
+	GetNotes(ctx context.Context, req *dto.GetNotebookNotesRequest) ([]*dto.NotebookNoteResponse, error)
+	Reorder(ctx context.Context, req *dto.ReorderNotebookRequest) (*dto.ReorderNotebookResponse, error)
 	Create(ctx context.Context, req *dto.CreateNotebookRequest) (*dto.CreateNotebookResponse, error)
 	Show(ctx context.Context, id uuid.UUID) (*dto.ShowNotebookResponse, error)
 	Update(ctx context.Context, req *dto.UpdateNotebookRequest) (*dto.UpdateNotebookResponse, error)
-	Delete(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, req *dto.DeleteNotebookRequest) error
 	Move(ctx context.Context, req *dto.MoveNotebookRequest) (*dto.MoveNotebookResponse, error)
 }
 
@@ -30,6 +41,9 @@ type notebookService struct {
 	publisherService        IPublisherService
 	fileRepository          repository.IFileRepository
 	s3Client                *garagestorages3.GarageS3
+	notebookAccessService   INotebookAccessService
+	auditEventRepository    repository.IAuditEventRepository
+	noteTagRepository       repository.INoteTagRepository
 
 	db *pgxpool.Pool
 }
@@ -41,6 +55,9 @@ func NewNotebookService(
 	publisherService IPublisherService,
 	fileRepository repository.IFileRepository,
 	s3Client *garagestorages3.GarageS3,
+	notebookAccessService INotebookAccessService,
+	auditEventRepository repository.IAuditEventRepository,
+	noteTagRepository repository.INoteTagRepository,
 	db *pgxpool.Pool) INotebookService {
 	return &notebookService{
 		notebookRepository:      notebookRepository,
@@ -50,23 +67,60 @@ func NewNotebookService(
 		db:                      db,
 		fileRepository:          fileRepository,
 		s3Client:                s3Client,
+		notebookAccessService:   notebookAccessService,
+		auditEventRepository:    auditEventRepository,
+		noteTagRepository:       noteTagRepository,
 	}
 }
 
-func (c *notebookService) GetAll(ctx context.Context) ([]*dto.ListNotebookResponse, error) {
-	// 1. Ambil semua Notebooks
-	notebooks, err := c.notebookRepository.GetAll(ctx)
+func (c *notebookService) GetAll(ctx context.Context, req *dto.GetAllNotebookRequest) ([]*dto.ListNotebookResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	tagIds, err := parseTagIds(req.TagIds)
+	if err != nil {
+		return nil, err
+	}
+
+	sort, err := parseSort(req.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. Ambil semua Notebooks yang bisa dibaca, milik sendiri maupun yang dibagikan
+	grants, err := c.notebookAccessService.Grants(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	readableIds := make([]uuid.UUID, 0, len(grants))
+	for id := range grants {
+		readableIds = append(readableIds, id)
+	}
+
+	notebooks, err := c.notebookRepository.GetByIds(ctx, readableIds)
+	if err != nil {
+		return nil, err
+	}
+	sortByKey(notebooks, sort, notebookSortKey)
+
 	notebookIds := make([]uuid.UUID, 0)
 	result := make([]*dto.ListNotebookResponse, 0)
 	for _, notebook := range notebooks {
+		// A shared subtree is shown as a root when its parent is not shared.
+		parentId := notebook.ParentId
+		if parentId != nil && grants[*parentId] == "" {
+			parentId = nil
+		}
+
 		res := dto.ListNotebookResponse{
 			Id:        notebook.Id,
 			Name:      notebook.Name,
-			ParentId:  notebook.ParentId,
+			ParentId:  parentId,
+			Role:      grants[notebook.Id],
+			Position:  notebook.Position,
 			CreatedAt: notebook.CreatedAt,
 			UpdateAt:  notebook.UpdatedAt,
 			Notes:     make([]*dto.GetAllNotebookResponseNote, 0),
@@ -81,11 +135,30 @@ func (c *notebookService) GetAll(ctx context.Context) ([]*dto.ListNotebookRespon
 		return nil, err
 	}
 
-	noteIds := make([]uuid.UUID, len(notes))
+	allNoteIds := make([]uuid.UUID, len(notes))
 	for i, n := range notes {
-		noteIds[i] = n.Id
+		allNoteIds[i] = n.Id
 	}
 
+	noteTags, err := c.noteTagRepository.GetByNoteIds(ctx, allNoteIds)
+	if err != nil {
+		return nil, err
+	}
+	tagMap := noteTagResponses(noteTags)
+
+	// With tag_ids only the notes carrying all of them are listed, the
+	// notebooks are all kept to show where they are.
+	noteIds := make([]uuid.UUID, 0, len(notes))
+	filteredNotes := make([]*entity.Note, 0, len(notes))
+	for _, n := range notes {
+		if hasAllTags(tagMap[n.Id], tagIds) {
+			noteIds = append(noteIds, n.Id)
+			filteredNotes = append(filteredNotes, n)
+		}
+	}
+	notes = filteredNotes
+	sortByKey(notes, sort, noteSortKey)
+
 	// 3. Ambil semua Files dan Generate Presigned URL
 	files, err := c.fileRepository.GetByNoteIds(ctx, noteIds)
 	if err != nil {
@@ -110,6 +183,7 @@ func (c *notebookService) GetAll(ctx context.Context) ([]*dto.ListNotebookRespon
 			}
 
 			fileMap[f.NoteId] = append(fileMap[f.NoteId], dto.NoteFileDTO{
+				Id:   f.Id,
 				Name: f.OriginalName,
 				Url:  url,
 			})
@@ -128,13 +202,20 @@ func (c *notebookService) GetAll(ctx context.Context) ([]*dto.ListNotebookRespon
 					attachedFiles = []dto.NoteFileDTO{}
 				}
 
+				tags := tagMap[note.Id]
+				if tags == nil {
+					tags = []*dto.NoteTagResponse{}
+				}
+
 				notebookRes.Notes = append(notebookRes.Notes, &dto.GetAllNotebookResponseNote{
 					Id:        note.Id,
 					Title:     note.Title,
 					Content:   note.Content,
 					Files:     attachedFiles, // Masukkan array file
+					Position:  note.Position,
 					CreatedAt: note.CreatedAt,
 					UpdateAt:  note.UpdatedAt,
+					Tags:      tags,
 				})
 			}
 		}
@@ -143,16 +224,238 @@ func (c *notebookService) GetAll(ctx context.Context) ([]*dto.ListNotebookRespon
 	return result, nil
 }
 
+// GetTree nests the notebooks the user can read, a shared subtree becomes a
+// root of its own. Notes are loaded per notebook through GetNotes.
+func (c *notebookService) GetTree(ctx context.Context, req *dto.GetNotebookTreeRequest) ([]*dto.NotebookTreeResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sort, err := parseSort(req.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	grants, err := c.notebookAccessService.Grants(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	readableIds := make([]uuid.UUID, 0, len(grants))
+	for id := range grants {
+		readableIds = append(readableIds, id)
+	}
+
+	nodes, err := c.notebookRepository.GetTree(ctx, readableIds)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeById := make(map[uuid.UUID]*entity.NotebookTreeNode)
+	byId := make(map[uuid.UUID]*dto.NotebookTreeResponse)
+	result := make([]*dto.NotebookTreeResponse, 0)
+	for _, node := range nodes {
+		res := &dto.NotebookTreeResponse{
+			Id:             node.Id,
+			Name:           node.Name,
+			Role:           grants[node.Id],
+			Position:       node.Position,
+			NoteCount:      node.NoteCount,
+			LastModifiedAt: node.LastModifiedAt,
+			Children:       make([]*dto.NotebookTreeResponse, 0),
+		}
+		byId[node.Id] = res
+		nodeById[node.Id] = node
+
+		// Parents come first, a parent missing here is not shared.
+		var parent *dto.NotebookTreeResponse
+		if node.ParentId != nil {
+			parent = byId[*node.ParentId]
+		}
+		if parent == nil {
+			result = append(result, res)
+			continue
+		}
+
+		res.ParentId = node.ParentId
+		parent.Children = append(parent.Children, res)
+	}
+
+	// Children come after their parent, walking backwards rolls the changes
+	// of a subtree up to its root.
+	for i := len(nodes) - 1; i >= 0; i-- {
+		res := byId[nodes[i].Id]
+		if res.ParentId == nil {
+			continue
+		}
+
+		parent := byId[*res.ParentId]
+		if res.LastModifiedAt.After(parent.LastModifiedAt) {
+			parent.LastModifiedAt = res.LastModifiedAt
+		}
+	}
+
+	treeSortKey := func(res *dto.NotebookTreeResponse) sortKey {
+		return sortKey{
+			Id:        res.Id,
+			Position:  res.Position,
+			Name:      res.Name,
+			UpdatedAt: res.LastModifiedAt,
+			CreatedAt: nodeById[res.Id].CreatedAt,
+		}
+	}
+
+	sortByKey(result, sort, treeSortKey)
+	for _, res := range byId {
+		sortByKey(res.Children, sort, treeSortKey)
+	}
+
+	return result, nil
+}
+
+// GetPath returns the breadcrumbs from the root down to the notebook, cut at
+// the first ancestor the user cannot read.
+func (c *notebookService) GetPath(ctx context.Context, id uuid.UUID) ([]*dto.NotebookPathResponse, error) {
+	userId, err := c.notebookAccessService.Authorize(ctx, id, constant.NotebookRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	ancestors, err := c.notebookRepository.GetAncestors(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	grants, err := c.notebookAccessService.Grants(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	start := len(ancestors) - 1
+	for start > 0 && grants[ancestors[start-1].Id] != "" {
+		start--
+	}
+
+	result := make([]*dto.NotebookPathResponse, 0, len(ancestors)-start)
+	for _, notebook := range ancestors[start:] {
+		result = append(result, &dto.NotebookPathResponse{
+			Id:   notebook.Id,
+			Name: notebook.Name,
+		})
+	}
+
+	return result, nil
+}
+
+// GetNotes lists the notes of the notebook without their content, to load
+// the tree one notebook at a time.
+func (c *notebookService) GetNotes(ctx context.Context, req *dto.GetNotebookNotesRequest) ([]*dto.NotebookNoteResponse, error) {
+	sort, err := parseSort(req.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.notebookAccessService.Authorize(ctx, req.Id, constant.NotebookRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err := c.noteRepository.GetSummariesByNotebookId(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	sortByKey(notes, sort, noteSortKey)
+
+	noteIds := make([]uuid.UUID, 0, len(notes))
+	for _, note := range notes {
+		noteIds = append(noteIds, note.Id)
+	}
+
+	noteTags, err := c.noteTagRepository.GetByNoteIds(ctx, noteIds)
+	if err != nil {
+		return nil, err
+	}
+	tagMap := noteTagResponses(noteTags)
+
+	result := make([]*dto.NotebookNoteResponse, 0, len(notes))
+	for _, note := range notes {
+		tags := tagMap[note.Id]
+		if tags == nil {
+			tags = []*dto.NoteTagResponse{}
+		}
+
+		result = append(result, &dto.NotebookNoteResponse{
+			Id:        note.Id,
+			Title:     note.Title,
+			Version:   note.Version,
+			Position:  note.Position,
+			CreatedAt: note.CreatedAt,
+			UpdatedAt: note.UpdatedAt,
+			Tags:      tags,
+		})
+	}
+
+	return result, nil
+}
+
 func (c *notebookService) Create(ctx context.Context, req *dto.CreateNotebookRequest) (*dto.CreateNotebookResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ParentId != nil {
+		_, err = c.notebookAccessService.Authorize(ctx, *req.ParentId, constant.NotebookRoleEditor)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	notebook := entity.Notebook{
 		Id:        uuid.New(),
 		Name:      req.Name,
 		ParentId:  req.ParentId,
+		UserId:    userId,
 		CreatedAt: time.Now(),
+		Version:   1,
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	notebookRepo := c.notebookRepository.UsingTx(ctx, tx)
+
+	// A parent being deleted meanwhile holds the lock of its tree, once it
+	// is gone it is not found.
+	if notebook.ParentId != nil {
+		err = c.lockHierarchy(ctx, notebookRepo, *notebook.ParentId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// New notebooks go last among their siblings.
+	notebook.Position, err = c.lastPosition(ctx, notebookRepo, notebook.UserId, notebook.ParentId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = notebookRepo.Create(ctx, &notebook)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.recordAuditEvent(ctx, c.auditEventRepository.UsingTx(ctx, tx), constant.AuditActionCreate, nil, &notebook)
+	if err != nil {
+		return nil, err
 	}
 
-	err := c.notebookRepository.Create(ctx, &notebook)
+	err = tx.Commit(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -163,17 +466,45 @@ func (c *notebookService) Create(ctx context.Context, req *dto.CreateNotebookReq
 }
 
 func (c *notebookService) Update(ctx context.Context, req *dto.UpdateNotebookRequest) (*dto.UpdateNotebookResponse, error) {
+	userId, err := c.notebookAccessService.Authorize(ctx, req.Id, constant.NotebookRoleEditor)
+	if err != nil {
+		return nil, err
+	}
 
 	notebook, err := c.notebookRepository.GetById(ctx, req.Id)
 	if err != nil {
 		return nil, err
 	}
 
+	before := *notebook
 	now := time.Now()
 	notebook.Name = req.Name
 	notebook.UpdatedAt = &now
+	if req.Version != nil {
+		notebook.Version = *req.Version
+	}
 
-	err = c.notebookRepository.Update(ctx, notebook)
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = c.notebookRepository.UsingTx(ctx, tx).Update(ctx, notebook)
+	if err != nil {
+		if errors.Is(err, serverutils.ErrConflict) {
+			return nil, c.conflict(ctx, notebook.Id)
+		}
+		return nil, err
+	}
+
+	err = c.recordAuditEvent(ctx, c.auditEventRepository.UsingTx(ctx, tx), constant.AuditActionUpdate, &before, notebook)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -186,6 +517,7 @@ func (c *notebookService) Update(ctx context.Context, req *dto.UpdateNotebookReq
 	for _, note := range notes {
 		msg := dto.PublishEmbedNoteMessage{
 			NotedId: note.Id,
+			UserId:  userId,
 		}
 
 		msgJson, err := json.Marshal(msg)
@@ -201,35 +533,115 @@ func (c *notebookService) Update(ctx context.Context, req *dto.UpdateNotebookReq
 	}
 
 	return &dto.UpdateNotebookResponse{
-		Id: notebook.Id,
+		Id:      notebook.Id,
+		Version: notebook.Version,
 	}, nil
 }
 
 func (c *notebookService) Move(ctx context.Context, req *dto.MoveNotebookRequest) (*dto.MoveNotebookResponse, error) {
 
-	_, err := c.notebookRepository.GetById(ctx, req.Id)
+	_, err := c.notebookAccessService.Authorize(ctx, req.Id, constant.NotebookRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ParentId != nil {
+		_, err = c.notebookAccessService.Authorize(ctx, *req.ParentId, constant.NotebookRoleEditor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	notebook, err := c.notebookRepository.GetById(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *notebook
+	now := time.Now()
+	notebook.ParentId = req.ParentId
+	notebook.UpdatedAt = &now
+	if req.Version != nil {
+		notebook.Version = *req.Version
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	notebookRepo := c.notebookRepository.UsingTx(ctx, tx)
+
+	// A notebook moved to the root starts a tree nobody else sees before the
+	// commit, only the trees it leaves and joins are locked.
+	lockedIds := []uuid.UUID{notebook.Id}
+	if req.ParentId != nil {
+		lockedIds = append(lockedIds, *req.ParentId)
+	}
+
+	err = c.lockHierarchy(ctx, notebookRepo, lockedIds...)
 	if err != nil {
 		return nil, err
 	}
 
 	if req.ParentId != nil {
-		_, err = c.notebookRepository.GetById(ctx, *req.ParentId)
+		err = c.checkNotInSubtree(ctx, notebookRepo, notebook.Id, *req.ParentId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = notebookRepo.Update(ctx, notebook)
+	if err != nil {
+		if errors.Is(err, serverutils.ErrConflict) {
+			return nil, c.conflict(ctx, notebook.Id)
+		}
+		return nil, err
+	}
+
+	if !sameParent(before.ParentId, notebook.ParentId) {
+		notebook.Position, err = c.lastPosition(ctx, notebookRepo, notebook.UserId, notebook.ParentId)
+		if err != nil {
+			return nil, err
+		}
+
+		err = notebookRepo.UpdatePosition(ctx, notebook.Id, notebook.Position)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	err = c.notebookRepository.Move(ctx, req.Id, req.ParentId)
+	err = c.recordAuditEvent(ctx, c.auditEventRepository.UsingTx(ctx, tx), constant.AuditActionMove, &before, notebook)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	return &dto.MoveNotebookResponse{
-		Id: req.Id,
+		Id:      req.Id,
+		Version: notebook.Version,
 	}, nil
 }
 
 func (c *notebookService) Show(ctx context.Context, idParam uuid.UUID) (*dto.ShowNotebookResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := c.notebookAccessService.Role(ctx, userId, idParam)
+	if err != nil {
+		return nil, err
+	}
+	if role == "" {
+		return nil, serverutils.ErrNotFound
+	}
 
 	notebook, err := c.notebookRepository.GetById(ctx, idParam)
 
@@ -241,15 +653,24 @@ func (c *notebookService) Show(ctx context.Context, idParam uuid.UUID) (*dto.Sho
 		Id:        notebook.Id,
 		Name:      notebook.Name,
 		ParentId:  notebook.ParentId,
+		Role:      role,
+		Version:   notebook.Version,
 		CreatedAt: notebook.CreatedAt,
 	}
 
 	return &res, nil
 }
 
-func (c *notebookService) Delete(ctx context.Context, idParam uuid.UUID) error {
+// Delete moves the notebook to the trash. With the cascade mode everything
+// below it goes too, so the subtree can be restored as it was. With the
+// reparent mode only the notebook and its notes go, its children move up to
+// its parent.
+func (c *notebookService) Delete(ctx context.Context, req *dto.DeleteNotebookRequest) error {
+	if req.Mode != constant.NotebookDeleteModeCascade && req.Mode != constant.NotebookDeleteModeReparent {
+		return fmt.Errorf("%w: mode must be %s or %s", serverutils.ErrBadRequest, constant.NotebookDeleteModeCascade, constant.NotebookDeleteModeReparent)
+	}
 
-	_, err := c.notebookRepository.GetById(ctx, idParam)
+	_, err := c.notebookAccessService.Authorize(ctx, req.Id, constant.NotebookRoleOwner)
 	if err != nil {
 		return err
 	}
@@ -265,36 +686,514 @@ func (c *notebookService) Delete(ctx context.Context, idParam uuid.UUID) error {
 	noteRepo := c.noteRepository.UsingTx(ctx, tx)
 	noteEmbeddingRepo := c.noteEmbeddingRepository.UsingTx(ctx, tx)
 	fileRepo := c.fileRepository.UsingTx(ctx, tx)
+	auditEventRepo := c.auditEventRepository.UsingTx(ctx, tx)
+
+	// Like Move and Create, so the subtree and children read below are still
+	// the ones under the notebook when it goes to the trash.
+	err = c.lockHierarchy(ctx, notebookRepo, req.Id)
+	if err != nil {
+		return err
+	}
 
-	err = notebookRepo.DeleteById(ctx, idParam)
+	notebook, err := notebookRepo.GetById(ctx, req.Id)
 	if err != nil {
 		return err
 	}
 
-	err = noteEmbeddingRepo.DeleteByNotebookId(ctx, idParam)
+	if req.Version != nil && notebook.Version != *req.Version {
+		return c.conflict(ctx, req.Id)
+	}
+
+	deletedIds := []uuid.UUID{notebook.Id}
+	children := make([]*entity.Notebook, 0)
+	if req.Mode == constant.NotebookDeleteModeCascade {
+		deletedIds, err = notebookRepo.GetSubtreeIds(ctx, notebook.Id)
+		if err != nil {
+			return err
+		}
+	} else {
+		// The children land in the parent, which takes the same right as
+		// moving them there.
+		if notebook.ParentId != nil {
+			_, err = c.notebookAccessService.Authorize(ctx, *notebook.ParentId, constant.NotebookRoleEditor)
+			if err != nil {
+				return err
+			}
+		}
+
+		children, err = notebookRepo.GetChildren(ctx, notebook.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	notebooks, err := notebookRepo.GetByIds(ctx, deletedIds)
 	if err != nil {
 		return err
 	}
 
-	err = notebookRepo.NullifyParentById(ctx, idParam)
+	notes, err := noteRepo.GetByNotesIds(ctx, deletedIds)
 	if err != nil {
 		return err
 	}
 
-	err = noteRepo.DeleteByNotebookId(ctx, idParam)
+	// The children keep their order, after the notebooks already there.
+	for _, child := range children {
+		before := *child
+		err = notebookRepo.Move(ctx, child.Id, notebook.ParentId)
+		if err != nil {
+			return err
+		}
+
+		child.Position, err = c.lastPosition(ctx, notebookRepo, child.UserId, notebook.ParentId)
+		if err != nil {
+			return err
+		}
+
+		err = notebookRepo.UpdatePosition(ctx, child.Id, child.Position)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		child.ParentId = notebook.ParentId
+		child.UpdatedAt = &now
+		child.Version++
+
+		err = c.recordAuditEvent(ctx, auditEventRepo, constant.AuditActionMove, &before, child)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The root goes first, restoring picks up the rows deleted from its
+	// deleted_at onwards. It only goes at the version read above, a rename
+	// does not wait for the lock.
+	for _, notebookId := range deletedIds {
+		if notebookId == notebook.Id {
+			err = notebookRepo.DeleteByIdAndVersion(ctx, notebookId, notebook.Version)
+			if errors.Is(err, serverutils.ErrConflict) {
+				return c.conflict(ctx, notebook.Id)
+			}
+		} else {
+			err = notebookRepo.DeleteById(ctx, notebookId)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = noteEmbeddingRepo.DeleteByNotebookId(ctx, notebookId)
+		if err != nil {
+			return err
+		}
+
+		err = noteRepo.DeleteByNotebookId(ctx, notebookId)
+		if err != nil {
+			return err
+		}
+
+		err = fileRepo.DeleteByNotebookId(ctx, notebookId)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The notes go with the notebook, each gets its own event so the note
+	// activity feed shows who removed it.
+	for _, note := range notes {
+		auditEvent, err := newAuditEvent(ctx, constant.AuditActionDelete, constant.AuditEntityNote, note.Id, note, nil)
+		if err != nil {
+			return err
+		}
+		auditEvent.NotebookId = &note.NotebookId
+		auditEvent.NoteId = &note.Id
+
+		err = auditEventRepo.Create(ctx, auditEvent)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, notebook := range notebooks {
+		err = c.recordAuditEvent(ctx, auditEventRepo, constant.AuditActionDelete, notebook, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit(ctx)
 	if err != nil {
 		return err
 	}
 
-	err = fileRepo.DeleteByNotebookId(ctx, idParam)
+	return nil
+}
+
+// lockHierarchy locks the trees holding the notebooks for the rest of the
+// transaction, keyed by the owner of their root. ErrNotFound when a notebook
+// is gone, ErrConflict when one changed trees before the lock was taken.
+func (c *notebookService) lockHierarchy(ctx context.Context, notebookRepo repository.INotebookRepository, notebookIds ...uuid.UUID) error {
+	ownerIds, err := rootOwnerIds(ctx, notebookRepo, notebookIds)
+	if err != nil {
+		return err
+	}
+
+	err = notebookRepo.LockHierarchy(ctx, ownerIds...)
+	if err != nil {
+		return err
+	}
+
+	lockedIds, err := rootOwnerIds(ctx, notebookRepo, notebookIds)
+	if err != nil {
+		return err
+	}
+
+	for _, ownerId := range lockedIds {
+		if !slices.Contains(ownerIds, ownerId) {
+			return fmt.Errorf("%w: the notebook was moved meanwhile, try again", serverutils.ErrConflict)
+		}
+	}
+
+	return nil
+}
+
+// rootOwnerIds returns the owners of the roots above the live notebooks.
+func rootOwnerIds(ctx context.Context, notebookRepo repository.INotebookRepository, notebookIds []uuid.UUID) ([]uuid.UUID, error) {
+	ownerIds := make([]uuid.UUID, 0, len(notebookIds))
+	for _, notebookId := range notebookIds {
+		ancestors, err := notebookRepo.GetAncestors(ctx, notebookId)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(ancestors) == 0 {
+			return nil, serverutils.ErrNotFound
+		}
+
+		ownerIds = append(ownerIds, ancestors[0].UserId)
+	}
+
+	return ownerIds, nil
+}
+
+// checkNotInSubtree rejects moving the notebook under itself or one of its
+// descendants, which would cut the subtree off into a cycle. The trees of
+// both must be locked so that two moves crossing each other cannot both
+// pass.
+func (c *notebookService) checkNotInSubtree(ctx context.Context, notebookRepo repository.INotebookRepository, notebookId uuid.UUID, parentId uuid.UUID) error {
+	ancestors, err := notebookRepo.GetAncestors(ctx, parentId)
 	if err != nil {
 		return err
 	}
 
+	for _, ancestor := range ancestors {
+		if ancestor.Id == notebookId {
+			return fmt.Errorf("%w: a notebook cannot be moved into itself or one of its descendants", serverutils.ErrBadRequest)
+		}
+	}
+
+	return nil
+}
+
+// Reorder needs the editor role on the parent, the order of siblings is
+// shared by everyone who sees them. At the root it needs the owner role.
+func (c *notebookService) Reorder(ctx context.Context, req *dto.ReorderNotebookRequest) (*dto.ReorderNotebookResponse, error) {
+	anchorId, after, err := parseAnchor(req.Id, req.BeforeId, req.AfterId)
+	if err != nil {
+		return nil, err
+	}
+
+	notebook, err := c.notebookRepository.GetById(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if notebook.ParentId != nil {
+		_, err = c.notebookAccessService.Authorize(ctx, *notebook.ParentId, constant.NotebookRoleEditor)
+	} else {
+		_, err = c.notebookAccessService.Authorize(ctx, notebook.Id, constant.NotebookRoleOwner)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The anchor is only placed against by users who can see it.
+	_, err = c.notebookAccessService.Authorize(ctx, anchorId, constant.NotebookRoleViewer)
+	if err != nil {
+		if errors.Is(err, serverutils.ErrForbidden) {
+			return nil, serverutils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	notebookRepo := c.notebookRepository.UsingTx(ctx, tx)
+
+	// Root notebooks are ordered among those of the same user.
+	siblings, err := notebookRepo.GetSiblingPositions(ctx, notebook.UserId, notebook.ParentId)
+	if err != nil {
+		return nil, err
+	}
+
+	position, err := placeNextTo(ctx, siblings, anchorId, after, notebookRepo.UpdatePosition)
+	if err != nil {
+		if errors.Is(err, serverutils.ErrNotFound) {
+			return nil, fmt.Errorf("%w: the anchor must be a notebook of the same parent", serverutils.ErrBadRequest)
+		}
+		return nil, err
+	}
+
+	err = notebookRepo.UpdatePosition(ctx, notebook.Id, position)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *notebook
+	notebook.Position = position
+
+	err = c.recordAuditEvent(ctx, c.auditEventRepository.UsingTx(ctx, tx), constant.AuditActionReorder, &before, notebook)
+	if err != nil {
+		return nil, err
+	}
+
 	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ReorderNotebookResponse{
+		Id:       notebook.Id,
+		Position: position,
+	}, nil
+}
+
+// lastPosition returns a position after the notebooks under parentId, at the
+// root after those of userId.
+func (c *notebookService) lastPosition(ctx context.Context, notebookRepo repository.INotebookRepository, userId uuid.UUID, parentId *uuid.UUID) (string, error) {
+	last, err := notebookRepo.GetLastPosition(ctx, userId, parentId)
+	if err != nil {
+		return "", err
+	}
+
+	return fractional.KeyBetween(last, "")
+}
+
+// conflict reports that the notebook moved past the version the client
+// edited, with the notebook as it is now.
+func (c *notebookService) conflict(ctx context.Context, notebookId uuid.UUID) error {
+	current, err := c.Show(ctx, notebookId)
+	if err != nil {
+		return err
+	}
+
+	return serverutils.NewConflictError(current.Version, current)
+}
+
+func (c *notebookService) recordAuditEvent(ctx context.Context, auditEventRepository repository.IAuditEventRepository, action string, before *entity.Notebook, after *entity.Notebook) error {
+	notebook := after
+	if notebook == nil {
+		notebook = before
+	}
+
+	auditEvent, err := newAuditEvent(ctx, action, constant.AuditEntityNotebook, notebook.Id, before, after)
 	if err != nil {
 		return err
 	}
+	auditEvent.NotebookId = &notebook.Id
+
+	return auditEventRepository.Create(ctx, auditEvent)
+}
+
+func sameParent(a *uuid.UUID, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+// parseAnchor checks that exactly one of beforeId and afterId is set, and
+// that it is not the item itself.
+func parseAnchor(id uuid.UUID, beforeId *uuid.UUID, afterId *uuid.UUID) (uuid.UUID, bool, error) {
+	if (beforeId == nil) == (afterId == nil) {
+		return uuid.Nil, false, fmt.Errorf("%w: set exactly one of before_id and after_id", serverutils.ErrBadRequest)
+	}
+
+	after := afterId != nil
+	anchorId := afterId
+	if !after {
+		anchorId = beforeId
+	}
+
+	if *anchorId == id {
+		return uuid.Nil, false, fmt.Errorf("%w: an item cannot be placed next to itself", serverutils.ErrBadRequest)
+	}
+
+	return *anchorId, after, nil
+}
+
+// positionNextTo returns a position between the anchor and its neighbor on
+// the after side, or on the before side when after is false.
+func positionNextTo(anchor string, neighbor string, after bool) (string, error) {
+	if after {
+		return fractional.KeyBetween(anchor, neighbor)
+	}
+
+	return fractional.KeyBetween(neighbor, anchor)
+}
+
+// placeNextTo returns the position right after the anchor among the
+// siblings, or right before it when after is false, ErrNotFound when the
+// anchor is not one of them. Concurrent creates can leave siblings on the
+// same position with no room in between, the siblings then get fresh
+// positions in their current order first, saved through updatePosition. So
+// do positions that are not valid keys.
+func placeNextTo(ctx context.Context, siblings []*entity.SiblingPosition, anchorId uuid.UUID, after bool, updatePosition func(ctx context.Context, id uuid.UUID, position string) error) (string, error) {
+	index := slices.IndexFunc(siblings, func(sibling *entity.SiblingPosition) bool {
+		return sibling.Id == anchorId
+	})
+	if index < 0 {
+		return "", serverutils.ErrNotFound
+	}
+
+	for i := 1; i < len(siblings); i++ {
+		if siblings[i].Position == siblings[i-1].Position {
+			err := spreadPositions(ctx, siblings, updatePosition)
+			if err != nil {
+				return "", err
+			}
+			break
+		}
+	}
+
+	position, err := positionBeside(siblings, index, after)
+	if errors.Is(err, fractional.ErrInvalidKey) {
+		err = spreadPositions(ctx, siblings, updatePosition)
+		if err != nil {
+			return "", err
+		}
+		position, err = positionBeside(siblings, index, after)
+	}
+
+	return position, err
+}
+
+func positionBeside(siblings []*entity.SiblingPosition, index int, after bool) (string, error) {
+	neighbor := ""
+	if after && index+1 < len(siblings) {
+		neighbor = siblings[index+1].Position
+	}
+	if !after && index > 0 {
+		neighbor = siblings[index-1].Position
+	}
+
+	return positionNextTo(siblings[index].Position, neighbor, after)
+}
+
+// spreadPositions gives the siblings distinct positions in their order.
+func spreadPositions(ctx context.Context, siblings []*entity.SiblingPosition, updatePosition func(ctx context.Context, id uuid.UUID, position string) error) error {
+	previous := ""
+	for _, sibling := range siblings {
+		position, err := fractional.KeyBetween(previous, "")
+		if err != nil {
+			return err
+		}
+
+		if position != sibling.Position {
+			err = updatePosition(ctx, sibling.Id, position)
+			if err != nil {
+				return err
+			}
+			sibling.Position = position
+		}
+
+		previous = position
+	}
 
 	return nil
 }
+
+// sortKey holds what listings can be sorted by.
+type sortKey struct {
+	Id        uuid.UUID
+	Position  string
+	Name      string
+	UpdatedAt time.Time
+	CreatedAt time.Time
+}
+
+func notebookSortKey(notebook *entity.Notebook) sortKey {
+	updatedAt := notebook.CreatedAt
+	if notebook.UpdatedAt != nil {
+		updatedAt = *notebook.UpdatedAt
+	}
+
+	return sortKey{
+		Id:        notebook.Id,
+		Position:  notebook.Position,
+		Name:      notebook.Name,
+		UpdatedAt: updatedAt,
+		CreatedAt: notebook.CreatedAt,
+	}
+}
+
+func noteSortKey(note *entity.Note) sortKey {
+	updatedAt := note.CreatedAt
+	if note.UpdatedAt != nil {
+		updatedAt = *note.UpdatedAt
+	}
+
+	return sortKey{
+		Id:        note.Id,
+		Position:  note.Position,
+		Name:      note.Title,
+		UpdatedAt: updatedAt,
+		CreatedAt: note.CreatedAt,
+	}
+}
+
+// parseSort reads the sort query parameter, manual when empty.
+func parseSort(raw string) (string, error) {
+	switch raw {
+	case "":
+		return constant.SortManual, nil
+	case constant.SortManual, constant.SortName, constant.SortUpdated, constant.SortCreated:
+		return raw, nil
+	}
+
+	return "", fmt.Errorf("%w: sort must be one of manual, name, updated or created", serverutils.ErrBadRequest)
+}
+
+// sortByKey sorts items in place. Ties fall back to the manual order, then
+// to the id like the manual order of the database.
+func sortByKey[T any](items []T, sort string, key func(T) sortKey) {
+	slices.SortStableFunc(items, func(a T, b T) int {
+		ka, kb := key(a), key(b)
+
+		res := 0
+		switch sort {
+		case constant.SortName:
+			res = strings.Compare(strings.ToLower(ka.Name), strings.ToLower(kb.Name))
+		case constant.SortUpdated:
+			res = kb.UpdatedAt.Compare(ka.UpdatedAt)
+		case constant.SortCreated:
+			res = kb.CreatedAt.Compare(ka.CreatedAt)
+		}
+		if res != 0 {
+			return res
+		}
+
+		res = strings.Compare(ka.Position, kb.Position)
+		if res != 0 {
+			return res
+		}
+
+		return bytes.Compare(ka.Id[:], kb.Id[:])
+	})
+}
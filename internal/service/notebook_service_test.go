@@ -0,0 +1,164 @@
+package service
+
+import (
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/repository"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPlaceNextTo(t *testing.T) {
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+
+	tests := []struct {
+		name      string
+		positions []string
+		anchor    int
+		after     bool
+		wantErr   error
+		// wantSpread is whether the siblings got fresh positions first.
+		wantSpread bool
+	}{
+		{name: "after the last", positions: []string{"G", "V", "l"}, anchor: 2, after: true},
+		{name: "before the first", positions: []string{"G", "V", "l"}, anchor: 0},
+		{name: "between two", positions: []string{"G", "V", "l"}, anchor: 0, after: true},
+		{name: "after a tie", positions: []string{"V", "V", "V"}, anchor: 0, after: true, wantSpread: true},
+		{name: "before a tie", positions: []string{"G", "V", "V"}, anchor: 2, wantSpread: true},
+		{name: "invalid key", positions: []string{"G", "V0", "l"}, anchor: 1, after: true, wantSpread: true},
+		{name: "unknown anchor", positions: []string{"G", "V", "l"}, anchor: -1, wantErr: serverutils.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			siblings := make([]*entity.SiblingPosition, len(tt.positions))
+			for i, position := range tt.positions {
+				siblings[i] = &entity.SiblingPosition{Id: ids[i], Position: position}
+			}
+
+			anchorId := uuid.New()
+			if tt.anchor >= 0 {
+				anchorId = ids[tt.anchor]
+			}
+
+			spread := false
+			updatePosition := func(ctx context.Context, id uuid.UUID, position string) error {
+				spread = true
+				return nil
+			}
+
+			position, err := placeNextTo(context.Background(), siblings, anchorId, tt.after, updatePosition)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("placeNextTo error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if spread != tt.wantSpread {
+				t.Errorf("spread = %v, want %v", spread, tt.wantSpread)
+			}
+
+			anchor := siblings[tt.anchor].Position
+			if tt.after && position <= anchor || !tt.after && position >= anchor {
+				t.Errorf("position %q is not on the right side of the anchor %q", position, anchor)
+			}
+			for i, sibling := range siblings {
+				if sibling.Position == position {
+					t.Errorf("position %q is taken by sibling %d", position, i)
+				}
+				if i == 0 {
+					continue
+				}
+
+				// The new position must fall between the anchor and its
+				// neighbor.
+				previous := siblings[i-1].Position
+				if previous < position && position < sibling.Position && i-1 != tt.anchor && i != tt.anchor {
+					t.Errorf("position %q is between siblings %d and %d, not next to the anchor", position, i-1, i)
+				}
+			}
+		})
+	}
+}
+
+type fakeNotebookRepository struct {
+	repository.INotebookRepository
+	// rootOwners maps the live notebooks to the owner of their root.
+	rootOwners map[uuid.UUID]uuid.UUID
+	lockedIds  []uuid.UUID
+	// onLock runs once the locks are taken, like a concurrent change that
+	// committed just before.
+	onLock func()
+}
+
+func (f *fakeNotebookRepository) GetAncestors(ctx context.Context, id uuid.UUID) ([]*entity.Notebook, error) {
+	ownerId, ok := f.rootOwners[id]
+	if !ok {
+		return []*entity.Notebook{}, nil
+	}
+
+	return []*entity.Notebook{{Id: uuid.New(), UserId: ownerId}, {Id: id}}, nil
+}
+
+func (f *fakeNotebookRepository) LockHierarchy(ctx context.Context, ownerIds ...uuid.UUID) error {
+	f.lockedIds = append(f.lockedIds, ownerIds...)
+	if f.onLock != nil {
+		f.onLock()
+	}
+
+	return nil
+}
+
+func TestLockHierarchy(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+	notebook, parent, gone := uuid.New(), uuid.New(), uuid.New()
+
+	tests := []struct {
+		name        string
+		notebookIds []uuid.UUID
+		onLock      func(rootOwners map[uuid.UUID]uuid.UUID)
+		wantLocked  []uuid.UUID
+		wantErr     error
+	}{
+		{name: "one tree", notebookIds: []uuid.UUID{notebook}, wantLocked: []uuid.UUID{owner}},
+		{name: "two trees", notebookIds: []uuid.UUID{notebook, parent}, wantLocked: []uuid.UUID{owner, other}},
+		{name: "gone", notebookIds: []uuid.UUID{gone}, wantErr: serverutils.ErrNotFound},
+		{
+			name:        "deleted while waiting",
+			notebookIds: []uuid.UUID{notebook},
+			onLock:      func(rootOwners map[uuid.UUID]uuid.UUID) { delete(rootOwners, notebook) },
+			wantLocked:  []uuid.UUID{owner},
+			wantErr:     serverutils.ErrNotFound,
+		},
+		{
+			name:        "moved to another tree while waiting",
+			notebookIds: []uuid.UUID{notebook},
+			onLock:      func(rootOwners map[uuid.UUID]uuid.UUID) { rootOwners[notebook] = other },
+			wantLocked:  []uuid.UUID{owner},
+			wantErr:     serverutils.ErrConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notebookRepo := &fakeNotebookRepository{rootOwners: map[uuid.UUID]uuid.UUID{notebook: owner, parent: other}}
+			if tt.onLock != nil {
+				notebookRepo.onLock = func() { tt.onLock(notebookRepo.rootOwners) }
+			}
+
+			err := (&notebookService{}).lockHierarchy(context.Background(), notebookRepo, tt.notebookIds...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("lockHierarchy error = %v, want %v", err, tt.wantErr)
+			}
+
+			if !slices.Equal(notebookRepo.lockedIds, tt.wantLocked) {
+				t.Errorf("locked %v, want %v", notebookRepo.lockedIds, tt.wantLocked)
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package service
+
+import (
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// promptTemplateVariablePattern matches placeholders such as {{ language }}.
+var promptTemplateVariablePattern = regexp.MustCompile(`{{\s*(\w+)\s*}}`)
+
+type IPromptTemplateService interface {
+	GetAll(ctx context.Context) ([]*dto.ListPromptTemplateResponse, error)
+	Show(ctx context.Context, id uuid.UUID) (*dto.ShowPromptTemplateResponse, error)
+	Create(ctx context.Context, req *dto.CreatePromptTemplateRequest) (*dto.CreatePromptTemplateResponse, error)
+	Update(ctx context.Context, req *dto.UpdatePromptTemplateRequest) (*dto.UpdatePromptTemplateResponse, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	CreateVersion(ctx context.Context, req *dto.CreatePromptTemplateVersionRequest) (*dto.CreatePromptTemplateVersionResponse, error)
+	SetDefault(ctx context.Context, id uuid.UUID) error
+	ResolveVersion(ctx context.Context, promptTemplateId *uuid.UUID, persona string) (*entity.PromptTemplateVersion, error)
+}
+
+type promptTemplateService struct {
+	promptTemplateRepository        repository.IPromptTemplateRepository
+	promptTemplateVersionRepository repository.IPromptTemplateVersionRepository
+	db                              *pgxpool.Pool
+}
+
+func NewPromptTemplateService(
+	promptTemplateRepository repository.IPromptTemplateRepository,
+	promptTemplateVersionRepository repository.IPromptTemplateVersionRepository,
+	db *pgxpool.Pool,
+) IPromptTemplateService {
+	return &promptTemplateService{
+		promptTemplateRepository:        promptTemplateRepository,
+		promptTemplateVersionRepository: promptTemplateVersionRepository,
+		db:                              db,
+	}
+}
+
+func (c *promptTemplateService) GetAll(ctx context.Context) ([]*dto.ListPromptTemplateResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	promptTemplates, err := c.promptTemplateRepository.GetAll(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*dto.ListPromptTemplateResponse, 0)
+	for _, promptTemplate := range promptTemplates {
+		response = append(response, &dto.ListPromptTemplateResponse{
+			Id:          promptTemplate.Id,
+			Name:        promptTemplate.Name,
+			Description: promptTemplate.Description,
+			IsDefault:   promptTemplate.IsDefault,
+			IsBuiltIn:   promptTemplate.UserId == nil,
+			CreatedAt:   promptTemplate.CreatedAt,
+			UpdatedAt:   promptTemplate.UpdatedAt,
+		})
+	}
+
+	return response, nil
+}
+
+func (c *promptTemplateService) Show(ctx context.Context, id uuid.UUID) (*dto.ShowPromptTemplateResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	promptTemplate, err := c.promptTemplateRepository.GetById(ctx, userId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := c.promptTemplateVersionRepository.GetByTemplateId(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.ShowPromptTemplateResponse{
+		Id:          promptTemplate.Id,
+		Name:        promptTemplate.Name,
+		Description: promptTemplate.Description,
+		IsDefault:   promptTemplate.IsDefault,
+		IsBuiltIn:   promptTemplate.UserId == nil,
+		CreatedAt:   promptTemplate.CreatedAt,
+		UpdatedAt:   promptTemplate.UpdatedAt,
+		Versions:    make([]*dto.PromptTemplateVersionResponse, 0),
+	}
+
+	for _, version := range versions {
+		response.Versions = append(response.Versions, &dto.PromptTemplateVersionResponse{
+			Id:          version.Id,
+			Version:     version.Version,
+			UserPrompt:  version.UserPrompt,
+			ModelPrompt: version.ModelPrompt,
+			Variables:   version.Variables,
+			CreatedAt:   version.CreatedAt,
+		})
+	}
+
+	return response, nil
+}
+
+func (c *promptTemplateService) Create(ctx context.Context, req *dto.CreatePromptTemplateRequest) (*dto.CreatePromptTemplateResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.promptTemplateRepository.GetByName(ctx, userId, req.Name)
+	if err == nil {
+		return nil, fmt.Errorf("%w: prompt template %s already exists", serverutils.ErrBadRequest, req.Name)
+	}
+	if !errors.Is(err, serverutils.ErrNotFound) {
+		return nil, err
+	}
+
+	now := time.Now()
+	promptTemplate := entity.PromptTemplate{
+		Id:          uuid.New(),
+		UserId:      &userId,
+		Name:        req.Name,
+		Description: req.Description,
+		IsDefault:   req.IsDefault,
+		CreatedAt:   now,
+	}
+
+	version := entity.PromptTemplateVersion{
+		Id:               uuid.New(),
+		PromptTemplateId: promptTemplate.Id,
+		UserPrompt:       req.UserPrompt,
+		ModelPrompt:      req.ModelPrompt,
+		Variables:        promptTemplateVariables(req.UserPrompt, req.ModelPrompt),
+		CreatedAt:        now,
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	promptTemplateRepository := c.promptTemplateRepository.UsingTx(ctx, tx)
+	promptTemplateVersionRepository := c.promptTemplateVersionRepository.UsingTx(ctx, tx)
+
+	if promptTemplate.IsDefault {
+		err = promptTemplateRepository.ClearDefault(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = promptTemplateRepository.Create(ctx, &promptTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	err = promptTemplateVersionRepository.Create(ctx, &version)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.CreatePromptTemplateResponse{
+		Id:        promptTemplate.Id,
+		VersionId: version.Id,
+		Version:   version.Version,
+	}, nil
+}
+
+func (c *promptTemplateService) Update(ctx context.Context, req *dto.UpdatePromptTemplateRequest) (*dto.UpdatePromptTemplateResponse, error) {
+	promptTemplate, _, err := c.getOwnTemplate(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	promptTemplate.Name = req.Name
+	promptTemplate.Description = req.Description
+	promptTemplate.UpdatedAt = &now
+
+	err = c.promptTemplateRepository.Update(ctx, promptTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.UpdatePromptTemplateResponse{
+		Id: promptTemplate.Id,
+	}, nil
+}
+
+func (c *promptTemplateService) Delete(ctx context.Context, id uuid.UUID) error {
+	_, _, err := c.getOwnTemplate(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return c.promptTemplateRepository.DeleteById(ctx, id)
+}
+
+// CreateVersion never edits an existing version, sessions keep pointing at the
+// version they were started with.
+func (c *promptTemplateService) CreateVersion(ctx context.Context, req *dto.CreatePromptTemplateVersionRequest) (*dto.CreatePromptTemplateVersionResponse, error) {
+	promptTemplate, _, err := c.getOwnTemplate(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	version := entity.PromptTemplateVersion{
+		Id:               uuid.New(),
+		PromptTemplateId: promptTemplate.Id,
+		UserPrompt:       req.UserPrompt,
+		ModelPrompt:      req.ModelPrompt,
+		Variables:        promptTemplateVariables(req.UserPrompt, req.ModelPrompt),
+		CreatedAt:        time.Now(),
+	}
+
+	err = c.promptTemplateVersionRepository.Create(ctx, &version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.CreatePromptTemplateVersionResponse{
+		Id:        promptTemplate.Id,
+		VersionId: version.Id,
+		Version:   version.Version,
+	}, nil
+}
+
+// SetDefault makes the template the default of its owner, it takes over from
+// the built-in default for that user only.
+func (c *promptTemplateService) SetDefault(ctx context.Context, id uuid.UUID) error {
+	promptTemplate, userId, err := c.getOwnTemplate(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	promptTemplateRepository := c.promptTemplateRepository.UsingTx(ctx, tx)
+
+	err = promptTemplateRepository.ClearDefault(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	promptTemplate.IsDefault = true
+	promptTemplate.UpdatedAt = &now
+
+	err = promptTemplateRepository.Update(ctx, promptTemplate)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ResolveVersion picks the latest version of the requested template, then of
+// the template named after the persona, then of the default template, among
+// the templates of the user and the built-ins. It returns ErrNotFound when
+// none of them exist.
+func (c *promptTemplateService) ResolveVersion(ctx context.Context, promptTemplateId *uuid.UUID, persona string) (*entity.PromptTemplateVersion, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var promptTemplate *entity.PromptTemplate
+	if promptTemplateId != nil {
+		promptTemplate, err = c.promptTemplateRepository.GetById(ctx, userId, *promptTemplateId)
+	} else if persona != "" {
+		promptTemplate, err = c.promptTemplateRepository.GetByName(ctx, userId, persona)
+	} else {
+		promptTemplate, err = c.promptTemplateRepository.GetDefault(ctx, userId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c.promptTemplateVersionRepository.GetLatestByTemplateId(ctx, promptTemplate.Id)
+}
+
+// getOwnTemplate returns a template of the user for a change, built-ins are
+// read-only.
+func (c *promptTemplateService) getOwnTemplate(ctx context.Context, id uuid.UUID) (*entity.PromptTemplate, uuid.UUID, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	promptTemplate, err := c.promptTemplateRepository.GetById(ctx, userId, id)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	if promptTemplate.UserId == nil {
+		return nil, uuid.Nil, serverutils.ErrForbidden
+	}
+
+	return promptTemplate, userId, nil
+}
+
+// promptTemplateVariables lists the distinct placeholders used by the prompts.
+func promptTemplateVariables(prompts ...string) []string {
+	seen := make(map[string]bool)
+	variables := make([]string, 0)
+	for _, prompt := range prompts {
+		for _, match := range promptTemplateVariablePattern.FindAllStringSubmatch(prompt, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				variables = append(variables, match[1])
+			}
+		}
+	}
+
+	return variables
+}
+
+// renderPromptTemplate fills every placeholder of prompt, a missing variable
+// is a bad request rather than a silently broken system prompt.
+func renderPromptTemplate(prompt string, variables map[string]string) (string, error) {
+	var missing []string
+	rendered := promptTemplateVariablePattern.ReplaceAllStringFunc(prompt, func(placeholder string) string {
+		name := promptTemplateVariablePattern.FindStringSubmatch(placeholder)[1]
+		value, ok := variables[name]
+		if !ok {
+			missing = append(missing, name)
+			return placeholder
+		}
+		return value
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: missing prompt variables %s", serverutils.ErrBadRequest, strings.Join(missing, ", "))
+	}
+
+	return rendered, nil
+}
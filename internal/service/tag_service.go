@@ -0,0 +1,567 @@
+package service
+
+import (
+	"ai-notetaking-be/internal/constant"
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ITagService interface {
+	GetAll(ctx context.Context) ([]*dto.TagResponse, error)
+	Create(ctx context.Context, req *dto.CreateTagRequest) (*dto.CreateTagResponse, error)
+	Update(ctx context.Context, req *dto.UpdateTagRequest) (*dto.UpdateTagResponse, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	Merge(ctx context.Context, req *dto.MergeTagRequest) (*dto.MergeTagResponse, error)
+	SetNoteTags(ctx context.Context, req *dto.SetNoteTagsRequest) (*dto.SetNoteTagsResponse, error)
+}
+
+type tagService struct {
+	tagRepository            repository.ITagRepository
+	noteTagRepository        repository.INoteTagRepository
+	chatSessionTagRepository repository.IChatSessionTagRepository
+	noteSuggestionRepository repository.INoteSuggestionRepository
+	noteRepository           repository.INoteRepository
+	notebookAccessService    INotebookAccessService
+	auditEventRepository     repository.IAuditEventRepository
+	publisherService         IPublisherService
+	db                       *pgxpool.Pool
+}
+
+func NewTagService(
+	tagRepository repository.ITagRepository,
+	noteTagRepository repository.INoteTagRepository,
+	chatSessionTagRepository repository.IChatSessionTagRepository,
+	noteSuggestionRepository repository.INoteSuggestionRepository,
+	noteRepository repository.INoteRepository,
+	notebookAccessService INotebookAccessService,
+	auditEventRepository repository.IAuditEventRepository,
+	publisherService IPublisherService,
+	db *pgxpool.Pool,
+) ITagService {
+	return &tagService{
+		tagRepository:            tagRepository,
+		noteTagRepository:        noteTagRepository,
+		chatSessionTagRepository: chatSessionTagRepository,
+		noteSuggestionRepository: noteSuggestionRepository,
+		noteRepository:           noteRepository,
+		notebookAccessService:    notebookAccessService,
+		auditEventRepository:     auditEventRepository,
+		publisherService:         publisherService,
+		db:                       db,
+	}
+}
+
+// GetAll lists the tags of the user with the number of notes they can read
+// carrying each one.
+func (c *tagService) GetAll(ctx context.Context) ([]*dto.TagResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := c.tagRepository.GetByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	notebookIds, err := c.notebookAccessService.ReadableNotebookIds(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := c.tagRepository.CountNotes(ctx, userId, notebookIds)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*dto.TagResponse, 0)
+	for _, tag := range tags {
+		response = append(response, &dto.TagResponse{
+			Id:        tag.Id,
+			Name:      tag.Name,
+			NoteCount: counts[tag.Id],
+			CreatedAt: tag.CreatedAt,
+			UpdatedAt: tag.UpdatedAt,
+		})
+	}
+
+	return response, nil
+}
+
+func (c *tagService) Create(ctx context.Context, req *dto.CreateTagRequest) (*dto.CreateTagResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := c.availableName(ctx, userId, nil, req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := entity.Tag{
+		Id:        uuid.New(),
+		UserId:    userId,
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = c.tagRepository.UsingTx(ctx, tx).Create(ctx, &tag)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.recordAuditEvent(ctx, c.auditEventRepository.UsingTx(ctx, tx), constant.AuditActionCreate, tag.Id, nil, &tag)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.CreateTagResponse{
+		Id: tag.Id,
+	}, nil
+}
+
+// Update renames the tag, the notes carrying it are embedded again since
+// tag names are part of what is embedded.
+func (c *tagService) Update(ctx context.Context, req *dto.UpdateTagRequest) (*dto.UpdateTagResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := c.tagRepository.GetById(ctx, userId, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := c.availableName(ctx, userId, &tag.Id, req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == tag.Name {
+		return &dto.UpdateTagResponse{
+			Id: tag.Id,
+		}, nil
+	}
+
+	before := *tag
+	now := time.Now()
+	tag.Name = name
+	tag.UpdatedAt = &now
+
+	noteIds, err := c.noteTagRepository.GetNoteIdsByTagId(ctx, tag.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = c.tagRepository.UsingTx(ctx, tx).Update(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.recordAuditEvent(ctx, c.auditEventRepository.UsingTx(ctx, tx), constant.AuditActionUpdate, tag.Id, &before, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.publishEmbed(ctx, userId, noteIds)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.UpdateTagResponse{
+		Id: tag.Id,
+	}, nil
+}
+
+// Delete detaches the tag from every note and chat session before deleting
+// it.
+func (c *tagService) Delete(ctx context.Context, id uuid.UUID) error {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := c.tagRepository.GetById(ctx, userId, id)
+	if err != nil {
+		return err
+	}
+
+	noteIds, err := c.noteTagRepository.GetNoteIdsByTagId(ctx, tag.Id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = c.noteTagRepository.UsingTx(ctx, tx).DeleteByTagId(ctx, tag.Id)
+	if err != nil {
+		return err
+	}
+
+	err = c.chatSessionTagRepository.UsingTx(ctx, tx).DeleteByTagId(ctx, tag.Id)
+	if err != nil {
+		return err
+	}
+
+	err = c.noteSuggestionRepository.UsingTx(ctx, tx).DeleteByTagId(ctx, tag.Id)
+	if err != nil {
+		return err
+	}
+
+	err = c.tagRepository.UsingTx(ctx, tx).DeleteById(ctx, tag.Id)
+	if err != nil {
+		return err
+	}
+
+	err = c.recordAuditEvent(ctx, c.auditEventRepository.UsingTx(ctx, tx), constant.AuditActionDelete, tag.Id, tag, nil)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.publishEmbed(ctx, userId, noteIds)
+}
+
+func (c *tagService) Merge(ctx context.Context, req *dto.MergeTagRequest) (*dto.MergeTagResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Id == req.TargetId {
+		return nil, fmt.Errorf("%w: a tag cannot be merged into itself", serverutils.ErrBadRequest)
+	}
+
+	tag, err := c.tagRepository.GetById(ctx, userId, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := c.tagRepository.GetById(ctx, userId, req.TargetId)
+	if err != nil {
+		return nil, err
+	}
+
+	noteIds, err := c.noteTagRepository.GetNoteIdsByTagId(ctx, tag.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = c.noteTagRepository.UsingTx(ctx, tx).Merge(ctx, tag.Id, target.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.chatSessionTagRepository.UsingTx(ctx, tx).Merge(ctx, tag.Id, target.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.noteSuggestionRepository.UsingTx(ctx, tx).DeleteByTagId(ctx, tag.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.tagRepository.UsingTx(ctx, tx).DeleteById(ctx, tag.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	// The diff shows which tag it became.
+	err = c.recordAuditEvent(ctx, c.auditEventRepository.UsingTx(ctx, tx), constant.AuditActionMerge, tag.Id, tag, target)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.publishEmbed(ctx, userId, noteIds)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.MergeTagResponse{
+		Id: target.Id,
+	}, nil
+}
+
+// SetNoteTags needs the editor role on the note. Only the tags of the user
+// are replaced, others keep the tags they attached.
+func (c *tagService) SetNoteTags(ctx context.Context, req *dto.SetNoteTagsRequest) (*dto.SetNoteTagsResponse, error) {
+	note, err := c.noteRepository.GetById(ctx, req.NoteId)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := c.notebookAccessService.Authorize(ctx, note.NotebookId, constant.NotebookRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	tagIds := uniqueIds(req.TagIds)
+	tags, err := c.tagRepository.GetByIds(ctx, userId, tagIds)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) != len(tagIds) {
+		return nil, fmt.Errorf("%w: every tag must be one of your tags", serverutils.ErrBadRequest)
+	}
+
+	current, err := c.noteTagRepository.GetByNoteIds(ctx, []uuid.UUID{note.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	removed := make([]uuid.UUID, 0)
+	kept := make(map[uuid.UUID]bool)
+	for _, noteTag := range current {
+		if noteTag.UserId != userId {
+			continue
+		}
+
+		if slices.Contains(tagIds, noteTag.TagId) {
+			kept[noteTag.TagId] = true
+		} else {
+			removed = append(removed, noteTag.TagId)
+		}
+	}
+
+	added := make([]uuid.UUID, 0)
+	for _, tagId := range tagIds {
+		if !kept[tagId] {
+			added = append(added, tagId)
+		}
+	}
+
+	if len(added) == 0 && len(removed) == 0 {
+		return &dto.SetNoteTagsResponse{
+			Id:   note.Id,
+			Tags: noteTagResponses(current)[note.Id],
+		}, nil
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	noteTagRepository := c.noteTagRepository.UsingTx(ctx, tx)
+
+	err = noteTagRepository.Delete(ctx, note.Id, removed)
+	if err != nil {
+		return nil, err
+	}
+
+	err = noteTagRepository.Create(ctx, note.Id, added, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := noteTagRepository.GetByNoteIds(ctx, []uuid.UUID{note.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	auditEvent, err := newAuditEvent(ctx, constant.AuditActionUpdate, constant.AuditEntityNote, note.Id, noteTagsSnapshot(current), noteTagsSnapshot(updated))
+	if err != nil {
+		return nil, err
+	}
+	auditEvent.NotebookId = &note.NotebookId
+	auditEvent.NoteId = &note.Id
+
+	err = c.auditEventRepository.UsingTx(ctx, tx).Create(ctx, auditEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.publishEmbed(ctx, userId, []uuid.UUID{note.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.SetNoteTagsResponse{
+		Id:   note.Id,
+		Tags: noteTagResponses(updated)[note.Id],
+	}, nil
+}
+
+// availableName trims name and checks that no other tag of the user has it.
+func (c *tagService) availableName(ctx context.Context, userId uuid.UUID, tagId *uuid.UUID, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: the tag name is empty", serverutils.ErrBadRequest)
+	}
+
+	existing, err := c.tagRepository.GetByName(ctx, userId, name)
+	if err != nil {
+		if errors.Is(err, serverutils.ErrNotFound) {
+			return name, nil
+		}
+		return "", err
+	}
+
+	if tagId == nil || existing.Id != *tagId {
+		return "", fmt.Errorf("%w: tag %s already exists, merge the tags instead", serverutils.ErrBadRequest, existing.Name)
+	}
+
+	return name, nil
+}
+
+func (c *tagService) recordAuditEvent(ctx context.Context, auditEventRepository repository.IAuditEventRepository, action string, tagId uuid.UUID, before *entity.Tag, after *entity.Tag) error {
+	auditEvent, err := newAuditEvent(ctx, action, constant.AuditEntityTag, tagId, before, after)
+	if err != nil {
+		return err
+	}
+
+	return auditEventRepository.Create(ctx, auditEvent)
+}
+
+func (c *tagService) publishEmbed(ctx context.Context, userId uuid.UUID, noteIds []uuid.UUID) error {
+	for _, noteId := range noteIds {
+		payload, err := json.Marshal(dto.PublishEmbedNoteMessage{
+			NotedId: noteId,
+			UserId:  userId,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = c.publisherService.Publish(ctx, payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func noteTagsSnapshot(noteTags []*entity.NoteTag) map[string]any {
+	names := make([]string, 0, len(noteTags))
+	for _, noteTag := range noteTags {
+		names = append(names, noteTag.Name)
+	}
+
+	return map[string]any{"tags": names}
+}
+
+// noteTagResponses groups noteTags by note.
+func noteTagResponses(noteTags []*entity.NoteTag) map[uuid.UUID][]*dto.NoteTagResponse {
+	res := make(map[uuid.UUID][]*dto.NoteTagResponse)
+	for _, noteTag := range noteTags {
+		res[noteTag.NoteId] = append(res[noteTag.NoteId], &dto.NoteTagResponse{
+			Id:   noteTag.TagId,
+			Name: noteTag.Name,
+		})
+	}
+
+	return res
+}
+
+// hasAllTags reports whether tags holds every id of tagIds.
+func hasAllTags(tags []*dto.NoteTagResponse, tagIds []uuid.UUID) bool {
+	for _, tagId := range tagIds {
+		found := slices.ContainsFunc(tags, func(tag *dto.NoteTagResponse) bool {
+			return tag.Id == tagId
+		})
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseTagIds reads a comma separated list of tag ids from a query string.
+func parseTagIds(raw string) ([]uuid.UUID, error) {
+	tagIds := make([]uuid.UUID, 0)
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		tagId, err := uuid.Parse(part)
+		if err != nil {
+			return nil, fmt.Errorf("%w: tag_ids must be UUIDs", serverutils.ErrBadRequest)
+		}
+
+		tagIds = append(tagIds, tagId)
+	}
+
+	return uniqueIds(tagIds), nil
+}
+
+func uniqueIds(ids []uuid.UUID) []uuid.UUID {
+	res := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !slices.Contains(res, id) {
+			res = append(res, id)
+		}
+	}
+
+	return res
+}
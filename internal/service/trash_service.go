@@ -0,0 +1,730 @@
+package service
+
+import (
+	"ai-notetaking-be/internal/constant"
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/repository"
+	garagestorages3 "ai-notetaking-be/pkg/garage-storage-s3"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	defaultTrashRetentionDays = 30
+	trashPurgeInterval        = time.Hour
+	trashPurgeBatchSize       = 500
+)
+
+// ParseTrashRetention reads the number of days deleted items stay in the
+// trash, empty means the default.
+func ParseTrashRetention(raw string) (int, error) {
+	if raw == "" {
+		return defaultTrashRetentionDays, nil
+	}
+
+	days, err := strconv.Atoi(raw)
+	if err != nil || days < 1 {
+		return 0, fmt.Errorf("invalid trash retention: %q", raw)
+	}
+
+	return days, nil
+}
+
+type ITrashService interface {
+	GetAll(ctx context.Context) (*dto.GetTrashResponse, error)
+	RestoreNote(ctx context.Context, id uuid.UUID) (*dto.RestoreNoteResponse, error)
+	RestoreNotebook(ctx context.Context, id uuid.UUID) (*dto.RestoreNotebookResponse, error)
+	RestoreChatSession(ctx context.Context, id uuid.UUID) (*dto.RestoreChatSessionResponse, error)
+	Purge(ctx context.Context) error
+	StartPurgeJob(ctx context.Context)
+}
+
+type trashService struct {
+	notebookRepository           repository.INotebookRepository
+	noteRepository               repository.INoteRepository
+	noteEmbeddingRepository      repository.INoteEmbeddingRepository
+	fileRepository               repository.IFileRepository
+	notebookMemberRepository     repository.INotebookMemberRepository
+	notebookInvitationRepository repository.INotebookInvitationRepository
+	chatSessionRepository        repository.IChatSessionRepository
+	chatMessageRepository        repository.IChatMessageRepository
+	chatMessageRawRepository     repository.IChatMessageRawRepository
+	chatToolCallRepository       repository.IChatToolCallRepository
+	chatRetrievalPlanRepository  repository.IChatRetrievalPlanRepository
+	noteRevisionRepository       repository.INoteRevisionRepository
+	noteTagRepository            repository.INoteTagRepository
+	chatSessionTagRepository     repository.IChatSessionTagRepository
+	noteSuggestionRepository     repository.INoteSuggestionRepository
+	uploadIntentRepository       repository.IUploadIntentRepository
+	uploadSessionRepository      repository.IUploadSessionRepository
+	fileBlobRepository           repository.IFileBlobRepository
+	auditEventRepository         repository.IAuditEventRepository
+	notebookAccessService        INotebookAccessService
+	publisherService             IPublisherService
+	s3Client                     *garagestorages3.GarageS3
+	retentionDays                int
+	db                           *pgxpool.Pool
+}
+
+func NewTrashService(
+	notebookRepository repository.INotebookRepository,
+	noteRepository repository.INoteRepository,
+	noteEmbeddingRepository repository.INoteEmbeddingRepository,
+	fileRepository repository.IFileRepository,
+	notebookMemberRepository repository.INotebookMemberRepository,
+	notebookInvitationRepository repository.INotebookInvitationRepository,
+	chatSessionRepository repository.IChatSessionRepository,
+	chatMessageRepository repository.IChatMessageRepository,
+	chatMessageRawRepository repository.IChatMessageRawRepository,
+	chatToolCallRepository repository.IChatToolCallRepository,
+	chatRetrievalPlanRepository repository.IChatRetrievalPlanRepository,
+	noteRevisionRepository repository.INoteRevisionRepository,
+	noteTagRepository repository.INoteTagRepository,
+	chatSessionTagRepository repository.IChatSessionTagRepository,
+	noteSuggestionRepository repository.INoteSuggestionRepository,
+	uploadIntentRepository repository.IUploadIntentRepository,
+	uploadSessionRepository repository.IUploadSessionRepository,
+	fileBlobRepository repository.IFileBlobRepository,
+	auditEventRepository repository.IAuditEventRepository,
+	notebookAccessService INotebookAccessService,
+	publisherService IPublisherService,
+	s3Client *garagestorages3.GarageS3,
+	retentionDays int,
+	db *pgxpool.Pool,
+) ITrashService {
+	return &trashService{
+		notebookRepository:           notebookRepository,
+		noteRepository:               noteRepository,
+		noteEmbeddingRepository:      noteEmbeddingRepository,
+		fileRepository:               fileRepository,
+		notebookMemberRepository:     notebookMemberRepository,
+		notebookInvitationRepository: notebookInvitationRepository,
+		chatSessionRepository:        chatSessionRepository,
+		chatMessageRepository:        chatMessageRepository,
+		chatMessageRawRepository:     chatMessageRawRepository,
+		chatToolCallRepository:       chatToolCallRepository,
+		chatRetrievalPlanRepository:  chatRetrievalPlanRepository,
+		noteRevisionRepository:       noteRevisionRepository,
+		noteTagRepository:            noteTagRepository,
+		chatSessionTagRepository:     chatSessionTagRepository,
+		noteSuggestionRepository:     noteSuggestionRepository,
+		uploadIntentRepository:       uploadIntentRepository,
+		uploadSessionRepository:      uploadSessionRepository,
+		fileBlobRepository:           fileBlobRepository,
+		auditEventRepository:         auditEventRepository,
+		notebookAccessService:        notebookAccessService,
+		publisherService:             publisherService,
+		s3Client:                     s3Client,
+		retentionDays:                retentionDays,
+		db:                           db,
+	}
+}
+
+// GetAll lists what the user can restore: notes of live notebooks they edit,
+// notebooks they own that were deleted as a whole, and their chat sessions.
+// Notes and notebooks inside a deleted notebook come back with it.
+func (c *trashService) GetAll(ctx context.Context) (*dto.GetTrashResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	grants, err := c.notebookAccessService.Grants(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	editableIds := make([]uuid.UUID, 0)
+	for notebookId, role := range grants {
+		if notebookRoleRank[role] >= notebookRoleRank[constant.NotebookRoleEditor] {
+			editableIds = append(editableIds, notebookId)
+		}
+	}
+
+	trashGrants, err := c.notebookAccessService.TrashGrants(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	ownedIds := make([]uuid.UUID, 0)
+	for notebookId, role := range trashGrants {
+		if role == constant.NotebookRoleOwner {
+			ownedIds = append(ownedIds, notebookId)
+		}
+	}
+
+	notes, err := c.noteRepository.GetDeletedByNotebookIds(ctx, editableIds)
+	if err != nil {
+		return nil, err
+	}
+
+	notebooks, err := c.notebookRepository.GetDeletedRootsByIds(ctx, ownedIds)
+	if err != nil {
+		return nil, err
+	}
+
+	chatSessions, err := c.chatSessionRepository.GetDeletedByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	response := dto.GetTrashResponse{
+		RetentionDays: c.retentionDays,
+		Notes:         make([]*dto.TrashNoteResponse, 0),
+		Notebooks:     make([]*dto.TrashNotebookResponse, 0),
+		ChatSessions:  make([]*dto.TrashChatSessionResponse, 0),
+	}
+
+	for _, note := range notes {
+		response.Notes = append(response.Notes, &dto.TrashNoteResponse{
+			Id:         note.Id,
+			Title:      note.Title,
+			NotebookId: note.NotebookId,
+			DeletedAt:  *note.DeletedAt,
+			PurgeAt:    c.purgeAt(*note.DeletedAt),
+		})
+	}
+
+	for _, notebook := range notebooks {
+		response.Notebooks = append(response.Notebooks, &dto.TrashNotebookResponse{
+			Id:        notebook.Id,
+			Name:      notebook.Name,
+			ParentId:  notebook.ParentId,
+			DeletedAt: *notebook.DeletedAt,
+			PurgeAt:   c.purgeAt(*notebook.DeletedAt),
+		})
+	}
+
+	for _, chatSession := range chatSessions {
+		response.ChatSessions = append(response.ChatSessions, &dto.TrashChatSessionResponse{
+			Id:        chatSession.Id,
+			Title:     chatSession.Title,
+			DeletedAt: *chatSession.DeletedAt,
+			PurgeAt:   c.purgeAt(*chatSession.DeletedAt),
+		})
+	}
+
+	return &response, nil
+}
+
+func (c *trashService) RestoreNote(ctx context.Context, id uuid.UUID) (*dto.RestoreNoteResponse, error) {
+	note, err := c.noteRepository.GetDeletedById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := c.notebookAccessService.AuthorizeTrashed(ctx, note.NotebookId, constant.NotebookRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.notebookRepository.GetById(ctx, note.NotebookId)
+	if err != nil {
+		if errors.Is(err, serverutils.ErrNotFound) {
+			return nil, fmt.Errorf("%w: the notebook of this note is in the trash, restore it first", serverutils.ErrBadRequest)
+		}
+		return nil, err
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = c.noteRepository.UsingTx(ctx, tx).Restore(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.fileRepository.UsingTx(ctx, tx).RestoreByNoteIds(ctx, []uuid.UUID{id})
+	if err != nil {
+		return nil, err
+	}
+
+	auditEvent, err := newAuditEvent(ctx, constant.AuditActionRestore, constant.AuditEntityNote, note.Id, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	auditEvent.NotebookId = &note.NotebookId
+	auditEvent.NoteId = &note.Id
+
+	err = c.auditEventRepository.UsingTx(ctx, tx).Create(ctx, auditEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The embeddings were dropped with the note, the consumer builds them
+	// again.
+	err = c.publishEmbed(ctx, note.Id, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.RestoreNoteResponse{
+		Id: note.Id,
+	}, nil
+}
+
+// RestoreNotebook brings back the notebook with the notebooks and notes that
+// were deleted along with it. A notebook whose parent is still in the trash
+// is restored at the root.
+func (c *trashService) RestoreNotebook(ctx context.Context, id uuid.UUID) (*dto.RestoreNotebookResponse, error) {
+	notebook, err := c.notebookRepository.GetDeletedById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := c.notebookAccessService.AuthorizeTrashed(ctx, id, constant.NotebookRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	subtreeIds, err := c.notebookRepository.GetDeletedSubtreeIds(ctx, id, *notebook.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	detach := false
+	if notebook.ParentId != nil {
+		_, err = c.notebookRepository.GetById(ctx, *notebook.ParentId)
+		if err != nil && !errors.Is(err, serverutils.ErrNotFound) {
+			return nil, err
+		}
+		detach = err != nil
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	notebookRepo := c.notebookRepository.UsingTx(ctx, tx)
+
+	err = notebookRepo.RestoreByIds(ctx, subtreeIds)
+	if err != nil {
+		return nil, err
+	}
+
+	if detach {
+		err = notebookRepo.Move(ctx, id, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	noteIds, err := c.noteRepository.UsingTx(ctx, tx).RestoreByNotebookIds(ctx, subtreeIds, *notebook.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.fileRepository.UsingTx(ctx, tx).RestoreByNoteIds(ctx, noteIds)
+	if err != nil {
+		return nil, err
+	}
+
+	auditEventRepo := c.auditEventRepository.UsingTx(ctx, tx)
+	for _, notebookId := range subtreeIds {
+		auditEvent, err := newAuditEvent(ctx, constant.AuditActionRestore, constant.AuditEntityNotebook, notebookId, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		auditEvent.NotebookId = &notebookId
+
+		err = auditEventRepo.Create(ctx, auditEvent)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, noteId := range noteIds {
+		err = c.publishEmbed(ctx, noteId, userId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &dto.RestoreNotebookResponse{
+		Id:      id,
+		NoteIds: noteIds,
+	}, nil
+}
+
+func (c *trashService) RestoreChatSession(ctx context.Context, id uuid.UUID) (*dto.RestoreChatSessionResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.chatSessionRepository.GetDeletedById(ctx, userId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = c.chatSessionRepository.UsingTx(ctx, tx).Restore(ctx, userId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.chatMessageRepository.UsingTx(ctx, tx).RestoreBySessionId(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.chatMessageRawRepository.UsingTx(ctx, tx).RestoreBySessionId(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.chatToolCallRepository.UsingTx(ctx, tx).RestoreBySessionId(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	auditEvent, err := newAuditEvent(ctx, constant.AuditActionRestore, constant.AuditEntityChatSession, id, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.auditEventRepository.UsingTx(ctx, tx).Create(ctx, auditEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.RestoreChatSessionResponse{
+		Id: id,
+	}, nil
+}
+
+// StartPurgeJob runs Purge in the background until ctx is done.
+func (c *trashService) StartPurgeJob(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			err := c.Purge(ctx)
+			if err != nil {
+				log.Printf("[Trash] Purge failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Purge permanently removes what has been in the trash longer than the
+// retention, a batch at a time.
+func (c *trashService) Purge(ctx context.Context) error {
+	deletedBefore := time.Now().AddDate(0, 0, -c.retentionDays)
+
+	err := c.purgeNotes(ctx, deletedBefore)
+	if err != nil {
+		return err
+	}
+
+	err = c.purgeChatSessions(ctx, deletedBefore)
+	if err != nil {
+		return err
+	}
+
+	// Embeddings of notes that are still around, replaced by a re-embed.
+	return c.noteEmbeddingRepository.PurgeDeleted(ctx, deletedBefore)
+}
+
+func (c *trashService) purgeNotes(ctx context.Context, deletedBefore time.Time) error {
+	notebookIds, err := c.notebookRepository.GetPurgeableIds(ctx, deletedBefore, trashPurgeBatchSize)
+	if err != nil {
+		return err
+	}
+
+	noteIds, err := c.noteRepository.GetPurgeableIds(ctx, deletedBefore, notebookIds, trashPurgeBatchSize)
+	if err != nil {
+		return err
+	}
+
+	// A full batch may leave notes of the notebooks behind, the notebooks
+	// wait for the next run.
+	if len(noteIds) == trashPurgeBatchSize {
+		notebookIds = make([]uuid.UUID, 0)
+	}
+
+	if len(noteIds) == 0 && len(notebookIds) == 0 {
+		return nil
+	}
+
+	files, err := c.fileRepository.GetAllByNoteIds(ctx, noteIds)
+	if err != nil {
+		return err
+	}
+
+	// Identical files share their object, count the files per object.
+	type object struct {
+		bucket   string
+		fileName string
+	}
+	objects := make(map[object]int)
+	for _, file := range files {
+		objects[object{bucket: file.Bucket, fileName: file.FileName}]++
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	// Objects still used by other files stay. The others are only deleted
+	// once the rows are committed, until then a failure could roll the rows
+	// back onto an object that is gone.
+	unused := make([]object, 0, len(objects))
+	for object, count := range objects {
+		remaining, err := c.fileBlobRepository.UsingTx(ctx, tx).Release(ctx, object.bucket, object.fileName, count)
+		if err != nil {
+			return err
+		}
+
+		if remaining == 0 {
+			unused = append(unused, object)
+		}
+	}
+
+	err = c.fileRepository.UsingTx(ctx, tx).HardDeleteByNoteIds(ctx, noteIds)
+	if err != nil {
+		return err
+	}
+
+	err = c.noteEmbeddingRepository.UsingTx(ctx, tx).HardDeleteByNoteIds(ctx, noteIds)
+	if err != nil {
+		return err
+	}
+
+	err = c.noteRevisionRepository.UsingTx(ctx, tx).HardDeleteByNoteIds(ctx, noteIds)
+	if err != nil {
+		return err
+	}
+
+	err = c.noteTagRepository.UsingTx(ctx, tx).HardDeleteByNoteIds(ctx, noteIds)
+	if err != nil {
+		return err
+	}
+
+	noteSuggestionRepo := c.noteSuggestionRepository.UsingTx(ctx, tx)
+	err = noteSuggestionRepo.HardDeleteByNoteIds(ctx, noteIds)
+	if err != nil {
+		return err
+	}
+
+	err = noteSuggestionRepo.HardDeleteByNotebookIds(ctx, notebookIds)
+	if err != nil {
+		return err
+	}
+
+	err = c.uploadIntentRepository.UsingTx(ctx, tx).HardDeleteByNoteIds(ctx, noteIds)
+	if err != nil {
+		return err
+	}
+
+	err = c.uploadSessionRepository.UsingTx(ctx, tx).HardDeleteByNoteIds(ctx, noteIds)
+	if err != nil {
+		return err
+	}
+
+	err = c.noteRepository.UsingTx(ctx, tx).HardDeleteByIds(ctx, noteIds)
+	if err != nil {
+		return err
+	}
+
+	err = c.notebookMemberRepository.UsingTx(ctx, tx).DeleteByNotebookIds(ctx, notebookIds)
+	if err != nil {
+		return err
+	}
+
+	err = c.notebookInvitationRepository.UsingTx(ctx, tx).DeleteByNotebookIds(ctx, notebookIds)
+	if err != nil {
+		return err
+	}
+
+	err = c.notebookRepository.UsingTx(ctx, tx).HardDeleteByIds(ctx, notebookIds)
+	if err != nil {
+		return err
+	}
+
+	auditEventRepo := c.auditEventRepository.UsingTx(ctx, tx)
+	for _, noteId := range noteIds {
+		auditEvent, err := newAuditEvent(ctx, constant.AuditActionPurge, constant.AuditEntityNote, noteId, nil, nil)
+		if err != nil {
+			return err
+		}
+		auditEvent.NoteId = &noteId
+
+		err = auditEventRepo.Create(ctx, auditEvent)
+		if err != nil {
+			return err
+		}
+	}
+	for _, notebookId := range notebookIds {
+		auditEvent, err := newAuditEvent(ctx, constant.AuditActionPurge, constant.AuditEntityNotebook, notebookId, nil, nil)
+		if err != nil {
+			return err
+		}
+		auditEvent.NotebookId = &notebookId
+
+		err = auditEventRepo.Create(ctx, auditEvent)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	// No row points at these objects anymore, a failed delete only leaves an
+	// orphaned object behind.
+	for _, object := range unused {
+		err = c.s3Client.Delete(ctx, object.bucket, object.fileName)
+		if err != nil {
+			log.Printf("[Trash] Failed to delete object %s: %v", object.fileName, err)
+		}
+	}
+
+	log.Printf("[Trash] Purged %d notes and %d notebooks", len(noteIds), len(notebookIds))
+
+	return nil
+}
+
+func (c *trashService) purgeChatSessions(ctx context.Context, deletedBefore time.Time) error {
+	sessionIds, err := c.chatSessionRepository.GetPurgeableIds(ctx, deletedBefore, trashPurgeBatchSize)
+	if err != nil {
+		return err
+	}
+
+	if len(sessionIds) == 0 {
+		return nil
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	chatSessionRepo := c.chatSessionRepository.UsingTx(ctx, tx)
+
+	err = chatSessionRepo.ClearActiveMessageByIds(ctx, sessionIds)
+	if err != nil {
+		return err
+	}
+
+	err = c.chatToolCallRepository.UsingTx(ctx, tx).HardDeleteBySessionIds(ctx, sessionIds)
+	if err != nil {
+		return err
+	}
+
+	err = c.chatRetrievalPlanRepository.UsingTx(ctx, tx).HardDeleteBySessionIds(ctx, sessionIds)
+	if err != nil {
+		return err
+	}
+
+	err = c.chatSessionTagRepository.UsingTx(ctx, tx).HardDeleteBySessionIds(ctx, sessionIds)
+	if err != nil {
+		return err
+	}
+
+	err = c.chatMessageRawRepository.UsingTx(ctx, tx).HardDeleteBySessionIds(ctx, sessionIds)
+	if err != nil {
+		return err
+	}
+
+	err = c.chatMessageRepository.UsingTx(ctx, tx).HardDeleteBySessionIds(ctx, sessionIds)
+	if err != nil {
+		return err
+	}
+
+	err = chatSessionRepo.HardDeleteByIds(ctx, sessionIds)
+	if err != nil {
+		return err
+	}
+
+	auditEventRepo := c.auditEventRepository.UsingTx(ctx, tx)
+	for _, sessionId := range sessionIds {
+		auditEvent, err := newAuditEvent(ctx, constant.AuditActionPurge, constant.AuditEntityChatSession, sessionId, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		err = auditEventRepo.Create(ctx, auditEvent)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[Trash] Purged %d chat sessions", len(sessionIds))
+
+	return nil
+}
+
+func (c *trashService) purgeAt(deletedAt time.Time) time.Time {
+	return deletedAt.AddDate(0, 0, c.retentionDays)
+}
+
+func (c *trashService) publishEmbed(ctx context.Context, noteId uuid.UUID, userId uuid.UUID) error {
+	msgPayload := dto.PublishEmbedNoteMessage{
+		NotedId: noteId,
+		UserId:  userId,
+	}
+
+	msgJson, err := json.Marshal(msgPayload)
+	if err != nil {
+		return err
+	}
+
+	return c.publisherService.Publish(ctx, msgJson)
+}
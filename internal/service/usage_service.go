@@ -0,0 +1,241 @@
+package service
+
+import (
+	"ai-notetaking-be/internal/dto"
+	"ai-notetaking-be/internal/entity"
+	"ai-notetaking-be/internal/pkg/serverutils"
+	"ai-notetaking-be/internal/repository"
+	"ai-notetaking-be/pkg/chatbot"
+	"ai-notetaking-be/pkg/embedding"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const usageReportDateLayout = "2006-01-02"
+
+// ModelPricing is the price in USD per one million tokens. Embedding tokens
+// are billed at the input price.
+type ModelPricing struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
+}
+
+// defaultModelPricing is the Gemini paid tier list price, override it with
+// the LLM_MODEL_PRICING environment variable.
+var defaultModelPricing = map[string]ModelPricing{
+	chatbot.GeminiChatModel:        {InputPerMillion: 0.30, OutputPerMillion: 2.50},
+	chatbot.GeminiChatLegacyModel:  {InputPerMillion: 0.075, OutputPerMillion: 0.30},
+	embedding.GeminiEmbeddingModel: {InputPerMillion: 0.15},
+}
+
+// ParseModelPricing merges a JSON object of model name to ModelPricing over
+// the default prices. An empty string keeps the defaults.
+func ParseModelPricing(raw string) (map[string]ModelPricing, error) {
+	pricing := make(map[string]ModelPricing)
+	for model, price := range defaultModelPricing {
+		pricing[model] = price
+	}
+
+	if raw == "" {
+		return pricing, nil
+	}
+
+	var overrides map[string]ModelPricing
+	err := json.Unmarshal([]byte(raw), &overrides)
+	if err != nil {
+		return nil, fmt.Errorf("invalid model pricing: %w", err)
+	}
+
+	for model, price := range overrides {
+		pricing[model] = price
+	}
+
+	return pricing, nil
+}
+
+type IUsageService interface {
+	Record(ctx context.Context, llmUsage *entity.LlmUsage)
+	GetReport(ctx context.Context, req *dto.GetUsageReportRequest) (*dto.GetUsageReportResponse, error)
+}
+
+type usageService struct {
+	llmUsageRepository repository.ILlmUsageRepository
+	pricing            map[string]ModelPricing
+}
+
+func NewUsageService(
+	llmUsageRepository repository.ILlmUsageRepository,
+	pricing map[string]ModelPricing,
+) IUsageService {
+	return &usageService{
+		llmUsageRepository: llmUsageRepository,
+		pricing:            pricing,
+	}
+}
+
+// Record stores usage outside of any transaction, the tokens are spent even
+// when the surrounding request fails. Failures are only logged so accounting
+// never breaks a user request.
+func (c *usageService) Record(ctx context.Context, llmUsage *entity.LlmUsage) {
+	llmUsage.Id = uuid.New()
+	llmUsage.CreatedAt = time.Now()
+	if llmUsage.UserId == nil {
+		userId, err := serverutils.GetUserId(ctx)
+		if err == nil {
+			llmUsage.UserId = &userId
+		}
+	}
+
+	err := c.llmUsageRepository.Create(ctx, llmUsage)
+	if err != nil {
+		log.Printf("[Usage] Failed to record %s usage of %s: %v", llmUsage.Feature, llmUsage.Model, err)
+	}
+}
+
+func (c *usageService) GetReport(ctx context.Context, req *dto.GetUsageReportRequest) (*dto.GetUsageReportResponse, error) {
+	userId, err := serverutils.GetUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, 0, -29)
+
+	if req.From != "" {
+		from, err = time.Parse(usageReportDateLayout, req.From)
+		if err != nil {
+			return nil, fmt.Errorf("%w: from must be formatted as %s", serverutils.ErrBadRequest, usageReportDateLayout)
+		}
+	}
+	if req.To != "" {
+		to, err = time.Parse(usageReportDateLayout, req.To)
+		if err != nil {
+			return nil, fmt.Errorf("%w: to must be formatted as %s", serverutils.ErrBadRequest, usageReportDateLayout)
+		}
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: to is before from", serverutils.ErrBadRequest)
+	}
+
+	// Both ends are inclusive days.
+	summaries, err := c.llmUsageRepository.GetSummary(ctx, userId, from, to.AddDate(0, 0, 1), req.Feature, req.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.GetUsageReportResponse{
+		From:      from.Format(usageReportDateLayout),
+		To:        to.Format(usageReportDateLayout),
+		Currency:  "USD",
+		Rows:      make([]*dto.UsageReportRow, 0),
+		ByFeature: make([]*dto.UsageReportTotal, 0),
+		ByModel:   make([]*dto.UsageReportTotal, 0),
+	}
+
+	byFeature := make(map[string]*dto.UsageReportTotal)
+	byModel := make(map[string]*dto.UsageReportTotal)
+	for _, summary := range summaries {
+		cost := c.cost(summary)
+
+		response.Rows = append(response.Rows, &dto.UsageReportRow{
+			Day:              summary.Day.Format(usageReportDateLayout),
+			Feature:          summary.Feature,
+			Model:            summary.Model,
+			Calls:            summary.Calls,
+			PromptTokens:     summary.PromptTokens,
+			CompletionTokens: summary.CompletionTokens,
+			EmbeddingTokens:  summary.EmbeddingTokens,
+			TotalTokens:      summary.TotalTokens,
+			AvgLatencyMs:     summary.AvgLatencyMs,
+			Cost:             cost,
+		})
+
+		response.TotalTokens += summary.TotalTokens
+		response.TotalCost += cost
+		addUsageTotal(byFeature, summary.Feature, summary, cost)
+		addUsageTotal(byModel, summary.Model, summary, cost)
+	}
+
+	response.ByFeature = sortedUsageTotals(byFeature)
+	response.ByModel = sortedUsageTotals(byModel)
+
+	return response, nil
+}
+
+// cost prices a summary row, models without a configured price cost nothing.
+func (c *usageService) cost(summary *entity.LlmUsageSummary) float64 {
+	price, ok := c.pricing[summary.Model]
+	if !ok {
+		return 0
+	}
+
+	input := float64(summary.PromptTokens+summary.EmbeddingTokens) * price.InputPerMillion
+	output := float64(summary.CompletionTokens) * price.OutputPerMillion
+
+	return (input + output) / 1_000_000
+}
+
+func addUsageTotal(totals map[string]*dto.UsageReportTotal, key string, summary *entity.LlmUsageSummary, cost float64) {
+	total, ok := totals[key]
+	if !ok {
+		total = &dto.UsageReportTotal{Key: key}
+		totals[key] = total
+	}
+
+	total.Calls += summary.Calls
+	total.TotalTokens += summary.TotalTokens
+	total.Cost += cost
+}
+
+func sortedUsageTotals(totals map[string]*dto.UsageReportTotal) []*dto.UsageReportTotal {
+	res := make([]*dto.UsageReportTotal, 0, len(totals))
+	for _, total := range totals {
+		res = append(res, total)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Key < res[j].Key
+	})
+
+	return res
+}
+
+// chatUsage converts Gemini generateContent usage metadata into a usage row.
+func chatUsage(feature string, usage *chatbot.GeminiUsageMetadata, latency time.Duration) *entity.LlmUsage {
+	return &entity.LlmUsage{
+		Feature:          feature,
+		Model:            chatbot.GeminiChatModel,
+		PromptTokens:     usage.PromptTokenCount,
+		CompletionTokens: usage.CandidatesTokenCount + usage.ThoughtsTokenCount,
+		TotalTokens:      usage.TotalTokenCount,
+		LatencyMs:        latency.Milliseconds(),
+	}
+}
+
+// embeddingUsage converts embedContent usage metadata into a usage row. The
+// embedding endpoint does not always report tokens, in which case the count
+// is estimated at four characters per token.
+func embeddingUsage(feature string, text string, usage *embedding.EmbeddingUsageMetadata, latency time.Duration) *entity.LlmUsage {
+	llmUsage := &entity.LlmUsage{
+		Feature:   feature,
+		Model:     embedding.GeminiEmbeddingModel,
+		LatencyMs: latency.Milliseconds(),
+	}
+
+	if usage != nil {
+		llmUsage.EmbeddingTokens = usage.PromptTokenCount
+	} else {
+		llmUsage.EmbeddingTokens = (len(text) + 3) / 4
+		llmUsage.IsEstimated = true
+	}
+	llmUsage.TotalTokens = llmUsage.EmbeddingTokens
+
+	return llmUsage
+}
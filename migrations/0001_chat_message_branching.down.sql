@@ -0,0 +1,4 @@
+ALTER TABLE chat_session DROP COLUMN active_message_id;
+ALTER TABLE chat_message_raw DROP COLUMN chat_message_id;
+ALTER TABLE chat_message_raw DROP COLUMN parent_id;
+ALTER TABLE chat_message DROP COLUMN parent_id;
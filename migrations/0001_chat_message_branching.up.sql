@@ -0,0 +1,43 @@
+ALTER TABLE chat_message ADD COLUMN parent_id UUID REFERENCES chat_message (id);
+ALTER TABLE chat_message_raw ADD COLUMN parent_id UUID REFERENCES chat_message_raw (id);
+ALTER TABLE chat_message_raw ADD COLUMN chat_message_id UUID REFERENCES chat_message (id);
+ALTER TABLE chat_session ADD COLUMN active_message_id UUID REFERENCES chat_message (id) DEFERRABLE INITIALLY DEFERRED;
+
+CREATE INDEX idx_chat_message_parent_id ON chat_message (parent_id);
+CREATE INDEX idx_chat_message_raw_parent_id ON chat_message_raw (parent_id);
+
+-- Existing sessions are linear, so every message's parent is the previous one.
+UPDATE chat_message AS m SET parent_id = prev.parent_id
+FROM (
+    SELECT id, LAG(id) OVER (PARTITION BY chat_session_id ORDER BY created_at, role DESC) AS parent_id
+    FROM chat_message
+) AS prev
+WHERE m.id = prev.id;
+
+UPDATE chat_message_raw AS m SET parent_id = prev.parent_id
+FROM (
+    SELECT id, LAG(id) OVER (PARTITION BY chat_session_id ORDER BY created_at, role DESC) AS parent_id
+    FROM chat_message_raw
+) AS prev
+WHERE m.id = prev.id;
+
+-- Pair raw messages with visible ones by position. The two initial raw prompts
+-- map onto the single greeting message, so the raw side is offset by one.
+UPDATE chat_message_raw AS r SET chat_message_id = m.id
+FROM (
+    SELECT id, chat_session_id, ROW_NUMBER() OVER (PARTITION BY chat_session_id ORDER BY created_at, role DESC) + 1 AS position
+    FROM chat_message
+) AS m,
+(
+    SELECT id, chat_session_id, ROW_NUMBER() OVER (PARTITION BY chat_session_id ORDER BY created_at, role DESC) AS position
+    FROM chat_message_raw
+) AS rp
+WHERE r.id = rp.id AND rp.chat_session_id = m.chat_session_id AND rp.position = m.position;
+
+UPDATE chat_session AS s SET active_message_id = last.id
+FROM (
+    SELECT DISTINCT ON (chat_session_id) id, chat_session_id
+    FROM chat_message
+    ORDER BY chat_session_id, created_at DESC, role ASC
+) AS last
+WHERE s.id = last.chat_session_id;
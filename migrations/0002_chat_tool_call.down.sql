@@ -0,0 +1 @@
+DROP TABLE chat_tool_call;
@@ -0,0 +1,15 @@
+CREATE TABLE chat_tool_call (
+    id UUID PRIMARY KEY,
+    chat_session_id UUID NOT NULL REFERENCES chat_session (id),
+    chat_message_id UUID NOT NULL REFERENCES chat_message (id),
+    tool_name VARCHAR(100) NOT NULL,
+    arguments JSONB NOT NULL,
+    result JSONB,
+    status VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP,
+    deleted_at TIMESTAMP,
+    is_deleted BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE INDEX idx_chat_tool_call_chat_session_id ON chat_tool_call (chat_session_id);
@@ -0,0 +1,12 @@
+CREATE TABLE chat_retrieval_plan (
+    id UUID PRIMARY KEY,
+    chat_session_id UUID NOT NULL REFERENCES chat_session (id),
+    chat_message_id UUID NOT NULL REFERENCES chat_message (id),
+    should_retrieve BOOLEAN NOT NULL,
+    standalone_query TEXT NOT NULL,
+    sub_queries TEXT[] NOT NULL DEFAULT '{}',
+    reasoning TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_chat_retrieval_plan_chat_message_id ON chat_retrieval_plan (chat_message_id);
@@ -0,0 +1,3 @@
+ALTER TABLE chat_session DROP COLUMN prompt_template_version_id;
+DROP TABLE prompt_template_version;
+DROP TABLE prompt_template;
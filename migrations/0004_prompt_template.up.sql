@@ -0,0 +1,66 @@
+CREATE TABLE prompt_template (
+    id UUID PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    is_default BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP,
+    deleted_at TIMESTAMP,
+    is_deleted BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE UNIQUE INDEX idx_prompt_template_name ON prompt_template (name) WHERE is_deleted = false;
+CREATE UNIQUE INDEX idx_prompt_template_default ON prompt_template (is_default) WHERE is_default = true AND is_deleted = false;
+
+CREATE TABLE prompt_template_version (
+    id UUID PRIMARY KEY,
+    prompt_template_id UUID NOT NULL REFERENCES prompt_template (id),
+    version INT NOT NULL,
+    user_prompt TEXT NOT NULL,
+    model_prompt TEXT NOT NULL,
+    variables TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (prompt_template_id, version)
+);
+
+ALTER TABLE chat_session ADD COLUMN prompt_template_version_id UUID REFERENCES prompt_template_version (id);
+
+-- Built-in personas. "default" carries the prompts that used to be hardcoded.
+WITH templates (name, description, is_default, user_prompt, model_prompt) AS (
+    VALUES
+    (
+        'default',
+        'General assistant that answers from the referenced notes.',
+        true,
+        'You are a chatbot assistant that will answer your user question based on references provided. You must answer based on user next chat language even the reference is in different language. There reference I provide will have reference number, never recall the reference using number since the number is only for raw chat session. This chat session is raw session that will be formatted again later. I''ll give you reference before you answering, you can mention again the reference if you need to. You must answer don''t know if you don''t have enough reference.',
+        'Understood. I will answer your questions based solely on the provided references, and I will indicate if I do not have enough information to answer. I will also adapt my responses to the language you use in your subsequent turns. I will not refer to the references by their numbers.'
+    ),
+    (
+        'tutor',
+        'Explains step by step and checks understanding, like a patient tutor.',
+        false,
+        'You are a patient tutor helping the user study their own notes. I will give you numbered references before each question, never mention the reference numbers. Explain concepts step by step in the user''s language, use small examples, and end with one short question that checks the user understood. If the references are not enough, say so instead of guessing.',
+        'Understood. I will teach step by step from the provided references, check your understanding at the end of each answer, reply in your language, and tell you when the references are not enough.'
+    ),
+    (
+        'summarizer',
+        'Answers with short, structured summaries.',
+        false,
+        'You are an assistant that summarizes the user''s notes. I will give you numbered references before each question, never mention the reference numbers. Answer in the user''s language with a short structured summary: a one sentence overview followed by at most five bullet points. Do not add information that is not in the references.',
+        'Understood. I will answer with a one sentence overview and at most five bullet points, only from the provided references, in your language.'
+    ),
+    (
+        'strict-citations',
+        'Only states facts that are backed by a quoted reference.',
+        false,
+        'You are a careful research assistant. I will give you numbered references before each question. Every claim in your answer must be backed by a short quote from the references, written in quotation marks right after the claim, without mentioning reference numbers. If a claim cannot be backed by a quote, leave it out. If nothing in the references answers the question, reply that you do not know. Answer in the user''s language.',
+        'Understood. I will only state what I can back with a quote from the references, leave out anything I cannot support, and say I do not know when the references do not answer the question.'
+    )
+), inserted AS (
+    INSERT INTO prompt_template (id, name, description, is_default, created_at)
+    SELECT gen_random_uuid(), name, description, is_default, NOW() FROM templates
+    RETURNING id, name
+)
+INSERT INTO prompt_template_version (id, prompt_template_id, version, user_prompt, model_prompt, created_at)
+SELECT gen_random_uuid(), inserted.id, 1, templates.user_prompt, templates.model_prompt, NOW()
+FROM inserted JOIN templates ON templates.name = inserted.name;
@@ -0,0 +1,16 @@
+CREATE TABLE llm_usage (
+    id UUID PRIMARY KEY,
+    feature VARCHAR(50) NOT NULL,
+    model VARCHAR(100) NOT NULL,
+    prompt_tokens INT NOT NULL DEFAULT 0,
+    completion_tokens INT NOT NULL DEFAULT 0,
+    embedding_tokens INT NOT NULL DEFAULT 0,
+    total_tokens INT NOT NULL DEFAULT 0,
+    is_estimated BOOLEAN NOT NULL DEFAULT false,
+    note_id UUID,
+    chat_session_id UUID,
+    latency_ms BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_llm_usage_created_at ON llm_usage (created_at);
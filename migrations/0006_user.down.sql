@@ -0,0 +1,8 @@
+ALTER TABLE llm_usage DROP COLUMN user_id;
+ALTER TABLE chat_session DROP COLUMN user_id;
+ALTER TABLE file DROP COLUMN user_id;
+ALTER TABLE note DROP COLUMN user_id;
+ALTER TABLE notebook DROP COLUMN user_id;
+
+DROP TABLE refresh_token;
+DROP TABLE "user";
@@ -0,0 +1,36 @@
+CREATE TABLE "user" (
+    id UUID PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    password_hash TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP,
+    deleted_at TIMESTAMP,
+    is_deleted BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE UNIQUE INDEX idx_user_email ON "user" (lower(email)) WHERE is_deleted = false;
+
+CREATE TABLE refresh_token (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES "user" (id),
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_refresh_token_user_id ON refresh_token (user_id);
+
+-- Rows created before accounts existed have no owner and are hidden from
+-- everyone. Assign them to an account with UPDATE ... SET user_id = '<id>'.
+ALTER TABLE notebook ADD COLUMN user_id UUID REFERENCES "user" (id);
+ALTER TABLE note ADD COLUMN user_id UUID REFERENCES "user" (id);
+ALTER TABLE file ADD COLUMN user_id UUID REFERENCES "user" (id);
+ALTER TABLE chat_session ADD COLUMN user_id UUID REFERENCES "user" (id);
+ALTER TABLE llm_usage ADD COLUMN user_id UUID REFERENCES "user" (id);
+
+CREATE INDEX idx_notebook_user_id ON notebook (user_id);
+CREATE INDEX idx_note_user_id ON note (user_id);
+CREATE INDEX idx_file_user_id ON file (user_id);
+CREATE INDEX idx_chat_session_user_id ON chat_session (user_id);
+CREATE INDEX idx_llm_usage_user_id ON llm_usage (user_id);
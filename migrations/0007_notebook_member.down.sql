@@ -0,0 +1,2 @@
+DROP TABLE notebook_invitation;
+DROP TABLE notebook_member;
@@ -0,0 +1,28 @@
+CREATE TABLE notebook_member (
+    id UUID PRIMARY KEY,
+    notebook_id UUID NOT NULL REFERENCES notebook (id),
+    user_id UUID NOT NULL REFERENCES "user" (id),
+    role VARCHAR(32) NOT NULL,
+    created_by UUID REFERENCES "user" (id),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP
+);
+
+-- A grant applies to the notebook and every descendant of it, the effective
+-- role is the highest grant found on the way up the parent_id tree.
+CREATE UNIQUE INDEX idx_notebook_member_notebook_user ON notebook_member (notebook_id, user_id);
+CREATE INDEX idx_notebook_member_user_id ON notebook_member (user_id);
+
+CREATE TABLE notebook_invitation (
+    id UUID PRIMARY KEY,
+    notebook_id UUID NOT NULL REFERENCES notebook (id),
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(32) NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    invited_by UUID NOT NULL REFERENCES "user" (id),
+    created_at TIMESTAMP NOT NULL,
+    responded_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_notebook_invitation_pending ON notebook_invitation (notebook_id, lower(email)) WHERE status = 'pending';
+CREATE INDEX idx_notebook_invitation_email ON notebook_invitation (lower(email));
@@ -0,0 +1,17 @@
+CREATE TABLE api_token (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES "user" (id),
+    name VARCHAR(255) NOT NULL,
+    -- Only the SHA-256 of the token is stored, the prefix lets users tell
+    -- their tokens apart.
+    token_hash VARCHAR(64) NOT NULL,
+    token_prefix VARCHAR(32) NOT NULL,
+    scopes TEXT[] NOT NULL,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_api_token_token_hash ON api_token (token_hash);
+CREATE INDEX idx_api_token_user_id ON api_token (user_id);
@@ -0,0 +1,31 @@
+-- audit_event has no foreign keys so the history outlives the entities and
+-- users it talks about.
+CREATE TABLE audit_event (
+    id UUID PRIMARY KEY,
+    actor_id UUID,
+    action VARCHAR(32) NOT NULL,
+    entity_type VARCHAR(64) NOT NULL,
+    entity_id UUID NOT NULL,
+    notebook_id UUID,
+    note_id UUID,
+    -- Changed fields as {"Field": {"before": ..., "after": ...}}.
+    diff JSONB NOT NULL DEFAULT '{}',
+    request_id VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_audit_event_created_at ON audit_event (created_at DESC);
+CREATE INDEX idx_audit_event_actor_id ON audit_event (actor_id, created_at DESC);
+CREATE INDEX idx_audit_event_entity ON audit_event (entity_type, entity_id, created_at DESC);
+CREATE INDEX idx_audit_event_notebook_id ON audit_event (notebook_id, created_at DESC);
+CREATE INDEX idx_audit_event_note_id ON audit_event (note_id, created_at DESC);
+
+CREATE FUNCTION audit_event_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_event is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_audit_event_append_only
+    BEFORE UPDATE OR DELETE ON audit_event
+    FOR EACH ROW EXECUTE FUNCTION audit_event_append_only();
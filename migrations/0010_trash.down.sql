@@ -0,0 +1,5 @@
+-- The file soft delete columns stay, the code before this migration ignores
+-- them.
+DROP INDEX idx_chat_session_trash;
+DROP INDEX idx_notebook_trash;
+DROP INDEX idx_note_trash;
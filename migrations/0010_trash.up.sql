@@ -0,0 +1,9 @@
+-- The base schema is not part of these migrations, file may predate its
+-- soft delete columns.
+ALTER TABLE file ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE file ADD COLUMN IF NOT EXISTS is_deleted BOOLEAN NOT NULL DEFAULT false;
+
+-- Serve the trash listing and the retention purge.
+CREATE INDEX idx_note_trash ON note (deleted_at) WHERE is_deleted = true;
+CREATE INDEX idx_notebook_trash ON notebook (deleted_at) WHERE is_deleted = true;
+CREATE INDEX idx_chat_session_trash ON chat_session (user_id, deleted_at) WHERE is_deleted = true;
@@ -0,0 +1,14 @@
+CREATE TABLE note_revision (
+    id UUID PRIMARY KEY,
+    note_id UUID NOT NULL REFERENCES note (id),
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    -- One of manual, ai_extraction or import.
+    source VARCHAR(32) NOT NULL,
+    author_id UUID REFERENCES "user" (id),
+    -- Set when the revision was created by restoring an older one.
+    restored_from_id UUID REFERENCES note_revision (id),
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_note_revision_note_id ON note_revision (note_id, created_at DESC);
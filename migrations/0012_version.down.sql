@@ -0,0 +1,2 @@
+ALTER TABLE notebook DROP COLUMN version;
+ALTER TABLE note DROP COLUMN version;
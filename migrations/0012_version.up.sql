@@ -0,0 +1,3 @@
+-- Bumped on every update, clients send it back in If-Match.
+ALTER TABLE note ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE notebook ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+DROP TABLE chat_session_tag;
+DROP TABLE note_tag;
+DROP TABLE tag;
@@ -0,0 +1,30 @@
+CREATE TABLE tag (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES "user" (id),
+    name VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP
+);
+
+-- Tags belong to the user who created them, names are case-insensitive.
+CREATE UNIQUE INDEX idx_tag_user_name ON tag (user_id, lower(name));
+
+-- Any editor of a note may attach their tags to it, everyone who can read
+-- the note sees them.
+CREATE TABLE note_tag (
+    note_id UUID NOT NULL REFERENCES note (id),
+    tag_id UUID NOT NULL REFERENCES tag (id),
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (note_id, tag_id)
+);
+
+CREATE INDEX idx_note_tag_tag_id ON note_tag (tag_id);
+
+-- Chat retrieval of a session only considers notes carrying all of its tags.
+CREATE TABLE chat_session_tag (
+    chat_session_id UUID NOT NULL REFERENCES chat_session (id),
+    tag_id UUID NOT NULL REFERENCES tag (id),
+    PRIMARY KEY (chat_session_id, tag_id)
+);
+
+CREATE INDEX idx_chat_session_tag_tag_id ON chat_session_tag (tag_id);
@@ -0,0 +1,24 @@
+CREATE TABLE note_suggestion (
+    id UUID PRIMARY KEY,
+    note_id UUID NOT NULL REFERENCES note (id),
+    -- The user whose tags and notebooks were considered, only they resolve
+    -- the suggestion.
+    user_id UUID NOT NULL REFERENCES "user" (id),
+    -- One of tag or notebook.
+    kind VARCHAR(32) NOT NULL,
+    -- A tag suggestion names an existing tag of the user, or a new tag by
+    -- tag_name alone.
+    tag_id UUID REFERENCES tag (id),
+    tag_name VARCHAR(64),
+    notebook_id UUID REFERENCES notebook (id),
+    confidence REAL NOT NULL,
+    -- One of pending, accepted or rejected. Rejected suggestions are kept so
+    -- that they are not suggested again.
+    status VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    resolved_at TIMESTAMP
+);
+
+CREATE INDEX idx_note_suggestion_note_user ON note_suggestion (note_id, user_id);
+CREATE INDEX idx_note_suggestion_tag_id ON note_suggestion (tag_id);
+CREATE INDEX idx_note_suggestion_notebook_id ON note_suggestion (notebook_id);
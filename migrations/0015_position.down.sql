@@ -0,0 +1,2 @@
+ALTER TABLE note DROP COLUMN position;
+ALTER TABLE notebook DROP COLUMN position;
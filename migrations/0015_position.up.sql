@@ -0,0 +1,25 @@
+-- Fractional index keys, compared byte by byte (COLLATE "C"). Notebooks are
+-- ordered among the notebooks of their parent, notes within their notebook.
+ALTER TABLE notebook ADD COLUMN position TEXT COLLATE "C";
+ALTER TABLE note ADD COLUMN position TEXT COLLATE "C";
+
+-- Keep the order listings used so far: notebooks by name, notes by creation.
+UPDATE notebook AS nb SET position = 'a' || lpad(ordered.n::text, 10, '0') || 'V'
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY name, id) AS n
+    FROM notebook
+) AS ordered
+WHERE nb.id = ordered.id;
+
+UPDATE note AS n SET position = 'a' || lpad(ordered.n::text, 10, '0') || 'V'
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY notebook_id ORDER BY created_at, id) AS n
+    FROM note
+) AS ordered
+WHERE n.id = ordered.id;
+
+ALTER TABLE notebook ALTER COLUMN position SET NOT NULL;
+ALTER TABLE note ALTER COLUMN position SET NOT NULL;
+
+CREATE INDEX idx_notebook_parent_position ON notebook (parent_id, position);
+CREATE INDEX idx_note_notebook_position ON note (notebook_id, position);
@@ -0,0 +1,16 @@
+-- Attachments are ordered within their note, by fractional index keys like
+-- notes and notebooks (see 0015_position).
+ALTER TABLE file ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+ALTER TABLE file ADD COLUMN position TEXT COLLATE "C";
+
+-- Keep the upload order.
+UPDATE file AS f SET position = 'a' || lpad(ordered.n::text, 10, '0') || 'V'
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY note_id ORDER BY created_at, id) AS n
+    FROM file
+) AS ordered
+WHERE f.id = ordered.id;
+
+ALTER TABLE file ALTER COLUMN position SET NOT NULL;
+
+CREATE INDEX idx_file_note_position ON file (note_id, position);
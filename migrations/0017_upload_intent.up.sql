@@ -0,0 +1,21 @@
+CREATE TABLE upload_intent (
+    id UUID PRIMARY KEY,
+    note_id UUID NOT NULL REFERENCES note (id),
+    -- Only the user who asked for the upload completes it.
+    user_id UUID NOT NULL REFERENCES "user" (id),
+    bucket VARCHAR(255) NOT NULL,
+    -- The staging key the presigned URL writes to. Completing copies it to
+    -- the file_name of the file row and deletes it.
+    file_name VARCHAR(255) NOT NULL,
+    original_name VARCHAR(255) NOT NULL,
+    -- Declared by the client and checked against the stored object.
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    -- One of pending or completed.
+    status VARCHAR(32) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX idx_upload_intent_note_id ON upload_intent (note_id);
@@ -0,0 +1,2 @@
+DROP TABLE upload_session_part;
+DROP TABLE upload_session;
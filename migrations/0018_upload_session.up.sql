@@ -0,0 +1,36 @@
+-- Resumable uploads, backed by S3 multipart uploads. The parts go through
+-- the API one at a time, so an interrupted upload resumes after the last
+-- stored part.
+CREATE TABLE upload_session (
+    id UUID PRIMARY KEY,
+    note_id UUID NOT NULL REFERENCES note (id),
+    -- Only the user who started the upload continues it.
+    user_id UUID NOT NULL REFERENCES "user" (id),
+    bucket VARCHAR(255) NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    original_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    -- Every part but the last has exactly part_size bytes.
+    part_size BIGINT NOT NULL,
+    -- The upload id S3 gave the multipart upload.
+    multipart_upload_id VARCHAR(1024) NOT NULL,
+    -- One of pending, completing, completed or aborted.
+    status VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    -- Last activity, stale pending sessions are aborted.
+    updated_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX idx_upload_session_note_id ON upload_session (note_id);
+CREATE INDEX idx_upload_session_status_updated_at ON upload_session (status, updated_at);
+
+CREATE TABLE upload_session_part (
+    upload_session_id UUID NOT NULL REFERENCES upload_session (id),
+    part_number INT NOT NULL,
+    etag VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (upload_session_id, part_number)
+);
@@ -0,0 +1,3 @@
+DROP TABLE file_blob;
+
+ALTER TABLE file DROP COLUMN sha256;
@@ -0,0 +1,19 @@
+-- Identical uploads share one object in storage. sha256 is the hex digest
+-- of the content, null for files uploaded before deduplication.
+ALTER TABLE file ADD COLUMN sha256 CHAR(64);
+
+CREATE INDEX idx_file_sha256 ON file (sha256);
+
+-- A stored object and how many file rows, trashed ones included, point at
+-- it. The object is deleted when the last of them is. Objects of files
+-- without sha256 have no row and belong to their single file.
+CREATE TABLE file_blob (
+    bucket VARCHAR(255) NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    ref_count INT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (bucket, file_name)
+);
+
+CREATE INDEX idx_file_blob_bucket_sha256 ON file_blob (bucket, sha256);
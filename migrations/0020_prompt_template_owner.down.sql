@@ -0,0 +1,9 @@
+DROP INDEX idx_prompt_template_user_default;
+DROP INDEX idx_prompt_template_default;
+DROP INDEX idx_prompt_template_user_name;
+DROP INDEX idx_prompt_template_name;
+
+ALTER TABLE prompt_template DROP COLUMN user_id;
+
+CREATE UNIQUE INDEX idx_prompt_template_name ON prompt_template (name) WHERE is_deleted = false;
+CREATE UNIQUE INDEX idx_prompt_template_default ON prompt_template (is_default) WHERE is_default = true AND is_deleted = false;
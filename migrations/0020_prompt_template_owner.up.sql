@@ -0,0 +1,13 @@
+-- Templates belong to the user who created them. Templates without an owner
+-- are built-ins, readable by everyone and changed only through migrations.
+-- Templates created before owners existed cannot be attributed and stay
+-- built-ins.
+ALTER TABLE prompt_template ADD COLUMN user_id UUID REFERENCES "user" (id);
+
+DROP INDEX idx_prompt_template_name;
+DROP INDEX idx_prompt_template_default;
+
+CREATE UNIQUE INDEX idx_prompt_template_name ON prompt_template (name) WHERE user_id IS NULL AND is_deleted = false;
+CREATE UNIQUE INDEX idx_prompt_template_user_name ON prompt_template (user_id, name) WHERE user_id IS NOT NULL AND is_deleted = false;
+CREATE UNIQUE INDEX idx_prompt_template_default ON prompt_template (is_default) WHERE user_id IS NULL AND is_default = true AND is_deleted = false;
+CREATE UNIQUE INDEX idx_prompt_template_user_default ON prompt_template (user_id) WHERE user_id IS NOT NULL AND is_default = true AND is_deleted = false;
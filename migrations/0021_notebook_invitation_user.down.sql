@@ -0,0 +1,3 @@
+DROP INDEX idx_notebook_invitation_user;
+
+ALTER TABLE notebook_invitation DROP COLUMN user_id;
@@ -0,0 +1,13 @@
+-- Invitations go to an existing account. Matching the email at acceptance let
+-- whoever registered the address first take the role, registration does not
+-- verify emails.
+ALTER TABLE notebook_invitation ADD COLUMN user_id UUID REFERENCES "user" (id);
+
+-- Pending invitations are kept for accounts that existed when they were sent.
+UPDATE notebook_invitation i SET user_id = u.id
+FROM "user" u
+WHERE i.status = 'pending' AND lower(u.email) = lower(i.email) AND u.is_deleted = false AND u.created_at <= i.created_at;
+
+UPDATE notebook_invitation SET status = 'revoked', responded_at = NOW() WHERE status = 'pending' AND user_id IS NULL;
+
+CREATE INDEX idx_notebook_invitation_user ON notebook_invitation (user_id) WHERE status = 'pending';
@@ -0,0 +1,5 @@
+DROP INDEX idx_file_blob_user_sha256;
+
+CREATE INDEX idx_file_blob_bucket_sha256 ON file_blob (bucket, sha256);
+
+ALTER TABLE file_blob DROP COLUMN user_id;
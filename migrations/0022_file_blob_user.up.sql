@@ -0,0 +1,17 @@
+-- Identical uploads only share an object when the same user stored them.
+-- Reusing the object of another user trusted content they controlled, and
+-- told the uploader that someone already had the file.
+ALTER TABLE file_blob ADD COLUMN user_id UUID REFERENCES "user" (id);
+
+-- Objects already shared between users stay shared, they belong to whoever
+-- stored them first.
+UPDATE file_blob b SET user_id = (
+    SELECT f.user_id FROM file f
+    WHERE f.bucket = b.bucket AND f.file_name = b.file_name
+    ORDER BY f.created_at ASC
+    LIMIT 1
+);
+
+DROP INDEX idx_file_blob_bucket_sha256;
+
+CREATE INDEX idx_file_blob_user_sha256 ON file_blob (bucket, user_id, sha256);
@@ -1,17 +1,26 @@
 package chatbot
 
 import (
-	"bytes"
+	"ai-notetaking-be/pkg/llmclient"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
+	"time"
+)
+
+const (
+	GeminiChatModel       = "gemini-2.5-flash"
+	GeminiChatLegacyModel = "gemini-1.5-flash"
+
+	// geminiChatTimeout bounds one generateContent attempt, thinking models
+	// can take a while on long prompts.
+	geminiChatTimeout = 90 * time.Second
 )
 
 type GeminiChatParts struct {
-	Text string `json:"text"`
+	Text             string                  `json:"text,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
 }
 
 type GeminiChatContent struct {
@@ -22,37 +31,65 @@ type GeminiChatContent struct {
 type GeminiChatRequest struct {
 	Contents         []*GeminiChatContent        `json:"contents"`
 	GeneretionConfig *GeminiChatGeneretionConfig `json:"generationConfig"`
+	Tools            []*GeminiTool               `json:"tools,omitempty"`
 }
 
 type GeminiChatCandidate struct {
-	Content *GeminiChatContent `json:"content"`
+	Content      *GeminiChatContent `json:"content"`
+	FinishReason string             `json:"finishReason"`
 }
 
-type GeminiChatResponse struct {
-	Candidates []*GeminiChatCandidate `json:"candidates"`
+type GeminiPromptFeedback struct {
+	BlockReason string `json:"blockReason"`
 }
 
-type GeminiChatPropertySchema struct {
-	Type string `json:"type"`
+type GeminiChatResponse struct {
+	Candidates     []*GeminiChatCandidate `json:"candidates"`
+	PromptFeedback *GeminiPromptFeedback  `json:"promptFeedback"`
+	UsageMetadata  *GeminiUsageMetadata   `json:"usageMetadata"`
 }
 
-type GeminiChatAppSchema struct {
-	AnswerDirectly *GeminiChatPropertySchema `json:"answer_directly"`
+// GeminiUsageMetadata is the token accounting Gemini returns with every
+// generateContent response. Thinking tokens are billed as output tokens.
+type GeminiUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
 }
 
-type GeminiChatResponseSchema struct {
-	Type       string               `json:"type"`
-	Properties *GeminiChatAppSchema `json:"properties"`
-	Required   []string             `json:"required"`
+// Usage returns the response usage, or an empty one when Gemini omitted it.
+func (r *GeminiChatResponse) Usage() *GeminiUsageMetadata {
+	if r.UsageMetadata == nil {
+		return &GeminiUsageMetadata{}
+	}
+
+	return r.UsageMetadata
 }
 
 type GeminiChatGeneretionConfig struct {
-	ResponseMimeType string                    `json:"responseMimeType"`
-	ResponseSchema   *GeminiChatResponseSchema `json:"responseSchema"`
+	ResponseMimeType string        `json:"responseMimeType"`
+	ResponseSchema   *GeminiSchema `json:"responseSchema"`
 }
 
-type GeminiResponseAppSchema struct {
-	AnswerDirectly bool `json:"answer_directly"`
+// RetrievalPlan is the planner's decision for one user message: whether the
+// notes should be searched at all and which standalone queries to embed.
+type RetrievalPlan struct {
+	ShouldRetrieve  bool     `json:"should_retrieve"`
+	StandaloneQuery string   `json:"standalone_query"`
+	SubQueries      []string `json:"sub_queries"`
+	Reasoning       string   `json:"reasoning"`
+}
+
+// TagSuggestion is a tag the model proposes for a note, Confidence goes from
+// 0 to 1.
+type TagSuggestion struct {
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+}
+
+type TagSuggestions struct {
+	Tags []*TagSuggestion `json:"tags"`
 }
 
 type ChatHistory struct {
@@ -60,12 +97,56 @@ type ChatHistory struct {
 	Role string
 }
 
-func GetGeminiResponse(
-	ctx context.Context,
-	apiKey string,
-	chatHistories []*ChatHistory,
-) (string, error) {
+// geminiBlockedFinishReasons are the finish reasons of a candidate that was
+// cut off by Gemini's safety filters.
+var geminiBlockedFinishReasons = map[string]bool{
+	"SAFETY":             true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+	"RECITATION":         true,
+}
+
+// FirstContent returns the first candidate's content, or a typed error when
+// the prompt or the answer was blocked or Gemini returned nothing.
+func (r *GeminiChatResponse) FirstContent() (*GeminiChatContent, error) {
+	if r.PromptFeedback != nil && r.PromptFeedback.BlockReason != "" {
+		return nil, fmt.Errorf("%w: prompt blocked, reason %s", llmclient.ErrSafetyBlocked, r.PromptFeedback.BlockReason)
+	}
 
+	if len(r.Candidates) == 0 {
+		return nil, llmclient.ErrEmptyResponse
+	}
+
+	candidate := r.Candidates[0]
+	if geminiBlockedFinishReasons[candidate.FinishReason] {
+		return nil, fmt.Errorf("%w: answer blocked, reason %s", llmclient.ErrSafetyBlocked, candidate.FinishReason)
+	}
+
+	if candidate.Content == nil || len(candidate.Content.Parts) == 0 {
+		return nil, llmclient.ErrEmptyResponse
+	}
+
+	return candidate.Content, nil
+}
+
+// generateContent calls generateContent of model through the shared client.
+func generateContent(ctx context.Context, apiKey string, model string, payload *GeminiChatRequest) (*GeminiChatResponse, error) {
+	var geminiRes GeminiChatResponse
+	err := llmclient.Default().PostJSON(ctx, &llmclient.Request{
+		URL:     "https://generativelanguage.googleapis.com/v1beta/models/" + model + ":generateContent",
+		APIKey:  apiKey,
+		Payload: payload,
+		Timeout: geminiChatTimeout,
+	}, &geminiRes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &geminiRes, nil
+}
+
+func toGeminiChatContents(chatHistories []*ChatHistory) []*GeminiChatContent {
 	chatContents := make([]*GeminiChatContent, 0)
 	for _, chatHistory := range chatHistories {
 		chatContents = append(chatContents, &GeminiChatContent{
@@ -78,207 +159,149 @@ func GetGeminiResponse(
 		})
 	}
 
-	payload := GeminiChatRequest{
-		Contents: chatContents,
-	}
-
-	payloadJson, err := json.Marshal(payload)
-	if err != nil {
-		return "", err
-	}
-
-	req, err := http.NewRequest(
-		"POST",
-		"https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-flash:generateContent",
-		bytes.NewBuffer(payloadJson),
-	)
-	if err != nil {
-		return "", err
-	}
+	return chatContents
+}
 
-	req.Header.Set("x-goog-api-key", apiKey)
-	req.Header.Set("Content-Type", "application/json")
+func GetGeminiResponse(
+	ctx context.Context,
+	apiKey string,
+	chatHistories []*ChatHistory,
+) (string, *GeminiUsageMetadata, error) {
 
-	client := &http.Client{}
-	res, err := client.Do(req)
-	if err != nil {
-		return "", err
+	payload := GeminiChatRequest{
+		Contents: toGeminiChatContents(chatHistories),
 	}
 
-	resBody, err := io.ReadAll(res.Body)
+	geminiRes, err := generateContent(ctx, apiKey, GeminiChatModel, &payload)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	if res.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("status error, got status %d. with response body %s", res.StatusCode, string(resBody))
-	}
-
-	var geminiRes GeminiChatResponse
-	err = json.Unmarshal(resBody, &geminiRes)
+	content, err := geminiRes.FirstContent()
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	return geminiRes.Candidates[0].Content.Parts[0].Text, nil
+	return content.Text(), geminiRes.Usage(), nil
 
 }
 
-func DecideToUseRAG(
+func PlanRetrieval(
 	ctx context.Context,
 	apiKey string,
 	chatHistories []*ChatHistory,
-) (bool, error) {
-
-	chatContents := make([]*GeminiChatContent, 0)
-	for _, chatHistory := range chatHistories {
-		chatContents = append(chatContents, &GeminiChatContent{
-			Parts: []*GeminiChatParts{
-				{
-					Text: chatHistory.Chat,
-				},
-			},
-			Role: chatHistory.Role,
-		})
-	}
+) (*RetrievalPlan, *GeminiUsageMetadata, error) {
 
 	payload := GeminiChatRequest{
-		Contents: chatContents,
+		Contents: toGeminiChatContents(chatHistories),
 		GeneretionConfig: &GeminiChatGeneretionConfig{
 			ResponseMimeType: "application/json",
-			ResponseSchema: &GeminiChatResponseSchema{
+			ResponseSchema: &GeminiSchema{
 				Type: "OBJECT",
-				Properties: &GeminiChatAppSchema{
-					AnswerDirectly: &GeminiChatPropertySchema{
-						Type: "BOOLEAN",
-					},
+				Properties: map[string]*GeminiSchema{
+					"should_retrieve":  {Type: "BOOLEAN"},
+					"standalone_query": {Type: "STRING"},
+					"sub_queries":      {Type: "ARRAY", Items: &GeminiSchema{Type: "STRING"}},
+					"reasoning":        {Type: "STRING"},
 				},
 				Required: []string{
-					"answer_directly",
+					"should_retrieve",
+					"standalone_query",
+					"reasoning",
 				},
 			},
 		},
 	}
 
-	payloadJson, err := json.Marshal(payload)
+	geminiRes, err := generateContent(ctx, apiKey, GeminiChatModel, &payload)
 	if err != nil {
-		return false, err
+		return nil, nil, err
 	}
 
-	req, err := http.NewRequest(
-		"POST",
-		"https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-flash:generateContent",
-		bytes.NewBuffer(payloadJson),
-	)
-	if err != nil {
-		return false, err
-	}
-
-	req.Header.Set("x-goog-api-key", apiKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	res, err := client.Do(req)
+	content, err := geminiRes.FirstContent()
 	if err != nil {
-		return false, err
+		return nil, nil, err
 	}
 
-	resBody, err := io.ReadAll(res.Body)
+	var plan RetrievalPlan
+	err = json.Unmarshal([]byte(content.Text()), &plan)
 	if err != nil {
-		return false, err
+		return nil, nil, fmt.Errorf("failed to unmarshal retrieval plan: %w", err)
 	}
 
-	if res.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("status error, got status %d. with response body %s", res.StatusCode, string(resBody))
-	}
-
-	var geminiRes GeminiChatResponse
-	err = json.Unmarshal(resBody, &geminiRes)
-	if err != nil {
-		return false, err
-	}
-
-	var appSchema GeminiResponseAppSchema
-	err = json.Unmarshal([]byte(geminiRes.Candidates[0].Content.Parts[0].Text), &appSchema.AnswerDirectly)
-
-	log.Printf("Use RAG: %v", !appSchema.AnswerDirectly)
-
-	return !appSchema.AnswerDirectly, nil
-
+	return &plan, geminiRes.Usage(), nil
 }
 
-func GetGeminiChatResponse(
+func SuggestTags(
 	ctx context.Context,
 	apiKey string,
 	chatHistories []*ChatHistory,
-) (string, error) {
+) (*TagSuggestions, *GeminiUsageMetadata, error) {
 
-	// 1. Konversi ChatHistory ke format Gemini
-	chatContents := make([]*GeminiChatContent, 0)
-	for _, chatHistory := range chatHistories {
-		chatContents = append(chatContents, &GeminiChatContent{
-			Parts: []*GeminiChatParts{
-				{
-					Text: chatHistory.Chat,
+	payload := GeminiChatRequest{
+		Contents: toGeminiChatContents(chatHistories),
+		GeneretionConfig: &GeminiChatGeneretionConfig{
+			ResponseMimeType: "application/json",
+			ResponseSchema: &GeminiSchema{
+				Type: "OBJECT",
+				Properties: map[string]*GeminiSchema{
+					"tags": {
+						Type: "ARRAY",
+						Items: &GeminiSchema{
+							Type: "OBJECT",
+							Properties: map[string]*GeminiSchema{
+								"name":       {Type: "STRING"},
+								"confidence": {Type: "NUMBER"},
+							},
+							Required: []string{"name", "confidence"},
+						},
+					},
 				},
+				Required: []string{"tags"},
 			},
-			Role: chatHistory.Role,
-		})
-	}
-
-	// 2. Buat Payload (Tanpa GenerationConfig yang mengunci JSON)
-	payload := GeminiChatRequest{
-		Contents: chatContents,
+		},
 	}
 
-	payloadJson, err := json.Marshal(payload)
+	geminiRes, err := generateContent(ctx, apiKey, GeminiChatModel, &payload)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal payload: %w", err)
+		return nil, nil, err
 	}
 
-	// 3. Buat HTTP Request
-	// Menggunakan gemini-1.5-flash untuk stabilitas dan kecepatan
-	req, err := http.NewRequest(
-		"POST",
-		"https://generativelanguage.googleapis.com/v1beta/models/gemini-1.5-flash:generateContent",
-		bytes.NewBuffer(payloadJson),
-	)
+	content, err := geminiRes.FirstContent()
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, nil, err
 	}
 
-	req.Header.Set("x-goog-api-key", apiKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	// 4. Eksekusi Request
-	client := &http.Client{}
-	res, err := client.Do(req)
+	var suggestions TagSuggestions
+	err = json.Unmarshal([]byte(content.Text()), &suggestions)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return nil, nil, fmt.Errorf("failed to unmarshal tag suggestions: %w", err)
 	}
-	defer res.Body.Close()
 
-	resBody, err := io.ReadAll(res.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read body: %w", err)
-	}
+	return &suggestions, geminiRes.Usage(), nil
+}
 
-	// 5. Handling Error Status
-	if res.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("gemini api error: status %d, body: %s", res.StatusCode, string(resBody))
+func GetGeminiChatResponse(
+	ctx context.Context,
+	apiKey string,
+	chatHistories []*ChatHistory,
+) (string, *GeminiUsageMetadata, error) {
+
+	// Tanpa GenerationConfig yang mengunci JSON, menggunakan gemini-1.5-flash
+	// untuk stabilitas dan kecepatan
+	payload := GeminiChatRequest{
+		Contents: toGeminiChatContents(chatHistories),
 	}
 
-	// 6. Unmarshal dan Ambil Teks
-	var geminiRes GeminiChatResponse
-	err = json.Unmarshal(resBody, &geminiRes)
+	geminiRes, err := generateContent(ctx, apiKey, GeminiChatLegacyModel, &payload)
 	if err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		return "", nil, err
 	}
 
-	// Validasi apakah ada kandidat jawaban
-	if len(geminiRes.Candidates) == 0 || len(geminiRes.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("empty response from gemini")
+	content, err := geminiRes.FirstContent()
+	if err != nil {
+		return "", nil, err
 	}
 
-	return geminiRes.Candidates[0].Content.Parts[0].Text, nil
+	return content.Text(), geminiRes.Usage(), nil
 }
@@ -0,0 +1,87 @@
+package chatbot
+
+import (
+	"context"
+)
+
+type GeminiSchema struct {
+	Type        string                   `json:"type"`
+	Description string                   `json:"description,omitempty"`
+	Properties  map[string]*GeminiSchema `json:"properties,omitempty"`
+	Items       *GeminiSchema            `json:"items,omitempty"`
+	Required    []string                 `json:"required,omitempty"`
+}
+
+type GeminiFunctionDeclaration struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Parameters  *GeminiSchema `json:"parameters,omitempty"`
+}
+
+type GeminiTool struct {
+	FunctionDeclarations []*GeminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type GeminiFunctionCall struct {
+	Name string         `json:"name"`
+	Args map[string]any `json:"args"`
+}
+
+type GeminiFunctionResponse struct {
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+// FunctionCalls returns every function call the model asked for in this turn.
+func (c *GeminiChatContent) FunctionCalls() []*GeminiFunctionCall {
+	calls := make([]*GeminiFunctionCall, 0)
+	for _, part := range c.Parts {
+		if part.FunctionCall != nil {
+			calls = append(calls, part.FunctionCall)
+		}
+	}
+
+	return calls
+}
+
+// Text joins every text part of this turn.
+func (c *GeminiChatContent) Text() string {
+	text := ""
+	for _, part := range c.Parts {
+		text += part.Text
+	}
+
+	return text
+}
+
+// GetGeminiToolResponse sends the conversation together with the declared
+// functions and returns the model turn as is, so the caller can either run
+// the requested function calls or read the final text answer.
+func GetGeminiToolResponse(
+	ctx context.Context,
+	apiKey string,
+	contents []*GeminiChatContent,
+	declarations []*GeminiFunctionDeclaration,
+) (*GeminiChatContent, *GeminiUsageMetadata, error) {
+
+	payload := GeminiChatRequest{
+		Contents: contents,
+		Tools: []*GeminiTool{
+			{
+				FunctionDeclarations: declarations,
+			},
+		},
+	}
+
+	geminiRes, err := generateContent(ctx, apiKey, GeminiChatModel, &payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := geminiRes.FirstContent()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return content, geminiRes.Usage(), nil
+}
@@ -0,0 +1,187 @@
+package crdt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+var (
+	ErrUnknownElement = errors.New("the operation refers to an unknown element")
+	ErrInvalidOp      = errors.New("the operation is malformed")
+)
+
+// Id identifies one character for the lifetime of a document. Clock is a
+// Lamport clock, so an element always has a larger Id than the elements that
+// existed when it was inserted.
+type Id struct {
+	Client string `json:"client"`
+	Clock  uint64 `json:"clock"`
+}
+
+func (a Id) after(b Id) bool {
+	if a.Clock != b.Clock {
+		return a.Clock > b.Clock
+	}
+
+	return a.Client > b.Client
+}
+
+// Op is an insert or a delete. An insert of several characters gives them
+// consecutive clocks starting at Id, each placed after the previous one. After
+// is nil for an insert at the start of the document.
+type Op struct {
+	Kind  string `json:"kind"`
+	Id    Id     `json:"id"`
+	After *Id    `json:"after,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// Element is a character of the document, deleted characters are kept as
+// tombstones so that operations referring to them still apply.
+type Element struct {
+	Id      Id     `json:"id"`
+	Value   string `json:"value"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// Document is a Replicated Growable Array of characters. Replicas applying
+// the same operations end up with the same text whatever the order of
+// concurrent operations, as long as every operation is applied after the ones
+// it refers to. Document is not safe for concurrent use.
+type Document struct {
+	elements []Element
+	clock    uint64
+}
+
+// NewDocument starts a document holding text, its characters are attributed
+// to client.
+func NewDocument(client string, text string) *Document {
+	d := &Document{}
+	if text != "" {
+		d.Apply(Op{Kind: OpInsert, Id: Id{Client: client, Clock: 1}, Value: text})
+	}
+
+	return d
+}
+
+// Apply integrates op. Inserting an Id the document already has is a no-op,
+// so redelivered operations are harmless.
+func (d *Document) Apply(op Op) error {
+	switch op.Kind {
+	case OpInsert:
+		return d.insert(op)
+	case OpDelete:
+		index := d.indexOf(op.Id)
+		if index < 0 {
+			return fmt.Errorf("%w: %s:%d", ErrUnknownElement, op.Id.Client, op.Id.Clock)
+		}
+		d.elements[index].Deleted = true
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidOp, op.Kind)
+	}
+}
+
+func (d *Document) insert(op Op) error {
+	if op.Value == "" || op.Id.Clock == 0 {
+		return fmt.Errorf("%w: insert needs a value and a clock", ErrInvalidOp)
+	}
+	if d.indexOf(op.Id) >= 0 {
+		return nil
+	}
+
+	position := 0
+	if op.After != nil {
+		index := d.indexOf(*op.After)
+		if index < 0 {
+			return fmt.Errorf("%w: %s:%d", ErrUnknownElement, op.After.Client, op.After.Clock)
+		}
+		position = index + 1
+	}
+
+	id := op.Id
+	for _, r := range op.Value {
+		// Concurrent inserts at the same place are ordered by descending Id,
+		// the elements that follow a larger Id were inserted after it too.
+		for position < len(d.elements) && d.elements[position].Id.after(id) {
+			position++
+		}
+
+		d.elements = append(d.elements, Element{})
+		copy(d.elements[position+1:], d.elements[position:])
+		d.elements[position] = Element{Id: id, Value: string(r)}
+
+		if id.Clock > d.clock {
+			d.clock = id.Clock
+		}
+
+		position++
+		id.Clock++
+	}
+
+	return nil
+}
+
+// Insert creates and applies the operation inserting text after the element
+// after, nil for the start of the document.
+func (d *Document) Insert(client string, after *Id, text string) (Op, error) {
+	op := Op{Kind: OpInsert, Id: Id{Client: client, Clock: d.clock + 1}, After: after, Value: text}
+	return op, d.Apply(op)
+}
+
+// Delete creates and applies the operation deleting the element id.
+func (d *Document) Delete(id Id) (Op, error) {
+	op := Op{Kind: OpDelete, Id: id}
+	return op, d.Apply(op)
+}
+
+// Text returns the visible characters.
+func (d *Document) Text() string {
+	var text strings.Builder
+	for _, element := range d.elements {
+		if !element.Deleted {
+			text.WriteString(element.Value)
+		}
+	}
+
+	return text.String()
+}
+
+// VisibleIds returns the Ids of the characters of Text, in order.
+func (d *Document) VisibleIds() []Id {
+	ids := make([]Id, 0, len(d.elements))
+	for _, element := range d.elements {
+		if !element.Deleted {
+			ids = append(ids, element.Id)
+		}
+	}
+
+	return ids
+}
+
+// Elements returns the whole state including tombstones, enough for another
+// replica to start from.
+func (d *Document) Elements() []Element {
+	return append([]Element(nil), d.elements...)
+}
+
+// Clock returns the largest clock seen, new operations must use larger ones.
+func (d *Document) Clock() uint64 {
+	return d.clock
+}
+
+func (d *Document) indexOf(id Id) int {
+	for i := range d.elements {
+		if d.elements[i].Id == id {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,253 @@
+package crdt
+
+import (
+	"errors"
+	"testing"
+)
+
+const seedClient = "seed"
+
+// The replicas all start from the same text, the first character is
+// seed:1 and the last seed:2.
+func newReplica() *Document {
+	return NewDocument(seedClient, "ac")
+}
+
+func TestConcurrentEditsConverge(t *testing.T) {
+	first := Id{Client: seedClient, Clock: 1}
+
+	tests := []struct {
+		name  string
+		edits map[string]func(t *testing.T, d *Document) []Op
+		want  string
+	}{
+		{
+			name: "inserts at the same place",
+			edits: map[string]func(t *testing.T, d *Document) []Op{
+				"A": func(t *testing.T, d *Document) []Op { return []Op{insert(t, d, "A", &first, "b")} },
+				"B": func(t *testing.T, d *Document) []Op { return []Op{insert(t, d, "B", &first, "x")} },
+			},
+			want: "axbc",
+		},
+		{
+			name: "multi-character inserts at the same place",
+			edits: map[string]func(t *testing.T, d *Document) []Op{
+				"A": func(t *testing.T, d *Document) []Op { return []Op{insert(t, d, "A", &first, "12")} },
+				"B": func(t *testing.T, d *Document) []Op { return []Op{insert(t, d, "B", &first, "xy")} },
+			},
+			want: "axy12c",
+		},
+		{
+			name: "inserts at the start",
+			edits: map[string]func(t *testing.T, d *Document) []Op{
+				"A": func(t *testing.T, d *Document) []Op { return []Op{insert(t, d, "A", nil, "x")} },
+				"B": func(t *testing.T, d *Document) []Op { return []Op{insert(t, d, "B", nil, "y")} },
+			},
+			want: "yxac",
+		},
+		{
+			name: "typing next to a concurrent insert",
+			edits: map[string]func(t *testing.T, d *Document) []Op{
+				"A": func(t *testing.T, d *Document) []Op {
+					op := insert(t, d, "A", &first, "1")
+					return []Op{op, insert(t, d, "A", &op.Id, "2")}
+				},
+				"B": func(t *testing.T, d *Document) []Op { return []Op{insert(t, d, "B", &first, "x")} },
+			},
+			want: "ax12c",
+		},
+		{
+			name: "insert after a concurrently deleted character",
+			edits: map[string]func(t *testing.T, d *Document) []Op{
+				"A": func(t *testing.T, d *Document) []Op { return []Op{remove(t, d, first)} },
+				"B": func(t *testing.T, d *Document) []Op { return []Op{insert(t, d, "B", &first, "b")} },
+			},
+			want: "bc",
+		},
+		{
+			name: "three clients",
+			edits: map[string]func(t *testing.T, d *Document) []Op{
+				"A": func(t *testing.T, d *Document) []Op { return []Op{insert(t, d, "A", &first, "1")} },
+				"B": func(t *testing.T, d *Document) []Op { return []Op{insert(t, d, "B", &first, "2")} },
+				"C": func(t *testing.T, d *Document) []Op {
+					return []Op{remove(t, d, Id{Client: seedClient, Clock: 2}), insert(t, d, "C", nil, "3")}
+				},
+			},
+			want: "3a21",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Every client edits its own replica without seeing the others.
+			var streams [][]Op
+			for client, edit := range tt.edits {
+				ops := edit(t, newReplica())
+				if len(ops) == 0 {
+					t.Fatalf("client %s made no edit", client)
+				}
+				streams = append(streams, ops)
+			}
+
+			for _, order := range interleavings(streams) {
+				d := newReplica()
+				for _, op := range order {
+					err := d.Apply(op)
+					if err != nil {
+						t.Fatalf("Apply(%+v) returned error: %v", op, err)
+					}
+				}
+
+				got := d.Text()
+				if got != tt.want {
+					t.Errorf("applying %+v gives %q, want %q", order, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		op      Op
+		want    string
+		wantErr error
+	}{
+		{
+			name: "insert",
+			op:   Op{Kind: OpInsert, Id: Id{Client: "A", Clock: 3}, After: &Id{Client: seedClient, Clock: 1}, Value: "b"},
+			want: "abc",
+		},
+		{
+			name: "redelivered insert",
+			op:   Op{Kind: OpInsert, Id: Id{Client: seedClient, Clock: 1}, Value: "z"},
+			want: "ac",
+		},
+		{
+			name: "delete",
+			op:   Op{Kind: OpDelete, Id: Id{Client: seedClient, Clock: 2}},
+			want: "a",
+		},
+		{
+			name:    "insert after an unknown element",
+			op:      Op{Kind: OpInsert, Id: Id{Client: "A", Clock: 3}, After: &Id{Client: "B", Clock: 1}, Value: "b"},
+			want:    "ac",
+			wantErr: ErrUnknownElement,
+		},
+		{
+			name:    "delete of an unknown element",
+			op:      Op{Kind: OpDelete, Id: Id{Client: "B", Clock: 1}},
+			want:    "ac",
+			wantErr: ErrUnknownElement,
+		},
+		{
+			name:    "insert without a value",
+			op:      Op{Kind: OpInsert, Id: Id{Client: "A", Clock: 3}},
+			want:    "ac",
+			wantErr: ErrInvalidOp,
+		},
+		{
+			name:    "insert without a clock",
+			op:      Op{Kind: OpInsert, Id: Id{Client: "A"}, Value: "b"},
+			want:    "ac",
+			wantErr: ErrInvalidOp,
+		},
+		{
+			name:    "unknown kind",
+			op:      Op{Kind: "move", Id: Id{Client: seedClient, Clock: 1}},
+			want:    "ac",
+			wantErr: ErrInvalidOp,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newReplica()
+			err := d.Apply(tt.op)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Apply(%+v) error = %v, want %v", tt.op, err, tt.wantErr)
+			}
+
+			got := d.Text()
+			if got != tt.want {
+				t.Errorf("Text() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInsertUsesLargerClocks(t *testing.T) {
+	d := newReplica()
+	op, err := d.Insert("A", nil, "xy")
+	if err != nil {
+		t.Fatalf("Insert returned error: %v", err)
+	}
+
+	if op.Id.Clock != 3 {
+		t.Errorf("Insert used clock %d, want 3", op.Id.Clock)
+	}
+	if d.Clock() != 4 {
+		t.Errorf("Clock() = %d, want 4", d.Clock())
+	}
+
+	want := []Id{{Client: "A", Clock: 3}, {Client: "A", Clock: 4}, {Client: seedClient, Clock: 1}, {Client: seedClient, Clock: 2}}
+	got := d.VisibleIds()
+	if len(got) != len(want) {
+		t.Fatalf("VisibleIds() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("VisibleIds() = %v, want %v", got, want)
+		}
+	}
+}
+
+func insert(t *testing.T, d *Document, client string, after *Id, text string) Op {
+	t.Helper()
+
+	op, err := d.Insert(client, after, text)
+	if err != nil {
+		t.Fatalf("Insert(%q, %v, %q) returned error: %v", client, after, text, err)
+	}
+
+	return op
+}
+
+func remove(t *testing.T, d *Document, id Id) Op {
+	t.Helper()
+
+	op, err := d.Delete(id)
+	if err != nil {
+		t.Fatalf("Delete(%v) returned error: %v", id, err)
+	}
+
+	return op
+}
+
+// interleavings returns every merge of the streams that keeps the order of
+// each stream, the orders in which a replica can receive them.
+func interleavings(streams [][]Op) [][]Op {
+	remaining := 0
+	for _, stream := range streams {
+		remaining += len(stream)
+	}
+	if remaining == 0 {
+		return [][]Op{nil}
+	}
+
+	var res [][]Op
+	for i, stream := range streams {
+		if len(stream) == 0 {
+			continue
+		}
+
+		rest := append([][]Op(nil), streams...)
+		rest[i] = stream[1:]
+		for _, tail := range interleavings(rest) {
+			res = append(res, append([]Op{stream[0]}, tail...))
+		}
+	}
+
+	return res
+}
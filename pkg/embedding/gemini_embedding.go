@@ -1,11 +1,16 @@
 package embedding
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
+	"ai-notetaking-be/pkg/llmclient"
+	"context"
+	"time"
+)
+
+const (
+	// GeminiEmbeddingModel is the model the embedContent endpoint is called with.
+	GeminiEmbeddingModel = "gemini-embedding-001"
+
+	geminiEmbeddingTimeout = 15 * time.Second
 )
 
 type EmbeddingRequestContentPart struct {
@@ -26,11 +31,20 @@ type EmbeddingResponseEmbedding struct {
 	Values []float32 `json:"values"`
 }
 
+// EmbeddingUsageMetadata is only sent by some embedding model versions, so
+// callers must handle it being nil.
+type EmbeddingUsageMetadata struct {
+	PromptTokenCount int `json:"promptTokenCount"`
+	TotalTokenCount  int `json:"totalTokenCount"`
+}
+
 type EmbeddingResponse struct {
-	Embedding EmbeddingResponseEmbedding `json:"embedding"`
+	Embedding     EmbeddingResponseEmbedding `json:"embedding"`
+	UsageMetadata *EmbeddingUsageMetadata    `json:"usageMetadata"`
 }
 
 func GetGeminiEmbedding(
+	ctx context.Context,
 	apiKey string,
 	modelName string,
 	text string,
@@ -49,42 +63,19 @@ func GetGeminiEmbedding(
 		TaskType: task_type,
 	}
 
-	geminiReqJson, err := json.Marshal(geminiReq)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest(
-		"Post",
-		"https://generativelanguage.googleapis.com/v1beta/models/gemini-embedding-001:embedContent",
-		bytes.NewBuffer(geminiReqJson),
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("x-goog-api-key", apiKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-
-	resBytes, err := io.ReadAll(res.Body)
+	var resEmbedding EmbeddingResponse
+	err := llmclient.Default().PostJSON(ctx, &llmclient.Request{
+		URL:     "https://generativelanguage.googleapis.com/v1beta/models/" + GeminiEmbeddingModel + ":embedContent",
+		APIKey:  apiKey,
+		Payload: geminiReq,
+		Timeout: geminiEmbeddingTimeout,
+	}, &resEmbedding)
 	if err != nil {
 		return nil, err
 	}
 
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error from response, code %d, body %s", res.StatusCode, resBytes)
-	}
-
-	var resEmbedding EmbeddingResponse
-	err = json.Unmarshal(resBytes, &resEmbedding)
-	if err != nil {
-		return nil, err
+	if len(resEmbedding.Embedding.Values) == 0 {
+		return nil, llmclient.ErrEmptyResponse
 	}
 
 	return &resEmbedding, nil
@@ -0,0 +1,93 @@
+// Package fractional generates sort keys that always leave room for another
+// key in between, so an item is reordered by changing only its own key.
+//
+// Keys are strings of base 62 digits read as a fraction after the point, and
+// compare byte by byte. A key never ends with the zero digit, otherwise
+// nothing could go right before it.
+package fractional
+
+import (
+	"errors"
+	"strings"
+)
+
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var ErrInvalidKey = errors.New("invalid fractional key")
+
+// KeyBetween returns a key sorting after a and before b. An empty a means
+// the start, an empty b the end.
+func KeyBetween(a string, b string) (string, error) {
+	if !valid(a) || !valid(b) {
+		return "", ErrInvalidKey
+	}
+	if a != "" && b != "" && a >= b {
+		return "", ErrInvalidKey
+	}
+
+	return midpoint(a, b), nil
+}
+
+func midpoint(a string, b string) string {
+	if b != "" {
+		// Skip the common prefix, a is padded with zero digits.
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + midpoint(rest, b[n:])
+		}
+	}
+
+	low := 0
+	if a != "" {
+		low = strings.IndexByte(digits, a[0])
+	}
+	high := len(digits)
+	if b != "" {
+		high = strings.IndexByte(digits, b[0])
+	}
+
+	if high-low > 1 {
+		return string(digits[(low+high+1)/2])
+	}
+
+	// The first digits are consecutive. A longer b still sorts after its
+	// first digit alone, otherwise go one digit deeper after a.
+	if len(b) > 1 {
+		return b[:1]
+	}
+
+	rest := ""
+	if a != "" {
+		rest = a[1:]
+	}
+	return string(digits[low]) + midpoint(rest, "")
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+
+	return digits[0]
+}
+
+func valid(key string) bool {
+	if strings.HasSuffix(key, digits[:1]) {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(digits, key[i]) < 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,158 @@
+package fractional
+
+import (
+	"errors"
+	"sort"
+	"testing"
+)
+
+func TestKeyBetween(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{name: "empty list", a: "", b: "", want: "V"},
+		{name: "before first", a: "", b: "V", want: "G"},
+		{name: "after last", a: "V", b: "", want: "l"},
+		{name: "between distant digits", a: "1", b: "9", want: "5"},
+		{name: "between adjacent digits", a: "1", b: "2", want: "1V"},
+		{name: "after the last digit", a: "z", b: "", want: "zV"},
+		{name: "before the first digit", a: "", b: "1", want: "0V"},
+		{name: "b extends a", a: "1", b: "1V", want: "1G"},
+		{name: "longer b after adjacent digit", a: "1", b: "2V", want: "2"},
+		{name: "common prefix", a: "AB", b: "AD", want: "AC"},
+		{name: "before a leading zero", a: "", b: "01", want: "00V"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := KeyBetween(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("KeyBetween(%q, %q) returned error: %v", tt.a, tt.b, err)
+			}
+			if got != tt.want {
+				t.Errorf("KeyBetween(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+			}
+			checkBetween(t, tt.a, tt.b, got)
+		})
+	}
+}
+
+func TestKeyBetweenInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+	}{
+		{name: "equal keys", a: "V", b: "V"},
+		{name: "reversed keys", a: "b", b: "a"},
+		{name: "trailing zero in a", a: "10", b: ""},
+		{name: "trailing zero in b", a: "", b: "V0"},
+		{name: "zero key", a: "0", b: ""},
+		{name: "digit outside the alphabet", a: "a-b", b: ""},
+		{name: "non-ascii digit", a: "", b: "é"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := KeyBetween(tt.a, tt.b)
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("KeyBetween(%q, %q) error = %v, want %v", tt.a, tt.b, err, ErrInvalidKey)
+			}
+		})
+	}
+}
+
+// The database compares positions with COLLATE "C", byte by byte like Go
+// strings, so keys generated in sequence must sort in the order they were
+// placed.
+func TestKeyBetweenSequences(t *testing.T) {
+	tests := []struct {
+		name string
+		next func(keys []string) (string, string, int)
+	}{
+		{
+			name: "append",
+			next: func(keys []string) (string, string, int) {
+				if len(keys) == 0 {
+					return "", "", 0
+				}
+				return keys[len(keys)-1], "", len(keys)
+			},
+		},
+		{
+			name: "prepend",
+			next: func(keys []string) (string, string, int) {
+				if len(keys) == 0 {
+					return "", "", 0
+				}
+				return "", keys[0], 0
+			},
+		},
+		{
+			name: "always after the first",
+			next: func(keys []string) (string, string, int) {
+				switch len(keys) {
+				case 0:
+					return "", "", 0
+				case 1:
+					return keys[0], "", 1
+				}
+				return keys[0], keys[1], 1
+			},
+		},
+		{
+			name: "always before the last",
+			next: func(keys []string) (string, string, int) {
+				switch len(keys) {
+				case 0:
+					return "", "", 0
+				case 1:
+					return "", keys[0], 0
+				}
+				return keys[len(keys)-2], keys[len(keys)-1], len(keys) - 1
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keys []string
+			for range 200 {
+				a, b, at := tt.next(keys)
+				key, err := KeyBetween(a, b)
+				if err != nil {
+					t.Fatalf("KeyBetween(%q, %q) returned error: %v", a, b, err)
+				}
+				checkBetween(t, a, b, key)
+
+				keys = append(keys[:at], append([]string{key}, keys[at:]...)...)
+			}
+
+			if !sort.StringsAreSorted(keys) {
+				t.Fatalf("keys are not sorted: %q", keys)
+			}
+			for i := 1; i < len(keys); i++ {
+				if keys[i-1] == keys[i] {
+					t.Fatalf("key %q was generated twice", keys[i])
+				}
+			}
+		})
+	}
+}
+
+func checkBetween(t *testing.T, a string, b string, key string) {
+	t.Helper()
+
+	if !valid(key) {
+		t.Errorf("key %q is not valid", key)
+	}
+	if a != "" && key <= a {
+		t.Errorf("key %q does not sort after %q", key, a)
+	}
+	if b != "" && key >= b {
+		t.Errorf("key %q does not sort before %q", key, b)
+	}
+}
@@ -2,6 +2,7 @@ package garagestorages3
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,8 +14,17 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 )
 
+// ErrObjectNotFound is returned by HeadObject when the key does not exist.
+var ErrObjectNotFound = errors.New("object not found")
+
+// ErrUploadNotFound is returned for a multipart upload that was completed or
+// aborted already.
+var ErrUploadNotFound = errors.New("multipart upload not found")
+
 type GarageS3 struct {
 	Client *s3.Client
 }
@@ -86,6 +96,131 @@ func (g *GarageS3) GetPresignedURL(ctx context.Context, bucket, key string, expi
 	return req.URL, nil
 }
 
+// GetPresignedPutURL membuat link upload sementara. The client must send
+// contentType as its Content-Type header, it is part of the signature.
+func (g *GarageS3) GetPresignedPutURL(ctx context.Context, bucket, key, contentType string, expiry time.Duration) (string, error) {
+	presignClient := s3.NewPresignClient(g.Client)
+
+	req, err := presignClient.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	}, s3.WithPresignExpires(expiry))
+
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+// ObjectInfo is what HeadObject tells about a stored object.
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+}
+
+// HeadObject returns the size and content type of the object without
+// downloading it, ErrObjectNotFound when it does not exist.
+func (g *GarageS3) HeadObject(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	res, err := g.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+
+	return &ObjectInfo{
+		Size:        aws.ToInt64(res.ContentLength),
+		ContentType: aws.ToString(res.ContentType),
+	}, nil
+}
+
+// UploadedPart is a part of a multipart upload, as needed to complete it.
+type UploadedPart struct {
+	PartNumber int32
+	ETag       string
+}
+
+// CreateMultipartUpload starts a multipart upload of key and returns its
+// upload id.
+func (g *GarageS3) CreateMultipartUpload(ctx context.Context, bucket, key, contentType string) (string, error) {
+	res, err := g.Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.ToString(res.UploadId), nil
+}
+
+// UploadPart uploads one part and returns its ETag. Uploading a part number
+// again replaces the part.
+func (g *GarageS3) UploadPart(ctx context.Context, bucket, key, uploadId string, partNumber int32, content io.ReadSeeker, size int64) (string, error) {
+	res, err := g.Client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(key),
+		UploadId:      aws.String(uploadId),
+		PartNumber:    aws.Int32(partNumber),
+		Body:          content,
+		ContentLength: aws.Int64(size),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.ToString(res.ETag), nil
+}
+
+// CompleteMultipartUpload assembles the parts, in the order of their
+// numbers, into the object.
+func (g *GarageS3) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadId string, parts []UploadedPart) error {
+	completedParts := make([]types.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completedParts = append(completedParts, types.CompletedPart{
+			PartNumber: aws.Int32(part.PartNumber),
+			ETag:       aws.String(part.ETag),
+		})
+	}
+
+	_, err := g.Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadId),
+		MultipartUpload: &types.CompletedMultipartUpload{
+			Parts: completedParts,
+		},
+	})
+	return uploadError(err)
+}
+
+// AbortMultipartUpload discards the uploaded parts.
+func (g *GarageS3) AbortMultipartUpload(ctx context.Context, bucket, key, uploadId string) error {
+	_, err := g.Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadId),
+	})
+	return uploadError(err)
+}
+
+// uploadError maps NoSuchUpload to ErrUploadNotFound. Not every operation
+// models the error, so it is matched by its code.
+func uploadError(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchUpload" {
+		return ErrUploadNotFound
+	}
+	return err
+}
+
 // FileExists mengecek apakah file ada di storage
 func (g *GarageS3) FileExists(ctx context.Context, bucket, key string) (bool, error) {
 	_, err := g.Client.HeadObject(ctx, &s3.HeadObjectInput{
@@ -99,6 +234,23 @@ func (g *GarageS3) FileExists(ctx context.Context, bucket, key string) (bool, er
 	return true, nil
 }
 
+// Copy menyalin object srcKey ke dstKey di bucket yang sama, without
+// passing the content through the server. ErrObjectNotFound when srcKey does
+// not exist.
+func (g *GarageS3) Copy(ctx context.Context, bucket, srcKey, dstKey string) error {
+	_, err := g.Client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(bucket),
+		CopySource: aws.String(bucket + "/" + srcKey),
+		Key:        aws.String(dstKey),
+	})
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchKey" {
+		return ErrObjectNotFound
+	}
+	return err
+}
+
 // Delete menghapus file dari storage
 func (g *GarageS3) Delete(ctx context.Context, bucket, key string) error {
 	_, err := g.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
@@ -123,6 +275,22 @@ func (g *GarageS3) Download(ctx context.Context, bucket, key string) (io.ReadClo
 	return result.Body, nil
 }
 
+// DownloadRange mengambil length byte dari object mulai offset, less when
+// the object ends before.
+func (g *GarageS3) DownloadRange(ctx context.Context, bucket, key string, offset, length int64) ([]byte, error) {
+	result, err := g.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download range from s3: %w", err)
+	}
+	defer result.Body.Close()
+
+	return io.ReadAll(result.Body)
+}
+
 // ---------------------------------------------------------
 // HELPER FUNCTIONS (Independent)
 // ---------------------------------------------------------
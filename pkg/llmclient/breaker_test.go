@@ -0,0 +1,118 @@
+package llmclient
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const (
+	stepAllow = iota
+	stepSucceed
+	stepFail
+	stepRelease
+	// stepWait lets the open duration elapse.
+	stepWait
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		steps     []int
+		// wantErrs is the result of each stepAllow, in order.
+		wantErrs  []error
+		wantState int
+	}{
+		{
+			name:      "stays closed below the threshold",
+			threshold: 3,
+			steps:     []int{stepFail, stepFail, stepAllow},
+			wantErrs:  []error{nil},
+			wantState: breakerClosed,
+		},
+		{
+			name:      "a success resets the failures",
+			threshold: 2,
+			steps:     []int{stepFail, stepSucceed, stepFail, stepAllow},
+			wantErrs:  []error{nil},
+			wantState: breakerClosed,
+		},
+		{
+			name:      "opens at the threshold",
+			threshold: 2,
+			steps:     []int{stepFail, stepFail, stepAllow},
+			wantErrs:  []error{ErrCircuitOpen},
+			wantState: breakerOpen,
+		},
+		{
+			name:      "lets a single probe through once open duration elapsed",
+			threshold: 1,
+			steps:     []int{stepFail, stepWait, stepAllow, stepAllow},
+			wantErrs:  []error{nil, ErrCircuitOpen},
+			wantState: breakerHalfOpen,
+		},
+		{
+			name:      "a successful probe closes",
+			threshold: 1,
+			steps:     []int{stepFail, stepWait, stepAllow, stepSucceed, stepAllow, stepAllow},
+			wantErrs:  []error{nil, nil, nil},
+			wantState: breakerClosed,
+		},
+		{
+			name:      "a failed probe reopens",
+			threshold: 3,
+			steps:     []int{stepFail, stepFail, stepFail, stepWait, stepAllow, stepFail, stepAllow},
+			wantErrs:  []error{nil, ErrCircuitOpen},
+			wantState: breakerOpen,
+		},
+		{
+			name:      "a released probe lets the next one through",
+			threshold: 1,
+			steps:     []int{stepFail, stepWait, stepAllow, stepRelease, stepAllow, stepAllow},
+			wantErrs:  []error{nil, nil, ErrCircuitOpen},
+			wantState: breakerHalfOpen,
+		},
+		{
+			name:      "disabled without a threshold",
+			threshold: 0,
+			steps:     []int{stepFail, stepFail, stepFail, stepAllow},
+			wantErrs:  []error{nil},
+			wantState: breakerClosed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newCircuitBreaker(tt.threshold, time.Minute)
+
+			var errs []error
+			for _, step := range tt.steps {
+				switch step {
+				case stepAllow:
+					errs = append(errs, b.allow())
+				case stepSucceed:
+					b.record(false)
+				case stepFail:
+					b.record(true)
+				case stepRelease:
+					b.release()
+				case stepWait:
+					b.openedAt = b.openedAt.Add(-b.openDuration)
+				}
+			}
+
+			if len(errs) != len(tt.wantErrs) {
+				t.Fatalf("allow returned %v, want %v", errs, tt.wantErrs)
+			}
+			for i := range errs {
+				if !errors.Is(errs[i], tt.wantErrs[i]) {
+					t.Errorf("allow call %d returned %v, want %v", i, errs[i], tt.wantErrs[i])
+				}
+			}
+			if b.state != tt.wantState {
+				t.Errorf("state = %d, want %d", b.state, tt.wantState)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package llmclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// retryDelayPattern reads the RetryInfo detail Gemini puts in 429 bodies,
+// for example "retryDelay": "27s".
+var retryDelayPattern = regexp.MustCompile(`"retryDelay":\s*"(\d+(?:\.\d+)?)s"`)
+
+type Config struct {
+	// Timeout bounds a single attempt, a request may override it.
+	Timeout    time.Duration
+	MaxRetries int
+	// BaseBackoff doubles on every retry up to MaxBackoff. A Retry-After
+	// longer than MaxRetryAfter is not waited for, the error is returned.
+	BaseBackoff   time.Duration
+	MaxBackoff    time.Duration
+	MaxRetryAfter time.Duration
+	// RequestsPerSecond and Burst configure the client side token bucket.
+	RequestsPerSecond float64
+	Burst             int
+	// FailureThreshold consecutive timeouts, network errors or 5xx open the
+	// circuit for OpenDuration.
+	FailureThreshold int
+	OpenDuration     time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Timeout:           60 * time.Second,
+		MaxRetries:        3,
+		BaseBackoff:       500 * time.Millisecond,
+		MaxBackoff:        8 * time.Second,
+		MaxRetryAfter:     30 * time.Second,
+		RequestsPerSecond: 10,
+		Burst:             20,
+		FailureThreshold:  5,
+		OpenDuration:      30 * time.Second,
+	}
+}
+
+type Client struct {
+	httpClient *http.Client
+	config     Config
+	limiter    *tokenBucket
+	breaker    *circuitBreaker
+}
+
+func New(config Config) *Client {
+	return &Client{
+		httpClient: &http.Client{},
+		config:     config,
+		limiter:    newTokenBucket(config.RequestsPerSecond, config.Burst),
+		breaker:    newCircuitBreaker(config.FailureThreshold, config.OpenDuration),
+	}
+}
+
+var (
+	defaultClient     *Client
+	defaultClientOnce sync.Once
+)
+
+// Default is the client shared by every Gemini call so they all draw from the
+// same rate limit and circuit breaker.
+func Default() *Client {
+	defaultClientOnce.Do(func() {
+		if defaultClient == nil {
+			defaultClient = New(DefaultConfig())
+		}
+	})
+
+	return defaultClient
+}
+
+// SetDefault replaces the shared client, call it before serving requests.
+func SetDefault(client *Client) {
+	defaultClient = client
+}
+
+type Request struct {
+	URL     string
+	APIKey  string
+	Payload any
+	// Timeout overrides Config.Timeout for each attempt when set.
+	Timeout time.Duration
+}
+
+// PostJSON sends the payload and decodes a 200 response into out, retrying
+// rate limits, timeouts, network errors and 5xx responses.
+func (c *Client) PostJSON(ctx context.Context, req *Request, out any) error {
+	payloadJson, err := json.Marshal(req.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = c.breaker.allow()
+		if err != nil {
+			return err
+		}
+
+		err = c.limiter.wait(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = c.post(ctx, req, payloadJson, out)
+		if err == nil {
+			c.breaker.record(false)
+			return nil
+		}
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			c.breaker.release()
+			return err
+		}
+
+		// A rate limit or a 4xx means the provider is up, only outages count.
+		c.breaker.record(apiErr.retryable && !errors.Is(err, ErrRateLimited))
+
+		if !apiErr.retryable || attempt >= c.config.MaxRetries {
+			return err
+		}
+
+		delay := c.backoff(attempt)
+		if apiErr.RetryAfter > 0 {
+			if apiErr.RetryAfter > c.config.MaxRetryAfter {
+				return err
+			}
+			delay = apiErr.RetryAfter
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) post(ctx context.Context, req *Request, payloadJson []byte, out any) error {
+	timeout := c.config.Timeout
+	if req.Timeout > 0 {
+		timeout = req.Timeout
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(attemptCtx, http.MethodPost, req.URL, bytes.NewReader(payloadJson))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("x-goog-api-key", req.APIKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	res, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		// The caller gave up, this says nothing about the provider.
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+			return &APIError{Err: ErrTimeout, Body: err.Error(), retryable: true}
+		}
+		return &APIError{Err: ErrUpstream, Body: err.Error(), retryable: true}
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+			return &APIError{Err: ErrTimeout, Body: err.Error(), retryable: true}
+		}
+		return &APIError{Err: ErrUpstream, Body: err.Error(), retryable: true}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return classifyStatus(res, resBody)
+	}
+
+	err = json.Unmarshal(resBody, out)
+	if err != nil {
+		return &APIError{Err: ErrUpstream, StatusCode: res.StatusCode, Body: fmt.Sprintf("invalid response body: %v", err)}
+	}
+
+	return nil
+}
+
+func classifyStatus(res *http.Response, resBody []byte) error {
+	apiErr := &APIError{
+		Err:        ErrUpstream,
+		StatusCode: res.StatusCode,
+		Body:       string(resBody),
+		RetryAfter: parseRetryAfter(res.Header.Get("Retry-After"), resBody),
+	}
+
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests:
+		// Gemini answers 429 both for per-minute limits and for exhausted
+		// daily or billing quotas, only the former is worth retrying.
+		body := string(resBody)
+		if strings.Contains(body, "PerDay") || strings.Contains(body, "billing") {
+			apiErr.Err = ErrQuotaExhausted
+		} else {
+			apiErr.Err = ErrRateLimited
+			apiErr.retryable = true
+		}
+	case res.StatusCode >= http.StatusInternalServerError:
+		apiErr.retryable = true
+	}
+
+	return apiErr
+}
+
+// parseRetryAfter accepts both forms of the Retry-After header, falling back
+// to the retryDelay of the response body.
+func parseRetryAfter(header string, body []byte) time.Duration {
+	if header != "" {
+		if seconds, err := strconv.Atoi(header); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if at, err := http.ParseTime(header); err == nil {
+			return time.Until(at)
+		}
+	}
+
+	match := retryDelayPattern.FindSubmatch(body)
+	if match != nil {
+		seconds, err := strconv.ParseFloat(string(match[1]), 64)
+		if err == nil {
+			return time.Duration(seconds * float64(time.Second))
+		}
+	}
+
+	return 0
+}
+
+// backoff is exponential with full jitter.
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.config.BaseBackoff << attempt
+	if delay <= 0 || delay > c.config.MaxBackoff {
+		delay = c.config.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
@@ -0,0 +1,223 @@
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		body   string
+		want   time.Duration
+	}{
+		{name: "nothing", want: 0},
+		{name: "seconds header", header: "12", want: 12 * time.Second},
+		{name: "header wins over body", header: "3", body: `{"retryDelay": "27s"}`, want: 3 * time.Second},
+		{name: "body retry delay", body: `{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "27s"}`, want: 27 * time.Second},
+		{name: "fractional body retry delay", body: `{"retryDelay":"1.5s"}`, want: 1500 * time.Millisecond},
+		{name: "invalid header falls back to body", header: "soon", body: `{"retryDelay": "2s"}`, want: 2 * time.Second},
+		{name: "invalid header", header: "soon", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseRetryAfter(tt.header, []byte(tt.body))
+			if got != tt.want {
+				t.Errorf("parseRetryAfter(%q, %q) = %v, want %v", tt.header, tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfterDate(t *testing.T) {
+	header := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+
+	got := parseRetryAfter(header, nil)
+	if got <= 59*time.Minute || got > time.Hour {
+		t.Errorf("parseRetryAfter(%q) = %v, want about an hour", header, got)
+	}
+}
+
+func TestClassifyStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		wantErr       error
+		wantRetryable bool
+	}{
+		{name: "rate limit", status: http.StatusTooManyRequests, body: `{"quotaId": "GenerateRequestsPerMinute"}`, wantErr: ErrRateLimited, wantRetryable: true},
+		{name: "daily quota", status: http.StatusTooManyRequests, body: `{"quotaId": "GenerateRequestsPerDay"}`, wantErr: ErrQuotaExhausted},
+		{name: "billing quota", status: http.StatusTooManyRequests, body: `check your plan and billing details`, wantErr: ErrQuotaExhausted},
+		{name: "server error", status: http.StatusServiceUnavailable, wantErr: ErrUpstream, wantRetryable: true},
+		{name: "bad request", status: http.StatusBadRequest, wantErr: ErrUpstream},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+
+			err := classifyStatus(res, []byte(tt.body))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("classifyStatus error = %v, want %v", err, tt.wantErr)
+			}
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("classifyStatus returned %T, want *APIError", err)
+			}
+			if apiErr.retryable != tt.wantRetryable {
+				t.Errorf("retryable = %v, want %v", apiErr.retryable, tt.wantRetryable)
+			}
+		})
+	}
+}
+
+func TestPostJSON(t *testing.T) {
+	type response struct {
+		status     int
+		retryAfter string
+		body       string
+	}
+
+	ok := response{status: http.StatusOK, body: `{"text": "ok"}`}
+	unavailable := response{status: http.StatusServiceUnavailable, body: `{}`}
+
+	tests := []struct {
+		name      string
+		config    Config
+		responses []response
+		calls     int
+		// wantCalls is how many requests reached the server.
+		wantCalls int
+		wantErr   error
+	}{
+		{
+			name:      "success",
+			responses: []response{ok},
+			calls:     1,
+			wantCalls: 1,
+		},
+		{
+			name:      "retries a rate limit after its retry delay",
+			config:    Config{MaxRetries: 3},
+			responses: []response{{status: http.StatusTooManyRequests, body: `{"retryDelay": "0.01s"}`}, ok},
+			calls:     1,
+			wantCalls: 2,
+		},
+		{
+			name:      "does not wait for a long Retry-After",
+			config:    Config{MaxRetries: 3},
+			responses: []response{{status: http.StatusTooManyRequests, retryAfter: "120", body: `{}`}, ok},
+			calls:     1,
+			wantCalls: 1,
+			wantErr:   ErrRateLimited,
+		},
+		{
+			name:      "does not retry an exhausted quota",
+			config:    Config{MaxRetries: 3},
+			responses: []response{{status: http.StatusTooManyRequests, body: `{"quotaId": "GenerateRequestsPerDay"}`}, ok},
+			calls:     1,
+			wantCalls: 1,
+			wantErr:   ErrQuotaExhausted,
+		},
+		{
+			name:      "retries server errors",
+			config:    Config{MaxRetries: 3},
+			responses: []response{unavailable, unavailable, ok},
+			calls:     1,
+			wantCalls: 3,
+		},
+		{
+			name:      "gives up after the retries",
+			config:    Config{MaxRetries: 1},
+			responses: []response{unavailable, unavailable, ok},
+			calls:     1,
+			wantCalls: 2,
+			wantErr:   ErrUpstream,
+		},
+		{
+			name:      "an open circuit rejects calls without sending them",
+			config:    Config{FailureThreshold: 2, OpenDuration: time.Minute},
+			responses: []response{unavailable, unavailable, ok},
+			calls:     3,
+			wantCalls: 2,
+			wantErr:   ErrCircuitOpen,
+		},
+		{
+			name:      "rate limits do not open the circuit",
+			config:    Config{FailureThreshold: 1, OpenDuration: time.Minute},
+			responses: []response{{status: http.StatusTooManyRequests, body: `{}`}, ok},
+			calls:     2,
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var served atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := int(served.Add(1)) - 1
+				if i >= len(tt.responses) {
+					i = len(tt.responses) - 1
+				}
+
+				res := tt.responses[i]
+				if res.retryAfter != "" {
+					w.Header().Set("Retry-After", res.retryAfter)
+				}
+				w.WriteHeader(res.status)
+				w.Write([]byte(res.body))
+			}))
+			defer server.Close()
+
+			config := tt.config
+			config.Timeout = time.Second
+			config.BaseBackoff = time.Millisecond
+			config.MaxBackoff = time.Millisecond
+			config.MaxRetryAfter = time.Second
+			client := New(config)
+
+			var err error
+			for range tt.calls {
+				var out struct {
+					Text string `json:"text"`
+				}
+				err = client.PostJSON(context.Background(), &Request{URL: server.URL, Payload: map[string]string{}}, &out)
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("PostJSON error = %v, want %v", err, tt.wantErr)
+			}
+			if got := int(served.Load()); got != tt.wantCalls {
+				t.Errorf("server got %d requests, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(1, 2)
+
+	for i := range 2 {
+		err := b.wait(context.Background())
+		if err != nil {
+			t.Fatalf("wait %d within the burst returned error: %v", i, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := b.wait(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait past the burst returned %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,40 @@
+package llmclient
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrRateLimited    = errors.New("the AI provider is rate limiting requests, please try again later")
+	ErrQuotaExhausted = errors.New("the AI provider quota is exhausted")
+	ErrSafetyBlocked  = errors.New("the AI provider blocked the content for safety reasons")
+	ErrEmptyResponse  = errors.New("the AI provider returned an empty response")
+	ErrCircuitOpen    = errors.New("the AI provider is temporarily unavailable, please try again later")
+	ErrTimeout        = errors.New("the AI provider did not respond in time")
+	ErrUpstream       = errors.New("the AI provider returned an error")
+)
+
+// APIError describes a failed call. It unwraps to one of the sentinel errors
+// above so callers and the error middleware can use errors.Is.
+type APIError struct {
+	Err        error
+	StatusCode int
+	Body       string
+	// RetryAfter is how long the provider asked us to wait, zero when unknown.
+	RetryAfter time.Duration
+	retryable  bool
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%s: %s", e.Err, e.Body)
+	}
+
+	return fmt.Sprintf("%s: status %d, body %s", e.Err, e.StatusCode, e.Body)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}